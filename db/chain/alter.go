@@ -0,0 +1,212 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/ShiftLeftSecurity/gaum/v2/selectparse"
+	"github.com/pkg/errors"
+)
+
+// AlterTableStatement builds an ALTER TABLE statement with one or more actions, which postgres
+// runs together.
+type AlterTableStatement struct {
+	table    string
+	ifExists bool
+	actions  []string
+	err      error
+}
+
+// AlterTable returns an AlterTableStatement for table, actions are added with its methods.
+func AlterTable(table string) *AlterTableStatement {
+	return &AlterTableStatement{table: table}
+}
+
+// IfExists makes the statement do nothing if the table does not exist.
+func (a *AlterTableStatement) IfExists() *AlterTableStatement {
+	a.ifExists = true
+	return a
+}
+
+// AddColumn adds a column of the passed SQL type, constraints are written after the type as
+// they are, ie AddColumn("score", "int", "NOT NULL", "DEFAULT 0").
+func (a *AlterTableStatement) AddColumn(name, sqlType string, constraints ...string) *AlterTableStatement {
+	return a.action("ADD COLUMN " + strings.Join(append([]string{name, sqlType}, constraints...), " "))
+}
+
+// DropColumn drops the column.
+func (a *AlterTableStatement) DropColumn(name string) *AlterTableStatement {
+	return a.action("DROP COLUMN " + name)
+}
+
+// RenameColumn renames the column from to to, postgres does not allow it along with other
+// actions.
+func (a *AlterTableStatement) RenameColumn(from, to string) *AlterTableStatement {
+	return a.action("RENAME COLUMN " + from + " TO " + to)
+}
+
+// SetType changes the type of the column, using, if not empty, is the expression that converts
+// the current values, ie SetType("price", "numeric(10,2)", "price::numeric / 100").
+func (a *AlterTableStatement) SetType(column, sqlType, using string) *AlterTableStatement {
+	action := "ALTER COLUMN " + column + " TYPE " + sqlType
+	if using != "" {
+		action += " USING " + using
+	}
+	return a.action(action)
+}
+
+// SetDefault sets the default of the column to expr, its ? marks are replaced by args, as SQL
+// literals since DDL can't take arguments, ie SetDefault("status", "?", "active") or
+// SetDefault("created", "now()").
+func (a *AlterTableStatement) SetDefault(column, expr string, args ...interface{}) *AlterTableStatement {
+	expr, err := inlineArgs(expr, args)
+	if err != nil {
+		a.fail(errors.Wrapf(err, "default of %s", column))
+		return a
+	}
+	return a.action("ALTER COLUMN " + column + " SET DEFAULT " + expr)
+}
+
+// DropDefault removes the default of the column.
+func (a *AlterTableStatement) DropDefault(column string) *AlterTableStatement {
+	return a.action("ALTER COLUMN " + column + " DROP DEFAULT")
+}
+
+// SetNotNull makes the column NOT NULL.
+func (a *AlterTableStatement) SetNotNull(column string) *AlterTableStatement {
+	return a.action("ALTER COLUMN " + column + " SET NOT NULL")
+}
+
+// DropNotNull makes the column nullable.
+func (a *AlterTableStatement) DropNotNull(column string) *AlterTableStatement {
+	return a.action("ALTER COLUMN " + column + " DROP NOT NULL")
+}
+
+// AddConstraint adds a constraint called name, ie AddConstraint("positive_price",
+// "CHECK (price > 0)").
+func (a *AlterTableStatement) AddConstraint(name, definition string) *AlterTableStatement {
+	return a.action("ADD CONSTRAINT " + name + " " + definition)
+}
+
+// AddConstraintNotValid adds a CHECK or FOREIGN KEY constraint that is enforced for new rows
+// but not checked against the existing ones, which does not hold a long lock on big tables,
+// they are checked later, and with a weaker lock, with ValidateConstraint.
+func (a *AlterTableStatement) AddConstraintNotValid(name, definition string) *AlterTableStatement {
+	return a.action("ADD CONSTRAINT " + name + " " + definition + " NOT VALID")
+}
+
+// AddTableConstraint adds a constraint called name built with ForeignKey, Check or Unique, ie
+// AddTableConstraint("orders_user_fk", ForeignKey("user_id").References("users", "id").NotValid()).
+func (a *AlterTableStatement) AddTableConstraint(name string, constraint TableConstraint) *AlterTableStatement {
+	definition, err := constraint.Definition()
+	if err != nil {
+		a.fail(errors.Wrapf(err, "constraint %s", name))
+		return a
+	}
+	return a.AddConstraint(name, definition)
+}
+
+// ValidateConstraint checks the existing rows against a constraint added as NOT VALID.
+func (a *AlterTableStatement) ValidateConstraint(name string) *AlterTableStatement {
+	return a.action("VALIDATE CONSTRAINT " + name)
+}
+
+// DropConstraint drops the constraint.
+func (a *AlterTableStatement) DropConstraint(name string) *AlterTableStatement {
+	return a.action("DROP CONSTRAINT " + name)
+}
+
+// Action adds an action, as it is, for the cases not covered by the other methods, its ? marks
+// are replaced by args as in SetDefault.
+func (a *AlterTableStatement) Action(action string, args ...interface{}) *AlterTableStatement {
+	action, err := inlineArgs(action, args)
+	if err != nil {
+		a.fail(err)
+		return a
+	}
+	return a.action(action)
+}
+
+func (a *AlterTableStatement) action(action string) *AlterTableStatement {
+	a.actions = append(a.actions, action)
+	return a
+}
+
+func (a *AlterTableStatement) fail(err error) {
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+// Render returns the statement or the first error found building it.
+func (a *AlterTableStatement) Render() (string, error) {
+	if a.err != nil {
+		return "", a.err
+	}
+	if len(a.actions) == 0 {
+		return "", errors.Errorf("altering %s needs at least one action", a.table)
+	}
+	ifExists := ""
+	if a.ifExists {
+		ifExists = "IF EXISTS "
+	}
+	return "ALTER TABLE " + ifExists + a.table + " " + strings.Join(a.actions, ", "), nil
+}
+
+// String returns the rendered statement or the error if it can't be rendered.
+func (a *AlterTableStatement) String() string {
+	statement, err := a.Render()
+	if err != nil {
+		return "invalid alter table, err: " + err.Error()
+	}
+	return statement
+}
+
+// Exec runs the statement in db.
+func (a *AlterTableStatement) Exec(ctx context.Context, db connection.DB) error {
+	statement, err := a.Render()
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(db.Exec(ctx, statement), "altering table %s", a.table)
+}
+
+// inlineArgs replaces the ? marks of expr with the SQL literals of args, for the statements,
+// such as DDL, that can't take arguments.
+func inlineArgs(expr string, args []interface{}) (string, error) {
+	dst := &strings.Builder{}
+	used := 0
+	for _, token := range selectparse.Tokenize(expr) {
+		switch token.Kind {
+		case selectparse.Placeholder:
+			if used >= len(args) {
+				return "", errors.Errorf("%q has more placeholders than the %d args passed", expr, len(args))
+			}
+			dst.WriteString(literal(args[used], 0))
+			used++
+		case selectparse.EscapedPlaceholder:
+			dst.WriteRune('?')
+		default:
+			dst.WriteString(token.Text)
+		}
+	}
+	if used != len(args) {
+		return "", errors.Errorf("%q has %d placeholders but %d args were passed", expr, used, len(args))
+	}
+	return dst.String(), nil
+}
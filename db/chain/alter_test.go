@@ -0,0 +1,67 @@
+package chain
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAlterTable(t *testing.T) {
+	tests := []struct {
+		alter *AlterTableStatement
+		want  string
+	}{
+		{
+			alter: AlterTable("users").AddColumn("score", "int", "NOT NULL", "DEFAULT 0").DropColumn("legacy"),
+			want:  "ALTER TABLE users ADD COLUMN score int NOT NULL DEFAULT 0, DROP COLUMN legacy",
+		},
+		{
+			alter: AlterTable("users").IfExists().SetDefault("status", "?", "it's active").
+				SetDefault("created", "now()").DropDefault("nick").SetNotNull("email").DropNotNull("name"),
+			want: "ALTER TABLE IF EXISTS users ALTER COLUMN status SET DEFAULT 'it''s active', " +
+				"ALTER COLUMN created SET DEFAULT now(), ALTER COLUMN nick DROP DEFAULT, " +
+				"ALTER COLUMN email SET NOT NULL, ALTER COLUMN name DROP NOT NULL",
+		},
+		{
+			alter: AlterTable("orders").AddConstraintNotValid("orders_user_fk",
+				"FOREIGN KEY (user_id) REFERENCES users (id)"),
+			want: "ALTER TABLE orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users (id) NOT VALID",
+		},
+		{
+			alter: AlterTable("orders").ValidateConstraint("orders_user_fk").DropConstraint("orders_old_fk"),
+			want:  "ALTER TABLE orders VALIDATE CONSTRAINT orders_user_fk, DROP CONSTRAINT orders_old_fk",
+		},
+		{
+			alter: AlterTable("orders").SetType("total", "numeric(10,2)", "total::numeric / 100").
+				Action("ADD CONSTRAINT positive_total CHECK (total > ?)", 0),
+			want: "ALTER TABLE orders ALTER COLUMN total TYPE numeric(10,2) USING total::numeric / 100, " +
+				"ADD CONSTRAINT positive_total CHECK (total > 0)",
+		},
+		{
+			alter: AlterTable("orders").RenameColumn("total", "amount"),
+			want:  "ALTER TABLE orders RENAME COLUMN total TO amount",
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.alter.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("expected:\n%s\ngot:\n%s", tt.want, got)
+		}
+	}
+
+	if _, err := AlterTable("users").SetDefault("status", "? || ?", "a").Render(); err == nil {
+		t.Errorf("expected an error for missing arguments")
+	}
+	if _, err := AlterTable("users").Render(); err == nil {
+		t.Errorf("expected an error without actions")
+	}
+	db := &txDB{}
+	if err := AlterTable("users").DropColumn("legacy").Exec(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.statements) != 1 || db.statements[0] != "ALTER TABLE users DROP COLUMN legacy" {
+		t.Errorf("unexpected statements %v", db.statements)
+	}
+}
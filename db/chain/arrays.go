@@ -0,0 +1,104 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/selectparse"
+)
+
+// InArrayThreshold makes the `IN (?)` lists of the chain, such as those of In and InSlice, with
+// more items than threshold be rendered as `= ANY(?)`, `<> ALL(?)` for `NOT IN (?)`, binding the
+// items as a single array argument instead of one placeholder per item, which keeps big lists
+// from bloating the statement or going over the 65535 arguments postgres takes.
+// Only drivers that can bind slices as arrays (ie, pgx) support this so it is disabled, 0, by
+// default. To never expand slices see connection.Information.SlicesAsArrays.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) InArrayThreshold(threshold int) *ExpressionChain {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+	ec.inArrayThreshold = threshold
+	return ec
+}
+
+// ArrayArgument is an argument that is passed to the driver as it is, instead of expanded
+// into one placeholder per item, see Array.
+type ArrayArgument struct {
+	Value interface{}
+}
+
+// Array wraps a slice so it is bound as a single postgres array argument, ie
+// `AndWhere("tags && ?", Array([]string{"a", "b"}))`. The driver must know how to bind the
+// slice, pgx does, for lib/pq use pq.Array instead.
+// There is no need for it in a DB configured with connection.Information.SlicesAsArrays.
+func Array(slice interface{}) ArrayArgument {
+	return ArrayArgument{Value: slice}
+}
+
+// InAny renders `field = ANY(?)` with slice bound as a single array argument, the array
+// counterpart of InSlice.
+func InAny(field string, slice interface{}) (string, interface{}) {
+	return fmt.Sprintf("%s = ANY(?)", field), Array(slice)
+}
+
+// inArrayList returns the slice of arg, the argument of an `IN (?)` list, if it has more items
+// than threshold allows. In passes its values as a slice, which holds the slice if it was passed
+// a single one.
+func inArrayList(arg interface{}, threshold int) (interface{}, bool) {
+	if threshold <= 0 || arg == nil {
+		return nil, false
+	}
+	value := reflect.ValueOf(arg)
+	if value.Kind() != reflect.Slice || value.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	if value.Len() == 1 {
+		if item := reflect.ValueOf(value.Index(0).Interface()); item.Kind() == reflect.Slice &&
+			item.Type().Elem().Kind() != reflect.Uint8 {
+			value = item
+		}
+	}
+	return value.Interface(), value.Len() > threshold
+}
+
+// inListAt returns the index of the token that closes the `IN (?)`, or `NOT IN (?)`, list that
+// starts at tokens[i], if there is one, and whether it is negated.
+func inListAt(tokens []selectparse.Token, i int) (int, bool, bool) {
+	negated := tokens[i].Is("NOT")
+	if negated {
+		i = skipSpace(tokens, i+1)
+	}
+	if i >= len(tokens) || !tokens[i].Is("IN") {
+		return 0, false, false
+	}
+	for _, kind := range []selectparse.TokenKind{selectparse.OpenParens, selectparse.Placeholder,
+		selectparse.CloseParens} {
+		i = skipSpace(tokens, i+1)
+		if i >= len(tokens) || tokens[i].Kind != kind {
+			return 0, false, false
+		}
+	}
+	return i, negated, true
+}
+
+// skipSpace returns the index of the first token from i on that is not white space.
+func skipSpace(tokens []selectparse.Token, i int) int {
+	for i < len(tokens) && tokens[i].Kind == selectparse.Space {
+		i++
+	}
+	return i
+}
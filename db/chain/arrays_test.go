@@ -0,0 +1,111 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/go-test/deep"
+)
+
+func TestInArrayThreshold(t *testing.T) {
+	ids := []int{1, 2, 3}
+	for _, tt := range []struct {
+		threshold    int
+		expected     string
+		expectedArgs []interface{}
+	}{
+		{0, "SELECT * FROM justforfun WHERE id IN ($1, $2, $3) AND tag IN ($4, $5) AND (kind NOT IN ($6, $7))",
+			[]interface{}{1, 2, 3, "a", "b", "x", "y"}},
+		{2, "SELECT * FROM justforfun WHERE id = ANY($1) AND tag IN ($2, $3) AND (kind NOT IN ($4, $5))",
+			[]interface{}{ids, "a", "b", "x", "y"}},
+		{1, "SELECT * FROM justforfun WHERE id = ANY($1) AND tag = ANY($2) AND (kind <> ALL($3))",
+			[]interface{}{ids, []interface{}{"a", "b"}, []string{"x", "y"}}},
+	} {
+		group := NewNoDB().AndWhere("kind NOT IN (?)", []string{"x", "y"})
+		q, args, err := NewNoDB().Select("*").Table("justforfun").InArrayThreshold(tt.threshold).
+			AndWhere(InSlice("id", ids)).AndWhere(In("tag", "a", "b")).AndWhereGroup(group).Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != tt.expected {
+			t.Errorf("expected %q with threshold %d got %q", tt.expected, tt.threshold, q)
+		}
+		if diff := deep.Equal(args, tt.expectedArgs); diff != nil {
+			t.Error(diff)
+		}
+	}
+
+	// In passed a single slice binds that slice.
+	q, args, err := NewNoDB().Select("*").Table("justforfun").InArrayThreshold(2).
+		AndWhere(In("id", ids)).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM justforfun WHERE id = ANY($1)"; q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+	if diff := deep.Equal(args, []interface{}{ids}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestArray(t *testing.T) {
+	q, args, err := NewNoDB().Select("*").Table("justforfun").
+		AndWhere("tags && ? AND id IN (?)", Array([]string{"a", "b"}), []int{1, 2}).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM justforfun WHERE tags && $1 AND id IN ($2, $3)"; q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+	if diff := deep.Equal(args, []interface{}{[]string{"a", "b"}, 1, 2}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+// arraysDB binds slices as arrays.
+type arraysDB struct {
+	connection.DB
+}
+
+func (arraysDB) SlicesAsArrays() bool { return true }
+
+func TestSlicesAsArrays(t *testing.T) {
+	group := NewNoDB().AndWhere("tags && ?", []string{"c"}).OrWhere("id = ?", 3)
+	q, args, err := New(arraysDB{}).Select("*").Table("justforfun").
+		AndWhere("id = ANY(?)", []int{1, 2}).AndWhereGroup(group).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM justforfun WHERE id = ANY($1) AND (tags && $2 OR id = $3)"; q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+	if diff := deep.Equal(args, []interface{}{[]int{1, 2}, []string{"c"}, 3}); diff != nil {
+		t.Error(diff)
+	}
+
+	q, args, err = MarksToPlaceholdersFor(arraysDB{}, "id = ANY(?) AND a = ?", []interface{}{[]int{1, 2}, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "id = ANY($1) AND a = NULL"; q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+	if diff := deep.Equal(args, []interface{}{[]int{1, 2}}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSlicesAsArraysInTransaction(t *testing.T) {
+	tx := &connection.FlexibleTransaction{DB: arraysDB{}}
+	q, args, err := New(tx).Select("*").Table("justforfun").AndWhere("id = ANY(?)", []int{1, 2}).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM justforfun WHERE id = ANY($1)"; q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+	if diff := deep.Equal(args, []interface{}{[]int{1, 2}}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,67 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBufferSize is the capacity above which render buffers are not kept for reuse, so a
+// single huge statement doesn't pin its memory.
+const maxPooledBufferSize = 64 * 1024
+
+// bufferPool holds the buffers chains are rendered into, rendering happens for every statement
+// so reusing them saves growing a new one each time.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &bytes.Buffer{}
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// argCapacity returns the amount of arguments the chain holds before expansion, to size the
+// argument list of the render.
+func (ec *ExpressionChain) argCapacity() int {
+	count := 0
+	if ec.mainOperation != nil {
+		count += len(ec.mainOperation.arguments)
+	}
+	for _, segment := range ec.segments {
+		count += len(segment.arguments)
+	}
+	if ec.limit != nil {
+		count += len(ec.limit.arguments)
+	}
+	if ec.offset != nil {
+		count += len(ec.offset.arguments)
+	}
+	for _, cte := range ec.ctes {
+		count += cte.argCapacity()
+	}
+	return count
+}
@@ -0,0 +1,59 @@
+package chain
+
+import (
+	"strings"
+	"testing"
+)
+
+func insertChain() *ExpressionChain {
+	return NewNoDB().Table("justforfun").Insert(map[string]interface{}{
+		"id":          1,
+		"description": "this is a description",
+		"created":     "now",
+	}).OnConflict(func(c *OnConflict) {
+		c.OnConstraint("id_pk").DoNothing()
+	})
+}
+
+func TestRenderReusesBuffers(t *testing.T) {
+	long := NewNoDB().Select("*").Table("justforfun").AndWhere("description = ?", strings.Repeat("a", 2*maxPooledBufferSize))
+	q, _, err := long.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the huge buffer was not pooled and the next render doesn't see its leftovers.
+	expected := "INSERT INTO justforfun (created, description, id) VALUES ($1, $2, $3) ON CONFLICT ON CONSTRAINT id_pk DO NOTHING"
+	for i := 0; i < 3; i++ {
+		if q, _, err = insertChain().Render(); err != nil || q != expected {
+			t.Fatalf("expected %q got %q, %v", expected, q, err)
+		}
+	}
+	// the sorted columns, the argument list, the raw and positional queries and little else, this
+	// used to be 17 before the buffers were pooled.
+	if allocs := testing.AllocsPerRun(100, func() { _, _, _ = insertChain().Render() }) -
+		testing.AllocsPerRun(100, func() { insertChain() }); allocs > 8 {
+		t.Errorf("expected at most 8 allocations rendering got %v", allocs)
+	}
+}
+
+func BenchmarkRenderInsert(b *testing.B) {
+	ec := insertChain()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ec.Render(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRenderSelect(b *testing.B) {
+	ec := NewNoDB().Select("id, description").Table("justforfun").
+		AndWhere("id > ?", 1).AndWhere("description IN (?)", []string{"a", "b", "c"}).
+		OrderBy(Desc("id")).Limit(10)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ec.Render(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -15,11 +15,10 @@ package chain
 //    limitations under the License.
 
 import (
-	"strings"
+	"reflect"
 	"sync"
 
 	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
-	"github.com/pkg/errors"
 )
 
 // NewExpressionChain returns a new instance of ExpressionChain hooked to the passed DB
@@ -45,6 +44,8 @@ type ExpressionChain struct {
 	lock          sync.Mutex
 	segments      []querySegmentAtom
 	table         string
+	schema        string
+	schemaJoins   bool
 	mainOperation *querySegmentAtom
 	ctes          map[string]*ExpressionChain
 	ctesOrder     []string // because deterministic tests and co-dependency
@@ -61,6 +62,23 @@ type ExpressionChain struct {
 
 	formatter    *Formatter
 	minQuerySize uint64
+	debugErrors  bool
+	columnCache  *ColumnCache
+
+	preload []string
+	// record is the struct the values of the main operation come from, see InsertStruct.
+	record          interface{}
+	recordOperation sqlSegment
+	// modelType is the struct type of the model the chain operates on, its tags drive behaviors
+	// such as the automatic timestamps.
+	modelType reflect.Type
+	// unscoped disables the soft delete of the model, see Unscoped.
+	unscoped bool
+	// inArrayThreshold is the size above which IN lists are bound as arrays, see InArrayThreshold.
+	inArrayThreshold int
+	// copyThreshold is the amount of rows above which InsertMulti uses COPY, see
+	// InsertMultiCopyThreshold.
+	copyThreshold int
 }
 
 // SetMinQuerySize will make sure that at least <size> bytes (runes actually) are allocated
@@ -125,6 +143,8 @@ func (ec *ExpressionChain) Clone() *ExpressionChain {
 		segments:      segments,
 		mainOperation: mainOperation,
 		table:         ec.table,
+		schema:        ec.schema,
+		schemaJoins:   ec.schemaJoins,
 		ctes:          ctes,
 		ctesOrder:     order,
 
@@ -132,6 +152,17 @@ func (ec *ExpressionChain) Clone() *ExpressionChain {
 
 		formatter:    &newFormatter,
 		minQuerySize: ec.minQuerySize,
+		debugErrors:  ec.debugErrors,
+		columnCache:  ec.columnCache,
+
+		preload:         append([]string(nil), ec.preload...),
+		record:          ec.record,
+		recordOperation: ec.recordOperation,
+		modelType:       ec.modelType,
+		unscoped:        ec.unscoped,
+
+		inArrayThreshold: ec.inArrayThreshold,
+		copyThreshold:    ec.copyThreshold,
 	}
 }
 
@@ -213,14 +244,12 @@ func (ec *ExpressionChain) hasErr() bool {
 	return len(ec.err) > 0
 }
 
-// getErr returns an error message about the stuff
+// getErr returns a *BuildError with the errors found building the chain, nil if none.
 func (ec *ExpressionChain) getErr() error {
 	if ec.err == nil {
 		return nil
 	}
-	errMsg := make([]string, len(ec.err))
-	for index, anErr := range ec.err {
-		errMsg[index] = anErr.Error()
-	}
-	return errors.New(strings.Join(errMsg, " "))
+	errs := make([]error, len(ec.err))
+	copy(errs, ec.err)
+	return &BuildError{Errors: errs}
 }
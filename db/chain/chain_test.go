@@ -200,6 +200,13 @@ func TestExpressionChain_Render(t *testing.T) {
 			wantArgs: []interface{}{"value1", 2, 222},
 			wantErr:  false,
 		},
+		{
+			name:     "insert with nextval",
+			chain:    NewNoDB().Insert(map[string]interface{}{"id": NextVal("convenient_seq"), "field1": "value1"}).Table("convenient_table"),
+			want:     "INSERT INTO convenient_table (field1, id) VALUES ($1, (SELECT nextval('convenient_seq')))",
+			wantArgs: []interface{}{"value1"},
+			wantErr:  false,
+		},
 		{
 			name: "insert multi with chan value",
 			chain: func() *ExpressionChain {
@@ -369,6 +376,18 @@ func TestExpressionChain_Render(t *testing.T) {
 			wantArgs: []interface{}{"unpirulo", 1, 2, "pajarito"},
 			wantErr:  false,
 		},
+		{
+			name: "basic selection with limit and offset removed",
+			chain: NewNoDB().Select("field1").
+				Table("convenient_table").
+				AndWhere("field1 > ?", 1).
+				Limit(100).
+				Offset(10).
+				Unpaginated(),
+			want:     "SELECT field1 FROM convenient_table WHERE field1 > $1",
+			wantArgs: []interface{}{1},
+			wantErr:  false,
+		},
 		{
 			name: "basic update with where and join",
 			chain: NewNoDB().Update("field1 = ?, field3 = ?", "value2", 9).
@@ -623,6 +642,47 @@ func TestExpressionChain_Render(t *testing.T) {
 			wantArgs: []interface{}{42},
 			wantErr:  false,
 		},
+		{
+			name: "selection with schema",
+			chain: NewNoDB().Select("field1").
+				Table("convenient_table AS ct").
+				Join("other_table AS ot", "ot.id = ct.id").
+				AndWhere("field1 > ?", 1).
+				Schema("tenant_42"),
+			want:     "SELECT field1 FROM tenant_42.convenient_table AS ct JOIN other_table AS ot ON ot.id = ct.id WHERE field1 > $1",
+			wantArgs: []interface{}{1},
+			wantErr:  false,
+		},
+		{
+			name: "selection with schema in joins",
+			chain: NewNoDB().Select("field1").
+				Table("convenient_table AS ct").
+				Join("other_table AS ot", "ot.id = ct.id").
+				LeftJoin("public.shared AS s", "s.id = ct.id").
+				SchemaWithJoins("tenant_42"),
+			want:     "SELECT field1 FROM tenant_42.convenient_table AS ct JOIN tenant_42.other_table AS ot ON ot.id = ct.id LEFT JOIN public.shared AS s ON s.id = ct.id",
+			wantArgs: []interface{}{},
+			wantErr:  false,
+		},
+		{
+			name: "update with schema",
+			chain: NewNoDB().UpdateMap(map[string]interface{}{"field1": 1}).
+				Table("convenient_table").
+				AndWhere("id = ?", 2).
+				Schema("tenant_42"),
+			want:     "UPDATE tenant_42.convenient_table SET field1 = $1 WHERE id = $2",
+			wantArgs: []interface{}{1, 2},
+			wantErr:  false,
+		},
+		{
+			name: "insert with schema",
+			chain: NewNoDB().Insert(map[string]interface{}{"field1": 1}).
+				Table("convenient_table").
+				Schema("tenant_42"),
+			want:     "INSERT INTO tenant_42.convenient_table (field1) VALUES ($1)",
+			wantArgs: []interface{}{1},
+			wantErr:  false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -17,6 +17,7 @@ package chain
 import (
 	"context"
 
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
 	"github.com/pkg/errors"
 )
 
@@ -40,7 +41,7 @@ func (cg *Group) Add(ec *ExpressionChain) {
 
 // Run runs all the chains in a group in a transaction, for this the db of the first query
 // will be used.
-func (cg *Group) Run(ctx context.Context) (execError error) {
+func (cg *Group) Run(ctx context.Context) error {
 	if len(cg.chains) == 0 {
 		return nil
 	}
@@ -49,39 +50,24 @@ func (cg *Group) Run(ctx context.Context) (execError error) {
 			return errors.Errorf("cannot query as part of a chain.")
 		}
 	}
-	db := cg.chains[0].db
-	txdb, err := db.BeginTransaction(ctx)
-	if err != nil {
-		return errors.Wrap(err, "getting transaction to run chain group")
-	}
-	defer func() {
-		if execError != nil {
-			err := db.RollbackTransaction(ctx)
-			execError = errors.Wrapf(execError,
-				"there was a failure running the expression and also rolling back te transaction: %v",
-				err)
-		} else {
-			err := db.CommitTransaction(ctx)
-			execError = errors.Wrap(err, "could not commit the transaction")
-		}
-	}()
-
-	if cg.set != "" {
-		err := txdb.Set(ctx, cg.set)
-		if err != nil {
-			return errors.Wrapf(err, "setting %q to the transaction", cg.set)
+	return connection.WithTransaction(ctx, cg.chains[0].db, func(txdb connection.DB) error {
+		if cg.set != "" {
+			err := txdb.Set(ctx, cg.set)
+			if err != nil {
+				return errors.Wrapf(err, "setting %q to the transaction", cg.set)
+			}
 		}
-	}
 
-	for _, op := range cg.chains {
-		query, args, err := op.Render()
-		if err != nil {
-			return errors.Wrap(err, "rendeding part of chain transaction")
-		}
-		err = txdb.Exec(ctx, query, args...)
-		if err != nil {
-			return errors.Wrap(err, "error executing query in group")
+		for _, op := range cg.chains {
+			query, args, err := op.Render()
+			if err != nil {
+				return errors.Wrap(err, "rendeding part of chain transaction")
+			}
+			err = txdb.Exec(ctx, query, args...)
+			if err != nil {
+				return errors.Wrap(err, "error executing query in group")
+			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
@@ -15,6 +15,7 @@
 package chain
 
 import (
+	"bytes"
 	"strings"
 )
 
@@ -183,11 +184,11 @@ func (o *OnUpdate) SetSQLRow(args ...string) *OnUpdate {
 // Where Adds Where condition to an update on conflict, does not return the OnUpdate because it
 // is intended to be the last part of the expression.
 func (o *OnUpdate) Where(ec *ExpressionChain) {
-	dst := &strings.Builder{}
-	whereArgs := ec.renderWhereRaw(dst)
+	dst := &queryWriter{Buffer: &bytes.Buffer{}, raw: true, verbatim: true}
+	ec.renderWhere(dst)
 	*o.operatorList = append(*o.operatorList, argList{
 		text:        "WHERE " + dst.String(),
-		data:        whereArgs,
+		data:        dst.args,
 		termination: true,
 	})
 }
@@ -0,0 +1,112 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/pkg/errors"
+)
+
+// InsertMultiCopyThreshold makes Exec and ExecResult insert the rows of this InsertMulti chain
+// with the driver BulkInsert (COPY), if there are more than threshold, instead of rendering a
+// statement with a placeholder per value, which for big sets is slow and can go over the 65535
+// arguments postgres takes. Drivers that don't implement BulkInsert run the statement as usual.
+// Chains with ON CONFLICT, RETURNING, CTEs, a Schema, sub-queries as values or table and column
+// names that COPY would quote differently (ie, with upper case) are never copied.
+// It is disabled, 0, by default.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) InsertMultiCopyThreshold(threshold int) *ExpressionChain {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+	ec.copyThreshold = threshold
+	return ec
+}
+
+// copyRows returns the columns and the rows of values to insert if this chain is an InsertMulti
+// to run with COPY, see InsertMultiCopyThreshold.
+func (ec *ExpressionChain) copyRows() ([]string, [][]interface{}, bool) {
+	if ec.copyThreshold <= 0 || ec.hasErr() || ec.mainOperation == nil ||
+		ec.mainOperation.segment != sqlInsertMulti {
+		return nil, nil, false
+	}
+	if ec.conflict != nil || len(ec.ctes) != 0 || ec.queryable() || ec.schema != "" ||
+		!plainIdentifier(ec.table) {
+		return nil, nil, false
+	}
+	columns := strings.Split(ec.mainOperation.expression, ", ")
+	for _, column := range columns {
+		if !plainIdentifier(column) {
+			return nil, nil, false
+		}
+	}
+	values := ec.mainOperation.arguments
+	if len(values)/len(columns) <= ec.copyThreshold {
+		return nil, nil, false
+	}
+	rows := make([][]interface{}, len(values)/len(columns))
+	for i := range rows {
+		row := make([]interface{}, len(columns))
+		for j, value := range values[i*len(columns) : (i+1)*len(columns)] {
+			switch v := value.(type) {
+			case *ExpressionChain:
+				return nil, nil, false
+			case ArrayArgument:
+				row[j] = v.Value
+			default:
+				row[j] = value
+			}
+		}
+		rows[i] = row
+	}
+	return columns, rows, true
+}
+
+// plainIdentifier returns true if name is a lower case SQL identifier, which means the same
+// quoted or not.
+func plainIdentifier(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// insertByCopy inserts rows with BulkInsert, or with the rendered statement if db does not
+// implement it, and returns the amount of rows inserted.
+func (ec *ExpressionChain) insertByCopy(ctx context.Context, db connection.DB, columns []string,
+	rows [][]interface{}) (int64, error) {
+	err := db.BulkInsert(ctx, ec.table, columns, rows)
+	if errors.Cause(err) == gaumErrors.NotImplemented {
+		q, args, err := ec.Render()
+		if err != nil {
+			return 0, errors.Wrap(err, "rendering query to exec")
+		}
+		rowsAffected, err := db.ExecResult(ctx, q, args...)
+		return rowsAffected, ec.debugErr(err, q, args)
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "copying the rows to insert")
+	}
+	return int64(len(rows)), nil
+}
@@ -0,0 +1,92 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/go-test/deep"
+)
+
+// copyDB records the rows inserted with BulkInsert.
+type copyDB struct {
+	execDB
+	noCopy  bool
+	table   string
+	columns []string
+	rows    [][]interface{}
+}
+
+func (c *copyDB) BulkInsert(ctx context.Context, tableName string, columns []string, values [][]interface{}) error {
+	if c.noCopy {
+		return gaumErrors.NotImplemented
+	}
+	c.table, c.columns, c.rows = tableName, columns, values
+	return nil
+}
+
+func TestInsertMultiCopyThreshold(t *testing.T) {
+	insert := func(db *copyDB, rows int) *ExpressionChain {
+		ids := make([]interface{}, rows)
+		tags := make([]interface{}, rows)
+		for i := range ids {
+			ids[i] = i
+			tags[i] = Array([]string{"a"})
+		}
+		ec, err := New(db).Table("justforfun").InsertMulti(map[string][]interface{}{
+			"id": ids, "tags": tags,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ec.InsertMultiCopyThreshold(2)
+	}
+
+	db := &copyDB{}
+	if _, err := insert(db, 2).ExecResult(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if db.rows != nil || len(db.statements) != 1 {
+		t.Errorf("expected rows up to the threshold to be inserted with a statement")
+	}
+
+	db = &copyDB{}
+	inserted, err := insert(db, 3).ExecResult(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inserted != 3 || len(db.statements) != 0 {
+		t.Errorf("expected the 3 rows to be copied got %d inserted and statements %v", inserted, db.statements)
+	}
+	expected := [][]interface{}{{0, []string{"a"}}, {1, []string{"a"}}, {2, []string{"a"}}}
+	if diff := deep.Equal(db.rows, expected); diff != nil {
+		t.Error(diff)
+	}
+	if db.table != "justforfun" || len(db.columns) != 2 || db.columns[1] != "tags" {
+		t.Errorf("expected the rows to be copied into justforfun (id, tags) got %s %v", db.table, db.columns)
+	}
+
+	db = &copyDB{}
+	if err := insert(db, 3).Clone().Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.rows) != 3 {
+		t.Errorf("expected a clone to keep the threshold got statements %v", db.statements)
+	}
+
+	db = &copyDB{noCopy: true}
+	if err := insert(db, 3).Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.statements) != 1 {
+		t.Errorf("expected a statement when the driver can't copy got %v", db.statements)
+	}
+
+	db = &copyDB{}
+	if err := insert(db, 3).OnConflict(func(c *OnConflict) { c.OnColumn("id").DoNothing() }).Exec(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if db.rows != nil {
+		t.Errorf("expected an insert with ON CONFLICT not to be copied")
+	}
+}
@@ -0,0 +1,45 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestCountAndExists(t *testing.T) {
+	ctx := context.Background()
+	db := &execDB{returned: func(receiver interface{}) {
+		switch r := receiver.(type) {
+		case *int64:
+			*r = 3
+		case *bool:
+			*r = true
+		}
+	}}
+	count, err := New(db).Select("DISTINCT name").Table("records").AndWhere("id > ?", 1).Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists, err := New(db).Select("id").Table("records").AndWhere("id = ?", 2).Exists(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || !exists {
+		t.Errorf("expected 3 rows that exist got %d and %v", count, exists)
+	}
+	expected := []string{
+		"SELECT count(*) FROM (SELECT DISTINCT name FROM records WHERE id > $1) AS gaum_count",
+		"SELECT EXISTS (SELECT id FROM records WHERE id = $1)",
+	}
+	if diff := deep.Equal(db.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(db.args, [][]interface{}{{1}, {2}}); diff != nil {
+		t.Error(diff)
+	}
+
+	if _, err := New(db).Delete().Table("records").Count(ctx); err == nil {
+		t.Errorf("expected an error counting a DELETE")
+	}
+}
@@ -1,8 +1,6 @@
 package chain
 
 import (
-	"strings"
-
 	"github.com/pkg/errors"
 )
 
@@ -34,20 +32,18 @@ func (ec *ExpressionChain) With(name string, cte *ExpressionChain) *ExpressionCh
 	return ec
 }
 
-func (ec *ExpressionChain) renderctes(dst *strings.Builder) ([]interface{}, error) {
+func (ec *ExpressionChain) renderctes(dst *queryWriter) error {
 	if len(ec.ctes) == 0 {
-		return []interface{}{}, nil
+		return nil
 	}
 
-	args := []interface{}{}
 	dst.WriteString("WITH ")
 	for i, name := range ec.ctesOrder {
 		expr := ec.ctes[name]
 		dst.WriteString(name)
 		dst.WriteString(" AS (")
-		cteArgs, err := expr.render(true, dst)
-		if err != nil {
-			return nil, errors.Wrapf(err, "rendering cte %s", name)
+		if err := expr.render(dst); err != nil {
+			return errors.Wrapf(err, "rendering cte %s", name)
 		}
 		dst.WriteRune(')')
 		// We need commas if we have more than one element
@@ -55,9 +51,8 @@ func (ec *ExpressionChain) renderctes(dst *strings.Builder) ([]interface{}, erro
 		if len(ec.ctesOrder) > 1 && i != len(ec.ctesOrder)-1 {
 			dst.WriteString(", ")
 		}
-		args = append(args, cteArgs...)
 	}
 	dst.WriteRune(' ')
 
-	return args, nil
+	return nil
 }
@@ -0,0 +1,142 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/pkg/errors"
+)
+
+// DefaultCursorFetchSize is the amount of rows fetched at a time by QueryCursor if no valid
+// size is passed.
+const DefaultCursorFetchSize = 1000
+
+// cursorSeq makes cursor names unique.
+var cursorSeq uint64
+
+// QueryCursor is QueryIter but the results are read through a server side cursor, fetchSize
+// rows at a time, so huge result sets don't need to be held by the client.
+// Cursors only live in transactions, if the chain's DB is not one a transaction is started and
+// it is committed once all the rows are read or rolled back if anything fails. The close function
+// returned by the iterator must be called if the iteration is abandoned before reaching the end.
+func (ec *ExpressionChain) QueryCursor(ctx context.Context, fetchSize int) (connection.ResultFetchIter, error) {
+	noop := func(interface{}) (bool, func(), error) { return false, func() {}, nil }
+	if ec.hasErr() {
+		return nil, ec.getErr()
+	}
+	if !ec.queryable() {
+		return noop, errors.Errorf("cannot invoke query cursor with statements other than SELECT, please use Exec")
+	}
+	q, args, err := ec.Render()
+	if err != nil {
+		return noop, errors.Wrap(err, "rendering query to query with cursor")
+	}
+	if fetchSize <= 0 {
+		fetchSize = DefaultCursorFetchSize
+	}
+
+	db := ec.db
+	ownTX := !db.IsTransaction()
+	if ownTX {
+		db, err = ec.db.BeginTransaction(ctx)
+		if err != nil {
+			return noop, errors.Wrap(err, "starting transaction to declare cursor")
+		}
+	}
+	name := fmt.Sprintf("gaum_cursor_%d", atomic.AddUint64(&cursorSeq, 1))
+	declared := false
+	done := false
+	batchCloser := func() {}
+	// end closes the cursor and, if we started it, ends the transaction, failure is returned
+	// with any error found doing so.
+	end := func(failure error) error {
+		if done {
+			return failure
+		}
+		done = true
+		batchCloser()
+		if failure == nil && declared {
+			// if not, the transaction is aborted anyway.
+			failure = errors.Wrap(db.Exec(ctx, "CLOSE "+name), "closing cursor")
+		}
+		if !ownTX {
+			return failure
+		}
+		if failure != nil {
+			if err := db.RollbackTransaction(ctx); err != nil {
+				return errors.Wrapf(failure, "rolling back the cursor transaction also failed: %v", err)
+			}
+			return failure
+		}
+		return errors.Wrap(db.CommitTransaction(ctx), "committing the cursor transaction")
+	}
+
+	if ec.set != "" {
+		if err := db.Set(ctx, ec.set); err != nil {
+			return noop, end(errors.Wrap(err, "running set for the cursor transaction"))
+		}
+	}
+	if err := db.Exec(ctx, fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", name, q), args...); err != nil {
+		return noop, end(errors.Wrap(err, "declaring cursor"))
+	}
+	declared = true
+
+	fields := ec.fields(ctx)
+	fetchStatement := fmt.Sprintf("FETCH %d FROM %s", fetchSize, name)
+	// fetchBatch returns an iterator for the next batch of rows, false if there are no more.
+	fetchBatch := func() (connection.ResultFetchIter, bool, error) {
+		batch, err := db.QueryIter(ctx, fetchStatement, fields)
+		if err != nil {
+			if gaumErrors.IsNoRows(err) {
+				return nil, false, end(nil)
+			}
+			return nil, false, end(errors.Wrap(err, "fetching from cursor"))
+		}
+		return batch, true, nil
+	}
+
+	current, more, err := fetchBatch()
+	if err != nil {
+		return noop, err
+	}
+	if !more {
+		return noop, gaumErrors.ErrNoRows
+	}
+	closer := func() { _ = end(nil) }
+	return func(destination interface{}) (bool, func(), error) {
+		if done {
+			return false, closer, nil
+		}
+		next, rowsCloser, err := current(destination)
+		batchCloser = rowsCloser
+		if err != nil {
+			return false, closer, end(err)
+		}
+		if next {
+			return true, closer, nil
+		}
+		// this batch is exhausted, the row just read is valid and the next one, if any, is
+		// in the next batch.
+		batchCloser()
+		batchCloser = func() {}
+		current, more, err = fetchBatch()
+		return more, closer, err
+	}, nil
+}
@@ -0,0 +1,297 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+	"github.com/pkg/errors"
+)
+
+// CreateTableStatement builds a CREATE TABLE statement and the CREATE INDEX ones for the
+// indexes of the table.
+type CreateTableStatement struct {
+	table       string
+	ifNotExists bool
+	columns     []string
+	constraints []string
+	indexes     []*IndexStatement
+	partitionBy string
+}
+
+// CreateTable returns a CreateTableStatement for table, to which columns and constraints are
+// added with the statement methods.
+func CreateTable(table string) *CreateTableStatement {
+	return &CreateTableStatement{table: table}
+}
+
+// IfNotExists makes the statements do nothing for the table and indexes that already exist.
+func (c *CreateTableStatement) IfNotExists() *CreateTableStatement {
+	c.ifNotExists = true
+	return c
+}
+
+// Column adds a column of the passed SQL type, constraints are written after the type as they
+// are, ie Column("id", "bigint", "NOT NULL", "DEFAULT 0").
+func (c *CreateTableStatement) Column(name, sqlType string, constraints ...string) *CreateTableStatement {
+	c.columns = append(c.columns, strings.Join(append([]string{name, sqlType}, constraints...), " "))
+	return c
+}
+
+// PrimaryKey adds a PRIMARY KEY constraint on columns.
+func (c *CreateTableStatement) PrimaryKey(columns ...string) *CreateTableStatement {
+	c.constraints = append(c.constraints, "PRIMARY KEY ("+strings.Join(columns, ", ")+")")
+	return c
+}
+
+// Unique adds a UNIQUE constraint on columns, named name unless it is empty.
+func (c *CreateTableStatement) Unique(name string, columns ...string) *CreateTableStatement {
+	return c.Constraint(name, "UNIQUE ("+strings.Join(columns, ", ")+")")
+}
+
+// Constraint adds a table constraint, ie Constraint("positive_price", "CHECK (price > 0)"),
+// named name unless it is empty.
+func (c *CreateTableStatement) Constraint(name, definition string) *CreateTableStatement {
+	if name != "" {
+		definition = "CONSTRAINT " + name + " " + definition
+	}
+	c.constraints = append(c.constraints, definition)
+	return c
+}
+
+// Index adds an index on columns, created after the table, named name or, if empty,
+// <table>_<columns>_idx.
+func (c *CreateTableStatement) Index(name string, columns ...string) *CreateTableStatement {
+	if name == "" {
+		name = strings.Join(append([]string{unqualified(c.table)}, columns...), "_") + "_idx"
+	}
+	c.indexes = append(c.indexes, CreateIndex(name).On(c.table, columns...))
+	return c
+}
+
+// PartitionBy makes it a partitioned table, method is RANGE, LIST or HASH and keys the
+// columns, or expressions, the rows are partitioned by. The partitions are created with
+// CreatePartition and friends.
+func (c *CreateTableStatement) PartitionBy(method string, keys ...string) *CreateTableStatement {
+	c.partitionBy = " PARTITION BY " + method + " (" + strings.Join(keys, ", ") + ")"
+	return c
+}
+
+// Statements returns the CREATE TABLE statement followed by one CREATE INDEX per index.
+func (c *CreateTableStatement) Statements() []string {
+	ifNotExists := ""
+	if c.ifNotExists {
+		ifNotExists = "IF NOT EXISTS "
+	}
+	definitions := append(append([]string{}, c.columns...), c.constraints...)
+	statements := []string{
+		"CREATE TABLE " + ifNotExists + c.table + " (" + strings.Join(definitions, ", ") + ")" + c.partitionBy,
+	}
+	for _, index := range c.indexes {
+		index.ifNotExists = c.ifNotExists
+		statements = append(statements, index.String())
+	}
+	return statements
+}
+
+// String returns the statements separated by ";\n".
+func (c *CreateTableStatement) String() string {
+	return strings.Join(c.Statements(), ";\n")
+}
+
+// Exec runs the statements in db, in a transaction so the table is not left without its
+// indexes if one of them fails.
+func (c *CreateTableStatement) Exec(ctx context.Context, db connection.DB) error {
+	return connection.WithTransaction(ctx, db, func(tx connection.DB) error {
+		for _, statement := range c.Statements() {
+			if err := tx.Exec(ctx, statement); err != nil {
+				return errors.Wrapf(err, "creating table %s", c.table)
+			}
+		}
+		return nil
+	})
+}
+
+// CreateTableFromStruct returns a CreateTableStatement for table with a column for each
+// exported field of model, a struct or a pointer to one, named as gaum maps them for scanning.
+// The SQL type is inferred from the Go one unless the type sub tag says otherwise, fields that
+// can't hold a NULL (not pointers nor sql.Null*) are NOT NULL unless tagged null. The pk,
+// unique, index and default sub tags (see srm) add the constraints, indexes and defaults, ie:
+//
+//	type User struct {
+//		ID    int64   `gaum:"field_name:id;type:bigserial;pk"`
+//		Email string  `gaum:"unique"`
+//		Name  *string `gaum:"index"`
+//		Admin bool    `gaum:"default:false"`
+//	}
+func CreateTableFromStruct(table string, model interface{}) (*CreateTableStatement, error) {
+	modelType := reflect.TypeOf(model)
+	for modelType != nil && modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return nil, errors.Errorf("expected a struct or a pointer to one got %T", model)
+	}
+	c := CreateTable(table)
+	var pk []string
+	// named unique constraints and indexes can span several columns, in order of appearance.
+	var uniqueNames, indexNames []string
+	unique := map[string][]string{}
+	indexes := map[string][]string{}
+	for _, field := range columnFields(modelType) {
+		name := srm.ColumnName(field)
+		options := srm.TagOptions(field)
+		sqlType := options[srm.SubTagNameType]
+		inferred, nullable, err := sqlTypeOf(field.Type)
+		if err != nil {
+			if sqlType == "" {
+				return nil, errors.Wrapf(err, "inferring the type of %s", field.Name)
+			}
+			// the Go type is unknown to us, so we can't tell if it holds NULLs.
+			nullable = true
+		}
+		if sqlType == "" {
+			sqlType = inferred
+		}
+		if _, ok := options[srm.SubTagNameNull]; ok {
+			nullable = true
+		}
+		var constraints []string
+		if !nullable {
+			constraints = append(constraints, "NOT NULL")
+		}
+		if def, ok := options[srm.SubTagNameDefault]; ok {
+			constraints = append(constraints, "DEFAULT "+def)
+		}
+		c.Column(name, sqlType, constraints...)
+
+		if _, ok := options[srm.SubTagNamePrimaryKey]; ok {
+			pk = append(pk, name)
+		}
+		if uniqueName, ok := options[srm.SubTagNameUnique]; ok {
+			if uniqueName == "" {
+				c.Unique("", name)
+			} else {
+				if _, seen := unique[uniqueName]; !seen {
+					uniqueNames = append(uniqueNames, uniqueName)
+				}
+				unique[uniqueName] = append(unique[uniqueName], name)
+			}
+		}
+		if indexName, ok := options[srm.SubTagNameIndex]; ok {
+			if indexName == "" {
+				c.Index("", name)
+			} else {
+				if _, seen := indexes[indexName]; !seen {
+					indexNames = append(indexNames, indexName)
+				}
+				indexes[indexName] = append(indexes[indexName], name)
+			}
+		}
+	}
+	if len(c.columns) == 0 {
+		return nil, errors.Errorf("%s has no exported fields", modelType)
+	}
+	if len(pk) > 0 {
+		c.constraints = append([]string{"PRIMARY KEY (" + strings.Join(pk, ", ") + ")"}, c.constraints...)
+	}
+	for _, name := range uniqueNames {
+		c.Unique(name, unique[name]...)
+	}
+	for _, name := range indexNames {
+		c.Index(name, indexes[name]...)
+	}
+	return c, nil
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	nullTypes      = map[reflect.Type]string{
+		reflect.TypeOf(sql.NullBool{}):    "boolean",
+		reflect.TypeOf(sql.NullFloat64{}): "double precision",
+		reflect.TypeOf(sql.NullInt32{}):   "integer",
+		reflect.TypeOf(sql.NullInt64{}):   "bigint",
+		reflect.TypeOf(sql.NullString{}):  "text",
+		reflect.TypeOf(sql.NullTime{}):    "timestamp with time zone",
+	}
+)
+
+// sqlTypeOf returns the postgres type for values of t and whether they can be NULL.
+func sqlTypeOf(t reflect.Type) (string, bool, error) {
+	if t.Kind() == reflect.Ptr {
+		sqlType, _, err := sqlTypeOf(t.Elem())
+		return sqlType, true, err
+	}
+	if sqlType, ok := nullTypes[t]; ok {
+		return sqlType, true, nil
+	}
+	switch t {
+	case timeType:
+		return "timestamp with time zone", false, nil
+	case rawMessageType:
+		return "jsonb", true, nil
+	}
+	if t.Kind() == reflect.Array && t.Len() == 16 && t.Elem().Kind() == reflect.Uint8 &&
+		strings.EqualFold(t.Name(), "uuid") {
+		return "uuid", false, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean", false, nil
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "smallint", false, nil
+	case reflect.Int32, reflect.Uint16:
+		return "integer", false, nil
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return "bigint", false, nil
+	case reflect.Uint, reflect.Uint64:
+		return "numeric(20)", false, nil
+	case reflect.Float32:
+		return "real", false, nil
+	case reflect.Float64:
+		return "double precision", false, nil
+	case reflect.String:
+		return "text", false, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytea", true, nil
+		}
+		elemType, _, err := sqlTypeOf(t.Elem())
+		if err != nil || t.Elem().Kind() == reflect.Slice {
+			// slices of slices or of structs are stored as JSON.
+			return "jsonb", true, nil
+		}
+		return elemType + "[]", true, nil
+	case reflect.Map, reflect.Struct:
+		return "jsonb", t.Kind() == reflect.Map, nil
+	}
+	return "", false, errors.Errorf("no SQL type for %s, use the type sub tag", t)
+}
+
+// unqualified returns table without its schema.
+func unqualified(table string) string {
+	if i := strings.LastIndex(table, "."); i != -1 {
+		return table[i+1:]
+	}
+	return table
+}
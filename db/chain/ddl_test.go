@@ -0,0 +1,63 @@
+package chain
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+type ddlBase struct {
+	ID      int64     `gaum:"field_name:id;type:bigserial;pk"`
+	Created time.Time `gaum:"default:now()"`
+}
+
+type ddlUser struct {
+	ddlBase
+	Email    string `gaum:"unique"`
+	Tenant   int32  `gaum:"unique:users_tenant_name_key;index:users_tenant_idx"`
+	Name     string `gaum:"unique:users_tenant_name_key"`
+	Nick     *string
+	Score    float64 `gaum:"null"`
+	Tags     []string
+	Settings map[string]interface{} `gaum:"index"`
+	Deleted  sql.NullTime
+	Price    int `gaum:"type:numeric(10,2)"`
+	hidden   string
+}
+
+func TestCreateTableFromStruct(t *testing.T) {
+	c, err := CreateTableFromStruct("public.users", &ddlUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"CREATE TABLE IF NOT EXISTS public.users (id bigserial NOT NULL, created timestamp with time zone NOT NULL DEFAULT now(), " +
+			"email text NOT NULL, tenant integer NOT NULL, name text NOT NULL, nick text, score double precision, " +
+			"tags text[], settings jsonb, deleted timestamp with time zone, price numeric(10,2) NOT NULL, " +
+			"PRIMARY KEY (id), UNIQUE (email), CONSTRAINT users_tenant_name_key UNIQUE (tenant, name))",
+		"CREATE INDEX IF NOT EXISTS users_settings_idx ON public.users (settings)",
+		"CREATE INDEX IF NOT EXISTS users_tenant_idx ON public.users (tenant)",
+	}
+	if diff := deep.Equal(c.IfNotExists().Statements(), expected); diff != nil {
+		t.Error(diff)
+	}
+
+	if _, err := CreateTableFromStruct("things", struct{ Thing interface{} }{}); err == nil {
+		t.Errorf("expected an error for a field with no SQL type")
+	}
+	if _, err := CreateTableFromStruct("things", 1); err == nil {
+		t.Errorf("expected an error for a model that is not a struct")
+	}
+}
+
+func TestCreateTable(t *testing.T) {
+	got := CreateTable("justforfun").Column("id", "int").Column("description", "text", "NOT NULL").
+		Unique("therecanbeonlyone", "id").Constraint("", "CHECK (id > 0)").String()
+	expected := "CREATE TABLE justforfun (id int, description text NOT NULL, " +
+		"CONSTRAINT therecanbeonlyone UNIQUE (id), CHECK (id > 0))"
+	if got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
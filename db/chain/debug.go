@@ -0,0 +1,192 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/pkg/errors"
+)
+
+// DebugSQLMaxValueLength is the amount of bytes of each string or binary argument kept by
+// DebugSQL, the rest is cut.
+const DebugSQLMaxValueLength = 64
+
+// DebugSQL returns the rendered query with the arguments inlined as SQL literals, to be read by
+// humans or pasted into psql. Long values are truncated so the result is not meant to be run
+// by the program, use Render for that.
+func (ec *ExpressionChain) DebugSQL() string {
+	q, args, err := ec.Render()
+	if err != nil {
+		return fmt.Sprintf("invalid query, err: %s", err.Error())
+	}
+	return interpolateDebug(q, args)
+}
+
+// DebugErrors makes the errors of running the chain include its DebugSQL, beware that this
+// can leak argument values wherever the errors end.
+func (ec *ExpressionChain) DebugErrors() *ExpressionChain {
+	ec.debugErrors = true
+	return ec
+}
+
+// debugErr wraps err with the debug form of q if asked to by DebugErrors.
+func (ec *ExpressionChain) debugErr(err error, q string, args []interface{}) error {
+	if err == nil || !ec.debugErrors {
+		return err
+	}
+	return errors.Wrapf(err, "running %s", interpolateDebug(q, args))
+}
+
+// debugFetch wraps the errors of fetch as debugErr does.
+func (ec *ExpressionChain) debugFetch(fetch connection.ResultFetch, q string, args []interface{}) connection.ResultFetch {
+	if fetch == nil || !ec.debugErrors {
+		return fetch
+	}
+	return func(destination interface{}) error {
+		return ec.debugErr(fetch(destination), q, args)
+	}
+}
+
+// debugIter wraps the errors of iter as debugErr does.
+func (ec *ExpressionChain) debugIter(iter connection.ResultFetchIter, q string, args []interface{}) connection.ResultFetchIter {
+	if iter == nil || !ec.debugErrors {
+		return iter
+	}
+	return func(destination interface{}) (bool, func(), error) {
+		next, closer, err := iter(destination)
+		return next, closer, ec.debugErr(err, q, args)
+	}
+}
+
+// interpolateDebug replaces the $n placeholders of q, outside of quotes, with the literal of the
+// matching argument.
+func interpolateDebug(q string, args []interface{}) string {
+	dst := &strings.Builder{}
+	var quote byte
+	for i := 0; i < len(q); i++ {
+		c := q[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '$':
+			end := i + 1
+			for end < len(q) && q[end] >= '0' && q[end] <= '9' {
+				end++
+			}
+			n, err := strconv.Atoi(q[i+1 : end])
+			if err != nil || n < 1 || n > len(args) {
+				break
+			}
+			dst.WriteString(debugLiteral(args[n-1]))
+			i = end - 1
+			continue
+		}
+		dst.WriteByte(c)
+	}
+	return dst.String()
+}
+
+// debugLiteral returns arg as an SQL literal, long values are truncated.
+func debugLiteral(arg interface{}) string {
+	return literal(arg, DebugSQLMaxValueLength)
+}
+
+// literal returns arg as an SQL literal with its strings and binaries truncated to maxLength
+// bytes, unless it is 0.
+func literal(arg interface{}, maxLength int) string {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	case string:
+		return quoteLiteral(v, maxLength)
+	case []byte:
+		if v == nil {
+			return "NULL"
+		}
+		encoded := hex.EncodeToString(v)
+		if maxLength > 0 && len(v) > maxLength {
+			encoded = hex.EncodeToString(v[:maxLength])
+			return fmt.Sprintf("'\\x%s' /* truncated %d bytes */", encoded, len(v)-maxLength)
+		}
+		return "'\\x" + encoded + "'"
+	case time.Time:
+		return quoteLiteral(v.Format(time.RFC3339Nano), maxLength)
+	case driver.Valuer:
+		value, err := v.Value()
+		if err != nil {
+			return fmt.Sprintf("/* invalid value: %v */ NULL", err)
+		}
+		return literal(value, maxLength)
+	case fmt.Stringer:
+		return quoteLiteral(v.String(), maxLength)
+	}
+
+	rv := reflect.ValueOf(arg)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "NULL"
+		}
+		return literal(rv.Elem().Interface(), maxLength)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return "NULL"
+		}
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = literal(rv.Index(i).Interface(), maxLength)
+		}
+		return "ARRAY[" + strings.Join(items, ", ") + "]"
+	}
+	return quoteLiteral(fmt.Sprint(arg), maxLength)
+}
+
+// quoteLiteral quotes s as an SQL string, truncating it if longer than maxLength, unless 0.
+func quoteLiteral(s string, maxLength int) string {
+	truncated := 0
+	if maxLength > 0 && len(s) > maxLength {
+		cut := maxLength
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		truncated = len(s) - cut
+		s = s[:cut]
+	}
+	quoted := "'" + strings.Replace(s, "'", "''", -1) + "'"
+	if truncated > 0 {
+		quoted += fmt.Sprintf(" /* truncated %d bytes */", truncated)
+	}
+	return quoted
+}
@@ -0,0 +1,56 @@
+package chain
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/pkg/errors"
+)
+
+func TestExpressionChain_DebugSQL(t *testing.T) {
+	long := strings.Repeat("a", DebugSQLMaxValueLength+10)
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	query := NewNoDB().Select("id").Table("users").
+		AndWhere("name = ? AND nick = ?", "O'Brien", long).
+		AndWhere("note = '$1' AND active = ?", true).
+		AndWhere("created > ? AND id IN (?)", when, []int{1, 2}).
+		AndWhere("deleted = ?", nil)
+	expected := "SELECT id FROM users WHERE name = 'O''Brien' AND nick = '" + long[:DebugSQLMaxValueLength] +
+		"' /* truncated 10 bytes */ AND note = '$1' AND active = TRUE AND created > '2020-01-02T03:04:05Z' " +
+		"AND id IN (1, 2) AND deleted = NULL"
+	if got := query.DebugSQL(); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	if got := debugLiteral([]byte{0xde, 0xad}); got != `'\xdead'` {
+		t.Errorf("unexpected bytea literal %s", got)
+	}
+	if got := debugLiteral([]string{"a", "b"}); got != "ARRAY['a', 'b']" {
+		t.Errorf("unexpected array literal %s", got)
+	}
+}
+
+type failingDB struct {
+	connection.DB
+}
+
+func (failingDB) ExecResult(context.Context, string, ...interface{}) (int64, error) {
+	return 0, errors.New("boom")
+}
+
+func TestExpressionChain_DebugErrors(t *testing.T) {
+	err := New(failingDB{}).Delete().Table("users").AndWhere("id = ?", 1).Exec(context.Background())
+	if err == nil || strings.Contains(err.Error(), "DELETE") {
+		t.Errorf("expected an error without the statement got %v", err)
+	}
+	err = New(failingDB{}).Delete().Table("users").AndWhere("id = ?", 1).DebugErrors().Exec(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "running DELETE FROM users WHERE id = 1") {
+		t.Errorf("expected the error to contain the statement got %v", err)
+	}
+	if errors.Cause(err).Error() != "boom" {
+		t.Errorf("expected the original error as cause got %v", errors.Cause(err))
+	}
+}
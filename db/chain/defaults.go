@@ -0,0 +1,48 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"reflect"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+)
+
+// columnDefaults returns the defaults, SQL expressions declared with the default sub tag, of the
+// columns of the model of the chain.
+func (ec *ExpressionChain) columnDefaults() map[string]string {
+	if ec.modelType == nil {
+		return nil
+	}
+	defaults := map[string]string{}
+	for _, field := range model.ColumnFields(ec.modelType) {
+		if def, ok := srm.TagOptions(field)[srm.SubTagNameDefault]; ok && def != "" {
+			defaults[srm.ColumnName(field)] = def
+		}
+	}
+	return defaults
+}
+
+// isZeroValue returns true if value is nil or the zero value of its type, sub queries never are.
+func isZeroValue(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if _, ok := value.(*ExpressionChain); ok {
+		return false
+	}
+	return reflect.ValueOf(value).IsZero()
+}
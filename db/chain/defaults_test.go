@@ -0,0 +1,54 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/go-test/deep"
+)
+
+type defaultedRecord struct {
+	ID     int    `gaum:"field_name:id"`
+	Name   string `gaum:"default:'anonymous'"`
+	Status string `gaum:"default:'pending'"`
+	Score  int    `gaum:"default:random() * 10"`
+}
+
+func TestInsertDefaults(t *testing.T) {
+	q, args, err := NewNoDB().InsertStruct(&defaultedRecord{Status: "active"}).Table("records").Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "INSERT INTO records (name, score, status) VALUES ('anonymous', random() * 10, $1)"
+	if q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+	if diff := deep.Equal(args, []interface{}{"active"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := model.Register(&defaultedRecord{}, model.Table("records")); err != nil {
+		t.Fatal(err)
+	}
+	defer model.Unregister(&defaultedRecord{})
+	q, args, err = NewNoDB().Model(&defaultedRecord{}).
+		Insert(map[string]interface{}{"name": nil, "score": 3, "status": ""}).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "INSERT INTO records (name, score, status) VALUES ('anonymous', $1, 'pending')"
+	if q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+	if diff := deep.Equal(args, []interface{}{3}); diff != nil {
+		t.Error(diff)
+	}
+
+	q, _, err = NewNoDB().Insert(map[string]interface{}{"status": ""}).Table("records").Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected = "INSERT INTO records (status) VALUES ($1)"; q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+}
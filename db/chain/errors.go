@@ -0,0 +1,49 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrMultipleOnConflict is found when OnConflict is called more than once in a chain.
+	ErrMultipleOnConflict = errors.New("only 1 ON CONFLICT clause can be associated per statement")
+	// ErrReturningNotAllowed is found when Returning is used with statements other than INSERT
+	// or UPDATE.
+	ErrReturningNotAllowed = errors.New("Returning is only valid on UPDATE and INSERT statements")
+)
+
+// BuildError holds all the errors found while building a chain, which are returned when trying
+// to run it. errors.Is and errors.As look into each of them.
+type BuildError struct {
+	Errors []error
+}
+
+// Error implements error
+func (b *BuildError) Error() string {
+	errMsg := make([]string, len(b.Errors))
+	for index, anErr := range b.Errors {
+		errMsg[index] = anErr.Error()
+	}
+	return strings.Join(errMsg, " ")
+}
+
+// Unwrap returns the errors found.
+func (b *BuildError) Unwrap() []error {
+	return b.Errors
+}
@@ -0,0 +1,26 @@
+package chain
+
+import (
+	stdErrors "errors"
+	"testing"
+)
+
+func TestBuildError(t *testing.T) {
+	query := NewNoDB().Select("id").Table("justforfun").Returning("id")
+	query.OnConflict(func(*OnConflict) {}).OnConflict(func(*OnConflict) {})
+	err := query.getErr()
+	var buildErr *BuildError
+	if !stdErrors.As(err, &buildErr) || len(buildErr.Errors) != 2 {
+		t.Fatalf("expected a *BuildError with 2 errors got %v", err)
+	}
+	if !stdErrors.Is(err, ErrReturningNotAllowed) || !stdErrors.Is(err, ErrMultipleOnConflict) {
+		t.Errorf("expected each error to be found in %v", err)
+	}
+	expected := ErrReturningNotAllowed.Error() + " " + ErrMultipleOnConflict.Error()
+	if err.Error() != expected {
+		t.Errorf("expected %q got %q", expected, err.Error())
+	}
+	if NewNoDB().Select("id").getErr() != nil {
+		t.Errorf("expected no error for a valid chain")
+	}
+}
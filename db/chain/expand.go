@@ -0,0 +1,162 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/ShiftLeftSecurity/gaum/v2/selectparse"
+	"github.com/pkg/errors"
+)
+
+// ColumnCache holds the columns of tables, as introspected from the database, so select items
+// such as `u.*` can be expanded into explicit columns, see ExpressionChain.ExpandStars.
+// It is safe for concurrent use and meant to be shared by all the chains of a program.
+type ColumnCache struct {
+	lock    sync.RWMutex
+	columns map[string][]string
+}
+
+// NewColumnCache returns an empty ColumnCache.
+func NewColumnCache() *ColumnCache {
+	return &ColumnCache{columns: map[string][]string{}}
+}
+
+const tableColumnsQuery = "SELECT attname::text FROM pg_catalog.pg_attribute " +
+	"WHERE attrelid = $1::text::regclass AND attnum > 0 AND NOT attisdropped ORDER BY attnum"
+
+// Columns returns the columns of table, in the order `table.*` yields them, db is queried only
+// the first time a table is seen. The table is resolved as postgres would in a query so it can
+// be schema qualified or rely on the search_path.
+func (c *ColumnCache) Columns(ctx context.Context, db connection.DB, table string) ([]string, error) {
+	c.lock.RLock()
+	columns, ok := c.columns[table]
+	c.lock.RUnlock()
+	if ok {
+		return columns, nil
+	}
+	fetch, err := db.QueryPrimitive(ctx, tableColumnsQuery, "attname", table)
+	if err != nil {
+		return nil, errors.Wrapf(err, "querying columns of %s", table)
+	}
+	if err := fetch(&columns); err != nil {
+		return nil, errors.Wrapf(err, "fetching columns of %s", table)
+	}
+	c.lock.Lock()
+	c.columns[table] = columns
+	c.lock.Unlock()
+	return columns, nil
+}
+
+// Forget drops table from the cache, use it after its columns are altered.
+func (c *ColumnCache) Forget(table string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.columns, table)
+}
+
+// ExpandStars makes the chain expand the `*` and `alias.*` items of its SELECT into the
+// columns of the corresponding FROM or JOIN table, as found in cache, so they are mapped
+// to the destination fields without the per row column lookup.
+// Items that can't be expanded, such as those of sub-queries or CTEs, make the chain fall back
+// to the usual behavior.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) ExpandStars(cache *ColumnCache) *ExpressionChain {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+	ec.columnCache = cache
+	return ec
+}
+
+// fields returns the names of the columns the main operation yields, with stars expanded if
+// ExpandStars was used.
+func (ec *ExpressionChain) fields(ctx context.Context) []string {
+	if ec.columnCache == nil || ec.mainOperation.segment != sqlSelect {
+		return ec.mainOperation.fields()
+	}
+	sources := ec.starSources()
+	fields, err := selectparse.FieldsFromSelectExpanding(ec.mainOperation.expression,
+		func(qualifier string) ([]string, error) {
+			table, ok := sources[normalizeIdentifier(qualifier)]
+			if qualifier == "" && len(sources) == 1 {
+				for _, t := range sources {
+					table, ok = t, true
+				}
+			}
+			if !ok {
+				return nil, errors.Errorf("no table can be found for %q", qualifier)
+			}
+			return ec.columnCache.Columns(ctx, ec.db, table)
+		})
+	if err != nil {
+		// missing fields will just prompt the DB for the columns.
+		return []string{}
+	}
+	return fields
+}
+
+// starSources returns the tables of the FROM and JOIN clauses by the name they are referred
+// to in the select list, their alias or, if they have none, their name.
+func (ec *ExpressionChain) starSources() map[string]string {
+	sources := map[string]string{}
+	add := func(expr string, qualify bool) {
+		table, alias := tableAndAlias(expr)
+		if table == "" {
+			return
+		}
+		if _, isCTE := ec.ctes[table]; isCTE {
+			return
+		}
+		qualified := table
+		if qualify {
+			qualified = qualifyTable(ec.schema, table)
+		}
+		if alias != "" {
+			sources[normalizeIdentifier(alias)] = qualified
+			return
+		}
+		sources[normalizeIdentifier(table)] = qualified
+		if i := strings.LastIndex(table, "."); i != -1 {
+			sources[normalizeIdentifier(table[i+1:])] = qualified
+		}
+	}
+	add(ec.table, true)
+	for _, join := range extractMany(ec, []sqlSegment{sqlJoin, sqlLeftJoin, sqlRightJoin, sqlInnerJoin, sqlFullJoin}) {
+		add(join.expression, ec.schemaJoins)
+	}
+	return sources
+}
+
+// tableAndAlias returns the table and alias, if any, of a FROM or JOIN expression such as
+// "users AS u", the table is empty for sub-queries and functions.
+func tableAndAlias(expr string) (string, string) {
+	references, err := selectparse.ParseFrom(expr)
+	if err != nil || len(references) != 1 {
+		return "", ""
+	}
+	return references[0].Table, references[0].Alias
+}
+
+// normalizeIdentifier lowercases name unless it is quoted, so it can be compared as postgres
+// does.
+func normalizeIdentifier(name string) string {
+	if strings.ContainsRune(name, '"') {
+		return name
+	}
+	return strings.ToLower(name)
+}
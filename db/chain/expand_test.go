@@ -0,0 +1,91 @@
+package chain
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+)
+
+// columnsDB answers the column introspection queries with the columns of its tables and records
+// the query ones.
+type columnsDB struct {
+	connection.DB
+	tables      map[string][]string
+	introspects int
+	fields      []string
+}
+
+func (c *columnsDB) QueryPrimitive(ctx context.Context, statement string, field string, args ...interface{}) (connection.ResultFetch, error) {
+	c.introspects++
+	columns, ok := c.tables[args[0].(string)]
+	if !ok {
+		return nil, errors.Errorf("relation %q does not exist", args[0])
+	}
+	return func(dst interface{}) error {
+		reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(columns))
+		return nil
+	}, nil
+}
+
+func (c *columnsDB) Query(ctx context.Context, statement string, fields []string, args ...interface{}) (connection.ResultFetch, error) {
+	c.fields = fields
+	return func(interface{}) error { return nil }, nil
+}
+
+func TestExpressionChain_ExpandStars(t *testing.T) {
+	db := &columnsDB{tables: map[string][]string{
+		"users":         {"id", "name"},
+		"tenant.orders": {"id", "user_id", "total"},
+	}}
+	cache := NewColumnCache()
+	query := New(db).Select("u.*, o.total, O.*").Table("users AS u").
+		Join("orders o", "o.user_id = u.id").SchemaWithJoins("tenant").ExpandStars(cache)
+	// the main table is qualified too, so this one does not exist.
+	if err := query.Fetch(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.fields) != 0 {
+		t.Errorf("expected no fields when a table does not exist got %v", db.fields)
+	}
+	db.tables["tenant.users"] = db.tables["users"]
+
+	for i := 0; i < 2; i++ {
+		if err := query.Clone().Fetch(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"id", "name", "total", "id", "user_id", "total"}
+		if diff := deep.Equal(db.fields, expected); diff != nil {
+			t.Error(diff)
+		}
+	}
+	if db.introspects != 3 {
+		t.Errorf("expected the tables to be introspected once each, got %d queries", db.introspects)
+	}
+	cache.Forget("tenant.orders")
+	if err := query.Fetch(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if db.introspects != 4 {
+		t.Errorf("expected a forgotten table to be introspected again, got %d queries", db.introspects)
+	}
+
+	err := New(db).Select("*").Table("users").ExpandStars(cache).Fetch(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(db.fields, []string{"id", "name"}); diff != nil {
+		t.Error(diff)
+	}
+
+	err = New(db).Select("x.*").Table("(SELECT 1) AS x").ExpandStars(cache).Fetch(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.fields) != 0 {
+		t.Errorf("expected sub-queries to fall back to no fields got %v", db.fields)
+	}
+}
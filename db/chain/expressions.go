@@ -15,6 +15,7 @@ package chain
 //    limitations under the License.
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 	"strings"
@@ -45,18 +46,17 @@ func (ec *ExpressionChain) OrWhereGroup(c *ExpressionChain) *ExpressionChain {
 }
 
 func (ec *ExpressionChain) whereGroup(c *ExpressionChain, whereFunc baseSegmentFunc) {
-	dst := &strings.Builder{}
+	dst := &queryWriter{Buffer: &bytes.Buffer{}, raw: true, verbatim: true}
 	dst.WriteRune('(')
-	whereArgs := c.renderWhereRaw(dst)
+	c.renderWhere(dst)
 	dst.WriteRune(')')
-	whereFunc(dst.String(), whereArgs...)
+	whereFunc(dst.String(), dst.args...)
 }
 
 // appendExpandedOp is the constructor of the most common chain segment.
 func (ec *ExpressionChain) appendExpandedOp(expr string,
 	op sqlSegment, boolOp sqlBool,
 	args ...interface{}) *ExpressionChain {
-	expr, args = ExpandArgs(args, expr)
 	ec.append(
 		querySegmentAtom{
 			segment:    op,
@@ -71,7 +71,6 @@ func (ec *ExpressionChain) appendExpandedOp(expr string,
 func (ec *ExpressionChain) setExpandedMainOp(expr string,
 	op sqlSegment, boolOp sqlBool,
 	args ...interface{}) *ExpressionChain {
-	expr, args = ExpandArgs(args, expr)
 	ec.mainOperation = &querySegmentAtom{
 		segment:    op,
 		expression: ec.populateTablePrefixes(expr),
@@ -114,7 +113,7 @@ func (ec *ExpressionChain) OrHaving(expr string, args ...interface{}) *Expressio
 // is an INSERT.
 func (ec *ExpressionChain) OnConflict(clause func(*OnConflict)) *ExpressionChain {
 	if ec.conflict != nil {
-		ec.err = append(ec.err, errors.New("only 1 ON CONFLICT clause can be associated per statement"))
+		ec.err = append(ec.err, ErrMultipleOnConflict)
 		return ec
 	}
 	ec.conflict = &OnConflict{}
@@ -131,7 +130,7 @@ func (ec *ExpressionChain) OnConflict(clause func(*OnConflict)) *ExpressionChain
 func (ec *ExpressionChain) Returning(args ...string) *ExpressionChain {
 	if ec.mainOperation == nil ||
 		(ec.mainOperation.segment != sqlInsert && ec.mainOperation.segment != sqlInsertMulti && ec.mainOperation.segment != sqlUpdate) {
-		ec.err = append(ec.err, errors.New("Returning is only valid on UPDATE and INSERT statements"))
+		ec.err = append(ec.err, ErrReturningNotAllowed)
 	}
 	ec.append(
 		querySegmentAtom{
@@ -191,6 +190,15 @@ func (ec *ExpressionChain) Offset(offset int64) *ExpressionChain {
 	return ec
 }
 
+// Unpaginated removes the 'LIMIT' and 'OFFSET' from the 'ExpressionChain' and returns the same
+// chain to facilitate further chaining.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) Unpaginated() *ExpressionChain {
+	ec.setLimit(nil)
+	ec.setOffset(nil)
+	return ec
+}
+
 // Join adds a 'JOIN' to the 'ExpressionChain' and returns the same chan to facilitate
 // further chaining.
 // THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
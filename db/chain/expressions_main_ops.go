@@ -109,6 +109,8 @@ func (ec *ExpressionChain) InsertMulti(insertPairs map[string][]interface{}) (*E
 }
 
 // Insert set fields/values for insertion.
+// If the chain has a Model the zero values of the columns with a default sub tag are replaced
+// by the default when rendered.
 func (ec *ExpressionChain) Insert(insertPairs map[string]interface{}) *ExpressionChain {
 	exprKeys := make([]string, len(insertPairs))
 	exprValues := make([]interface{}, len(insertPairs))
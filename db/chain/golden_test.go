@@ -0,0 +1,29 @@
+package chain_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/chain"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection_testing"
+)
+
+func TestExpressionChain_Golden(t *testing.T) {
+	testCases := map[string]*chain.ExpressionChain{
+		"select_join": chain.NewNoDB().Select("u.id, u.name, count(o.id) AS orders").Table("users AS u").
+			LeftJoin("orders AS o", "o.user_id = u.id").
+			AndWhere("u.active = ?", true).AndWhere("u.id IN (?)", []int{1, 2, 3}).
+			GroupBy("u.id, u.name").OrderBy(chain.Desc("orders")).Limit(10),
+		"insert_upsert": chain.NewNoDB().Insert(map[string]interface{}{"id": 1, "name": "alice"}).
+			Table("users").OnConflict(func(c *chain.OnConflict) {
+			c.OnColumn("id").DoUpdate().Set("name", "alice")
+		}),
+		"update": chain.NewNoDB().UpdateMap(map[string]interface{}{"name": "bob"}).Table("users").
+			AndWhere("id = ?", 2),
+	}
+	for name, query := range testCases {
+		t.Run(name, func(t *testing.T) {
+			connection_testing.AssertGolden(t, query, filepath.Join("testdata", name+".golden"))
+		})
+	}
+}
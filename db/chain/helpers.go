@@ -15,12 +15,10 @@
 package chain
 
 import (
-	"database/sql"
 	"fmt"
 	"strings"
 
 	"github.com/ShiftLeftSecurity/gaum/v2/db/errors"
-	"github.com/jackc/pgx/v4"
 )
 
 const (
@@ -61,6 +59,12 @@ func SUM(column string) string {
 	return SimpleFunction("SUM", column)
 }
 
+// NextVal returns a chain that advances the sequence seq, to be used as a value in Insert or
+// InsertMulti so the id is allocated by the same statement.
+func NextVal(seq string) *ExpressionChain {
+	return NewNoDB().Select(SimpleFunction("nextval", quoteLiteral(seq, 0)))
+}
+
 // Function represents a SQL function.
 type Function interface {
 	// Static adds an argument to the function
@@ -231,6 +235,7 @@ func LesserOrEqualThan(field string) string {
 }
 
 // In is a convenience function to enable use of go for where definitions
+// If there are more values than the chain InArrayThreshold, they are bound as an array (see InAny).
 func In(field string, value ...interface{}) (string, []interface{}) {
 	return fmt.Sprintf("%s IN (?)", field), value
 }
@@ -247,6 +252,7 @@ func NotLike(field string) string {
 
 // InSlice is a convenience function to enable use of go for where definitions and assumes the
 // passed value is already a slice.
+// If there are more values than the chain InArrayThreshold, they are bound as an array (see InAny).
 func InSlice(field string, value interface{}) (string, interface{}) {
 	return fmt.Sprintf("%s IN (?)", field), value
 }
@@ -272,5 +278,5 @@ func SetToCurrentTimestamp(field string) string {
 // IsNoRows returns true if the passed error is one of the many possibilities of
 // no rows returned by the different libraries.
 func IsNoRows(err error) bool {
-	return err == errors.ErrNoRows || err == sql.ErrNoRows || err == pgx.ErrNoRows
+	return errors.IsNoRows(err)
 }
@@ -0,0 +1,123 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// BeforeInserter is implemented by the structs that need to run something before being
+// inserted with InsertStruct, ie stamping audit fields, an error aborts the insert.
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context, ec *ExpressionChain) error
+}
+
+// AfterInserter is implemented by the structs that need to run something after being inserted
+// with InsertStruct, the error is returned by the chain execution.
+type AfterInserter interface {
+	AfterInsert(ctx context.Context, ec *ExpressionChain) error
+}
+
+// BeforeUpdater is implemented by the structs that need to run something before being updated
+// with UpdateStruct, an error aborts the update.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context, ec *ExpressionChain) error
+}
+
+// AfterUpdater is implemented by the structs that need to run something after being updated
+// with UpdateStruct, the error is returned by the chain execution.
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context, ec *ExpressionChain) error
+}
+
+// AfterFetcher is implemented by the structs that need to run something after being fetched,
+// ie filling denormalized fields, by Fetch or q, the error is returned by them.
+type AfterFetcher interface {
+	AfterFetch(ctx context.Context, ec *ExpressionChain) error
+}
+
+// Validator is implemented by the structs that check their invariants before being written with
+// InsertStruct or UpdateStruct, an error aborts the statement and is returned by the chain
+// execution.
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+// beforeRecordHooks runs the hook of the record, if any, for its operation, then its validation
+// and takes its values again.
+func (ec *ExpressionChain) beforeRecordHooks(ctx context.Context) error {
+	if ec.record == nil {
+		return nil
+	}
+	var err error
+	switch ec.recordOperation {
+	case sqlInsert:
+		if hook, ok := ec.record.(BeforeInserter); ok {
+			err = errors.Wrap(hook.BeforeInsert(ctx, ec), "running before insert hook")
+		}
+	case sqlUpdate:
+		if hook, ok := ec.record.(BeforeUpdater); ok {
+			err = errors.Wrap(hook.BeforeUpdate(ctx, ec), "running before update hook")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if validator, ok := ec.record.(Validator); ok {
+		if err := validator.Validate(ctx); err != nil {
+			return errors.Wrap(err, "validating record")
+		}
+	}
+	return ec.refreshRecord()
+}
+
+// afterRecordHooks runs the hook of the record, if any, for its operation.
+func (ec *ExpressionChain) afterRecordHooks(ctx context.Context) error {
+	switch ec.recordOperation {
+	case sqlInsert:
+		if hook, ok := ec.record.(AfterInserter); ok {
+			return errors.Wrap(hook.AfterInsert(ctx, ec), "running after insert hook")
+		}
+	case sqlUpdate:
+		if hook, ok := ec.record.(AfterUpdater); ok {
+			return errors.Wrap(hook.AfterUpdate(ctx, ec), "running after update hook")
+		}
+	}
+	return nil
+}
+
+// AfterFetch runs the AfterFetch hook of each of the structs in dst, a pointer to a struct or
+// to a slice of them or of pointers to them, that implements AfterFetcher. Fetch calls it once
+// the rows are fetched.
+func (ec *ExpressionChain) AfterFetch(ctx context.Context, dst interface{}) error {
+	value := reflect.ValueOf(dst)
+	if !value.IsValid() {
+		return nil
+	}
+	for _, record := range structValues(value) {
+		if !record.CanAddr() {
+			continue
+		}
+		if hook, ok := record.Addr().Interface().(AfterFetcher); ok {
+			if err := hook.AfterFetch(ctx, ec); err != nil {
+				return errors.Wrap(err, "running after fetch hook")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+)
+
+// execDB records the statements it executes.
+type execDB struct {
+	connection.DB
+	statements []string
+	args       [][]interface{}
+	// noRows makes the statements affect no rows.
+	noRows bool
+	// returned fills the receiver of the rows returned by the statements.
+	returned func(receiver interface{})
+}
+
+func (e *execDB) Raw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error {
+	e.statements = append(e.statements, statement)
+	e.args = append(e.args, args)
+	e.returned(fields[0])
+	return nil
+}
+
+func (e *execDB) QueryIter(ctx context.Context, statement string, fields []string, args ...interface{}) (connection.ResultFetchIter, error) {
+	e.statements = append(e.statements, statement)
+	e.args = append(e.args, args)
+	if e.noRows {
+		return nil, gaumErrors.ErrNoRows
+	}
+	return func(receiver interface{}) (bool, func(), error) {
+		e.returned(receiver)
+		return false, func() {}, nil
+	}, nil
+}
+
+func (e *execDB) ExecResult(ctx context.Context, statement string, args ...interface{}) (int64, error) {
+	e.statements = append(e.statements, statement)
+	e.args = append(e.args, args)
+	if e.noRows {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func (e *execDB) IsTransaction() bool {
+	return false
+}
+
+var errInvalidRecord = errors.New("records need a name")
+
+type hookedRecord struct {
+	ID        int `gaum:"field_name:id"`
+	Name      string
+	CreatedBy string
+	calls     []string
+	fail      bool
+}
+
+func (h *hookedRecord) BeforeInsert(ctx context.Context, ec *ExpressionChain) error {
+	h.calls = append(h.calls, "BeforeInsert")
+	if h.fail {
+		return errors.New("refused")
+	}
+	h.CreatedBy = "auditor"
+	return nil
+}
+
+func (h *hookedRecord) AfterInsert(ctx context.Context, ec *ExpressionChain) error {
+	h.calls = append(h.calls, "AfterInsert")
+	return nil
+}
+
+func (h *hookedRecord) BeforeUpdate(ctx context.Context, ec *ExpressionChain) error {
+	h.calls = append(h.calls, "BeforeUpdate")
+	return nil
+}
+
+func (h *hookedRecord) Validate(ctx context.Context) error {
+	h.calls = append(h.calls, "Validate")
+	if h.Name == "" {
+		return errInvalidRecord
+	}
+	return nil
+}
+
+func (h *hookedRecord) AfterFetch(ctx context.Context, ec *ExpressionChain) error {
+	h.calls = append(h.calls, "AfterFetch")
+	return nil
+}
+
+func TestStructHooks(t *testing.T) {
+	ctx := context.Background()
+	db := &execDB{}
+	record := &hookedRecord{Name: "jane"}
+	if err := New(db).InsertStruct(record).Table("records").Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	record.ID = 7
+	record.Name = "janet"
+	if err := New(db).UpdateStruct(record).Table("records").Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"INSERT INTO records (created_by, name) VALUES ($1, $2)",
+		"UPDATE records SET created_by = $1, name = $2 WHERE id = $3",
+	}
+	if diff := deep.Equal(db.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(db.args, [][]interface{}{{"auditor", "jane"}, {"auditor", "janet", 7}}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(record.calls, []string{"BeforeInsert", "Validate", "AfterInsert", "BeforeUpdate", "Validate"}); diff != nil {
+		t.Error(diff)
+	}
+
+	record = &hookedRecord{fail: true}
+	if err := New(db).InsertStruct(record).Table("records").Exec(ctx); err == nil {
+		t.Errorf("expected the before insert hook to abort the insert")
+	}
+	if len(db.statements) != 2 {
+		t.Errorf("expected nothing to be executed got %v", db.statements[2:])
+	}
+
+	record = &hookedRecord{}
+	err := New(db).InsertStruct(record).Table("records").Exec(ctx)
+	if errors.Cause(err) != errInvalidRecord {
+		t.Errorf("expected the validation error got %v", err)
+	}
+	if len(db.statements) != 2 {
+		t.Errorf("expected nothing to be executed got %v", db.statements[2:])
+	}
+
+	records := []hookedRecord{{}, {}}
+	if err := New(db).AfterFetch(ctx, &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records[0].calls) != 1 || len(records[1].calls) != 1 {
+		t.Errorf("expected the after fetch hook to run for each record")
+	}
+
+	if !New(db).InsertStruct(hookedRecord{}).hasErr() {
+		t.Errorf("expected an error inserting a struct that is not a pointer")
+	}
+	type keyless struct{ Name string }
+	if !New(db).UpdateStruct(&keyless{}).hasErr() {
+		t.Errorf("expected an error updating a struct without primary key")
+	}
+}
+
+type savedRecord struct {
+	ID        int `gaum:"field_name:id"`
+	Name      string
+	Owner     string `gaum:"readonly"`
+	Generated string `gaum:"omit"`
+}
+
+func TestExecInto(t *testing.T) {
+	ctx := context.Background()
+	db := &execDB{returned: func(receiver interface{}) {
+		record := receiver.(*savedRecord)
+		record.ID = 9
+		record.Generated = "generated"
+	}}
+	record := &savedRecord{Name: "jane", Owner: "john", Generated: "ignored"}
+	if err := New(db).InsertStruct(record).Table("records").Returning("*").ExecInto(ctx, record); err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != 9 || record.Generated != "generated" {
+		t.Errorf("expected the returned row to be fetched got %+v", record)
+	}
+	if err := New(db).UpdateStruct(record).Table("records").Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"INSERT INTO records (name, owner) VALUES ($1, $2) RETURNING *",
+		"UPDATE records SET name = $1 WHERE id = $2",
+	}
+	if diff := deep.Equal(db.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	if err := New(db).InsertStruct(record).Table("records").ExecInto(ctx, record); err == nil {
+		t.Errorf("expected an error executing into a receiver without RETURNING")
+	}
+}
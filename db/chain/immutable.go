@@ -0,0 +1,314 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import "github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+
+// ImmutableChain is an ExpressionChain that is never modified, its builder methods return a new
+// ImmutableChain built on a copy instead, so it can be shared between goroutines and used as the
+// template of other queries, ie:
+//
+//	byTenant := chain.New(db).Select("*").Table("users").AndWhere("tenant = ?", tenant).Immutable()
+//	admins := byTenant.AndWhere("admin")
+//	err := admins.Chain().Fetch(ctx, &users)
+//
+// Chain returns the ExpressionChain to run it. Chains passed to With are copied, those passed
+// to the rest of the methods (ie, as values) and records are not so they must not be changed.
+type ImmutableChain struct {
+	ec *ExpressionChain
+}
+
+// Immutable returns an ImmutableChain built on a copy of this chain.
+func (ec *ExpressionChain) Immutable() *ImmutableChain {
+	return newImmutableChain(ec.copyAll())
+}
+
+func newImmutableChain(ec *ExpressionChain) *ImmutableChain {
+	// Clone initializes it otherwise, which would be a write.
+	ec.TablePrefixes()
+	return &ImmutableChain{ec: ec}
+}
+
+// copyAll is Clone but also copies what Clone leaves out: the Set, the ON CONFLICT clause and
+// the errors.
+func (ec *ExpressionChain) copyAll() *ExpressionChain {
+	c := ec.Clone()
+	c.set = ec.set
+	// an OnConflict is never changed once built.
+	c.conflict = ec.conflict
+	c.err = append([]error(nil), ec.err...)
+	return c
+}
+
+// Chain returns a copy of the chain, which is an ExpressionChain as any other, to run it or
+// change it in place.
+func (ic *ImmutableChain) Chain() *ExpressionChain {
+	return ic.ec.copyAll()
+}
+
+// Render is ExpressionChain.Render.
+func (ic *ImmutableChain) Render() (string, []interface{}, error) {
+	return ic.Chain().Render()
+}
+
+// derive returns a new ImmutableChain built applying build to a copy of this one.
+func (ic *ImmutableChain) derive(build func(ec *ExpressionChain)) *ImmutableChain {
+	ec := ic.Chain()
+	build(ec)
+	return newImmutableChain(ec)
+}
+
+// Set is ExpressionChain.Set on a copy of the chain.
+func (ic *ImmutableChain) Set(set string) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Set(set) })
+}
+
+// NewDB is ExpressionChain.NewDB on a copy of the chain.
+func (ic *ImmutableChain) NewDB(db connection.DB) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.NewDB(db) })
+}
+
+// With is ExpressionChain.With on a copy of the chain.
+func (ic *ImmutableChain) With(name string, cte *ExpressionChain) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.With(name, cte.Clone()) })
+}
+
+// DebugErrors is ExpressionChain.DebugErrors on a copy of the chain.
+func (ic *ImmutableChain) DebugErrors() *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.DebugErrors() })
+}
+
+// ExpandStars is ExpressionChain.ExpandStars on a copy of the chain.
+func (ic *ImmutableChain) ExpandStars(cache *ColumnCache) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.ExpandStars(cache) })
+}
+
+// AndWhereGroup is ExpressionChain.AndWhereGroup on a copy of the chain.
+func (ic *ImmutableChain) AndWhereGroup(c *ExpressionChain) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.AndWhereGroup(c) })
+}
+
+// OrWhereGroup is ExpressionChain.OrWhereGroup on a copy of the chain.
+func (ic *ImmutableChain) OrWhereGroup(c *ExpressionChain) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.OrWhereGroup(c) })
+}
+
+// AndWhere is ExpressionChain.AndWhere on a copy of the chain.
+func (ic *ImmutableChain) AndWhere(expr string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.AndWhere(expr, args...) })
+}
+
+// OrWhere is ExpressionChain.OrWhere on a copy of the chain.
+func (ic *ImmutableChain) OrWhere(expr string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.OrWhere(expr, args...) })
+}
+
+// AndHaving is ExpressionChain.AndHaving on a copy of the chain.
+func (ic *ImmutableChain) AndHaving(expr string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.AndHaving(expr, args...) })
+}
+
+// OrHaving is ExpressionChain.OrHaving on a copy of the chain.
+func (ic *ImmutableChain) OrHaving(expr string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.OrHaving(expr, args...) })
+}
+
+// OnConflict is ExpressionChain.OnConflict on a copy of the chain.
+func (ic *ImmutableChain) OnConflict(clause func(*OnConflict)) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.OnConflict(clause) })
+}
+
+// Returning is ExpressionChain.Returning on a copy of the chain.
+func (ic *ImmutableChain) Returning(args ...string) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Returning(args...) })
+}
+
+// Table is ExpressionChain.Table on a copy of the chain.
+func (ic *ImmutableChain) Table(table string) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Table(table) })
+}
+
+// From is ExpressionChain.From on a copy of the chain.
+func (ic *ImmutableChain) From(table string) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.From(table) })
+}
+
+// FromUpdate is ExpressionChain.FromUpdate on a copy of the chain.
+func (ic *ImmutableChain) FromUpdate(expr string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.FromUpdate(expr, args...) })
+}
+
+// Limit is ExpressionChain.Limit on a copy of the chain.
+func (ic *ImmutableChain) Limit(limit int64) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Limit(limit) })
+}
+
+// Offset is ExpressionChain.Offset on a copy of the chain.
+func (ic *ImmutableChain) Offset(offset int64) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Offset(offset) })
+}
+
+// Join is ExpressionChain.Join on a copy of the chain.
+func (ic *ImmutableChain) Join(expr, on string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Join(expr, on, args...) })
+}
+
+// LeftJoin is ExpressionChain.LeftJoin on a copy of the chain.
+func (ic *ImmutableChain) LeftJoin(expr, on string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.LeftJoin(expr, on, args...) })
+}
+
+// RightJoin is ExpressionChain.RightJoin on a copy of the chain.
+func (ic *ImmutableChain) RightJoin(expr, on string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.RightJoin(expr, on, args...) })
+}
+
+// InnerJoin is ExpressionChain.InnerJoin on a copy of the chain.
+func (ic *ImmutableChain) InnerJoin(expr, on string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.InnerJoin(expr, on, args...) })
+}
+
+// FullJoin is ExpressionChain.FullJoin on a copy of the chain.
+func (ic *ImmutableChain) FullJoin(expr, on string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.FullJoin(expr, on, args...) })
+}
+
+// OrderBy is ExpressionChain.OrderBy on a copy of the chain.
+func (ic *ImmutableChain) OrderBy(order *OrderByOperator) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.OrderBy(order) })
+}
+
+// GroupBy is ExpressionChain.GroupBy on a copy of the chain.
+func (ic *ImmutableChain) GroupBy(expr string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.GroupBy(expr, args...) })
+}
+
+// GroupByReplace is ExpressionChain.GroupByReplace on a copy of the chain.
+func (ic *ImmutableChain) GroupByReplace(expr string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.GroupByReplace(expr, args...) })
+}
+
+// Union is ExpressionChain.Union on a copy of the chain.
+func (ic *ImmutableChain) Union(unionExpr string, all bool, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Union(unionExpr, all, args...) })
+}
+
+// ForUpdate is ExpressionChain.ForUpdate on a copy of the chain.
+func (ic *ImmutableChain) ForUpdate() *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.ForUpdate() })
+}
+
+// Select is ExpressionChain.Select on a copy of the chain.
+func (ic *ImmutableChain) Select(fields ...string) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Select(fields...) })
+}
+
+// SelectWithArgs is ExpressionChain.SelectWithArgs on a copy of the chain.
+func (ic *ImmutableChain) SelectWithArgs(fields ...SelectArgument) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.SelectWithArgs(fields...) })
+}
+
+// Delete is ExpressionChain.Delete on a copy of the chain.
+func (ic *ImmutableChain) Delete() *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Delete() })
+}
+
+// Insert is ExpressionChain.Insert on a copy of the chain.
+func (ic *ImmutableChain) Insert(insertPairs map[string]interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Insert(insertPairs) })
+}
+
+// Update is ExpressionChain.Update on a copy of the chain.
+func (ic *ImmutableChain) Update(expr string, args ...interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Update(expr, args...) })
+}
+
+// UpdateMap is ExpressionChain.UpdateMap on a copy of the chain.
+func (ic *ImmutableChain) UpdateMap(exprMap map[string]interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.UpdateMap(exprMap) })
+}
+
+// Model is ExpressionChain.Model on a copy of the chain.
+func (ic *ImmutableChain) Model(m interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Model(m) })
+}
+
+// InferTable is ExpressionChain.InferTable on a copy of the chain.
+func (ic *ImmutableChain) InferTable(receiver interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.InferTable(receiver) })
+}
+
+// Preload is ExpressionChain.Preload on a copy of the chain.
+func (ic *ImmutableChain) Preload(relations ...string) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Preload(relations...) })
+}
+
+// Schema is ExpressionChain.Schema on a copy of the chain.
+func (ic *ImmutableChain) Schema(schema string) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Schema(schema) })
+}
+
+// SchemaWithJoins is ExpressionChain.SchemaWithJoins on a copy of the chain.
+func (ic *ImmutableChain) SchemaWithJoins(schema string) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.SchemaWithJoins(schema) })
+}
+
+// InArrayThreshold is ExpressionChain.InArrayThreshold on a copy of the chain.
+func (ic *ImmutableChain) InArrayThreshold(threshold int) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.InArrayThreshold(threshold) })
+}
+
+// InsertMultiCopyThreshold is ExpressionChain.InsertMultiCopyThreshold on a copy of the chain.
+func (ic *ImmutableChain) InsertMultiCopyThreshold(threshold int) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.InsertMultiCopyThreshold(threshold) })
+}
+
+// Unscoped is ExpressionChain.Unscoped on a copy of the chain.
+func (ic *ImmutableChain) Unscoped() *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.Unscoped() })
+}
+
+// InsertStruct is ExpressionChain.InsertStruct on a copy of the chain.
+func (ic *ImmutableChain) InsertStruct(record interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.InsertStruct(record) })
+}
+
+// UpdateStruct is ExpressionChain.UpdateStruct on a copy of the chain.
+func (ic *ImmutableChain) UpdateStruct(record interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.UpdateStruct(record) })
+}
+
+// SelectStruct is ExpressionChain.SelectStruct on a copy of the chain.
+func (ic *ImmutableChain) SelectStruct(receiver interface{}) *ImmutableChain {
+	return ic.derive(func(ec *ExpressionChain) { ec.SelectStruct(receiver) })
+}
+
+// InsertMulti is ExpressionChain.InsertMulti on a copy of the chain.
+func (ic *ImmutableChain) InsertMulti(insertPairs map[string][]interface{}) (*ImmutableChain, error) {
+	ec := ic.Chain()
+	if _, err := ec.InsertMulti(insertPairs); err != nil {
+		return nil, err
+	}
+	return newImmutableChain(ec), nil
+}
+
+// AddUnionFromChain is ExpressionChain.AddUnionFromChain on a copy of the chain.
+func (ic *ImmutableChain) AddUnionFromChain(union *ExpressionChain, all bool) (*ImmutableChain, error) {
+	ec := ic.Chain()
+	if _, err := ec.AddUnionFromChain(union, all); err != nil {
+		return nil, err
+	}
+	return newImmutableChain(ec), nil
+}
@@ -0,0 +1,69 @@
+package chain
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestImmutableChain(t *testing.T) {
+	base := NewNoDB().Select("id").Table("justforfun").AndWhere("tenant = ?", 1).ForUpdate().Immutable()
+	withName := base.AndWhere("name = ?", "a")
+	limited := base.Limit(10)
+
+	for _, c := range []struct {
+		chain *ImmutableChain
+		query string
+		args  []interface{}
+	}{
+		{base, "SELECT id FROM justforfun WHERE tenant = $1 FOR UPDATE", []interface{}{1}},
+		{withName, "SELECT id FROM justforfun WHERE tenant = $1 AND name = $2 FOR UPDATE", []interface{}{1, "a"}},
+		{limited, "SELECT id FROM justforfun WHERE tenant = $1 LIMIT 10 FOR UPDATE", []interface{}{1}},
+	} {
+		q, args, err := c.chain.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != c.query {
+			t.Errorf("expected %q got %q", c.query, q)
+		}
+		if diff := deep.Equal(args, c.args); diff != nil {
+			t.Error(diff)
+		}
+	}
+
+	// changing the chain returned by Chain does not change the template.
+	base.Chain().AndWhere("deleted = false")
+	if q, _, _ := base.Render(); q != "SELECT id FROM justforfun WHERE tenant = $1 FOR UPDATE" {
+		t.Errorf("expected the template not to change got %q", q)
+	}
+
+	conflicted := NewNoDB().Table("justforfun").Insert(map[string]interface{}{"id": 1}).Immutable().
+		OnConflict(func(c *OnConflict) { c.DoNothing() })
+	if !conflicted.OnConflict(func(c *OnConflict) { c.DoNothing() }).Chain().hasErr() {
+		t.Errorf("expected the copies to keep the conflict clause and errors")
+	}
+}
+
+func TestImmutableChainConcurrent(t *testing.T) {
+	base := NewNoDB().Select("id").Table("justforfun").With("recent", NewNoDB().Select("id").Table("recent")).
+		Immutable()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			q, _, err := base.AndWhere(fmt.Sprintf("id > %d", i)).Render()
+			if err != nil {
+				t.Error(err)
+			}
+			expected := fmt.Sprintf("WITH recent AS (SELECT id FROM recent) SELECT id FROM justforfun WHERE id > %d", i)
+			if q != expected {
+				t.Errorf("expected %q got %q", expected, q)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,144 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/pkg/errors"
+)
+
+// IndexStatement builds a CREATE INDEX statement.
+type IndexStatement struct {
+	name         string
+	table        string
+	columns      []string
+	unique       bool
+	concurrently bool
+	ifNotExists  bool
+	method       string
+	include      []string
+	where        string
+}
+
+// CreateIndex returns an IndexStatement for an index called name, On must be called to say
+// on what.
+func CreateIndex(name string) *IndexStatement {
+	return &IndexStatement{name: name}
+}
+
+// On sets the table and the columns, or expressions such as "lower(email)", indexed.
+func (i *IndexStatement) On(table string, columns ...string) *IndexStatement {
+	i.table = table
+	i.columns = columns
+	return i
+}
+
+// Unique makes it a unique index.
+func (i *IndexStatement) Unique() *IndexStatement {
+	i.unique = true
+	return i
+}
+
+// Concurrently builds the index without locking out writes to the table, which takes longer and
+// can't be done in a transaction, Exec fails if asked to.
+func (i *IndexStatement) Concurrently() *IndexStatement {
+	i.concurrently = true
+	return i
+}
+
+// IfNotExists makes the statement do nothing if there is an index with the same name.
+func (i *IndexStatement) IfNotExists() *IndexStatement {
+	i.ifNotExists = true
+	return i
+}
+
+// Using sets the index method, ie "gin" or "brin", the default is btree.
+func (i *IndexStatement) Using(method string) *IndexStatement {
+	i.method = method
+	return i
+}
+
+// Include adds non key columns to the index so queries that only need those can be answered
+// by it.
+func (i *IndexStatement) Include(columns ...string) *IndexStatement {
+	i.include = columns
+	return i
+}
+
+// Where makes it a partial index of the rows that satisfy predicate, which is written as it is,
+// DDL can't take arguments.
+func (i *IndexStatement) Where(predicate string) *IndexStatement {
+	i.where = predicate
+	return i
+}
+
+// Render returns the statement or an error if it lacks the table or columns.
+func (i *IndexStatement) Render() (string, error) {
+	if i.table == "" || len(i.columns) == 0 {
+		return "", errors.Errorf("index %s needs a table and columns, use On", i.name)
+	}
+	statement := &strings.Builder{}
+	statement.WriteString("CREATE ")
+	if i.unique {
+		statement.WriteString("UNIQUE ")
+	}
+	statement.WriteString("INDEX ")
+	if i.concurrently {
+		statement.WriteString("CONCURRENTLY ")
+	}
+	if i.ifNotExists {
+		statement.WriteString("IF NOT EXISTS ")
+	}
+	if i.name != "" {
+		statement.WriteString(i.name + " ")
+	}
+	statement.WriteString("ON " + i.table)
+	if i.method != "" {
+		statement.WriteString(" USING " + i.method)
+	}
+	statement.WriteString(" (" + strings.Join(i.columns, ", ") + ")")
+	if len(i.include) > 0 {
+		statement.WriteString(" INCLUDE (" + strings.Join(i.include, ", ") + ")")
+	}
+	if i.where != "" {
+		statement.WriteString(" WHERE " + i.where)
+	}
+	return statement.String(), nil
+}
+
+// String returns the rendered statement or the error if it can't be rendered.
+func (i *IndexStatement) String() string {
+	statement, err := i.Render()
+	if err != nil {
+		return "invalid index, err: " + err.Error()
+	}
+	return statement
+}
+
+// Exec creates the index in db. It fails for a concurrent index if db is a transaction,
+// postgres would refuse it anyway.
+func (i *IndexStatement) Exec(ctx context.Context, db connection.DB) error {
+	statement, err := i.Render()
+	if err != nil {
+		return err
+	}
+	if i.concurrently && db.IsTransaction() {
+		return errors.Errorf("index %s can't be created concurrently in a transaction", i.name)
+	}
+	return errors.Wrapf(db.Exec(ctx, statement), "creating index %s", i.name)
+}
@@ -0,0 +1,78 @@
+package chain
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+)
+
+// txDB is a DB that records the statements it runs and can pretend to be a transaction.
+type txDB struct {
+	connection.DB
+	tx         bool
+	statements []string
+}
+
+func (d *txDB) IsTransaction() bool {
+	return d.tx
+}
+
+func (d *txDB) Exec(ctx context.Context, statement string, args ...interface{}) error {
+	d.statements = append(d.statements, statement)
+	return nil
+}
+
+func TestCreateIndex(t *testing.T) {
+	tests := []struct {
+		index *IndexStatement
+		want  string
+	}{
+		{
+			index: CreateIndex("users_email_idx").On("users", "email"),
+			want:  "CREATE INDEX users_email_idx ON users (email)",
+		},
+		{
+			index: CreateIndex("users_active_email_key").On("users", "lower(email)").Unique().
+				Where("deleted_at IS NULL").Concurrently().IfNotExists(),
+			want: "CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS users_active_email_key ON users (lower(email)) " +
+				"WHERE deleted_at IS NULL",
+		},
+		{
+			index: CreateIndex("").On("events", "payload").Using("gin").Include("id"),
+			want:  "CREATE INDEX ON events USING gin (payload) INCLUDE (id)",
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.index.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("expected:\n%s\ngot:\n%s", tt.want, got)
+		}
+	}
+	if _, err := CreateIndex("nothing").Render(); err == nil {
+		t.Errorf("expected an error for an index without table")
+	}
+}
+
+func TestCreateIndexConcurrentlyInTransaction(t *testing.T) {
+	index := CreateIndex("users_email_idx").On("users", "email").Concurrently()
+	db := &txDB{tx: true}
+	err := index.Exec(context.Background(), db)
+	if err == nil || !strings.Contains(err.Error(), "transaction") {
+		t.Errorf("expected an error creating an index concurrently in a transaction got %v", err)
+	}
+	if len(db.statements) != 0 {
+		t.Errorf("expected nothing to run got %v", db.statements)
+	}
+	db.tx = false
+	if err := index.Exec(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.statements) != 1 || db.statements[0] != "CREATE INDEX CONCURRENTLY users_email_idx ON users (email)" {
+		t.Errorf("unexpected statements %v", db.statements)
+	}
+}
@@ -0,0 +1,91 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/pkg/errors"
+)
+
+// TableLikeStatement builds a CREATE TABLE ... (LIKE ...) statement that clones the structure of
+// an existing table, optionally followed by the copy of its rows.
+type TableLikeStatement struct {
+	table        string
+	source       string
+	includingAll bool
+	ifNotExists  bool
+	withData     bool
+}
+
+// CreateTableLike returns a TableLikeStatement for a table called name with the columns of
+// source, if includingAll is true defaults, constraints, indexes, identities, generated columns,
+// storage settings, comments and statistics are copied too, otherwise only the columns and their
+// NOT NULL constraints are.
+func CreateTableLike(name, source string, includingAll bool) *TableLikeStatement {
+	return &TableLikeStatement{table: name, source: source, includingAll: includingAll}
+}
+
+// IfNotExists makes the CREATE TABLE do nothing if the table exists, the rows are still copied
+// if WithData was called.
+func (l *TableLikeStatement) IfNotExists() *TableLikeStatement {
+	l.ifNotExists = true
+	return l
+}
+
+// WithData copies the rows of source into the new table with an INSERT ... SELECT, which fails
+// for tables with generated columns, they can't be written.
+func (l *TableLikeStatement) WithData() *TableLikeStatement {
+	l.withData = true
+	return l
+}
+
+// Statements returns the CREATE TABLE followed, if WithData was called, by the INSERT.
+func (l *TableLikeStatement) Statements() []string {
+	ifNotExists := ""
+	if l.ifNotExists {
+		ifNotExists = "IF NOT EXISTS "
+	}
+	including := ""
+	if l.includingAll {
+		including = " INCLUDING ALL"
+	}
+	statements := []string{"CREATE TABLE " + ifNotExists + l.table + " (LIKE " + l.source + including + ")"}
+	if l.withData {
+		// identities are copied by INCLUDING ALL, the values must be kept.
+		statements = append(statements,
+			"INSERT INTO "+l.table+" OVERRIDING SYSTEM VALUE SELECT * FROM "+l.source)
+	}
+	return statements
+}
+
+// String returns the statements separated by ";\n".
+func (l *TableLikeStatement) String() string {
+	return strings.Join(l.Statements(), ";\n")
+}
+
+// Exec runs the statements in db, in a transaction so the table is not left half copied.
+func (l *TableLikeStatement) Exec(ctx context.Context, db connection.DB) error {
+	return connection.WithTransaction(ctx, db, func(tx connection.DB) error {
+		for _, statement := range l.Statements() {
+			if err := tx.Exec(ctx, statement); err != nil {
+				return errors.Wrapf(err, "creating table %s like %s", l.table, l.source)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,40 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestCreateTableLike(t *testing.T) {
+	tests := []struct {
+		like *TableLikeStatement
+		want []string
+	}{
+		{
+			like: CreateTableLike("users_next", "users", false),
+			want: []string{"CREATE TABLE users_next (LIKE users)"},
+		},
+		{
+			like: CreateTableLike("users_next", "users", true).IfNotExists().WithData(),
+			want: []string{
+				"CREATE TABLE IF NOT EXISTS users_next (LIKE users INCLUDING ALL)",
+				"INSERT INTO users_next OVERRIDING SYSTEM VALUE SELECT * FROM users",
+			},
+		},
+	}
+	for _, tt := range tests {
+		if diff := deep.Equal(tt.like.Statements(), tt.want); diff != nil {
+			t.Error(diff)
+		}
+	}
+
+	db := &txDB{tx: true}
+	if err := CreateTableLike("users_next", "users", true).WithData().Exec(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.statements) != 2 {
+		t.Errorf("expected the table to be created and filled got %v", db.statements)
+	}
+}
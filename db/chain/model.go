@@ -0,0 +1,63 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/pkg/errors"
+)
+
+// Model sets the table to the one m, a struct registered with model.Register, a pointer to one
+// or a slice of either, is stored in, the tags of m then apply to the chain (ie, autoupdate
+// columns are set by UpdateMap).
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) Model(m interface{}) *ExpressionChain {
+	table, err := model.TableOf(m)
+	if err != nil {
+		ec.err = append(ec.err, errors.Wrap(err, "setting the table from a model"))
+		return ec
+	}
+	ec.setTable(table)
+	ec.modelType = reflect.TypeOf(m)
+	return ec
+}
+
+// InferTable sets the table to the one receiver, the destination of the query, is stored in if
+// no table was set and its type is a registered model, otherwise it does nothing. Fetch calls
+// it so the table can be left out when fetching into registered models.
+// If the table, or the one aliased, is the one of the model its tags apply to the chain as in
+// Model.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) InferTable(receiver interface{}) *ExpressionChain {
+	m, ok := model.Lookup(receiver)
+	if !ok {
+		return ec
+	}
+	if ec.table == "" {
+		ec.setTable(m.Table)
+	}
+	if fields := strings.Fields(ec.table); len(fields) != 0 && fields[0] == m.Table {
+		ec.modelType = m.Type
+	}
+	return ec
+}
+
+// columnFields returns the fields of modelType that map to columns, see model.ColumnFields.
+func columnFields(modelType reflect.Type) []reflect.StructField {
+	return model.ColumnFields(modelType)
+}
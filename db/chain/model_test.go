@@ -0,0 +1,57 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+)
+
+type registeredUser struct {
+	ID   int `gaum:"field_name:id"`
+	Name string
+}
+
+func TestModel(t *testing.T) {
+	if err := model.Register(&registeredUser{}, model.Table("users")); err != nil {
+		t.Fatal(err)
+	}
+	defer model.Unregister(&registeredUser{})
+
+	q, _, err := NewNoDB().Select("*").Model(&registeredUser{}).AndWhere("id = ?", 1).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != "SELECT * FROM users WHERE id = $1" {
+		t.Errorf("expected the table of the model got %q", q)
+	}
+	if !NewNoDB().Select("*").Model(1).hasErr() {
+		t.Errorf("expected an error for an unregistered model")
+	}
+
+	var users []registeredUser
+	q, _, err = NewNoDB().SelectStruct(&users).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != "SELECT id, name FROM users" {
+		t.Errorf("expected the columns of the model got %q", q)
+	}
+	if !NewNoDB().SelectStruct(1).hasErr() {
+		t.Errorf("expected an error selecting the columns of something that is not a struct")
+	}
+
+	q, _, err = NewNoDB().Select("*").InferTable(&users).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != "SELECT * FROM users" {
+		t.Errorf("expected the table to be inferred got %q", q)
+	}
+	q, _, err = NewNoDB().Select("*").Table("admins").InferTable(&users).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != "SELECT * FROM admins" {
+		t.Errorf("expected the table set to be kept got %q", q)
+	}
+}
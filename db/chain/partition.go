@@ -0,0 +1,157 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/pkg/errors"
+)
+
+// MinValue and MaxValue can be passed as range partition bounds to leave them open.
+const (
+	MinValue = rawBound("MINVALUE")
+	MaxValue = rawBound("MAXVALUE")
+)
+
+// rawBound is a bound written as it is, not as a literal.
+type rawBound string
+
+// PartitionStatement builds a CREATE TABLE ... PARTITION OF statement.
+type PartitionStatement struct {
+	parent      string
+	name        string
+	bounds      string
+	ifNotExists bool
+}
+
+// CreatePartition returns a PartitionStatement for the partition name of parent, which is
+// partitioned by RANGE, holding the rows from from (inclusive) to to (exclusive).
+func CreatePartition(parent, name string, from, to interface{}) *PartitionStatement {
+	return &PartitionStatement{parent: parent, name: name, bounds: rangeBounds(from, to)}
+}
+
+// CreateListPartition returns a PartitionStatement for the partition name of parent, which is
+// partitioned by LIST, holding the rows with the passed values.
+func CreateListPartition(parent, name string, values ...interface{}) *PartitionStatement {
+	return &PartitionStatement{parent: parent, name: name, bounds: listBounds(values)}
+}
+
+// CreateDefaultPartition returns a PartitionStatement for the partition name of parent holding
+// the rows no other partition takes.
+func CreateDefaultPartition(parent, name string) *PartitionStatement {
+	return &PartitionStatement{parent: parent, name: name, bounds: "DEFAULT"}
+}
+
+// CreateMonthlyPartition is CreatePartition for the month of month, in its location, named
+// <parent>_<year>_<month>, ie events_2020_01.
+func CreateMonthlyPartition(parent string, month time.Time) *PartitionStatement {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	return CreatePartition(parent, MonthlyPartitionName(parent, month), from, from.AddDate(0, 1, 0))
+}
+
+// MonthlyPartitionName returns the name CreateMonthlyPartition gives to the partition of parent
+// for month.
+func MonthlyPartitionName(parent string, month time.Time) string {
+	return fmt.Sprintf("%s_%04d_%02d", parent, month.Year(), month.Month())
+}
+
+// IfNotExists makes the statement do nothing if the partition exists.
+func (p *PartitionStatement) IfNotExists() *PartitionStatement {
+	p.ifNotExists = true
+	return p
+}
+
+// Render returns the statement.
+func (p *PartitionStatement) Render() (string, error) {
+	if p.parent == "" || p.name == "" {
+		return "", errors.Errorf("partitions need a parent and a name")
+	}
+	ifNotExists := ""
+	if p.ifNotExists {
+		ifNotExists = "IF NOT EXISTS "
+	}
+	return "CREATE TABLE " + ifNotExists + p.name + " PARTITION OF " + p.parent + " " + forValues(p.bounds), nil
+}
+
+// String returns the rendered statement or the error if it can't be rendered.
+func (p *PartitionStatement) String() string {
+	statement, err := p.Render()
+	if err != nil {
+		return "invalid partition, err: " + err.Error()
+	}
+	return statement
+}
+
+// Exec creates the partition in db.
+func (p *PartitionStatement) Exec(ctx context.Context, db connection.DB) error {
+	statement, err := p.Render()
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(db.Exec(ctx, statement), "creating partition %s", p.name)
+}
+
+// AttachPartition returns the ALTER TABLE that makes the existing table name the partition of
+// parent, partitioned by RANGE, for the rows from from (inclusive) to to (exclusive).
+func AttachPartition(parent, name string, from, to interface{}) *AlterTableStatement {
+	return AlterTable(parent).action("ATTACH PARTITION " + name + " " + forValues(rangeBounds(from, to)))
+}
+
+// AttachListPartition is AttachPartition for tables partitioned by LIST.
+func AttachListPartition(parent, name string, values ...interface{}) *AlterTableStatement {
+	return AlterTable(parent).action("ATTACH PARTITION " + name + " " + forValues(listBounds(values)))
+}
+
+// DetachPartition returns the ALTER TABLE that makes the partition name of parent a table on
+// its own. Concurrently does it without blocking queries to parent, which requires postgres 14
+// and can't be done in a transaction.
+func DetachPartition(parent, name string, concurrently bool) *AlterTableStatement {
+	action := "DETACH PARTITION " + name
+	if concurrently {
+		action += " CONCURRENTLY"
+	}
+	return AlterTable(parent).action(action)
+}
+
+func rangeBounds(from, to interface{}) string {
+	return "FROM (" + boundLiteral(from) + ") TO (" + boundLiteral(to) + ")"
+}
+
+func listBounds(values []interface{}) string {
+	literals := make([]string, len(values))
+	for i := range values {
+		literals[i] = boundLiteral(values[i])
+	}
+	return "IN (" + strings.Join(literals, ", ") + ")"
+}
+
+func forValues(bounds string) string {
+	if bounds == "DEFAULT" {
+		return bounds
+	}
+	return "FOR VALUES " + bounds
+}
+
+func boundLiteral(value interface{}) string {
+	if raw, ok := value.(rawBound); ok {
+		return string(raw)
+	}
+	return literal(value, 0)
+}
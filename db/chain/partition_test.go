@@ -0,0 +1,55 @@
+package chain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPartitions(t *testing.T) {
+	month := time.Date(2020, 12, 15, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		statement fmt.Stringer
+		want      string
+	}{
+		{
+			statement: CreateTable("events").Column("id", "bigint").Column("created", "timestamptz").
+				PartitionBy("RANGE", "created"),
+			want: "CREATE TABLE events (id bigint, created timestamptz) PARTITION BY RANGE (created)",
+		},
+		{
+			statement: CreateMonthlyPartition("events", month).IfNotExists(),
+			want: "CREATE TABLE IF NOT EXISTS events_2020_12 PARTITION OF events FOR VALUES " +
+				"FROM ('2020-12-01T00:00:00Z') TO ('2021-01-01T00:00:00Z')",
+		},
+		{
+			statement: CreatePartition("scores", "scores_low", MinValue, 10),
+			want:      "CREATE TABLE scores_low PARTITION OF scores FOR VALUES FROM (MINVALUE) TO (10)",
+		},
+		{
+			statement: CreateListPartition("users", "users_eu", "de", "fr"),
+			want:      "CREATE TABLE users_eu PARTITION OF users FOR VALUES IN ('de', 'fr')",
+		},
+		{
+			statement: CreateDefaultPartition("users", "users_other"),
+			want:      "CREATE TABLE users_other PARTITION OF users DEFAULT",
+		},
+		{
+			statement: AttachPartition("scores", "scores_high", 10, MaxValue),
+			want:      "ALTER TABLE scores ATTACH PARTITION scores_high FOR VALUES FROM (10) TO (MAXVALUE)",
+		},
+		{
+			statement: AttachListPartition("users", "users_us", "us"),
+			want:      "ALTER TABLE users ATTACH PARTITION users_us FOR VALUES IN ('us')",
+		},
+		{
+			statement: DetachPartition("events", "events_2020_12", true),
+			want:      "ALTER TABLE events DETACH PARTITION events_2020_12 CONCURRENTLY",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.statement.String(); got != tt.want {
+			t.Errorf("expected:\n%s\ngot:\n%s", tt.want, got)
+		}
+	}
+}
@@ -1,189 +1,235 @@
 package chain
 
 import (
+	"bytes"
 	"math"
 	"reflect"
 	"strconv"
 	"strings"
 
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/ShiftLeftSecurity/gaum/v2/selectparse"
 	"github.com/pkg/errors"
 )
 
-// ExpandArgs will unravel a slice of arguments, converting slices into individual items
-// to determine if an item needs unraveling it uses the placeholders (? marks) for the
-// future positional arguments in a query segment.
-func ExpandArgs(args []interface{}, querySegment string) (string, []interface{}) {
-	expandedArgs := []interface{}{}
-	newQuery := &strings.Builder{}
-	var argPosition = 0
-	skip := false
-	for i, queryChar := range querySegment {
-		if skip {
-			skip = false
-			continue
-		}
+// queryWriter is what chains are rendered into, expressions are written along with their
+// arguments so the ? marks are expanded and, unless raw, numbered as positional arguments in the
+// same pass.
+type queryWriter struct {
+	*bytes.Buffer
+	// raw keeps the ? marks, and the escaped ones, as they are.
+	raw bool
+	// verbatim keeps the arguments as they are, for fragments that are rendered to be part of
+	// another chain, which will expand them when rendered.
+	verbatim bool
+	// arrays passes slices as they are, for DBs that bind them as arrays.
+	arrays bool
+	args   []interface{}
+	// marks is the amount of placeholders written, more than args if some lacked an argument.
+	marks int
+	// inArrayThreshold is the size above which IN lists are bound as arrays, see
+	// ExpressionChain.InArrayThreshold.
+	inArrayThreshold int
+	scratch          [20]byte
+}
 
-		if queryChar == '\\' && i < len(querySegment)-1 && querySegment[i+1] == '?' {
-			// Escaped '?'
-			newQuery.WriteString("\\?")
-			skip = true
-			continue
+// writeExpression writes expr replacing each of its ? marks by the placeholder for the next of
+// args, a nil argument is written as NULL and a slice, other than []byte or one wrapped by
+// Array, as one placeholder per item. Marks without an argument are written anyway for
+// checkArgs to complain about.
+func (w *queryWriter) writeExpression(expr string, args []interface{}) {
+	if len(args) == 0 && strings.IndexByte(expr, '?') == -1 {
+		w.WriteString(expr)
+		return
+	}
+	if w.inArrayThreshold > 0 && !w.verbatim {
+		w.writeExpressionArrays(expr, args)
+		return
+	}
+	position := 0
+	selectparse.EachToken(expr, func(token selectparse.Token) {
+		switch token.Kind {
+		case selectparse.EscapedPlaceholder:
+			if w.raw {
+				w.WriteString(token.Text)
+			} else {
+				w.WriteByte('?')
+			}
+		case selectparse.Placeholder:
+			if position >= len(args) {
+				w.writeMark()
+				return
+			}
+			w.writeArg(args[position])
+			position++
+		default:
+			w.WriteString(token.Text)
 		}
+	})
+}
 
-		if queryChar == '?' {
-			arg := args[argPosition]
-			if arg == nil {
-				// nil pointer is considered NULL and this must be part of the query string to avoid
-				// being escaped as the string "NULL"
-				argPosition++
-				newQuery.WriteString("NULL")
+// writeExpressionArrays is writeExpression for chains with an InArrayThreshold, the `IN (?)`
+// lists with more items than it are written as `= ANY(?)`.
+func (w *queryWriter) writeExpressionArrays(expr string, args []interface{}) {
+	position := 0
+	tokens := selectparse.Tokenize(expr)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch token.Kind {
+		case selectparse.EscapedPlaceholder:
+			if w.raw {
+				w.WriteString(token.Text)
+			} else {
+				w.WriteByte('?')
+			}
+		case selectparse.Placeholder:
+			if position >= len(args) {
+				w.writeMark()
 				continue
 			}
-			// If this is a supported slice we will expand it
-			switch reflect.TypeOf(arg).Kind() {
-			case reflect.Slice:
-				elementType := reflect.TypeOf(arg).Elem().Kind()
-				// So, if I recall correctly this avoids converting []byte into individual
-				// byte arguments and passes it as one to most likely a bytea pg type
-				if elementType != reflect.Int8 && elementType != reflect.Uint8 {
-					s := reflect.ValueOf(arg)
-					for i := 0; i < s.Len(); i++ {
-						newQuery.WriteRune('?')
-						if i != s.Len()-1 {
-							newQuery.WriteString(", ")
-						}
-						expandedArgs = append(expandedArgs, s.Index(i).Interface())
+			w.writeArg(args[position])
+			position++
+		case selectparse.Word:
+			if end, negated, ok := inListAt(tokens, i); ok && position < len(args) {
+				if list, over := inArrayList(args[position], w.inArrayThreshold); over {
+					if negated {
+						w.WriteString("<> ALL(")
+					} else {
+						w.WriteString("= ANY(")
 					}
-				} else {
-					newQuery.WriteRune('?')
-					expandedArgs = append(expandedArgs, arg)
+					w.writeValue(list)
+					w.WriteByte(')')
+					position++
+					i = end
+					continue
 				}
-			default:
-				newQuery.WriteRune('?')
-				expandedArgs = append(expandedArgs, arg)
 			}
-			argPosition++
-			continue
+			w.WriteString(token.Text)
+		default:
+			w.WriteString(token.Text)
 		}
-		newQuery.WriteRune(queryChar)
+	}
+}
 
+// writeArg writes the placeholders for arg, expanded as writeExpression does.
+func (w *queryWriter) writeArg(arg interface{}) {
+	if w.verbatim {
+		w.writeValue(arg)
+		return
+	}
+	if arg == nil {
+		// nil pointer is considered NULL and this must be part of the query string to avoid
+		// being escaped as the string "NULL"
+		w.WriteString("NULL")
+		return
+	}
+	if array, ok := arg.(ArrayArgument); ok {
+		w.writeValue(array.Value)
+		return
+	}
+	// So, if I recall correctly this avoids converting []byte into individual
+	// byte arguments and passes it as one to most likely a bytea pg type
+	argType := reflect.TypeOf(arg)
+	if w.arrays || argType.Kind() != reflect.Slice ||
+		argType.Elem().Kind() == reflect.Int8 || argType.Elem().Kind() == reflect.Uint8 {
+		w.writeValue(arg)
+		return
+	}
+	s := reflect.ValueOf(arg)
+	for i := 0; i < s.Len(); i++ {
+		if i != 0 {
+			w.WriteString(", ")
+		}
+		item := s.Index(i).Interface()
+		// In passes its variadic values as a slice, the array would be in it.
+		if array, ok := item.(ArrayArgument); ok {
+			item = array.Value
+		}
+		w.writeValue(item)
 	}
-	return newQuery.String(), expandedArgs
 }
 
-// MarksToPlaceholders replaces `?` in the query with `$1` style placeholders, this must be
-// done with a finished query and requires the args as they depend on the position of the
-// already rendered query, it does some consistency control and finally expands `(?)`.
-func MarksToPlaceholders(q string, args []interface{}) (string, []interface{}, error) {
+// writeValue writes a single placeholder for arg, whatever its type.
+func (w *queryWriter) writeValue(arg interface{}) {
+	w.writeMark()
+	w.args = append(w.args, arg)
+}
 
-	// assume a nil pointer is a null
-	// this is hacky, but it should work
-	otherArgs := make([]interface{}, len(args))
-	for index, arg := range args {
-		if arg == nil {
-			otherArgs[index] = "NULL"
-		} else {
-			otherArgs[index] = arg
-		}
+func (w *queryWriter) writeMark() {
+	w.marks++
+	if w.raw {
+		w.WriteByte('?')
+		return
 	}
-	args = otherArgs
+	w.WriteByte('$')
+	w.Write(strconv.AppendInt(w.scratch[:0], int64(w.marks), 10))
+}
 
-	// TODO: make this a bit less ugly
-	// TODO: use an actual parser <3
-	// TODO: structure query segments around SQL-Standard AST
-	queryWithArgs := &strings.Builder{}
-	argCounter := 1
-	argPositioner := 0
-	expandedArgs := []interface{}{}
-	skip := false
-	for i, queryChar := range q {
-		if skip {
-			skip = false
-			continue
-		}
+// checkArgs returns an error if any placeholder was written without an argument.
+func (w *queryWriter) checkArgs() error {
+	if w.marks != len(w.args) {
+		return errors.Errorf("the query has %d args but %d were passed: \n %q \n %#v",
+			w.marks, len(w.args), w.String(), w.args)
+	}
+	return nil
+}
 
-		if queryChar == '\\' && i < len(q)-1 && q[i+1] == '?' {
-			// Escaped '?'
-			queryWithArgs.WriteRune('?')
-			skip = true
-			continue
-		}
-		if queryChar == '?' {
-			arg := args[argPositioner]
-			switch reflect.TypeOf(arg).Kind() {
-			case reflect.Slice:
-				elementType := reflect.TypeOf(arg).Elem().Kind()
-				if elementType != reflect.Int8 && elementType != reflect.Uint8 {
-					s := reflect.ValueOf(arg)
-					for i := 0; i < s.Len(); i++ {
-						expandedArgs = append(expandedArgs, s.Index(i).Interface())
-						queryWithArgs.WriteRune('$')
-						queryWithArgs.WriteString(strconv.Itoa(argCounter))
-						if i != s.Len()-1 {
-							queryWithArgs.WriteString(", ")
-						}
-						argCounter++
-					}
-				} else {
-					expandedArgs = append(expandedArgs, arg)
-					queryWithArgs.WriteRune('$')
-					queryWithArgs.WriteString(strconv.Itoa(argCounter))
-					argCounter++
-				}
-			default:
-				expandedArgs = append(expandedArgs, arg)
-				queryWithArgs.WriteRune('$')
-				queryWithArgs.WriteString(strconv.Itoa(argCounter))
-				argCounter++
-			}
-			argPositioner++
-		} else {
-			queryWithArgs.WriteRune(queryChar)
-		}
+// MarksToPlaceholdersFor is MarksToPlaceholders for a query to run in db, slice arguments are
+// left as they are if db binds them as arrays (see connection.Information.SlicesAsArrays).
+func MarksToPlaceholdersFor(db connection.DB, q string, args []interface{}) (string, []interface{}, error) {
+	if !connection.BindsSlicesAsArrays(db) {
+		return MarksToPlaceholders(q, args)
 	}
-	if len(expandedArgs) != argCounter-1 {
-		return "", nil, errors.Errorf("the query has %d args but %d were passed: \n %q \n %#v",
-			argCounter-1, len(args), queryWithArgs, args)
+	w := &queryWriter{Buffer: &bytes.Buffer{}, arrays: true, args: []interface{}{}}
+	w.writeExpression(q, args)
+	if err := w.checkArgs(); err != nil {
+		return "", nil, errors.Wrap(err, "the query has more placeholders than the args passed")
 	}
-	return queryWithArgs.String(), expandedArgs, nil
+	return w.String(), w.args, nil
+}
+
+// ExpandArgs will unravel a slice of arguments, converting slices into individual items
+// to determine if an item needs unraveling it uses the placeholders (? marks) for the
+// future positional arguments in a query segment.
+// Chains do this when rendered, this is for queries that are built by hand.
+func ExpandArgs(args []interface{}, querySegment string) (string, []interface{}) {
+	w := &queryWriter{Buffer: &bytes.Buffer{}, raw: true, args: []interface{}{}}
+	w.writeExpression(querySegment, args)
+	return w.String(), w.args
+}
+
+// MarksToPlaceholders replaces `?` in the query with `$1` style placeholders, this must be
+// done with a finished query and requires the args as they depend on the position of the
+// already rendered query, arguments are expanded as ExpandArgs does.
+func MarksToPlaceholders(q string, args []interface{}) (string, []interface{}, error) {
+	w := &queryWriter{Buffer: &bytes.Buffer{}, args: []interface{}{}}
+	w.Grow(len(q) + digitSize(len(args)))
+	w.writeExpression(q, args)
+	if err := w.checkArgs(); err != nil {
+		return "", nil, errors.Wrap(err, "the query has more placeholders than the args passed")
+	}
+	return w.String(), w.args, nil
 }
 
 // PlaceholdersToPositional converts ? in a query into $<argument number> which postgres expects
 func PlaceholdersToPositional(q *strings.Builder, argCount int) (*strings.Builder, int, error) {
-	// TODO: use an actual parser <3
-	// TODO: structure query segments around SQL-Standard AST
 	newQ := &strings.Builder{}
 	// new string should accommodate the digits we are adding for positional arguments.
-	renderedLength := q.Len() + digitSize(argCount)
-	if newQ.Len() < renderedLength {
-		newQ.Grow(renderedLength - newQ.Len())
-	}
-
-	queryString := q.String()
+	newQ.Grow(q.Len() + digitSize(argCount))
+	// TODO: structure query segments around SQL-Standard AST
 	argCounter := 1
-	skip := false
-	for i, queryChar := range queryString {
-		if skip {
-			skip = false
-			continue
-		}
-
-		if queryChar == '\\' && i < len(queryString)-1 && queryString[i+1] == '?' {
-			// Escaped '?'
-			newQ.WriteRune('?')
-			skip = true
-			continue
-		}
-
-		if queryChar == '?' {
-			newQ.WriteRune('$')
+	selectparse.EachToken(q.String(), func(token selectparse.Token) {
+		switch token.Kind {
+		case selectparse.EscapedPlaceholder:
+			newQ.WriteByte('?')
+		case selectparse.Placeholder:
+			newQ.WriteByte('$')
 			newQ.WriteString(strconv.Itoa(argCounter))
 			argCounter++
-			continue
+		default:
+			newQ.WriteString(token.Text)
 		}
-		newQ.WriteRune(queryChar)
-	}
-
+	})
 	return newQ, argCounter - 1, nil
 }
 
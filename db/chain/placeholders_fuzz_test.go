@@ -0,0 +1,122 @@
+//go:build go1.18
+// +build go1.18
+
+package chain
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/selectparse"
+)
+
+// placeholderSeeds are the queries the fuzz targets start from, the cases that bit us before:
+// escaped marks, jsonb operators, marks in literals and comments and multibyte runes.
+var placeholderSeeds = []string{
+	"SELECT * FROM t WHERE id = ?",
+	"id IN (?) AND name = ?",
+	`data \? 'key' AND id = ?`,
+	"data ?| array['a', 'b'] AND data ?& ?",
+	"name = '?' AND note = ? -- is it?",
+	`"weird?column" = ? /* ? */`,
+	"név = ? AND 名前 = ?",
+	`\\?`,
+	`ends with \`,
+	"$1 = ? AND ?::text = $2",
+}
+
+// fuzzArgs builds n arguments mixing the kinds that the functions treat differently: slices,
+// which get expanded, byte slices, which don't, nils and plain values.
+func fuzzArgs(n, sliceLen uint8, withNil bool) []interface{} {
+	args := make([]interface{}, int(n%16))
+	for i := range args {
+		switch i % 4 {
+		case 0:
+			args[i] = make([]int, int(sliceLen%8))
+		case 1:
+			if withNil {
+				continue
+			}
+			args[i] = "value"
+		case 2:
+			args[i] = []byte("bytes")
+		default:
+			args[i] = i
+		}
+	}
+	return args
+}
+
+func countKind(q string, kind selectparse.TokenKind) int {
+	count := 0
+	for _, token := range selectparse.Tokenize(q) {
+		if token.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func FuzzExpandArgs(f *testing.F) {
+	for _, seed := range placeholderSeeds {
+		f.Add(seed, uint8(2), uint8(3), false)
+	}
+	f.Fuzz(func(t *testing.T, q string, n, sliceLen uint8, withNil bool) {
+		args := fuzzArgs(n, sliceLen, withNil)
+		expanded, expandedArgs := ExpandArgs(args, q)
+		if utf8.ValidString(q) && !utf8.ValidString(expanded) {
+			t.Fatalf("valid query %q expanded into invalid UTF-8 %q", q, expanded)
+		}
+		if countKind(q, selectparse.Placeholder) == 0 && expanded != q {
+			t.Fatalf("query without placeholders %q changed into %q", q, expanded)
+		}
+		// each placeholder becomes one mark per argument it expands to, or NULL, the rest of
+		// the query is left as it was.
+		placeholders := countKind(q, selectparse.Placeholder)
+		if placeholders <= len(args) &&
+			strings.Count(expanded, "?")-(strings.Count(q, "?")-placeholders) != len(expandedArgs) {
+			t.Fatalf("%q has a different number of marks than the %d args in %#v", expanded, len(expandedArgs), expandedArgs)
+		}
+	})
+}
+
+func FuzzMarksToPlaceholders(f *testing.F) {
+	for _, seed := range placeholderSeeds {
+		f.Add(seed, uint8(2), uint8(3), true)
+	}
+	f.Fuzz(func(t *testing.T, q string, n, sliceLen uint8, withNil bool) {
+		args := fuzzArgs(n, sliceLen, withNil)
+		rendered, renderedArgs, err := MarksToPlaceholders(q, args)
+		if err != nil {
+			return
+		}
+		if utf8.ValidString(q) && !utf8.ValidString(rendered) {
+			t.Fatalf("valid query %q rendered into invalid UTF-8 %q", q, rendered)
+		}
+		// each argument gets a $ of its own, the rest of the query is left as it was.
+		if strings.Count(rendered, "$")-strings.Count(q, "$") != len(renderedArgs) {
+			t.Fatalf("%q has a different number of placeholders than the %d args in %#v", rendered, len(renderedArgs), renderedArgs)
+		}
+	})
+}
+
+func FuzzPlaceholdersToPositional(f *testing.F) {
+	for _, seed := range placeholderSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, q string) {
+		b := &strings.Builder{}
+		b.WriteString(q)
+		rendered, count, err := PlaceholdersToPositional(b, strings.Count(q, "?"))
+		if err != nil {
+			return
+		}
+		if want := countKind(q, selectparse.Placeholder); count != want {
+			t.Fatalf("%q has %d placeholders but %d were reported", q, want, count)
+		}
+		if utf8.ValidString(q) && !utf8.ValidString(rendered.String()) {
+			t.Fatalf("valid query %q rendered into invalid UTF-8 %q", q, rendered.String())
+		}
+	})
+}
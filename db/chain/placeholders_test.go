@@ -3,6 +3,8 @@ package chain
 import (
 	"fmt"
 	"testing"
+
+	"github.com/go-test/deep"
 )
 
 func Test_digitSize(t *testing.T) {
@@ -97,6 +99,12 @@ func TestPlaceholderEscaping(t *testing.T) {
 			wantExpanded:     `'["a", "b"]'::jsonb \?& array[?]`,
 			args:             []interface{}{"a"},
 		},
+		{
+			q:                `a = 'what?' AND "b?" = ? -- or ?`,
+			wantPlaceholders: `a = 'what?' AND "b?" = $1 -- or ?`,
+			wantExpanded:     `a = 'what?' AND "b?" = ? -- or ?`,
+			args:             []interface{}{1},
+		},
 	}
 	for i, tt := range tests {
 		t.Run(fmt.Sprint(i), func(t *testing.T) {
@@ -112,3 +120,61 @@ func TestPlaceholderEscaping(t *testing.T) {
 		})
 	}
 }
+
+func TestMarksToPlaceholdersMissingArgs(t *testing.T) {
+	if _, _, err := MarksToPlaceholders("a = ? AND b = ?", []interface{}{1}); err == nil {
+		t.Errorf("expected an error for a query with more placeholders than args")
+	}
+	if q, args := ExpandArgs([]interface{}{1}, "a = ? AND b = ?"); q != "a = ? AND b = ?" || len(args) != 1 {
+		t.Errorf("expected the extra mark to be left alone got %q with %v", q, args)
+	}
+}
+
+func TestRenderExpandsArgs(t *testing.T) {
+	group := NewNoDB().AndWhere("b IN (?)", []int{2, 3}).OrWhere("c = ?", nil)
+	q, args, err := NewNoDB().Select("a").Table("justforfun").
+		AndWhere("a = ? AND e \\? 'f'", 1).AndWhereGroup(group).
+		Union("SELECT a FROM archive WHERE a IN (?)", false, []int{4, 5}).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "SELECT a FROM justforfun WHERE a = $1 AND e ? 'f' AND (b IN ($2, $3) OR c = NULL) " +
+		"UNION SELECT a FROM archive WHERE a IN ($4, $5)"
+	if q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+	if diff := deep.Equal(args, []interface{}{1, 2, 3, 4, 5}); diff != nil {
+		t.Error(diff)
+	}
+
+	sub := NewNoDB().Select("max(id)").Table("other").AndWhere("d IN (?)", []string{"x", "y"})
+	q, args, err = NewNoDB().Table("justforfun").Insert(map[string]interface{}{
+		"description": "a", "id": sub}).Returning("id").Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "INSERT INTO justforfun (description, id) VALUES ($1, (SELECT max(id) FROM other WHERE d IN ($2, $3))) RETURNING id"
+	if q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+	if diff := deep.Equal(args, []interface{}{"a", "x", "y"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if _, _, err := NewNoDB().Select("a").Table("justforfun").AndWhere("a = ? AND b = ?", 1).Render(); err == nil {
+		t.Errorf("expected an error for a mark without argument")
+	}
+}
+
+func TestMarksToPlaceholdersNull(t *testing.T) {
+	q, args, err := MarksToPlaceholders("a = ? AND b IN (?) AND c = ?", []interface{}{nil, []int{1, 2}, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "a = NULL AND b IN ($1, $2) AND c = $3"; q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+	if diff := deep.Equal(args, []interface{}{1, 2, 3}); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,229 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+	"github.com/pkg/errors"
+)
+
+// Preload makes Fetch load the passed relations of the fetched models once they are fetched,
+// see LoadRelations.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) Preload(relations ...string) *ExpressionChain {
+	ec.preload = append(ec.preload, relations...)
+	return ec
+}
+
+// LoadRelations fills the relation fields named by relations of dst, a pointer to a registered
+// model or to a slice of them, with one query per relation that fetches the related rows of all
+// of dst at once and stitches them to their parents. The relations of the related rows are
+// loaded with dotted names, ie "Orders.Items" loads the Orders of dst and the Items of those.
+// Has many fields of parents without children are set to an empty slice.
+func LoadRelations(ctx context.Context, db connection.DB, dst interface{}, relations ...string) error {
+	if len(relations) == 0 {
+		return nil
+	}
+	value := reflect.ValueOf(dst)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return errors.Errorf("loading relations needs a pointer got %T", dst)
+	}
+	m, ok := model.Lookup(dst)
+	if !ok {
+		return errors.Errorf("loading relations: %T is not a registered model", dst)
+	}
+	parents := structValues(value.Elem())
+	if len(parents) == 0 {
+		return nil
+	}
+
+	// "Orders", "Orders.Items" and "Orders.Payments" load Orders once with Items and Payments.
+	var names []string
+	nested := map[string][]string{}
+	for _, relation := range relations {
+		parts := strings.SplitN(relation, ".", 2)
+		if _, ok := nested[parts[0]]; !ok {
+			names = append(names, parts[0])
+			nested[parts[0]] = nil
+		}
+		if len(parts) == 2 {
+			nested[parts[0]] = append(nested[parts[0]], parts[1])
+		}
+	}
+	for _, name := range names {
+		relation, ok := m.Relations[name]
+		if !ok {
+			return errors.Errorf("model %s has no relation %s", m.Type, name)
+		}
+		if err := loadRelation(ctx, db, m, relation, parents, nested[name]); err != nil {
+			return errors.Wrapf(err, "loading relation %s of %s", name, m.Type)
+		}
+	}
+	return nil
+}
+
+func loadRelation(ctx context.Context, db connection.DB, m *model.Model, relation *model.Relation,
+	parents []reflect.Value, nested []string) error {
+	target, ok := model.Lookup(relation.Target)
+	if !ok {
+		return errors.Errorf("%s is not a registered model", relation.Target)
+	}
+	// the columns that join parents and related rows, in each of them.
+	parentColumn, relatedColumn := relation.References, relation.ForeignKey
+	keyModel := m
+	if relation.Kind == model.RelationBelongsTo {
+		parentColumn, relatedColumn = relation.ForeignKey, relation.References
+		keyModel = target
+	}
+	if relation.References == "" {
+		if len(keyModel.PK) == 0 {
+			return errors.Errorf("%s has no primary key to reference", keyModel.Type)
+		}
+		if relation.Kind == model.RelationBelongsTo {
+			relatedColumn = keyModel.PK[0]
+		} else {
+			parentColumn = keyModel.PK[0]
+		}
+	}
+	parentField, err := fieldForColumn(m.Type, parentColumn)
+	if err != nil {
+		return err
+	}
+	relatedField, err := fieldForColumn(target.Type, relatedColumn)
+	if err != nil {
+		return err
+	}
+
+	var keys []interface{}
+	seen := map[string]bool{}
+	for _, parent := range parents {
+		key, ok := keyOf(parent.FieldByName(parentField))
+		if !ok || seen[fmt.Sprint(key)] {
+			continue
+		}
+		seen[fmt.Sprint(key)] = true
+		keys = append(keys, key)
+	}
+
+	field, _ := m.Type.FieldByName(relation.Field)
+	related := reflect.New(field.Type)
+	if relation.Kind == model.RelationBelongsTo {
+		related = reflect.New(reflect.SliceOf(field.Type))
+	}
+	if len(keys) > 0 {
+		where, arg := InSlice(relatedColumn, keys)
+		if connection.BindsSlicesAsArrays(db) {
+			where, arg = InAny(relatedColumn, typedSlice(keys))
+		}
+		err := New(db).Select("*").Table(target.Table).AndWhere(where, arg).
+			Fetch(ctx, related.Interface())
+		if err != nil {
+			return err
+		}
+		if err := LoadRelations(ctx, db, related.Interface(), nested...); err != nil {
+			return err
+		}
+	}
+
+	byKey := map[string][]reflect.Value{}
+	rows := related.Elem()
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		key, ok := keyOf(reflect.Indirect(row).FieldByName(relatedField))
+		if ok {
+			byKey[fmt.Sprint(key)] = append(byKey[fmt.Sprint(key)], row)
+		}
+	}
+	for _, parent := range parents {
+		destination := parent.FieldByName(relation.Field)
+		key, ok := keyOf(parent.FieldByName(parentField))
+		matches := byKey[fmt.Sprint(key)]
+		if relation.Kind == model.RelationBelongsTo {
+			if ok && len(matches) > 0 {
+				destination.Set(matches[0])
+			}
+			continue
+		}
+		children := reflect.MakeSlice(field.Type, 0, len(matches))
+		if ok {
+			children = reflect.Append(children, matches...)
+		}
+		destination.Set(children)
+	}
+	return nil
+}
+
+// structValues returns the addressable structs in v, a struct, a pointer to one or a slice of
+// either, nil pointers are skipped.
+func structValues(v reflect.Value) []reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return structValues(v.Elem())
+	case reflect.Struct:
+		return []reflect.Value{v}
+	case reflect.Slice:
+		var values []reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, structValues(v.Index(i))...)
+		}
+		return values
+	}
+	return nil
+}
+
+// fieldForColumn returns the name of the field of modelType that maps to column.
+func fieldForColumn(modelType reflect.Type, column string) (string, error) {
+	for _, field := range columnFields(modelType) {
+		if srm.ColumnName(field) == column {
+			return field.Name, nil
+		}
+	}
+	return "", errors.Errorf("%s has no field for column %s", modelType, column)
+}
+
+// keyOf returns the value of a key field, false if it is a nil pointer.
+func keyOf(v reflect.Value) (interface{}, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	return v.Interface(), true
+}
+
+// typedSlice returns keys as a slice of their type, so the driver can bind it as an array, if
+// they all are of the same one.
+func typedSlice(keys []interface{}) interface{} {
+	keyType := reflect.TypeOf(keys[0])
+	slice := reflect.MakeSlice(reflect.SliceOf(keyType), 0, len(keys))
+	for _, key := range keys {
+		if reflect.TypeOf(key) != keyType {
+			return keys
+		}
+		slice = reflect.Append(slice, reflect.ValueOf(key))
+	}
+	return slice.Interface()
+}
@@ -0,0 +1,132 @@
+package chain
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/go-test/deep"
+)
+
+// preloadDB returns the rows of the table in the FROM of each query, unfiltered.
+type preloadDB struct {
+	connection.DB
+	tables     map[string]interface{}
+	statements []string
+	args       [][]interface{}
+}
+
+// arraysPreloadDB is preloadDB binding slices as arrays.
+type arraysPreloadDB struct {
+	*preloadDB
+}
+
+func (arraysPreloadDB) SlicesAsArrays() bool { return true }
+
+func (p *preloadDB) Query(ctx context.Context, statement string, fields []string, args ...interface{}) (connection.ResultFetch, error) {
+	p.statements = append(p.statements, statement)
+	p.args = append(p.args, args)
+	table := strings.Fields(strings.SplitN(statement, " FROM ", 2)[1])[0]
+	return func(dst interface{}) error {
+		// dst can be a slice of structs or of pointers to them, whatever the canned rows are.
+		rows := reflect.ValueOf(p.tables[table])
+		slice := reflect.ValueOf(dst).Elem()
+		for i := 0; i < rows.Len(); i++ {
+			row := reflect.New(reflect.Indirect(rows.Index(i)).Type())
+			row.Elem().Set(reflect.Indirect(rows.Index(i)))
+			if slice.Type().Elem().Kind() != reflect.Ptr {
+				row = row.Elem()
+			}
+			slice.Set(reflect.Append(slice, row))
+		}
+		return nil
+	}, nil
+}
+
+type preloadUser struct {
+	ID     int `gaum:"field_name:id"`
+	Name   string
+	Orders []preloadOrder `gaum:"has_many:user_id"`
+}
+
+type preloadOrder struct {
+	ID     int `gaum:"field_name:id"`
+	UserID int `gaum:"field_name:user_id"`
+	User   *preloadUser
+	Items  []*preloadItem
+}
+
+type preloadItem struct {
+	ID      int `gaum:"field_name:id"`
+	OrderID int `gaum:"field_name:order_id"`
+}
+
+func TestPreload(t *testing.T) {
+	for _, registration := range []struct {
+		m       interface{}
+		options []model.Option
+	}{
+		{&preloadUser{}, []model.Option{model.Table("users")}},
+		{&preloadOrder{}, []model.Option{model.Table("orders"), model.BelongsTo("User", "user_id"),
+			model.HasMany("Items", "order_id")}},
+		{&preloadItem{}, []model.Option{model.Table("items")}},
+	} {
+		if err := model.Register(registration.m, registration.options...); err != nil {
+			t.Fatal(err)
+		}
+		defer model.Unregister(registration.m)
+	}
+	db := &preloadDB{tables: map[string]interface{}{
+		"users":  []preloadUser{{ID: 1, Name: "jane"}, {ID: 2, Name: "john"}},
+		"orders": []preloadOrder{{ID: 10, UserID: 1}, {ID: 11, UserID: 1}, {ID: 12, UserID: 3}},
+		"items":  []*preloadItem{{ID: 100, OrderID: 10}},
+	}}
+
+	var users []preloadUser
+	err := New(db).Select("*").Preload("Orders", "Orders.Items").Fetch(context.Background(), &users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"SELECT * FROM users",
+		"SELECT * FROM orders WHERE user_id IN ($1, $2)",
+		"SELECT * FROM items WHERE order_id IN ($1, $2, $3)",
+	}
+	if diff := deep.Equal(db.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	if len(users[0].Orders) != 2 || users[1].Orders == nil || len(users[1].Orders) != 0 {
+		t.Fatalf("expected the orders to be stitched to their users got %+v", users)
+	}
+	if len(users[0].Orders[0].Items) != 1 || users[0].Orders[0].Items[0].ID != 100 {
+		t.Errorf("expected the items to be stitched to their orders got %+v", users[0].Orders)
+	}
+
+	orders := []*preloadOrder{{ID: 10, UserID: 1}, {ID: 12, UserID: 3}}
+	if err := LoadRelations(context.Background(), db, &orders, "User"); err != nil {
+		t.Fatal(err)
+	}
+	if orders[0].User == nil || orders[0].User.Name != "jane" || orders[1].User != nil {
+		t.Errorf("expected the users to be stitched to their orders got %+v, %+v", orders[0], orders[1])
+	}
+
+	if err := LoadRelations(context.Background(), db, &orders, "Payments"); err == nil {
+		t.Errorf("expected an error for an unknown relation")
+	}
+
+	arrays := arraysPreloadDB{&preloadDB{tables: db.tables}}
+	users = nil
+	if err := New(arrays).Select("*").Preload("Orders").Fetch(context.Background(), &users); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"SELECT * FROM users", "SELECT * FROM orders WHERE user_id = ANY($1)"}
+	if diff := deep.Equal(arrays.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(arrays.args[1], []interface{}{[]int{1, 2}}); diff != nil {
+		t.Error(diff)
+	}
+}
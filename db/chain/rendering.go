@@ -18,34 +18,48 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
 	"github.com/pkg/errors"
 )
 
 // Render returns the SQL expression string and the arguments of said expression, there is no checkig
 // of validity or consistency for the time being.
 func (ec *ExpressionChain) Render() (string, []interface{}, error) {
-	dst := &strings.Builder{}
-	if ec.minQuerySize > 0 {
-		if uint64(dst.Len()) < ec.minQuerySize {
-			dst.Grow(int(ec.minQuerySize - uint64(dst.Len())))
-		}
+	w := ec.newQueryWriter(false)
+	defer putBuffer(w.Buffer)
+	if ec.minQuerySize > 0 && uint64(w.Cap()) < ec.minQuerySize {
+		w.Grow(int(ec.minQuerySize))
 	}
-	args, err := ec.render(false, dst)
-	if err != nil {
+	if err := ec.render(w); err != nil {
 		return "", nil, err
 	}
-	return dst.String(), args, nil
+	if err := w.checkArgs(); err != nil {
+		return "", nil, errors.Wrap(err, "rendering query")
+	}
+	return w.String(), w.args, nil
 }
 
 // RenderRaw returns the SQL expression string and the arguments of said expression,
 // No positional argument replacement is done.
 func (ec *ExpressionChain) RenderRaw() (string, []interface{}, error) {
-	dst := &strings.Builder{}
-	args, err := ec.render(true, dst)
-	if err != nil {
+	w := ec.newQueryWriter(true)
+	defer putBuffer(w.Buffer)
+	if err := ec.render(w); err != nil {
 		return "", nil, err
 	}
-	return dst.String(), args, nil
+	return w.String(), w.args, nil
+}
+
+// newQueryWriter returns a writer, on a pooled buffer, to render the chain into.
+func (ec *ExpressionChain) newQueryWriter(raw bool) *queryWriter {
+	return &queryWriter{
+		Buffer: getBuffer(),
+		raw:    raw,
+		arrays: connection.BindsSlicesAsArrays(ec.db),
+		args:   make([]interface{}, 0, ec.argCapacity()),
+
+		inArrayThreshold: ec.inArrayThreshold,
+	}
 }
 
 // String implements the stringer interface. It is intended to be used for logging/debugging purposes only.
@@ -58,108 +72,85 @@ func (ec *ExpressionChain) String() string {
 	return fmt.Sprintf("query: %s, args: %v", strQuery, args)
 }
 
-// renderWhereRaw renders only the where portion of an ExpressionChain and returns it without
-// placeholder markers replaced.
-func (ec *ExpressionChain) renderWhereRaw(dst *strings.Builder) []interface{} {
+// renderWhere renders only the where portion of an ExpressionChain.
+func (ec *ExpressionChain) renderWhere(dst *queryWriter) {
 	// WHERE
 	wheres := extract(ec, sqlWhere)
 	// Separate where statements that are not ANDed since they will need
 	// to go after others with AND.
 	var whereOrs []querySegmentAtom
-	if len(wheres) != 0 {
-		args := []interface{}{}
-		whereCount := 0
-		for i, item := range wheres {
-			if item.sqlBool != SQLAnd {
-				whereOrs = append(whereOrs, item)
-				continue
-			}
-			arguments := item.render(whereCount == 0, i == len(wheres)-1, dst)
-			args = append(args, arguments...)
-			whereCount++
-		}
-		for i, item := range whereOrs {
-			arguments := item.render(whereCount+i == 0, i == len(whereOrs)-1, dst)
-			args = append(args, arguments...)
+	whereCount := 0
+	for i, item := range wheres {
+		if item.sqlBool != SQLAnd {
+			whereOrs = append(whereOrs, item)
+			continue
 		}
-		return args
+		item.render(whereCount == 0, i == len(wheres)-1, dst)
+		whereCount++
+	}
+	for i, item := range whereOrs {
+		item.render(whereCount+i == 0, i == len(whereOrs)-1, dst)
 	}
-	return nil
 }
 
-// renderHavingRaw renders only the HAVING portion of an ExpressionChain and returns it without
-// placeholder markers replaced.
-func (ec *ExpressionChain) renderHavingRaw(dst *strings.Builder) []interface{} {
+// renderHaving renders only the HAVING portion of an ExpressionChain.
+func (ec *ExpressionChain) renderHaving(dst *queryWriter) {
 	// HAVING
 	havings := extract(ec, sqlHaving)
 	// Separate having statements that are not ANDed since they will need
 	// to go after others with AND.
 	var havingOrs []querySegmentAtom
-	if len(havings) != 0 {
-
-		args := []interface{}{}
-		havingCount := 0
-		for i, item := range havings {
-			if item.sqlBool != SQLAnd {
-				havingOrs = append(havingOrs, item)
-				continue
-			}
-			arguments := item.render(havingCount == 0, i == len(havings)-1, dst)
-			args = append(args, arguments...)
-			havingCount++
-		}
-		for i, item := range havingOrs {
-			arguments := item.render(havingCount+i == 0, i == len(havingOrs)-1, dst)
-			args = append(args, arguments...)
+	havingCount := 0
+	for i, item := range havings {
+		if item.sqlBool != SQLAnd {
+			havingOrs = append(havingOrs, item)
+			continue
 		}
-		return args
+		item.render(havingCount == 0, i == len(havings)-1, dst)
+		havingCount++
+	}
+	for i, item := range havingOrs {
+		item.render(havingCount+i == 0, i == len(havingOrs)-1, dst)
 	}
-	return nil
 }
 
-// render returns the rendered expression along with an arguments list and all marker placeholders
-// replaced by their positional placeholder.
-func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface{}, error) {
-	args := []interface{}{}
+// render writes the expression to query, along with its arguments.
+func (ec *ExpressionChain) render(query *queryWriter) error {
 	if ec.mainOperation == nil {
-		return nil, errors.Errorf("missing main operation to perform on the db")
+		return errors.Errorf("missing main operation to perform on the db")
 	}
-	if query == nil {
-		query = &strings.Builder{}
+	if scoped := ec.scoped(); scoped != nil {
+		return scoped.render(query)
 	}
 
 	// For now CTEs are only supported with SELECT until I have time to actually go and read
 	// the doc.
-	cteArgs, err := ec.renderctes(query)
-	if err != nil {
-		return nil, errors.Wrap(err, "rendering CTEs before main render")
-	}
-	if len(cteArgs) != 0 {
-		args = append(args, cteArgs...)
+	if err := ec.renderctes(query); err != nil {
+		return errors.Wrap(err, "rendering CTEs before main render")
 	}
 
 	switch ec.mainOperation.segment {
 	// INSERT
 	case sqlInsert:
 		// Too much of a special cookie for the general case.
-		return ec.renderInsert(raw, query)
+		return ec.renderInsert(query)
 	case sqlInsertMulti:
 		// Too much of a special cookie for the general case.
-		return ec.renderInsertMulti(raw, query)
+		return ec.renderInsertMulti(query)
 	// UPDATE
 	case sqlUpdate:
 		if ec.table == "" {
-			return nil, errors.Errorf("no table specified for update")
+			return errors.Errorf("no table specified for update")
 		}
 		expression := ec.mainOperation.expression
 		if len(expression) == 0 {
-			return nil, errors.Errorf("empty update expression")
+			return errors.Errorf("empty update expression")
 		}
 		query.WriteString("UPDATE ")
-		query.WriteString(ec.table)
+		query.WriteString(ec.renderTable())
 		query.WriteString(" SET ")
-		query.WriteString(ec.mainOperation.expression)
-		args = append(args, ec.mainOperation.arguments...)
+		query.writeExpression(ec.mainOperation.expression, ec.mainOperation.arguments)
+		ec.renderAutoUpdate(query)
 
 	// SELECT, DELETE
 	case sqlSelect, sqlDelete:
@@ -169,9 +160,7 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 		}
 		if ec.mainOperation.segment == sqlSelect {
 			query.WriteString("SELECT ")
-			if ec.mainOperation.segment == sqlSelect {
-				query.WriteString(expression)
-			}
+			query.writeExpression(expression, ec.mainOperation.arguments)
 		} else {
 			query.WriteString("DELETE")
 		}
@@ -180,14 +169,11 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 		}
 		// FROM
 		if ec.table == "" && ec.mainOperation.segment == sqlDelete {
-			return nil, errors.Errorf("no table specified for this query")
+			return errors.Errorf("no table specified for this query")
 		}
 		if ec.table != "" {
 			query.WriteString(" FROM ")
-			query.WriteString(ec.table)
-		}
-		if len(ec.mainOperation.arguments) != 0 {
-			args = append(args, ec.mainOperation.arguments...)
+			query.WriteString(ec.renderTable())
 		}
 
 	}
@@ -195,14 +181,11 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 		ec.mainOperation.segment == sqlDelete {
 		// JOIN, preserver the order in which they were declared
 		joins := extractMany(ec, []sqlSegment{sqlJoin, sqlLeftJoin, sqlRightJoin, sqlInnerJoin, sqlFullJoin})
-		if len(joins) != 0 {
-			for _, join := range joins {
-				query.WriteRune(' ')
-				query.WriteString(string(join.segment))
-				query.WriteRune(' ')
-				query.WriteString(join.expression)
-				args = append(args, join.arguments...)
-			}
+		for _, join := range joins {
+			query.WriteRune(' ')
+			query.WriteString(string(join.segment))
+			query.WriteRune(' ')
+			query.writeExpression(ec.renderJoinTable(join.expression), join.arguments)
 		}
 	}
 	if ec.mainOperation.segment == sqlUpdate {
@@ -216,8 +199,7 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 				if i != 0 {
 					query.WriteString(", ")
 				}
-				query.WriteString(from.expression)
-				args = append(args, from.arguments...)
+				query.writeExpression(ec.renderJoinTable(from.expression), from.arguments)
 			}
 		}
 	}
@@ -225,7 +207,7 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 	// WHERE
 	if segmentsPresent(ec, sqlWhere) > 0 {
 		query.WriteString(" WHERE ")
-		args = append(args, ec.renderWhereRaw(query)...)
+		ec.renderWhere(query)
 	}
 
 	// GROUP BY
@@ -233,11 +215,7 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 	if len(groups) != 0 {
 		query.WriteString(" GROUP BY ")
 		for i, item := range groups {
-			expr := item.expression
-			if len(item.arguments) != 0 {
-				args = append(args, item.arguments...)
-			}
-			query.WriteString(expr)
+			query.writeExpression(item.expression, item.arguments)
 			if i < len(groups)-1 {
 				query.WriteString(", ")
 			}
@@ -248,7 +226,7 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 	// HAVING
 	if segmentsPresent(ec, sqlHaving) > 0 {
 		query.WriteString(" HAVING ")
-		args = append(args, ec.renderHavingRaw(query)...)
+		ec.renderHaving(query)
 	}
 
 	// ORDER BY
@@ -256,8 +234,7 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 		query.WriteString(" ORDER BY ")
 		orders := extract(ec, sqlOrder)
 		for i, item := range orders {
-			query.WriteString(item.expression)
-			args = append(args, item.arguments...)
+			query.writeExpression(item.expression, item.arguments)
 			if i != len(orders)-1 {
 				query.WriteString(", ")
 			}
@@ -266,27 +243,16 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 	}
 
 	// RETURNING
-	for _, segment := range ec.segments {
-		if segment.segment != sqlReturning {
-			continue
-		}
-		query.WriteRune(' ')
-		query.WriteString(segment.expression)
-		if len(segment.arguments) > 0 {
-			args = append(args, segment.arguments...)
-		}
-	}
+	ec.renderReturning(query)
 
 	if ec.limit != nil {
 		query.WriteString(" LIMIT ")
-		query.WriteString(ec.limit.expression)
-		args = append(args, ec.limit.arguments...)
+		query.writeExpression(ec.limit.expression, ec.limit.arguments)
 	}
 
 	if ec.offset != nil {
 		query.WriteString(" OFFSET ")
-		query.WriteString(ec.offset.expression)
-		args = append(args, ec.offset.arguments...)
+		query.writeExpression(ec.offset.expression, ec.offset.arguments)
 	}
 
 	// UNION
@@ -298,12 +264,7 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 				query.WriteString(string(item.sqlModifier))
 				query.WriteRune(' ')
 			}
-			query.WriteString(item.expression)
-
-			if len(item.arguments) != 0 {
-				args = append(args, item.arguments...)
-			}
-
+			query.writeExpression(item.expression, item.arguments)
 		}
 	}
 
@@ -318,53 +279,69 @@ func (ec *ExpressionChain) render(raw bool, query *strings.Builder) ([]interface
 		}
 	}
 
-	if !raw {
-		newQuery, argCount, err := PlaceholdersToPositional(query, len(args))
-		if err != nil {
-			return nil, errors.Wrap(err, "rendering query")
+	return nil
+}
+
+// renderReturning renders the RETURNING clauses, if any.
+func (ec *ExpressionChain) renderReturning(dst *queryWriter) {
+	for _, segment := range ec.segments {
+		if segment.segment != sqlReturning {
+			continue
 		}
-		*query = *newQuery
-		if len(args) != argCount {
-			return nil, errors.Errorf("the query has %d args but %d were passed: %v",
-				argCount, len(args), query.String())
+		dst.WriteRune(' ')
+		dst.writeExpression(segment.expression, segment.arguments)
+	}
+}
+
+// renderInsertValue renders one of the values of an insert, these are never expanded as
+// arguments of other expressions are since it makes no sense.
+func renderInsertValue(dst *queryWriter, value interface{}) error {
+	if value == nil {
+		dst.WriteString("NULL")
+		return nil
+	}
+	if innerEC, ok := value.(*ExpressionChain); ok {
+		// support using a query as a value
+		dst.WriteRune('(')
+		if err := innerEC.render(dst); err != nil {
+			return errors.Wrap(err, "rendering a SQL insert")
 		}
-		return args, nil
+		dst.WriteRune(')')
+		return nil
+	}
+	dst.writeValue(value)
+	return nil
+}
+
+// renderConflict renders the ON CONFLICT clause of an insert, if any.
+func (ec *ExpressionChain) renderConflict(dst *queryWriter) {
+	conflictExpr, conflictArgs := ec.conflict.render()
+	if len(conflictExpr) > 0 {
+		dst.WriteRune(' ')
+		dst.writeExpression(conflictExpr, conflictArgs)
 	}
-	return args, nil
 }
 
 // RenderInsert does render for the very particular case of insert
-// NOTE: These values are never passed through ExpandArgs since it makes no sense
-func (ec *ExpressionChain) renderInsert(raw bool, dst *strings.Builder) ([]interface{}, error) {
+func (ec *ExpressionChain) renderInsert(dst *queryWriter) error {
 	if ec.table == "" {
-		return nil, errors.Errorf("no table specified for this insert")
+		return errors.Errorf("no table specified for this insert")
 	}
 
 	// build insert
-	args := make([]interface{}, 0, len(ec.mainOperation.arguments)) // we might need to resize anyway but chances are not.
 	dst.WriteString("INSERT INTO ")
-	dst.WriteString(ec.table)
+	dst.WriteString(ec.renderTable())
 	dst.WriteString(" (")
 	dst.WriteString(ec.mainOperation.expression)
 	dst.WriteString(") VALUES (")
-	for i := range ec.mainOperation.arguments {
-		if ec.mainOperation.arguments[i] == nil {
-			dst.WriteString("NULL")
-		} else if innerEC, ok := ec.mainOperation.arguments[i].(*ExpressionChain); ok {
-			// support using a query as a value
-			q, qArgs, err := innerEC.RenderRaw()
-			if err != nil {
-				return nil, errors.Wrap(err, "rendering a SQL insert")
-			}
-			if len(qArgs) != 0 {
-				args = append(args, qArgs...)
-			}
-			dst.WriteRune('(')
-			dst.WriteString(q)
-			dst.WriteRune(')')
-		} else {
-			dst.WriteRune('?')
-			args = append(args, ec.mainOperation.arguments[i])
+	// zero values of columns with a default in the model are replaced by it.
+	defaults := ec.columnDefaults()
+	columns := strings.Split(ec.mainOperation.expression, ", ")
+	for i, value := range ec.mainOperation.arguments {
+		if def, hasDefault := defaults[columns[i]]; hasDefault && isZeroValue(value) {
+			dst.WriteString(def)
+		} else if err := renderInsertValue(dst, value); err != nil {
+			return err
 		}
 		if i != len(ec.mainOperation.arguments)-1 {
 			dst.WriteString(", ")
@@ -372,87 +349,34 @@ func (ec *ExpressionChain) renderInsert(raw bool, dst *strings.Builder) ([]inter
 	}
 	dst.WriteRune(')')
 
-	// render conflict
-	conflictExpr, conflictArgs := ec.conflict.render()
-	if len(conflictExpr) > 0 {
-		dst.WriteRune(' ')
-		dst.WriteString(conflictExpr)
-	}
-
-	// operationally do something with it
-	if len(conflictArgs) > 0 {
-		args = append(args, conflictArgs...)
-	}
-
-	// look for clauses we can handle
-	for _, segment := range ec.segments {
-		// skip all that stuff we can't handle
-		if segment.segment != sqlReturning {
-			continue
-		}
-		dst.WriteRune(' ')
-		dst.WriteString(segment.expression)
-
-		// add arguments
-		if len(segment.arguments) > 0 {
-			args = append(args, segment.arguments...)
-		}
-	}
-
-	if !raw {
-		query, argCount, err := PlaceholdersToPositional(dst, len(args))
-		if err != nil {
-			return nil, errors.Wrap(err, "rendering insert")
-		}
-		if len(args) != argCount {
-			return nil, errors.Errorf("Insert Single expected %d arguments but got %d: %s",
-				argCount, len(args), dst.String())
-		}
-		*dst = *query
-		return args, nil
-	}
-	return args, nil
+	ec.renderConflict(dst)
+	ec.renderReturning(dst)
+	return nil
 }
 
 // renderInsertMulti does render for the very particular case of a multiple insertion
-func (ec *ExpressionChain) renderInsertMulti(raw bool, dst *strings.Builder) ([]interface{}, error) {
+func (ec *ExpressionChain) renderInsertMulti(dst *queryWriter) error {
 	if ec.table == "" {
-		return nil, errors.Errorf("no table specified for this insert")
+		return errors.Errorf("no table specified for this insert")
 	}
 	argCount := strings.Count(ec.mainOperation.expression, ",") + 1
 
 	if argCount == 0 {
-		return []interface{}{}, nil
+		return nil
 	}
 	dst.WriteString("INSERT INTO ")
-	dst.WriteString(ec.table)
+	dst.WriteString(ec.renderTable())
 	dst.WriteRune('(')
 	dst.WriteString(ec.mainOperation.expression)
 	dst.WriteString(") VALUES ")
 
-	args := make([]interface{}, 0, len(ec.mainOperation.arguments))
 	valueGroupCount := len(ec.mainOperation.arguments) / argCount
 	position := 0
 	for i := 0; i < valueGroupCount; i++ {
 		dst.WriteRune('(')
 		for j := 0; j < argCount; j++ {
-			if ec.mainOperation.arguments[position] == nil {
-				dst.WriteString("NULL")
-			} else if innerEC, ok := ec.mainOperation.arguments[position].(*ExpressionChain); ok {
-				// support using a query as a value
-				q, qArgs, err := innerEC.RenderRaw()
-				if err != nil {
-					return nil, errors.Wrap(err, "rendering a SQL insert")
-				}
-				if len(qArgs) != 0 {
-					args = append(args, qArgs...)
-				}
-				dst.WriteRune('(')
-				dst.WriteString(q)
-				dst.WriteRune(')')
-			} else {
-				dst.WriteRune('?')
-				args = append(args, ec.mainOperation.arguments[position])
+			if err := renderInsertValue(dst, ec.mainOperation.arguments[position]); err != nil {
+				return err
 			}
 			if j != argCount-1 {
 				dst.WriteString(", ")
@@ -466,44 +390,7 @@ func (ec *ExpressionChain) renderInsertMulti(raw bool, dst *strings.Builder) ([]
 
 	}
 
-	// render conflict
-	conflict, conflictArgs := ec.conflict.render()
-	if conflict != "" {
-		dst.WriteRune(' ')
-		dst.WriteString(conflict)
-	}
-
-	// operationally do something with it
-	if len(conflictArgs) > 0 {
-		args = append(args, conflictArgs...)
-	}
-
-	// look for clauses we can handle
-	for _, segment := range ec.segments {
-		// skip all that stuff we can't handle
-		if segment.segment != sqlReturning {
-			continue
-		}
-		dst.WriteRune(' ')
-		dst.WriteString(segment.expression)
-
-		// add arguments
-		if len(segment.arguments) > 0 {
-			args = append(args, segment.arguments...)
-		}
-	}
-
-	if !raw {
-		query, argCount, err := PlaceholdersToPositional(dst, len(args))
-		if err != nil {
-			return nil, errors.Wrap(err, "rendering insert")
-		}
-		if len(args) != argCount {
-			return nil, errors.Errorf("Insert expected %d arguments but got %d: %s",
-				argCount, len(args), query.String())
-		}
-		*dst = *query
-		return args, nil
-	}
-	return args, nil
+	ec.renderConflict(dst)
+	ec.renderReturning(dst)
+	return nil
 }
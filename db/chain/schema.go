@@ -0,0 +1,76 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"strings"
+)
+
+// Schema makes the chain's table be qualified with the passed schema when rendered, so
+// Table("users").Schema("tenant_42") renders as tenant_42.users. Tables that are already
+// qualified or sub-queries are left alone. The schema is written verbatim, it is not quoted.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) Schema(schema string) *ExpressionChain {
+	ec.setSchema(schema, false)
+	return ec
+}
+
+// SchemaWithJoins is Schema but also qualifies the tables of the JOIN clauses and, for UPDATE,
+// those of FromUpdate.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) SchemaWithJoins(schema string) *ExpressionChain {
+	ec.setSchema(schema, true)
+	return ec
+}
+
+// setSchema sets the schema of the chain and whether it qualifies the joins too.
+func (ec *ExpressionChain) setSchema(schema string, joins bool) {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+	ec.schema = schema
+	ec.schemaJoins = joins
+}
+
+// renderTable returns the table of the chain, qualified with the schema if one was set.
+func (ec *ExpressionChain) renderTable() string {
+	return qualifyTable(ec.schema, ec.table)
+}
+
+// renderJoinTable returns the passed JOIN or FROM expression with its table qualified if
+// SchemaWithJoins was used.
+func (ec *ExpressionChain) renderJoinTable(expr string) string {
+	if !ec.schemaJoins {
+		return expr
+	}
+	return qualifyTable(ec.schema, expr)
+}
+
+// qualifyTable prefixes the table at the start of expr (ie "users AS u" or "users ON ...")
+// with schema, unless it already has one or it is not a table name.
+func qualifyTable(schema, expr string) string {
+	if schema == "" {
+		return expr
+	}
+	trimmed := strings.TrimLeft(expr, " ")
+	name := trimmed
+	if i := strings.IndexAny(trimmed, " \t\n"); i != -1 {
+		name = trimmed[:i]
+	}
+	if name == "" || strings.ContainsAny(name, ".(") || strings.EqualFold(name, "LATERAL") ||
+		strings.EqualFold(name, "ONLY") {
+		return expr
+	}
+	return schema + "." + trimmed
+}
@@ -0,0 +1,31 @@
+package chain
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSchemaConcurrent(t *testing.T) {
+	ec := NewNoDB().Select("*").Table("users").Join("orders o", "o.user_id = users.id")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				ec.Schema("tenant")
+				return
+			}
+			ec.SchemaWithJoins("tenant")
+		}(i)
+	}
+	wg.Wait()
+
+	q, _, err := ec.SchemaWithJoins("tenant").Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SELECT * FROM tenant.users JOIN tenant.orders o ON o.user_id = users.id"; q != expected {
+		t.Errorf("expected %q got %q", expected, q)
+	}
+}
@@ -15,8 +15,6 @@
 package chain
 
 import (
-	"strings"
-
 	"github.com/ShiftLeftSecurity/gaum/v2/selectparse"
 )
 
@@ -89,10 +87,11 @@ func (q *querySegmentAtom) clone() querySegmentAtom {
 		arguments[i] = a
 	}
 	return querySegmentAtom{
-		segment:    q.segment,
-		expression: q.expression,
-		sqlBool:    q.sqlBool,
-		arguments:  arguments,
+		segment:     q.segment,
+		expression:  q.expression,
+		sqlBool:     q.sqlBool,
+		sqlModifier: q.sqlModifier,
+		arguments:   arguments,
 	}
 }
 
@@ -111,14 +110,11 @@ func (q *querySegmentAtom) fields() []string {
 	return fields
 }
 
-func (q *querySegmentAtom) render(firstForSegment, lastForSegment bool,
-	dst *strings.Builder) []interface{} {
-
+func (q *querySegmentAtom) render(firstForSegment, lastForSegment bool, dst *queryWriter) {
 	if !firstForSegment {
 		dst.WriteRune(' ')
 		dst.WriteString(string(q.sqlBool))
 		dst.WriteRune(' ')
 	}
-	dst.WriteString(q.expression)
-	return q.arguments
+	dst.writeExpression(q.expression, q.arguments)
 }
@@ -0,0 +1,91 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"strings"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+)
+
+// Unscoped disables the soft delete of the model of the chain, a SELECT includes the rows marked
+// as deleted and a DELETE removes the rows instead of marking them.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) Unscoped() *ExpressionChain {
+	ec.unscoped = true
+	return ec
+}
+
+// softDeleteColumn returns the soft_delete column of the model of the chain, if any.
+func (ec *ExpressionChain) softDeleteColumn() (string, bool) {
+	if ec.modelType == nil || ec.unscoped {
+		return "", false
+	}
+	fields := model.TaggedFields(ec.modelType, srm.SubTagNameSoftDelete)
+	if len(fields) == 0 {
+		return "", false
+	}
+	return srm.ColumnName(fields[0]), true
+}
+
+// scoped returns the chain to render in place of this one when its model has soft delete, a
+// SELECT skips the rows marked as deleted and a DELETE becomes an UPDATE that marks them,
+// otherwise it returns nil.
+func (ec *ExpressionChain) scoped() *ExpressionChain {
+	if ec.mainOperation == nil ||
+		(ec.mainOperation.segment != sqlSelect && ec.mainOperation.segment != sqlDelete) {
+		return nil
+	}
+	column, ok := ec.softDeleteColumn()
+	if !ok {
+		return nil
+	}
+	scoped := ec.Clone()
+	scoped.unscoped = true
+	if scoped.mainOperation.segment == sqlDelete {
+		scoped.Update(column + " = CURRENT_TIMESTAMP")
+	}
+
+	// ORs would otherwise apply on their own, see renderWhere.
+	ored := false
+	for _, item := range extract(scoped, sqlWhere) {
+		ored = ored || item.sqlBool != SQLAnd
+	}
+	if ored {
+		group := NewNoDB()
+		segments := make([]querySegmentAtom, 0, len(scoped.segments))
+		for _, item := range scoped.segments {
+			if item.segment == sqlWhere {
+				group.segments = append(group.segments, item)
+				continue
+			}
+			segments = append(segments, item)
+		}
+		scoped.segments = segments
+		scoped.AndWhereGroup(group)
+	}
+	scoped.AndWhere(tableReference(scoped.table) + "." + column + " IS NULL")
+	return scoped
+}
+
+// tableReference returns the name a table expression, such as "users AS u", is referenced by.
+func tableReference(table string) string {
+	fields := strings.Fields(table)
+	if len(fields) == 0 {
+		return table
+	}
+	return fields[len(fields)-1]
+}
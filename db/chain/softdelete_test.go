@@ -0,0 +1,85 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+)
+
+type softDeleted struct {
+	ID        int `gaum:"field_name:id"`
+	Name      string
+	DeletedAt *time.Time `gaum:"soft_delete"`
+}
+
+func TestSoftDelete(t *testing.T) {
+	if err := model.Register(&softDeleted{}, model.Table("records")); err != nil {
+		t.Fatal(err)
+	}
+	defer model.Unregister(&softDeleted{})
+
+	tests := []struct {
+		name  string
+		chain *ExpressionChain
+		want  string
+		args  []interface{}
+	}{
+		{
+			name:  "select",
+			chain: NewNoDB().Model(&softDeleted{}).Select("*").AndWhere("name = ?", "jane"),
+			want:  "SELECT * FROM records WHERE name = $1 AND records.deleted_at IS NULL",
+			args:  []interface{}{"jane"},
+		},
+		{
+			name: "select with ors",
+			chain: NewNoDB().Model(&softDeleted{}).Select("*").AndWhere("name = ?", "jane").
+				OrWhere("name = ?", "john"),
+			want: "SELECT * FROM records WHERE (name = $1 OR name = $2) AND records.deleted_at IS NULL",
+			args: []interface{}{"jane", "john"},
+		},
+		{
+			name:  "select unscoped",
+			chain: NewNoDB().Model(&softDeleted{}).Select("*").Unscoped(),
+			want:  "SELECT * FROM records",
+		},
+		{
+			name:  "select with alias",
+			chain: NewNoDB().Select("r.name").Table("records AS r").InferTable(&softDeleted{}),
+			want:  "SELECT r.name FROM records AS r WHERE r.deleted_at IS NULL",
+		},
+		{
+			name:  "delete",
+			chain: NewNoDB().Model(&softDeleted{}).Delete().AndWhere("id = ?", 1),
+			want:  "UPDATE records SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND records.deleted_at IS NULL",
+			args:  []interface{}{1},
+		},
+		{
+			name:  "delete unscoped",
+			chain: NewNoDB().Model(&softDeleted{}).Delete().AndWhere("id = ?", 1).Unscoped(),
+			want:  "DELETE FROM records WHERE id = $1",
+			args:  []interface{}{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := tt.chain.Render()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q got %q", tt.want, got)
+			}
+			if len(args) != len(tt.args) {
+				t.Errorf("expected args %v got %v", tt.args, args)
+			}
+		})
+	}
+
+	scoped := NewNoDB().Model(&softDeleted{}).Select("*")
+	first, _, _ := scoped.Render()
+	second, _, _ := scoped.Render()
+	if first != second {
+		t.Errorf("rendering twice changed the query from %q to %q", first, second)
+	}
+}
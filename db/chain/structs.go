@@ -0,0 +1,198 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+	"github.com/pkg/errors"
+)
+
+// InsertStruct sets an INSERT of the columns of record, a pointer to a struct, as gaum maps them
+// for scanning, primary key columns holding the zero value are left out so the database can
+// generate them, autocreate and autoupdate fields holding the zero value are set to the current
+// time first and zero values of fields with a default sub tag are replaced by it, those tagged
+// omit are never written. If no table was set it is inferred from record (see InferTable).
+// The BeforeInsert and AfterInsert hooks of record run when the chain is executed, the values
+// are taken again after BeforeInsert so it can change them.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) InsertStruct(record interface{}) *ExpressionChain {
+	if err := ec.setRecord(record, sqlInsert); err != nil {
+		ec.err = append(ec.err, errors.Wrap(err, "inserting struct"))
+		return ec
+	}
+	return ec.InferTable(record)
+}
+
+// UpdateStruct sets an UPDATE of the row of record, a pointer to a struct, found by its primary
+// key (see model.PrimaryKey), that sets the rest of its columns but the omit, readonly and
+// autocreate ones, those tagged autoupdate are set to CURRENT_TIMESTAMP. If no table was set it is inferred from record
+// (see InferTable).
+// If record has a version field the row is only updated if it is still at the version record
+// holds, which is then incremented, otherwise executing the chain returns a StaleObjectError.
+// The BeforeUpdate and AfterUpdate hooks of record run when the chain is executed, the values
+// are taken again after BeforeUpdate so it can change them.
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) UpdateStruct(record interface{}) *ExpressionChain {
+	if err := ec.setRecord(record, sqlUpdate); err != nil {
+		ec.err = append(ec.err, errors.Wrap(err, "updating struct"))
+		return ec
+	}
+	_, pk, _ := recordColumns(record)
+	if len(pk) == 0 {
+		ec.err = append(ec.err, errors.Errorf("updating struct: %T has no primary key", record))
+		return ec
+	}
+	columns := make([]string, 0, len(pk))
+	for column := range pk {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		ec.AndWhere(column+" = ?", pk[column])
+	}
+	if field, ok := versionField(ec.modelType); ok {
+		version := reflect.ValueOf(record).Elem().FieldByName(field.Name)
+		if !isInteger(version) {
+			ec.err = append(ec.err, errors.Errorf("updating struct: version field %s of %T is a %s, not an integer",
+				field.Name, record, field.Type))
+			return ec
+		}
+		ec.AndWhere(srm.ColumnName(field)+" = ?", version.Interface())
+	}
+	return ec.InferTable(record)
+}
+
+// SelectStruct sets a SELECT of the columns of the struct of receiver, a struct, a pointer to
+// one or a slice of either, as gaum maps them for scanning, so the destination of a query
+// doesn't need to be spelled out. If no table was set it is inferred from receiver (see
+// InferTable).
+// THIS DOES NOT CREATE A COPY OF THE CHAIN, IT MUTATES IN PLACE.
+func (ec *ExpressionChain) SelectStruct(receiver interface{}) *ExpressionChain {
+	modelType := reflect.TypeOf(receiver)
+	for modelType != nil && (modelType.Kind() == reflect.Ptr || modelType.Kind() == reflect.Slice) {
+		modelType = modelType.Elem()
+	}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		ec.err = append(ec.err, errors.Errorf("selecting struct: expected a struct got %T", receiver))
+		return ec
+	}
+	fields := columnFields(modelType)
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = srm.ColumnName(field)
+	}
+	return ec.Select(columns...).InferTable(receiver)
+}
+
+// setRecord makes record the source of the values of the main operation, op.
+func (ec *ExpressionChain) setRecord(record interface{}, op sqlSegment) error {
+	value := reflect.ValueOf(record)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("expected a pointer to a struct got %T", record)
+	}
+	ec.record = record
+	ec.recordOperation = op
+	ec.modelType = value.Elem().Type()
+	return ec.refreshRecord()
+}
+
+// refreshRecord sets the main operation from the current values of the record.
+func (ec *ExpressionChain) refreshRecord() error {
+	if ec.recordOperation == sqlInsert {
+		if err := setCreateTimestamps(ec.record, time.Now()); err != nil {
+			return err
+		}
+	}
+	columns, pk, err := recordColumns(ec.record)
+	if err != nil {
+		return err
+	}
+	switch ec.recordOperation {
+	case sqlInsert:
+		for column, value := range pk {
+			if !reflect.ValueOf(value).IsZero() {
+				columns[column] = value
+			}
+		}
+		for column := range taggedColumns(ec.modelType, srm.SubTagNameOmit) {
+			delete(columns, column)
+		}
+		ec.Insert(columns)
+	case sqlUpdate:
+		// the creation time is kept and the update one is set by the database, see renderAutoUpdate.
+		for column := range taggedColumns(ec.modelType, srm.SubTagNameOmit, srm.SubTagNameReadOnly,
+			srm.SubTagNameAutoCreate, srm.SubTagNameAutoUpdate) {
+			delete(columns, column)
+		}
+		field, versioned := versionField(ec.modelType)
+		if versioned {
+			delete(columns, srm.ColumnName(field))
+		}
+		if len(columns) == 0 && !versioned {
+			return errors.Errorf("%T has no columns to update besides its primary key", ec.record)
+		}
+		ec.UpdateMap(columns)
+		if versioned {
+			ec.bumpVersion(srm.ColumnName(field))
+		}
+	}
+	return nil
+}
+
+// recordColumns returns the values of the columns of record, a pointer to a struct, split in
+// those of its primary key and the rest.
+func recordColumns(record interface{}) (map[string]interface{}, map[string]interface{}, error) {
+	value := reflect.ValueOf(record).Elem()
+	isPK := map[string]bool{}
+	for _, column := range model.PrimaryKey(record) {
+		isPK[column] = true
+	}
+	columns := map[string]interface{}{}
+	pk := map[string]interface{}{}
+	for _, field := range columnFields(value.Type()) {
+		column := srm.ColumnName(field)
+		fieldValue := value.FieldByName(field.Name).Interface()
+		if isPK[column] {
+			pk[column] = fieldValue
+			continue
+		}
+		columns[column] = fieldValue
+	}
+	if len(pk) != len(isPK) {
+		return nil, nil, errors.Errorf("%T has no fields for all of its primary key (%s)", record,
+			strings.Join(model.PrimaryKey(record), ", "))
+	}
+	return columns, pk, nil
+}
+
+// taggedColumns returns the columns of modelType with any of the subTags.
+func taggedColumns(modelType reflect.Type, subTags ...string) map[string]bool {
+	columns := map[string]bool{}
+	if modelType == nil {
+		return columns
+	}
+	for _, subTag := range subTags {
+		for _, field := range model.TaggedFields(modelType, subTag) {
+			columns[srm.ColumnName(field)] = true
+		}
+	}
+	return columns
+}
@@ -0,0 +1,213 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/pkg/errors"
+)
+
+// TableConstraint is a constraint that can be added to a table with
+// AlterTableStatement.AddTableConstraint or AddConstraintTwoPhase.
+type TableConstraint interface {
+	// Definition returns the constraint as written after ADD CONSTRAINT <name>.
+	Definition() (string, error)
+}
+
+// ReferentialAction is what a foreign key does to the referencing rows when the referenced one
+// is deleted or updated.
+type ReferentialAction string
+
+const (
+	// NoAction fails the statement if referencing rows exist when the constraint is checked.
+	NoAction ReferentialAction = "NO ACTION"
+	// Restrict fails the statement if referencing rows exist, even if the check is deferred.
+	Restrict ReferentialAction = "RESTRICT"
+	// Cascade deletes or updates the referencing rows along.
+	Cascade ReferentialAction = "CASCADE"
+	// SetNull sets the referencing columns to NULL.
+	SetNull ReferentialAction = "SET NULL"
+	// SetDefault sets the referencing columns to their defaults.
+	SetDefault ReferentialAction = "SET DEFAULT"
+)
+
+// ForeignKeyConstraint builds a FOREIGN KEY constraint.
+type ForeignKeyConstraint struct {
+	columns    []string
+	refTable   string
+	refColumns []string
+	onDelete   ReferentialAction
+	onUpdate   ReferentialAction
+	notValid   bool
+}
+
+// ForeignKey returns a ForeignKeyConstraint on columns, what they reference is set with
+// References.
+func ForeignKey(columns ...string) *ForeignKeyConstraint {
+	return &ForeignKeyConstraint{columns: columns}
+}
+
+// References sets the referenced table and columns, if no columns are passed the primary key
+// of table is referenced.
+func (f *ForeignKeyConstraint) References(table string, columns ...string) *ForeignKeyConstraint {
+	f.refTable = table
+	f.refColumns = columns
+	return f
+}
+
+// OnDelete sets what happens to the referencing rows when the referenced one is deleted.
+func (f *ForeignKeyConstraint) OnDelete(action ReferentialAction) *ForeignKeyConstraint {
+	f.onDelete = action
+	return f
+}
+
+// OnUpdate sets what happens to the referencing rows when the referenced columns are updated.
+func (f *ForeignKeyConstraint) OnUpdate(action ReferentialAction) *ForeignKeyConstraint {
+	f.onUpdate = action
+	return f
+}
+
+// NotValid adds the constraint without checking the existing rows, see
+// AlterTableStatement.AddConstraintNotValid.
+func (f *ForeignKeyConstraint) NotValid() *ForeignKeyConstraint {
+	f.notValid = true
+	return f
+}
+
+// Definition implements TableConstraint.
+func (f *ForeignKeyConstraint) Definition() (string, error) {
+	if len(f.columns) == 0 || f.refTable == "" {
+		return "", errors.Errorf("foreign key needs columns and the table they reference")
+	}
+	if len(f.refColumns) != 0 && len(f.refColumns) != len(f.columns) {
+		return "", errors.Errorf("foreign key on %d columns references %d", len(f.columns), len(f.refColumns))
+	}
+	definition := "FOREIGN KEY (" + strings.Join(f.columns, ", ") + ") REFERENCES " + f.refTable
+	if len(f.refColumns) != 0 {
+		definition += " (" + strings.Join(f.refColumns, ", ") + ")"
+	}
+	if f.onDelete != "" {
+		definition += " ON DELETE " + string(f.onDelete)
+	}
+	if f.onUpdate != "" {
+		definition += " ON UPDATE " + string(f.onUpdate)
+	}
+	if f.notValid {
+		definition += " NOT VALID"
+	}
+	return definition, nil
+}
+
+// CheckConstraint builds a CHECK constraint.
+type CheckConstraint struct {
+	expr     string
+	args     []interface{}
+	notValid bool
+}
+
+// Check returns a CheckConstraint for the boolean expression expr, its ? marks are replaced by
+// args, as SQL literals since DDL can't take arguments, ie Check("status IN (?, ?)", "on", "off").
+func Check(expr string, args ...interface{}) *CheckConstraint {
+	return &CheckConstraint{expr: expr, args: args}
+}
+
+// NotValid adds the constraint without checking the existing rows, see
+// AlterTableStatement.AddConstraintNotValid.
+func (c *CheckConstraint) NotValid() *CheckConstraint {
+	c.notValid = true
+	return c
+}
+
+// Definition implements TableConstraint.
+func (c *CheckConstraint) Definition() (string, error) {
+	expr, err := inlineArgs(c.expr, c.args)
+	if err != nil {
+		return "", errors.Wrap(err, "check constraint")
+	}
+	definition := "CHECK (" + expr + ")"
+	if c.notValid {
+		definition += " NOT VALID"
+	}
+	return definition, nil
+}
+
+// UniqueConstraint builds a UNIQUE constraint, postgres can't add them as NOT VALID, for big
+// tables use AddConstraintTwoPhase, which builds the index first without blocking writes.
+type UniqueConstraint struct {
+	columns    []string
+	usingIndex string
+}
+
+// Unique returns a UniqueConstraint on columns.
+func Unique(columns ...string) *UniqueConstraint {
+	return &UniqueConstraint{columns: columns}
+}
+
+// UsingIndex makes the constraint use an existing unique index instead of building one, the
+// index is renamed after the constraint.
+func (u *UniqueConstraint) UsingIndex(index string) *UniqueConstraint {
+	u.usingIndex = index
+	return u
+}
+
+// Definition implements TableConstraint.
+func (u *UniqueConstraint) Definition() (string, error) {
+	if u.usingIndex != "" {
+		return "UNIQUE USING INDEX " + u.usingIndex, nil
+	}
+	if len(u.columns) == 0 {
+		return "", errors.Errorf("unique constraint needs columns or an index")
+	}
+	return "UNIQUE (" + strings.Join(u.columns, ", ") + ")", nil
+}
+
+// AddConstraintTwoPhase adds constraint to table without holding a lock that blocks writes
+// while the existing rows are checked, which can take long for big tables:
+// foreign keys and checks are added as NOT VALID and then validated, as separate statements so
+// the validation only takes a lock that allows writes; unique constraints are added on a unique
+// index built concurrently.
+// db must not be a transaction, as the point is not holding the lock of the first step in the
+// second. If building the index concurrently fails it is left invalid and must be dropped.
+func AddConstraintTwoPhase(ctx context.Context, db connection.DB, table, name string, constraint TableConstraint) error {
+	if db.IsTransaction() {
+		return errors.Errorf("adding constraint %s in two phases can't be done in a transaction", name)
+	}
+	switch c := constraint.(type) {
+	case *ForeignKeyConstraint:
+		notValid := *c
+		constraint = notValid.NotValid()
+	case *CheckConstraint:
+		notValid := *c
+		constraint = notValid.NotValid()
+	case *UniqueConstraint:
+		if c.usingIndex == "" {
+			err := CreateIndex(name).On(table, c.columns...).Unique().Concurrently().Exec(ctx, db)
+			if err != nil {
+				return errors.Wrapf(err, "building the index of constraint %s", name)
+			}
+			constraint = Unique().UsingIndex(name)
+		}
+		return AlterTable(table).AddTableConstraint(name, constraint).Exec(ctx, db)
+	default:
+		return errors.Errorf("constraint %s: %T can't be added in two phases", name, constraint)
+	}
+	if err := AlterTable(table).AddTableConstraint(name, constraint).Exec(ctx, db); err != nil {
+		return err
+	}
+	return AlterTable(table).ValidateConstraint(name).Exec(ctx, db)
+}
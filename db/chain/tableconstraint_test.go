@@ -0,0 +1,112 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestTableConstraints(t *testing.T) {
+	tests := []struct {
+		name       string
+		constraint TableConstraint
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "foreign key",
+			constraint: ForeignKey("user_id").References("users", "id").OnDelete(Cascade).OnUpdate(Restrict),
+			want:       "FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE ON UPDATE RESTRICT",
+		},
+		{
+			name:       "foreign key to primary key not valid",
+			constraint: ForeignKey("user_id").References("users").NotValid(),
+			want:       "FOREIGN KEY (user_id) REFERENCES users NOT VALID",
+		},
+		{
+			name:       "foreign key without references",
+			constraint: ForeignKey("user_id"),
+			wantErr:    true,
+		},
+		{
+			name:       "foreign key column count mismatch",
+			constraint: ForeignKey("user_id").References("users", "id", "tenant"),
+			wantErr:    true,
+		},
+		{
+			name:       "check",
+			constraint: Check("status IN (?, ?)", "on", "off").NotValid(),
+			want:       "CHECK (status IN ('on', 'off')) NOT VALID",
+		},
+		{
+			name:       "check missing args",
+			constraint: Check("price > ?"),
+			wantErr:    true,
+		},
+		{
+			name:       "unique",
+			constraint: Unique("tenant", "email"),
+			want:       "UNIQUE (tenant, email)",
+		},
+		{
+			name:       "unique using index",
+			constraint: Unique().UsingIndex("users_email_idx"),
+			want:       "UNIQUE USING INDEX users_email_idx",
+		},
+		{
+			name:       "unique without columns",
+			constraint: Unique(),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.constraint.Definition()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Definition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Definition() got %q want %q", got, tt.want)
+			}
+		})
+	}
+
+	statement, err := AlterTable("orders").AddTableConstraint("orders_user_fk", ForeignKey("user_id").References("users")).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement != "ALTER TABLE orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users" {
+		t.Errorf("unexpected statement %q", statement)
+	}
+	if _, err := AlterTable("orders").AddTableConstraint("orders_user_fk", ForeignKey("user_id")).Render(); err == nil {
+		t.Errorf("expected the constraint error to be returned by Render")
+	}
+}
+
+func TestAddConstraintTwoPhase(t *testing.T) {
+	ctx := context.Background()
+	db := &txDB{}
+	fk := ForeignKey("user_id").References("users")
+	if err := AddConstraintTwoPhase(ctx, db, "orders", "orders_user_fk", fk); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddConstraintTwoPhase(ctx, db, "users", "users_email_key", Unique("email")); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"ALTER TABLE orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users NOT VALID",
+		"ALTER TABLE orders VALIDATE CONSTRAINT orders_user_fk",
+		"CREATE UNIQUE INDEX CONCURRENTLY users_email_key ON users (email)",
+		"ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE USING INDEX users_email_key",
+	}
+	if diff := deep.Equal(db.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	if fk.notValid {
+		t.Errorf("expected the passed constraint to be left as it was")
+	}
+	if err := AddConstraintTwoPhase(ctx, &txDB{tx: true}, "orders", "orders_user_fk", fk); err == nil {
+		t.Errorf("expected an error in a transaction")
+	}
+}
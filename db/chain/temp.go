@@ -0,0 +1,70 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// OnCommit is what happens to a temporary table when the transaction that created it ends.
+type OnCommit string
+
+const (
+	// OnCommitDrop drops the temporary table, it only makes sense in a transaction.
+	OnCommitDrop OnCommit = "DROP"
+	// OnCommitDeleteRows empties the temporary table but keeps it for the rest of the session.
+	OnCommitDeleteRows OnCommit = "DELETE ROWS"
+	// OnCommitPreserveRows keeps the temporary table as it is until the session ends.
+	OnCommitPreserveRows OnCommit = "PRESERVE ROWS"
+)
+
+// IntoTemp creates a temporary table called name with the results of the chain and returns a
+// new chain, on the same DB, that selects everything from it; use it for the intermediate steps
+// of multi step jobs. The arguments of the chain are inlined as SQL literals since DDL can't
+// take arguments.
+// Temporary tables belong to the session that created them, so this needs the chain's DB to be
+// a transaction unless the pool holds a single connection, with OnCommitDrop a transaction is
+// always required.
+func (ec *ExpressionChain) IntoTemp(ctx context.Context, name string, onCommit OnCommit) (*ExpressionChain, error) {
+	if ec.hasErr() {
+		return nil, ec.getErr()
+	}
+	if !ec.queryable() {
+		return nil, errors.Errorf("cannot create a temporary table from statements other than SELECT")
+	}
+	if onCommit == OnCommitDrop && !ec.db.IsTransaction() {
+		return nil, errors.Errorf("temporary table %s would be dropped as soon as it is created, "+
+			"it must be created in a transaction", name)
+	}
+	q, args, err := ec.RenderRaw()
+	if err != nil {
+		return nil, errors.Wrapf(err, "rendering the query of temporary table %s", name)
+	}
+	q, err = inlineArgs(q, args)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rendering the query of temporary table %s", name)
+	}
+	statement := "CREATE TEMPORARY TABLE " + name
+	if onCommit != "" {
+		statement += " ON COMMIT " + string(onCommit)
+	}
+	statement += " AS (" + q + ")"
+	if err := ec.db.Exec(ctx, statement); err != nil {
+		return nil, errors.Wrapf(err, "creating temporary table %s", name)
+	}
+	return New(ec.db).Select("*").Table(name), nil
+}
@@ -0,0 +1,40 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestIntoTemp(t *testing.T) {
+	ctx := context.Background()
+	db := &txDB{tx: true}
+	query := New(db).Select("id").Table("users").AndWhere("name = ?", "Jane")
+	temp, err := query.IntoTemp(ctx, "stage_users", OnCommitDrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"CREATE TEMPORARY TABLE stage_users ON COMMIT DROP AS (SELECT id FROM users WHERE name = 'Jane')"}
+	if diff := deep.Equal(db.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	q, _, err := temp.AndWhere("id > ?", 3).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q != "SELECT * FROM stage_users WHERE id > $1" {
+		t.Errorf("expected the returned chain to query the temporary table got %q", q)
+	}
+
+	db = &txDB{}
+	if _, err := New(db).Select("id").Table("users").IntoTemp(ctx, "stage_users", OnCommitDrop); err == nil {
+		t.Errorf("expected an error creating a table dropped on commit outside of a transaction")
+	}
+	if _, err := New(db).Select("id").Table("users").IntoTemp(ctx, "stage_users", OnCommitPreserveRows); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(db).Delete().Table("users").IntoTemp(ctx, "stage_users", ""); err == nil {
+		t.Errorf("expected an error creating a temporary table from a DELETE")
+	}
+}
@@ -16,6 +16,7 @@ package chain
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
 	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
@@ -36,7 +37,8 @@ func (ec *ExpressionChain) QueryIter(ctx context.Context) (connection.ResultFetc
 		return func(interface{}) (bool, func(), error) { return false, func() {}, nil },
 			errors.Wrap(err, "rendering query to query with iterator")
 	}
-	return ec.db.QueryIter(ctx, q, ec.mainOperation.fields(), args...)
+	iter, err := ec.db.QueryIter(ec.operationContext(ctx), q, ec.fields(ctx), args...)
+	return ec.debugIter(iter, q, args), ec.debugErr(err, q, args)
 }
 
 // Query is a convenience function to run the current chain through the db query with iterator.
@@ -53,7 +55,8 @@ func (ec *ExpressionChain) Query(ctx context.Context) (connection.ResultFetch, e
 		return func(interface{}) error { return nil },
 			errors.Wrap(err, "rendering query to query")
 	}
-	return ec.db.Query(ctx, q, ec.mainOperation.fields(), args...)
+	fetch, err := ec.db.Query(ec.operationContext(ctx), q, ec.fields(ctx), args...)
+	return ec.debugFetch(fetch, q, args), ec.debugErr(err, q, args)
 }
 
 // QueryPrimitive is a convenience function to run the current chain through the db query.
@@ -70,18 +73,21 @@ func (ec *ExpressionChain) QueryPrimitive(ctx context.Context) (connection.Resul
 		return func(interface{}) error { return nil },
 			errors.Wrap(err, "rendering query to query")
 	}
-	fields := ec.mainOperation.fields()
+	fields := ec.fields(ctx)
 	if len(fields) != 1 {
 		return func(interface{}) error { return nil },
 			errors.Errorf("querying for primitives can be done for 1 column only, got %d",
 				len(fields))
 	}
-	return ec.db.QueryPrimitive(ctx, q, fields[0], args...)
+	fetch, err := ec.db.QueryPrimitive(ec.operationContext(ctx), q, fields[0], args...)
+	return ec.debugFetch(fetch, q, args), ec.debugErr(err, q, args)
 }
 
-// Fetch is a one step version of the Query->fetch typical workflow.
+// Fetch is a one step version of the Query->fetch typical workflow, if no table was set it is
+// inferred from receiver (see InferTable), the relations passed to Preload are loaded and the
+// AfterFetch hooks run.
 func (ec *ExpressionChain) Fetch(ctx context.Context, receiver interface{}) error {
-	fetch, err := ec.Query(ctx)
+	fetch, err := ec.InferTable(receiver).Query(ctx)
 	if err != nil {
 		return errors.Wrap(err, "querying")
 	}
@@ -89,8 +95,11 @@ func (ec *ExpressionChain) Fetch(ctx context.Context, receiver interface{}) erro
 	if err != nil {
 		return errors.Wrap(err, "fetching")
 	}
+	if err := LoadRelations(ctx, ec.db, receiver, ec.preload...); err != nil {
+		return errors.Wrap(err, "preloading")
+	}
 
-	return nil
+	return ec.AfterFetch(ctx, receiver)
 }
 
 // FetchIntoPrimitive is a one step version of the QueryPrimitive->fetch typical workflow.
@@ -113,26 +122,101 @@ func (ec *ExpressionChain) Exec(ctx context.Context) (execError error) {
 }
 
 // ExecResult executes the chain and returns rows affected info, works for Insert and Update
+// InsertMulti chains with more rows than their InsertMultiCopyThreshold are inserted with COPY.
 func (ec *ExpressionChain) ExecResult(ctx context.Context) (rowsAffected int64, execError error) {
+	if columns, rows, ok := ec.copyRows(); ok {
+		execError = ec.execRendered(ctx, noRender, func(ctx context.Context, db connection.DB, _ string, _ []interface{}) (int64, error) {
+			var err error
+			rowsAffected, err = ec.insertByCopy(ctx, db, columns, rows)
+			return rowsAffected, err
+		})
+		return rowsAffected, execError
+	}
+	execError = ec.exec(ctx, func(ctx context.Context, db connection.DB, q string, args []interface{}) (int64, error) {
+		var err error
+		rowsAffected, err = db.ExecResult(ctx, q, args...)
+		return rowsAffected, err
+	})
+	return rowsAffected, execError
+}
+
+// ExecInfo executes the chain and returns the command tag of the statement, works for Insert
+// and Update
+func (ec *ExpressionChain) ExecInfo(ctx context.Context) (tag connection.CommandTag, execError error) {
+	execError = ec.exec(ctx, func(ctx context.Context, db connection.DB, q string, args []interface{}) (int64, error) {
+		var err error
+		tag, err = db.ExecInfo(ctx, q, args...)
+		return tag.RowsAffected, err
+	})
+	return tag, execError
+}
+
+// ExecInto executes the chain, an INSERT or UPDATE with RETURNING, and fetches the first row
+// returned into receiver, ie a struct passed to InsertStruct to get the generated keys back.
+// The hooks of InsertStruct and UpdateStruct run as they do with Exec.
+func (ec *ExpressionChain) ExecInto(ctx context.Context, receiver interface{}) error {
+	if !ec.hasErr() && !ec.queryable() {
+		return errors.Errorf("cannot invoke exec into with statements without RETURNING, please use Exec")
+	}
+	return ec.exec(ctx, func(ctx context.Context, db connection.DB, q string, args []interface{}) (int64, error) {
+		fetch, err := db.QueryIter(ctx, q, ec.fields(ctx), args...)
+		if gaumErrors.IsNoRows(err) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		_, closer, err := fetch(receiver)
+		if closer != nil {
+			closer()
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "fetching returned row")
+		}
+		return 1, nil
+	})
+}
+
+// exec renders the chain and passes it to run along with the db to use, which will be a
+// transaction if the chain has a Set, and the context to run it with, run returns the amount of
+// rows affected.
+func (ec *ExpressionChain) exec(ctx context.Context,
+	run func(ctx context.Context, db connection.DB, q string, args []interface{}) (int64, error)) error {
+	return ec.execRendered(ctx, ec.Render, run)
+}
+
+// noRender is the render of execRendered for the runs that build their own statements.
+func noRender() (string, []interface{}, error) {
+	return "", nil, nil
+}
+
+// execRendered is exec but the statement passed to run is the one returned by render, which
+// is called once the hooks ran, an empty one is not added to the errors by debugErr.
+func (ec *ExpressionChain) execRendered(ctx context.Context, render func() (string, []interface{}, error),
+	run func(ctx context.Context, db connection.DB, q string, args []interface{}) (int64, error)) (execError error) {
 	if ec.hasErr() {
 		execError = ec.getErr()
 		return
 	}
+	if execError = ec.beforeRecordHooks(ctx); execError != nil {
+		return execError
+	}
 	var q string
 	var args []interface{}
-	q, args, execError = ec.Render()
+	q, args, execError = render()
 	if execError != nil {
-		return 0, errors.Wrap(execError, "rendering query to exec")
+		return errors.Wrap(execError, "rendering query to exec")
 	}
 	var db connection.DB
 	// default we use the current db and transaction
 	db = ec.db
+	ctx = ec.operationContext(ctx)
 
 	// If Set is implied, we need to start a transaction
 	if ec.set != "" && !ec.db.IsTransaction() {
 		db, execError = ec.db.BeginTransaction(ctx)
 		if execError != nil {
-			return 0, errors.Wrap(execError, "starting transaction to run SET LOCAL")
+			return errors.Wrap(execError, "starting transaction to run SET LOCAL")
 		}
 		defer func() {
 			if execError != nil {
@@ -150,11 +234,41 @@ func (ec *ExpressionChain) ExecResult(ctx context.Context) (rowsAffected int64,
 	if ec.set != "" && ec.db.IsTransaction() {
 		execError = db.Set(ctx, ec.set)
 		if execError != nil {
-			return 0, errors.Wrap(execError, "running set for this transaction")
+			return errors.Wrap(execError, "running set for this transaction")
 		}
 	}
 
-	return db.ExecResult(ctx, q, args...)
+	var rowsAffected int64
+	rowsAffected, execError = run(ctx, db, q, args)
+	if q != "" {
+		execError = ec.debugErr(execError, q, args)
+	}
+	if execError != nil {
+		return execError
+	}
+	if execError = ec.checkVersion(rowsAffected); execError != nil {
+		return execError
+	}
+	return ec.afterRecordHooks(ctx)
+}
+
+// operationContext returns ctx marked with the kind of statement of the chain so it is
+// reported as such to the connection metrics.
+func (ec *ExpressionChain) operationContext(ctx context.Context) context.Context {
+	if ec.mainOperation == nil {
+		return ctx
+	}
+	switch ec.mainOperation.segment {
+	case sqlSelect:
+		return connection.WithOperation(ctx, "select")
+	case sqlInsert, sqlInsertMulti:
+		return connection.WithOperation(ctx, "insert")
+	case sqlUpdate:
+		return connection.WithOperation(ctx, "update")
+	case sqlDelete:
+		return connection.WithOperation(ctx, "delete")
+	}
+	return ctx
 }
 
 // Raw executes the query and tries to scan the result into fields without much safeguard nor
@@ -170,13 +284,44 @@ func (ec *ExpressionChain) Raw(ctx context.Context, fields ...interface{}) error
 	if err != nil {
 		return errors.Wrap(err, "rendering query to raw query")
 	}
-	err = ec.db.Raw(ctx, q, args, fields...)
+	err = ec.db.Raw(ec.operationContext(ctx), q, args, fields...)
 	if err == gaumErrors.ErrNoRows {
 		return err
 	}
 	return errors.Wrap(err, "running a raw query from within a chain")
 }
 
+// Count returns the amount of rows the query, a SELECT, yields, it is run as a sub query so
+// GROUP BY, DISTINCT, LIMIT and such are honored.
+func (ec *ExpressionChain) Count(ctx context.Context) (int64, error) {
+	var count int64
+	err := ec.wrappedRaw(ctx, "SELECT count(*) FROM (%s) AS gaum_count", &count)
+	return count, errors.Wrap(err, "counting")
+}
+
+// Exists returns true if the query, a SELECT, yields at least one row.
+func (ec *ExpressionChain) Exists(ctx context.Context) (bool, error) {
+	var exists bool
+	err := ec.wrappedRaw(ctx, "SELECT EXISTS (%s)", &exists)
+	return exists, errors.Wrap(err, "checking existence")
+}
+
+// wrappedRaw runs the query rendered into the wrapper format and scans the result into field.
+func (ec *ExpressionChain) wrappedRaw(ctx context.Context, wrapper string, field interface{}) error {
+	if ec.hasErr() {
+		return ec.getErr()
+	}
+	if ec.mainOperation == nil || ec.mainOperation.segment != sqlSelect {
+		return errors.Errorf("only SELECT statements can be wrapped")
+	}
+	q, args, err := ec.Render()
+	if err != nil {
+		return errors.Wrap(err, "rendering query to wrap")
+	}
+	q = fmt.Sprintf(wrapper, q)
+	return ec.debugErr(ec.db.Raw(ec.operationContext(ctx), q, args, field), q, args)
+}
+
 // TODO add batch running of many chains.
 
 // TODO Inspect stacklocation and try re-run queryies if arguments have similiar memory address to save serialization time
@@ -0,0 +1,71 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+	"github.com/pkg/errors"
+)
+
+// setCreateTimestamps sets the autocreate and autoupdate fields of record, a pointer to a
+// struct, that hold the zero value to now so an explicit value can still be inserted.
+func setCreateTimestamps(record interface{}, now time.Time) error {
+	value := reflect.ValueOf(record).Elem()
+	fields := append(model.TaggedFields(record, srm.SubTagNameAutoCreate),
+		model.TaggedFields(record, srm.SubTagNameAutoUpdate)...)
+	for _, field := range fields {
+		fieldValue := value.FieldByName(field.Name)
+		switch {
+		case field.Type == timeType:
+			if fieldValue.Interface().(time.Time).IsZero() {
+				fieldValue.Set(reflect.ValueOf(now))
+			}
+		case field.Type == reflect.PtrTo(timeType):
+			if fieldValue.IsNil() || fieldValue.Elem().Interface().(time.Time).IsZero() {
+				fieldValue.Set(reflect.ValueOf(&now))
+			}
+		default:
+			return errors.Errorf("automatic timestamp field %s of %T is a %s, not a time.Time",
+				field.Name, record, field.Type)
+		}
+	}
+	return nil
+}
+
+// renderAutoUpdate writes the assignment to CURRENT_TIMESTAMP of the autoupdate columns of the
+// model of the chain that the update expression does not already assign.
+func (ec *ExpressionChain) renderAutoUpdate(dst *queryWriter) {
+	columns := taggedColumns(ec.modelType, srm.SubTagNameAutoUpdate)
+	if len(columns) == 0 {
+		return
+	}
+	for _, assignment := range strings.Split(ec.mainOperation.expression, ",") {
+		column := strings.TrimSpace(strings.SplitN(assignment, "=", 2)[0])
+		delete(columns, column)
+	}
+	for _, field := range model.TaggedFields(ec.modelType, srm.SubTagNameAutoUpdate) {
+		column := srm.ColumnName(field)
+		if columns[column] {
+			dst.WriteString(", ")
+			dst.WriteString(column)
+			dst.WriteString(" = CURRENT_TIMESTAMP")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package chain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/go-test/deep"
+)
+
+type timestampedRecord struct {
+	ID        int `gaum:"field_name:id"`
+	Name      string
+	CreatedAt time.Time  `gaum:"autocreate"`
+	UpdatedAt *time.Time `gaum:"autoupdate"`
+}
+
+func TestAutomaticTimestamps(t *testing.T) {
+	ctx := context.Background()
+	db := &execDB{}
+	record := &timestampedRecord{Name: "jane"}
+	if err := New(db).InsertStruct(record).Table("records").Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if record.CreatedAt.IsZero() || record.UpdatedAt == nil || !record.UpdatedAt.Equal(record.CreatedAt) {
+		t.Fatalf("expected both timestamps to be set got %v and %v", record.CreatedAt, record.UpdatedAt)
+	}
+	if diff := deep.Equal(db.args[0], []interface{}{record.CreatedAt, "jane", record.UpdatedAt}); diff != nil {
+		t.Error(diff)
+	}
+
+	record.ID = 1
+	if err := New(db).UpdateStruct(record).Table("records").Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := model.Register(&timestampedRecord{}, model.Table("records")); err != nil {
+		t.Fatal(err)
+	}
+	defer model.Unregister(&timestampedRecord{})
+	update := New(db).Model(&timestampedRecord{}).UpdateMap(map[string]interface{}{"name": "janet"}).
+		AndWhere("id = ?", 1)
+	if err := update.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	explicit := New(db).Model(&timestampedRecord{}).
+		UpdateMap(map[string]interface{}{"name": "janet", "updated_at": time.Time{}})
+	if err := explicit.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"INSERT INTO records (created_at, name, updated_at) VALUES ($1, $2, $3)",
+		"UPDATE records SET name = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		"UPDATE records SET name = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		"UPDATE records SET name = $1, updated_at = $2",
+	}
+	if diff := deep.Equal(db.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	type wrongType struct {
+		CreatedAt string `gaum:"autocreate"`
+	}
+	if !New(db).InsertStruct(&wrongType{}).hasErr() {
+		t.Errorf("expected an error for an automatic timestamp that is not a time.Time")
+	}
+}
@@ -0,0 +1,226 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+	"github.com/pkg/errors"
+)
+
+// DriftKind tells what is wrong between a struct and the table it maps to.
+type DriftKind string
+
+const (
+	// DriftMissingField is a column of the table with no field in the struct.
+	DriftMissingField DriftKind = "column has no field"
+	// DriftMissingColumn is a field of the struct with no column in the table.
+	DriftMissingColumn DriftKind = "field has no column"
+	// DriftType is a field whose Go type can't hold the values of its column.
+	DriftType DriftKind = "type mismatch"
+	// DriftNullable is a nullable column mapped to a field that can't hold a NULL.
+	DriftNullable DriftKind = "nullable column in a non nullable field"
+)
+
+// Drift is a difference between a struct and the table it maps to.
+type Drift struct {
+	Kind DriftKind
+	// Column is the name of the column, it is the one the field would map to for
+	// DriftMissingColumn.
+	Column string
+	// Field is the name of the struct field, empty for DriftMissingField.
+	Field string
+	// ColumnType is the SQL type of the column, empty for DriftMissingColumn.
+	ColumnType string
+	// FieldType is the Go type of the field, empty for DriftMissingField.
+	FieldType string
+}
+
+// String returns a human readable description of the drift.
+func (d Drift) String() string {
+	switch d.Kind {
+	case DriftMissingField:
+		return fmt.Sprintf("column %s (%s) has no field", d.Column, d.ColumnType)
+	case DriftMissingColumn:
+		return fmt.Sprintf("field %s (%s) has no column %s", d.Field, d.FieldType, d.Column)
+	}
+	return fmt.Sprintf("%s: column %s (%s) is mapped to field %s (%s)", d.Kind, d.Column, d.ColumnType,
+		d.Field, d.FieldType)
+}
+
+// SchemaDriftError is returned by Verify when a struct does not match its table.
+type SchemaDriftError struct {
+	Table  string
+	Drifts []Drift
+}
+
+// Error implements error.
+func (e *SchemaDriftError) Error() string {
+	drifts := make([]string, len(e.Drifts))
+	for i := range e.Drifts {
+		drifts[i] = e.Drifts[i].String()
+	}
+	return fmt.Sprintf("%s does not match its struct: %s", e.Table, strings.Join(drifts, ", "))
+}
+
+const tableColumnTypesQuery = "SELECT attname::text AS name, format_type(atttypid, atttypmod) AS type, " +
+	"attnotnull AS not_null FROM pg_catalog.pg_attribute " +
+	"WHERE attrelid = $1::text::regclass AND attnum > 0 AND NOT attisdropped ORDER BY attnum"
+
+type tableColumn struct {
+	Name    string
+	Type    string
+	NotNull bool
+}
+
+// Verify compares model, a struct or a pointer to one, with the columns of table, as found in
+// db, and returns a *SchemaDriftError listing the columns with no field, the fields with no
+// column, the fields whose Go type can't hold the values of the column and the nullable columns
+// mapped to fields that can't hold a NULL (unless tagged null), so the drift between the Go
+// models and the schema shows up before a scan fails.
+// Fields are mapped to columns, and their types inferred, as CreateTableFromStruct does.
+func Verify(ctx context.Context, db connection.DB, model interface{}, table string) error {
+	modelType := reflect.TypeOf(model)
+	for modelType != nil && modelType.Kind() == reflect.Ptr {
+		modelType = modelType.Elem()
+	}
+	if modelType == nil || modelType.Kind() != reflect.Struct {
+		return errors.Errorf("expected a struct or a pointer to one got %T", model)
+	}
+	fetch, err := db.Query(ctx, tableColumnTypesQuery, []string{"name", "type", "not_null"}, table)
+	if err != nil {
+		return errors.Wrapf(err, "querying columns of %s", table)
+	}
+	var columns []tableColumn
+	if err := fetch(&columns); err != nil {
+		return errors.Wrapf(err, "fetching columns of %s", table)
+	}
+	if drifts := schemaDrifts(modelType, columns); len(drifts) > 0 {
+		return &SchemaDriftError{Table: table, Drifts: drifts}
+	}
+	return nil
+}
+
+func schemaDrifts(modelType reflect.Type, columns []tableColumn) []Drift {
+	var drifts []Drift
+	fields := map[string]reflect.StructField{}
+	for _, field := range columnFields(modelType) {
+		fields[srm.ColumnName(field)] = field
+	}
+	found := map[string]bool{}
+	for _, column := range columns {
+		field, ok := fields[column.Name]
+		if !ok {
+			drifts = append(drifts, Drift{Kind: DriftMissingField, Column: column.Name, ColumnType: column.Type})
+			continue
+		}
+		found[column.Name] = true
+		drift := Drift{Column: column.Name, ColumnType: column.Type, Field: field.Name, FieldType: field.Type.String()}
+		options := srm.TagOptions(field)
+		sqlType, nullable, err := sqlTypeOf(field.Type)
+		if tagType := options[srm.SubTagNameType]; tagType != "" {
+			sqlType = tagType
+		} else if err != nil {
+			// nothing to compare with, the field type knows how to scan itself or it will fail.
+			continue
+		}
+		if !compatibleTypes(sqlType, column.Type) {
+			drift.Kind = DriftType
+			drifts = append(drifts, drift)
+		}
+		if _, ok := options[srm.SubTagNameNull]; !ok && err == nil && !nullable && !column.NotNull {
+			drift.Kind = DriftNullable
+			drifts = append(drifts, drift)
+		}
+	}
+	for _, field := range columnFields(modelType) {
+		if name := srm.ColumnName(field); !found[name] {
+			drifts = append(drifts, Drift{Kind: DriftMissingColumn, Column: name, Field: field.Name,
+				FieldType: field.Type.String()})
+		}
+	}
+	return drifts
+}
+
+// typeFamilies groups the types whose values can be scanned into the same Go types.
+var typeFamilies = map[string]string{
+	"smallint": "integer", "integer": "integer", "bigint": "integer", "int": "integer",
+	"int2": "integer", "int4": "integer", "int8": "integer",
+	"smallserial": "integer", "serial": "integer", "bigserial": "integer",
+	"numeric": "numeric", "decimal": "numeric",
+	"real": "float", "double precision": "float", "float4": "float", "float8": "float",
+	"text": "text", "character varying": "text", "varchar": "text", "character": "text",
+	"char": "text", "citext": "text", "name": "text",
+	"boolean": "boolean", "bool": "boolean",
+	"timestamp with time zone": "timestamp", "timestamp without time zone": "timestamp",
+	"timestamptz": "timestamp", "timestamp": "timestamp", "date": "timestamp",
+	"json": "json", "jsonb": "json",
+	"bytea": "bytea",
+	"uuid":  "uuid",
+}
+
+// typeFamily returns the family of the passed SQL type, arrays are the family of their
+// elements followed by [], unknown types are their own family.
+func typeFamily(sqlType string) string {
+	sqlType = strings.ToLower(strings.TrimSpace(sqlType))
+	if strings.HasSuffix(sqlType, "[]") {
+		return typeFamily(strings.TrimSuffix(sqlType, "[]")) + "[]"
+	}
+	if i := strings.Index(sqlType, "("); i != -1 {
+		// drop the modifiers, ie varchar(10) or timestamp(3) with time zone.
+		end := strings.Index(sqlType[i:], ")")
+		if end == -1 {
+			end = len(sqlType) - i - 1
+		}
+		sqlType = strings.Join(strings.Fields(sqlType[:i]+" "+sqlType[i+end+1:]), " ")
+	}
+	if family, ok := typeFamilies[sqlType]; ok {
+		return family
+	}
+	return sqlType
+}
+
+// compatibleTypes returns true if values of the actual column type fit where the expected one
+// is, integers fit in any number and anything but arrays fit in text if it is not one of the
+// types we know about (ie, enums).
+func compatibleTypes(expected, actual string) bool {
+	expectedFamily, actualFamily := typeFamily(expected), typeFamily(actual)
+	if expectedFamily == actualFamily {
+		return true
+	}
+	switch expectedFamily {
+	case "numeric", "float":
+		return actualFamily == "integer" || actualFamily == "numeric" || actualFamily == "float"
+	case "text":
+		return actualFamily == "uuid" || !knownFamily(actualFamily) && !strings.HasSuffix(actualFamily, "[]")
+	case "bytea":
+		return actualFamily == "json"
+	}
+	return false
+}
+
+func knownFamily(family string) bool {
+	for _, known := range typeFamilies {
+		if known == family {
+			return true
+		}
+	}
+	return false
+}
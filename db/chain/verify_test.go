@@ -0,0 +1,72 @@
+package chain
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/go-test/deep"
+)
+
+// schemaDB answers the column types introspection query with columns.
+type schemaDB struct {
+	connection.DB
+	columns []tableColumn
+}
+
+func (s *schemaDB) Query(ctx context.Context, statement string, fields []string, args ...interface{}) (connection.ResultFetch, error) {
+	return func(dst interface{}) error {
+		reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(s.columns))
+		return nil
+	}, nil
+}
+
+type verifyUser struct {
+	ID      int64 `gaum:"field_name:id"`
+	Name    string
+	Email   *string
+	Score   int
+	Kind    string
+	Tags    []string
+	Created time.Time `gaum:"type:timestamp(3) with time zone"`
+	Nick    string    `gaum:"null"`
+	Gone    string
+}
+
+func TestVerify(t *testing.T) {
+	db := &schemaDB{columns: []tableColumn{
+		{Name: "id", Type: "bigint", NotNull: true},
+		{Name: "name", Type: "character varying(50)", NotNull: false},
+		{Name: "email", Type: "text"},
+		{Name: "score", Type: "numeric(10,2)", NotNull: true},
+		{Name: "kind", Type: "user_kind", NotNull: true},
+		{Name: "tags", Type: "text[]"},
+		{Name: "created", Type: "timestamp(3) with time zone", NotNull: true},
+		{Name: "nick", Type: "text"},
+		{Name: "extra", Type: "jsonb"},
+	}}
+	err := Verify(context.Background(), db, &verifyUser{}, "users")
+	driftErr, ok := err.(*SchemaDriftError)
+	if !ok {
+		t.Fatalf("expected a *SchemaDriftError got %v", err)
+	}
+	expected := []Drift{
+		{Kind: DriftNullable, Column: "name", ColumnType: "character varying(50)", Field: "Name", FieldType: "string"},
+		{Kind: DriftType, Column: "score", ColumnType: "numeric(10,2)", Field: "Score", FieldType: "int"},
+		{Kind: DriftMissingField, Column: "extra", ColumnType: "jsonb"},
+		{Kind: DriftMissingColumn, Column: "gone", Field: "Gone", FieldType: "string"},
+	}
+	if diff := deep.Equal(driftErr.Drifts, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	db.columns = db.columns[:8]
+	db.columns[1].NotNull = true
+	db.columns[3].Type = "integer"
+	db.columns = append(db.columns, tableColumn{Name: "gone", Type: "text", NotNull: true})
+	if err := Verify(context.Background(), db, verifyUser{}, "users"); err != nil {
+		t.Errorf("expected no drift got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"reflect"
+
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+)
+
+// versionField returns the field of modelType tagged version, if any.
+func versionField(modelType reflect.Type) (reflect.StructField, bool) {
+	if modelType == nil {
+		return reflect.StructField{}, false
+	}
+	fields := model.TaggedFields(modelType, srm.SubTagNameVersion)
+	if len(fields) == 0 {
+		return reflect.StructField{}, false
+	}
+	return fields[0], true
+}
+
+func isInteger(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// bumpVersion adds the increment of the version column to the update.
+func (ec *ExpressionChain) bumpVersion(column string) {
+	if ec.mainOperation.expression != "" {
+		ec.mainOperation.expression += ", "
+	}
+	ec.mainOperation.expression += column + " = " + column + " + 1"
+}
+
+// checkVersion returns a StaleObjectError if the update of a versioned record affected no rows,
+// otherwise it increments the version of the record as the update did.
+func (ec *ExpressionChain) checkVersion(rowsAffected int64) error {
+	if ec.record == nil || ec.recordOperation != sqlUpdate {
+		return nil
+	}
+	field, ok := versionField(ec.modelType)
+	if !ok {
+		return nil
+	}
+	version := reflect.ValueOf(ec.record).Elem().FieldByName(field.Name)
+	if rowsAffected == 0 {
+		return &gaumErrors.StaleObjectError{Table: ec.table, Version: version.Interface()}
+	}
+	switch version.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		version.SetInt(version.Int() + 1)
+	default:
+		version.SetUint(version.Uint() + 1)
+	}
+	return nil
+}
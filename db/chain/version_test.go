@@ -0,0 +1,53 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+)
+
+type versionedRecord struct {
+	ID      int `gaum:"field_name:id"`
+	Name    string
+	Version int32 `gaum:"version"`
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	ctx := context.Background()
+	db := &execDB{}
+	record := &versionedRecord{ID: 1, Name: "jane", Version: 3}
+	if err := New(db).UpdateStruct(record).Table("records").Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"UPDATE records SET name = $1, version = version + 1 WHERE id = $2 AND version = $3"}
+	if diff := deep.Equal(db.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(db.args, [][]interface{}{{"jane", 1, int32(3)}}); diff != nil {
+		t.Error(diff)
+	}
+	if record.Version != 4 {
+		t.Errorf("expected the version to be bumped to 4 got %d", record.Version)
+	}
+
+	db.noRows = true
+	err := New(db).UpdateStruct(record).Table("records").Exec(ctx)
+	var stale *gaumErrors.StaleObjectError
+	if !errors.As(err, &stale) || !errors.Is(err, gaumErrors.ErrStaleObject) {
+		t.Fatalf("expected a StaleObjectError got %v", err)
+	}
+	if stale.Table != "records" || stale.Version != int32(4) || record.Version != 4 {
+		t.Errorf("unexpected stale error %v for version %d", stale, record.Version)
+	}
+
+	type wrongType struct {
+		ID      int    `gaum:"field_name:id"`
+		Version string `gaum:"version"`
+	}
+	if !New(db).UpdateStruct(&wrongType{}).hasErr() {
+		t.Errorf("expected an error for a version that is not an integer")
+	}
+}
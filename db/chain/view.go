@@ -0,0 +1,127 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package chain
+
+import (
+	"context"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/pkg/errors"
+)
+
+// ViewStatement builds a CREATE VIEW or CREATE MATERIALIZED VIEW statement defined by a chain,
+// so the query is not duplicated between the code and the migrations.
+type ViewStatement struct {
+	name         string
+	query        *ExpressionChain
+	materialized bool
+	orReplace    bool
+	ifNotExists  bool
+	withNoData   bool
+}
+
+// CreateViewFromChain returns a ViewStatement for a view called name defined by query.
+func CreateViewFromChain(name string, query *ExpressionChain) *ViewStatement {
+	return &ViewStatement{name: name, query: query}
+}
+
+// CreateMaterializedView returns a ViewStatement for a materialized view called name defined
+// by query.
+func CreateMaterializedView(name string, query *ExpressionChain) *ViewStatement {
+	return &ViewStatement{name: name, query: query, materialized: true}
+}
+
+// OrReplace replaces the view if it exists, postgres only allows it if the new query has the
+// same columns, maybe with more at the end, and only for views that are not materialized.
+func (v *ViewStatement) OrReplace() *ViewStatement {
+	v.orReplace = true
+	return v
+}
+
+// IfNotExists makes the statement do nothing if the materialized view exists.
+func (v *ViewStatement) IfNotExists() *ViewStatement {
+	v.ifNotExists = true
+	return v
+}
+
+// WithNoData creates the materialized view empty, it can't be queried until refreshed.
+func (v *ViewStatement) WithNoData() *ViewStatement {
+	v.withNoData = true
+	return v
+}
+
+// Render returns the statement, the arguments of the query are inlined as SQL literals since
+// DDL can't take arguments.
+func (v *ViewStatement) Render() (string, error) {
+	switch {
+	case v.materialized && v.orReplace:
+		return "", errors.Errorf("materialized view %s can't be replaced, drop it first", v.name)
+	case !v.materialized && (v.ifNotExists || v.withNoData):
+		return "", errors.Errorf("view %s: IF NOT EXISTS and WITH NO DATA are only for materialized views", v.name)
+	}
+	q, args, err := v.query.RenderRaw()
+	if err != nil {
+		return "", errors.Wrapf(err, "rendering the query of view %s", v.name)
+	}
+	q, err = inlineArgs(q, args)
+	if err != nil {
+		return "", errors.Wrapf(err, "rendering the query of view %s", v.name)
+	}
+	statement := "CREATE "
+	if v.orReplace {
+		statement += "OR REPLACE "
+	}
+	if v.materialized {
+		statement += "MATERIALIZED "
+	}
+	statement += "VIEW "
+	if v.ifNotExists {
+		statement += "IF NOT EXISTS "
+	}
+	statement += v.name + " AS " + q
+	if v.withNoData {
+		statement += " WITH NO DATA"
+	}
+	return statement, nil
+}
+
+// String returns the rendered statement or the error if it can't be rendered.
+func (v *ViewStatement) String() string {
+	statement, err := v.Render()
+	if err != nil {
+		return "invalid view, err: " + err.Error()
+	}
+	return statement
+}
+
+// Exec creates the view in db.
+func (v *ViewStatement) Exec(ctx context.Context, db connection.DB) error {
+	statement, err := v.Render()
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(db.Exec(ctx, statement), "creating view %s", v.name)
+}
+
+// RefreshMaterializedView replaces the contents of the materialized view name with the current
+// results of its query. Concurrently does it without locking out reads, which requires a unique
+// index on the view and that it was populated before.
+func RefreshMaterializedView(ctx context.Context, db connection.DB, name string, concurrently bool) error {
+	statement := "REFRESH MATERIALIZED VIEW "
+	if concurrently {
+		statement += "CONCURRENTLY "
+	}
+	return errors.Wrapf(db.Exec(ctx, statement+name), "refreshing materialized view %s", name)
+}
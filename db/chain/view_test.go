@@ -0,0 +1,51 @@
+package chain
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCreateView(t *testing.T) {
+	query := NewNoDB().Select("user_id, sum(total) AS total").Table("orders").
+		AndWhere("status = ? AND user_id IN (?)", "paid", []int{1, 2}).GroupBy("user_id")
+	tests := []struct {
+		view *ViewStatement
+		want string
+	}{
+		{
+			view: CreateViewFromChain("paid_totals", query).OrReplace(),
+			want: "CREATE OR REPLACE VIEW paid_totals AS SELECT user_id, sum(total) AS total FROM orders " +
+				"WHERE status = 'paid' AND user_id IN (1, 2) GROUP BY user_id",
+		},
+		{
+			view: CreateMaterializedView("paid_totals_mv", query).IfNotExists().WithNoData(),
+			want: "CREATE MATERIALIZED VIEW IF NOT EXISTS paid_totals_mv AS SELECT user_id, sum(total) AS total " +
+				"FROM orders WHERE status = 'paid' AND user_id IN (1, 2) GROUP BY user_id WITH NO DATA",
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.view.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("expected:\n%s\ngot:\n%s", tt.want, got)
+		}
+	}
+	if _, err := CreateMaterializedView("mv", query).OrReplace().Render(); err == nil {
+		t.Errorf("expected an error replacing a materialized view")
+	}
+	if _, err := CreateViewFromChain("v", query).WithNoData().Render(); err == nil {
+		t.Errorf("expected an error for a view with no data")
+	}
+}
+
+func TestRefreshMaterializedView(t *testing.T) {
+	db := &txDB{}
+	if err := RefreshMaterializedView(context.Background(), db, "paid_totals_mv", true); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.statements) != 1 || db.statements[0] != "REFRESH MATERIALIZED VIEW CONCURRENTLY paid_totals_mv" {
+		t.Errorf("unexpected statements %v", db.statements)
+	}
+}
@@ -0,0 +1,171 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package conformance is a test suite for connection.DB implementations, it checks that a driver
+// behaves as gaum, and the code built on it, expects. The drivers in this repository run it and
+// any other implementation can do the same from its tests:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, func(t *testing.T) connection.DB {
+//			db, err := mydriver.Connector{ConnectionString: dsn}.Open(context.TODO(), &connection.Information{})
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return db
+//		})
+//	}
+//
+// The suite needs a Postgres (or something that speaks its SQL) where it can create a table,
+// connection_testing.Table, which it creates and fills before each test.
+package conformance
+
+import (
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection_testing"
+)
+
+// NewDB returns a DB, of the implementation under test, connected to the test database.
+type NewDB func(t *testing.T) connection.DB
+
+// OpenDB is NewDB for the tests that need to configure the DB, it must honor the passed
+// connection.Information, to which it can add what it needs to connect.
+type OpenDB func(t *testing.T, ci *connection.Information) connection.DB
+
+// Suite is the configuration of a conformance run.
+type Suite struct {
+	// NewDB is required.
+	NewDB NewDB
+	// OpenDB, if set, is used for the tests of the features configured with
+	// connection.Information (ie, metrics or logging), they are skipped otherwise.
+	OpenDB OpenDB
+	// Skip has the names (see Tests) of the tests to skip, for features the implementation
+	// does not support.
+	Skip []string
+}
+
+type test struct {
+	name string
+	run  func(t *testing.T, s Suite)
+}
+
+var tests = []test{
+	{"QueryIter", func(t *testing.T, s Suite) { connection_testing.DotestconnectorQueryiter(t, s.newDB) }},
+	{"Query", func(t *testing.T, s Suite) { connection_testing.DotestconnectorQuery(t, s.newDB) }},
+	{"QueryReflection", func(t *testing.T, s Suite) { connection_testing.DotestconnectorQueryreflection(t, s.newDB) }},
+	{"QueryStar", func(t *testing.T, s Suite) { connection_testing.DotestconnectorQuerystar(t, s.newDB) }},
+	{"QueryReturningWithError", func(t *testing.T, s Suite) { connection_testing.DotestconnectorQueryreturningwitherror(t, s.newDB) }},
+	{"QueryNoRows", func(t *testing.T, s Suite) { connection_testing.DotestconnectorQuerynorows(t, s.newDB) }},
+	{"Distinct", func(t *testing.T, s Suite) { connection_testing.DotestconnectorDistinct(t, s.newDB) }},
+	{"DistinctAs", func(t *testing.T, s Suite) { connection_testing.DotestconnectorDistinctas(t, s.newDB) }},
+	{"Raw", func(t *testing.T, s Suite) { connection_testing.DotestconnectorRaw(t, s.newDB) }},
+	{"Insert", func(t *testing.T, s Suite) { connection_testing.DotestconnectorInsert(t, s.newDB) }},
+	{"MultiInsert", func(t *testing.T, s Suite) { connection_testing.DotestconnectorMultiinsert(t, s.newDB) }},
+	{"InsertConstraint", func(t *testing.T, s Suite) { connection_testing.DotestconnectorInsertconstraint(t, s.newDB) }},
+	{"Transaction", func(t *testing.T, s Suite) { connection_testing.DotestconnectorTransaction(t, s.newDB) }},
+	{"QueryPrimitives", func(t *testing.T, s Suite) { connection_testing.DotestconnectorQueryprimitives(t, s.newDB) }},
+	{"RegressionReturning", func(t *testing.T, s Suite) { connection_testing.DotestconnectorRegressionReturning(t, s.newDB) }},
+	{"ExecResult", func(t *testing.T, s Suite) { connection_testing.DotestconnectorExecresult(t, s.newDB) }},
+	{"NestedTransaction", func(t *testing.T, s Suite) { connection_testing.DotestconnectorNestedTransaction(t, s.newDB) }},
+	{"FetchCancelledContext", func(t *testing.T, s Suite) { connection_testing.DotestconnectorFetchCancelledContext(t, s.newDB) }},
+	{"Deallocate", func(t *testing.T, s Suite) { connection_testing.DotestconnectorDeallocate(t, s.newDB) }},
+	{"SimpleProtocol", func(t *testing.T, s Suite) { connection_testing.DotestconnectorSimpleProtocol(t, s.newDB) }},
+	{"ListenNotify", func(t *testing.T, s Suite) { connection_testing.DotestconnectorListenNotify(t, s.newDB) }},
+	{"BulkInsertReturning", func(t *testing.T, s Suite) { connection_testing.DotestconnectorBulkInsertReturning(t, s.newDB) }},
+	{"BulkUpsert", func(t *testing.T, s Suite) { connection_testing.DotestconnectorBulkUpsert(t, s.newDB) }},
+	{"BulkUpdate", func(t *testing.T, s Suite) { connection_testing.DotestconnectorBulkUpdate(t, s.newDB) }},
+	{"BulkInsertChunked", func(t *testing.T, s Suite) { connection_testing.DotestconnectorBulkInsertChunked(t, s.newDB) }},
+	{"QueryCursor", func(t *testing.T, s Suite) { connection_testing.DotestconnectorQueryCursor(t, s.newDB) }},
+	{"ExecInfo", func(t *testing.T, s Suite) { connection_testing.DotestconnectorExecInfo(t, s.newDB) }},
+	{"Get", func(t *testing.T, s Suite) { connection_testing.DotestconnectorGet(t, s.newDB) }},
+	{"ExecBatch", func(t *testing.T, s Suite) { connection_testing.DotestconnectorExecBatch(t, s.newDB) }},
+	{"TwoPhaseCommit", func(t *testing.T, s Suite) { connection_testing.DotestconnectorTwoPhaseCommit(t, s.newDB) }},
+	{"SetParam", func(t *testing.T, s Suite) { connection_testing.DotestconnectorSetParam(t, s.newDB) }},
+	{"CloseIdle", func(t *testing.T, s Suite) { connection_testing.DotestconnectorCloseIdle(t, s.newDB) }},
+	{"ConstraintViolation", func(t *testing.T, s Suite) { connection_testing.DotestconnectorConstraintViolation(t, s.newDB) }},
+	{"NoRows", func(t *testing.T, s Suite) { connection_testing.DotestconnectorNoRows(t, s.newDB) }},
+	{"ExpandStars", func(t *testing.T, s Suite) { connection_testing.DotestconnectorExpandStars(t, s.newDB) }},
+	{"LoadFixtures", func(t *testing.T, s Suite) { connection_testing.DotestconnectorLoadFixtures(t, s.newDB) }},
+	{"Seed", func(t *testing.T, s Suite) { connection_testing.DotestconnectorSeed(t, s.newDB) }},
+	{"Verify", func(t *testing.T, s Suite) { connection_testing.DotestconnectorVerify(t, s.newDB) }},
+	{"DDL", func(t *testing.T, s Suite) { connection_testing.DotestconnectorDDL(t, s.newDB) }},
+	{"Views", func(t *testing.T, s Suite) { connection_testing.DotestconnectorViews(t, s.newDB) }},
+	{"Partitions", func(t *testing.T, s Suite) { connection_testing.DotestconnectorPartitions(t, s.newDB) }},
+	{"Sequences", func(t *testing.T, s Suite) { connection_testing.DotestconnectorSequences(t, s.newDB) }},
+	{"IntoTemp", func(t *testing.T, s Suite) { connection_testing.DotestconnectorIntoTemp(t, s.newDB) }},
+	{"CreateTableLike", func(t *testing.T, s Suite) { connection_testing.DotestconnectorCreateTableLike(t, s.newDB) }},
+	{"Constraints", func(t *testing.T, s Suite) { connection_testing.DotestconnectorConstraints(t, s.newDB) }},
+	{"Preload", func(t *testing.T, s Suite) { connection_testing.DotestconnectorPreload(t, s.newDB) }},
+	{"ParallelDecoding", func(t *testing.T, s Suite) { connection_testing.DotestconnectorParallelDecoding(t, s.newDB) }},
+	{"Scannable", func(t *testing.T, s Suite) { connection_testing.DotestconnectorScannable(t, s.newDB) }},
+	{"RuntimeParams", func(t *testing.T, s Suite) { connection_testing.DotestconnectorRuntimeParams(t, s.openDB) }},
+	{"PgBouncer", func(t *testing.T, s Suite) { connection_testing.DotestconnectorPgBouncer(t, s.openDB) }},
+	{"LeakDetection", func(t *testing.T, s Suite) { connection_testing.DotestconnectorLeakDetection(t, s.openDB) }},
+	{"Metrics", func(t *testing.T, s Suite) { connection_testing.DotestconnectorMetrics(t, s.openDB) }},
+	{"SetLogLevel", func(t *testing.T, s Suite) { connection_testing.DotestconnectorSetLogLevel(t, s.openDB) }},
+	{"QueryNoRowsError", func(t *testing.T, s Suite) { connection_testing.DotestconnectorQueryNoRowsError(t, s.openDB) }},
+	{"AttachQueryToErrors", func(t *testing.T, s Suite) { connection_testing.DotestconnectorAttachQueryToErrors(t, s.openDB) }},
+}
+
+// Tests returns the names of the tests in the suite, in the order they run.
+func Tests() []string {
+	names := make([]string, len(tests))
+	for i := range tests {
+		names[i] = tests[i].name
+	}
+	return names
+}
+
+// Run runs the whole suite against the DBs returned by newDB, see Suite.Run for more control.
+func Run(t *testing.T, newDB NewDB) {
+	Suite{NewDB: newDB}.Run(t)
+}
+
+// Run runs each test of the suite as a subtest of t, named as in Tests.
+func (s Suite) Run(t *testing.T) {
+	if s.NewDB == nil {
+		t.Fatal("conformance: Suite.NewDB is required")
+	}
+	skip := make(map[string]bool, len(s.Skip))
+	for _, name := range s.Skip {
+		skip[name] = true
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if skip[tt.name] {
+				t.Skip("skipped by the suite configuration")
+			}
+			tt.run(t, s)
+		})
+	}
+}
+
+// newDB returns a DB from NewDB with the test schema in place.
+func (s Suite) newDB(t *testing.T) connection.DB {
+	db := s.NewDB(t)
+	connection_testing.Setup(t, db)
+	return db
+}
+
+// openDB is newDB for OpenDB, the test is skipped if there is no OpenDB.
+func (s Suite) openDB(t *testing.T, ci *connection.Information) connection.DB {
+	if s.OpenDB == nil {
+		t.Skip("needs Suite.OpenDB")
+	}
+	db := s.OpenDB(t, ci)
+	connection_testing.Setup(t, db)
+	return db
+}
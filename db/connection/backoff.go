@@ -0,0 +1,85 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff describes how to retry an operation with exponential backoff, a nil *Backoff means
+// the operation is attempted only once.
+type Backoff struct {
+	// MaxAttempts is the total amount of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure, it doubles on each subsequent one.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts, 0 means no cap.
+	MaxDelay time.Duration
+	// Jitter, between 0 and 1, is the fraction of each delay that is randomized so clients
+	// failing at the same time don't retry in lockstep.
+	Jitter float64
+}
+
+// Delay returns how long to wait after the attempt number attempt (starting at 1) failed.
+func (b *Backoff) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := b.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if b.MaxDelay > 0 && delay >= b.MaxDelay {
+			break
+		}
+		// overflow
+		if delay <= 0 {
+			delay = b.MaxDelay
+			break
+		}
+	}
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	if b.Jitter > 0 && delay > 0 {
+		jitter := time.Duration(b.Jitter * float64(delay))
+		delay = delay - jitter + time.Duration(rand.Int63n(int64(jitter)+1))
+	}
+	return delay
+}
+
+// Retry runs f until it succeeds, the attempts are exhausted, the context is done or f returns
+// an error for which retryable (if not nil) returns false, the last error from f is returned.
+func (b *Backoff) Retry(ctx context.Context, retryable func(error) bool, f func() error) error {
+	maxAttempts := 1
+	if b != nil && b.MaxAttempts > 1 {
+		maxAttempts = b.MaxAttempts
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = f()
+		if err == nil || attempt >= maxAttempts || (retryable != nil && !retryable(err)) {
+			return err
+		}
+		timer := time.NewTimer(b.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
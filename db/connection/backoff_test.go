@@ -0,0 +1,68 @@
+package connection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := &Backoff{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, expected := range map[int]time.Duration{
+		1:  100 * time.Millisecond,
+		2:  200 * time.Millisecond,
+		4:  800 * time.Millisecond,
+		5:  time.Second,
+		80: time.Second,
+	} {
+		if got := b.Delay(attempt); got != expected {
+			t.Errorf("Delay(%d) = %v, expected %v", attempt, got, expected)
+		}
+	}
+
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		got := b.Delay(2)
+		if got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("Delay(2) with jitter = %v, expected within [100ms, 200ms]", got)
+		}
+	}
+}
+
+func TestBackoffRetry(t *testing.T) {
+	ctx := context.Background()
+	failure := errors.New("not yet")
+
+	attempts := 0
+	err := (&Backoff{MaxAttempts: 5}).Retry(ctx, nil, func() error {
+		attempts++
+		if attempts < 3 {
+			return failure
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Errorf("expected success after 3 attempts, got %v after %d", err, attempts)
+	}
+
+	attempts = 0
+	err = (&Backoff{MaxAttempts: 5}).Retry(ctx, func(error) bool { return false }, func() error {
+		attempts++
+		return failure
+	})
+	if err != failure || attempts != 1 {
+		t.Errorf("expected a single attempt for non retryable errors, got %v after %d", err, attempts)
+	}
+
+	attempts = 0
+	var nilBackoff *Backoff
+	err = nilBackoff.Retry(ctx, nil, func() error {
+		attempts++
+		return failure
+	})
+	if err != failure || attempts != 1 {
+		t.Errorf("expected a single attempt for a nil Backoff, got %v after %d", err, attempts)
+	}
+}
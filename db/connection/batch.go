@@ -0,0 +1,29 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+// Statement is a query along with its arguments, as run by DB.ExecBatch.
+type Statement struct {
+	SQL  string
+	Args []interface{}
+}
+
+// BatchResult is the outcome of one of the statements run by DB.ExecBatch.
+type BatchResult struct {
+	RowsAffected int64
+	// Err is the error running the statement, errors.ErrBatchAborted if it was not run because
+	// a previous one failed.
+	Err error
+}
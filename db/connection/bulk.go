@@ -0,0 +1,394 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxBindParameters is the max amount of arguments postgres accepts in a statement.
+	maxBindParameters = 65535
+	// bulkValuesMaxRows is the amount of rows up to which a multi VALUES statement is used
+	// instead of a temporary table.
+	bulkValuesMaxRows = 1000
+)
+
+// tempTableSeq makes the names of the temporary tables created for bulk operations unique
+// within a session.
+var tempTableSeq uint64
+
+// valuesChunkSize returns how many rows of columns length fit in a multi VALUES statement.
+func valuesChunkSize(columns int) int {
+	if columns == 0 {
+		return bulkValuesMaxRows
+	}
+	if size := maxBindParameters / columns; size < bulkValuesMaxRows {
+		return size
+	}
+	return bulkValuesMaxRows
+}
+
+// renderValues returns the `($1, $2), ($3, $4)` list for values, with placeholders starting
+// after argOffset, and the flattened arguments.
+func renderValues(columns int, values [][]interface{}, argOffset int) (string, []interface{}, error) {
+	var sb strings.Builder
+	args := make([]interface{}, 0, columns*len(values))
+	for i, row := range values {
+		if len(row) != columns {
+			return "", nil, errors.Errorf("row %d has %d values but %d columns were passed", i, len(row), columns)
+		}
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for j := range row {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "$%d", argOffset+len(args)+1)
+			args = append(args, row[j])
+		}
+		sb.WriteString(")")
+	}
+	return sb.String(), args, nil
+}
+
+// createTempTable creates a temporary table with the passed columns of table, dropped when the
+// transaction db is in commits, and returns its name. Only the column types are copied, not
+// their constraints, so the table can hold partial rows.
+func createTempTable(ctx context.Context, db DB, table string, columns []string) (string, error) {
+	name := fmt.Sprintf("gaum_bulk_%d", atomic.AddUint64(&tempTableSeq, 1))
+	err := db.Exec(ctx, fmt.Sprintf("CREATE TEMPORARY TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA",
+		name, strings.Join(columns, ", "), table))
+	if err != nil {
+		return "", errors.Wrapf(err, "creating temporary table from %s", table)
+	}
+	return name, nil
+}
+
+// fillTable inserts values into table using COPY (DB.BulkInsert) if the driver supports it
+// or multi VALUES inserts otherwise.
+func fillTable(ctx context.Context, db DB, table string, columns []string, values [][]interface{}) error {
+	err := db.BulkInsert(ctx, table, columns, values)
+	if errors.Cause(err) != gaumErrors.NotImplemented {
+		return err
+	}
+	chunk := valuesChunkSize(len(columns))
+	for start := 0; start < len(values); start += chunk {
+		end := start + chunk
+		if end > len(values) {
+			end = len(values)
+		}
+		valuesList, args, err := renderValues(len(columns), values[start:end], 0)
+		if err != nil {
+			return err
+		}
+		err = db.Exec(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES %s",
+			table, strings.Join(columns, ", "), valuesList), args...)
+		if err != nil {
+			return errors.Wrapf(err, "inserting rows %d to %d", start, end)
+		}
+	}
+	return nil
+}
+
+// BulkInsertReturning inserts values into table and fetches the returning columns of the
+// inserted rows into dst, which must be a pointer to a slice as in ResultFetch. Small sets are
+// inserted with a single multi VALUES statement, bigger ones are loaded into a temporary table
+// and then moved into table, all in a transaction.
+func BulkInsertReturning(ctx context.Context, db DB, table string, columns []string, values [][]interface{},
+	returning []string, dst interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if len(values) <= valuesChunkSize(len(columns)) {
+		valuesList, args, err := renderValues(len(columns), values, 0)
+		if err != nil {
+			return err
+		}
+		fetch, err := db.Query(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES %s RETURNING %s",
+			table, strings.Join(columns, ", "), valuesList, strings.Join(returning, ", ")),
+			returning, args...)
+		if err != nil {
+			return errors.Wrap(err, "bulk inserting")
+		}
+		return fetch(dst)
+	}
+
+	return WithTransaction(ctx, db, func(tx DB) error {
+		tempTable, err := createTempTable(ctx, tx, table, columns)
+		if err != nil {
+			return err
+		}
+		if err := fillTable(ctx, tx, tempTable, columns, values); err != nil {
+			return errors.Wrap(err, "loading temporary table")
+		}
+		cols := strings.Join(columns, ", ")
+		fetch, err := tx.Query(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s RETURNING %s",
+			table, cols, cols, tempTable, strings.Join(returning, ", ")),
+			returning)
+		if err != nil {
+			return errors.Wrap(err, "bulk inserting from temporary table")
+		}
+		return fetch(dst)
+	})
+}
+
+// BulkUpsert loads values into a temporary table and then inserts them all into table with a
+// single statement, rows conflicting on the conflictTarget columns get their updateCols set to
+// the new values instead, if no updateCols are passed conflicting rows are skipped.
+// Postgres won't update the same row twice in a statement so values must not repeat keys.
+func BulkUpsert(ctx context.Context, db DB, table string, columns, conflictTarget, updateCols []string,
+	values [][]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if len(conflictTarget) == 0 {
+		return errors.New("a conflict target is required for an upsert")
+	}
+	action := "NOTHING"
+	if len(updateCols) > 0 {
+		sets := make([]string, len(updateCols))
+		for i, col := range updateCols {
+			sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", col, col)
+		}
+		action = "UPDATE SET " + strings.Join(sets, ", ")
+	}
+
+	return WithTransaction(ctx, db, func(tx DB) error {
+		tempTable, err := createTempTable(ctx, tx, table, columns)
+		if err != nil {
+			return err
+		}
+		if err := fillTable(ctx, tx, tempTable, columns, values); err != nil {
+			return errors.Wrap(err, "loading temporary table")
+		}
+		cols := strings.Join(columns, ", ")
+		err = tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s ON CONFLICT (%s) DO %s",
+			table, cols, cols, tempTable, strings.Join(conflictTarget, ", "), action))
+		if err != nil {
+			return errors.Wrap(err, "bulk upserting from temporary table")
+		}
+		return nil
+	})
+}
+
+// BulkUpdate updates the setCols of the rows of table matching the keyCols of each of rows,
+// which hold the values for keyCols followed by those for setCols. Small sets are updated with
+// a single statement joining the values, bigger ones are loaded into a temporary table first.
+func BulkUpdate(ctx context.Context, db DB, table string, keyCols, setCols []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if len(keyCols) == 0 || len(setCols) == 0 {
+		return errors.New("both key and set columns are required for a bulk update")
+	}
+	columns := append(append([]string{}, keyCols...), setCols...)
+	update := func(tx DB, source string, args ...interface{}) error {
+		sets := make([]string, len(setCols))
+		for i, col := range setCols {
+			sets[i] = fmt.Sprintf("%s = gaum_v.%s", col, col)
+		}
+		conditions := make([]string, len(keyCols))
+		for i, col := range keyCols {
+			conditions[i] = fmt.Sprintf("%s.%s = gaum_v.%s", table, col, col)
+		}
+		err := tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET %s FROM %s AS gaum_v WHERE %s",
+			table, strings.Join(sets, ", "), source, strings.Join(conditions, " AND ")), args...)
+		if err != nil {
+			return errors.Wrap(err, "bulk updating")
+		}
+		return nil
+	}
+
+	if len(rows) <= valuesChunkSize(len(columns)) {
+		// a VALUES list would make the arguments text, the leading empty select from table gives
+		// them the types of the columns instead, as each select of the union adopts them.
+		cols := strings.Join(columns, ", ")
+		var source strings.Builder
+		fmt.Fprintf(&source, "(SELECT %s FROM %s WHERE false", cols, table)
+		args := make([]interface{}, 0, len(columns)*len(rows))
+		for i, row := range rows {
+			if len(row) != len(columns) {
+				return errors.Errorf("row %d has %d values but %d columns were passed", i, len(row), len(columns))
+			}
+			source.WriteString(" UNION ALL SELECT ")
+			for j := range row {
+				if j > 0 {
+					source.WriteString(", ")
+				}
+				fmt.Fprintf(&source, "$%d", len(args)+1)
+				args = append(args, row[j])
+			}
+		}
+		source.WriteString(")")
+		return update(db, source.String(), args...)
+	}
+
+	return WithTransaction(ctx, db, func(tx DB) error {
+		tempTable, err := createTempTable(ctx, tx, table, columns)
+		if err != nil {
+			return err
+		}
+		if err := fillTable(ctx, tx, tempTable, columns, rows); err != nil {
+			return errors.Wrap(err, "loading temporary table")
+		}
+		return update(tx, tempTable)
+	})
+}
+
+// BulkSource provides the rows for BulkInsertChunked one at a time, so they don't need to be in
+// memory all at once. It has the same methods as pgx.CopyFromSource.
+type BulkSource interface {
+	// Next advances to the next row, it returns false when there are no more rows or on error.
+	Next() bool
+	// Values returns the values of the current row, the slice must not be reused for the next.
+	Values() ([]interface{}, error)
+	// Err returns the error, if any, that stopped Next.
+	Err() error
+}
+
+// BulkSourceFromRows returns a BulkSource for rows already in memory.
+func BulkSourceFromRows(rows [][]interface{}) BulkSource {
+	return &rowsSource{rows: rows, idx: -1}
+}
+
+type rowsSource struct {
+	rows [][]interface{}
+	idx  int
+}
+
+func (r *rowsSource) Next() bool {
+	r.idx++
+	return r.idx < len(r.rows)
+}
+
+func (r *rowsSource) Values() ([]interface{}, error) {
+	return r.rows[r.idx], nil
+}
+
+func (r *rowsSource) Err() error {
+	return nil
+}
+
+// BulkInsertOptions configures BulkInsertChunked.
+type BulkInsertOptions struct {
+	// ChunkSize is the amount of rows inserted in each transaction (or savepoint), 0 means
+	// DefaultBulkChunkSize.
+	ChunkSize int
+	// ContinueOnError makes BulkInsertChunked go on with the next chunk when one fails instead
+	// of stopping.
+	ContinueOnError bool
+}
+
+// DefaultBulkChunkSize is the chunk size used by BulkInsertChunked if none is configured.
+const DefaultBulkChunkSize = 10000
+
+// ChunkError describes a chunk that could not be inserted.
+type ChunkError struct {
+	// Start and End are the positions in the source of the first row of the chunk and the one
+	// after the last.
+	Start int
+	End   int
+	// Rows are the rows of the chunk, so they can be inserted again.
+	Rows [][]interface{}
+	Err  error
+}
+
+// BulkInsertError is returned by BulkInsertChunked when chunks fail to be inserted.
+type BulkInsertError struct {
+	// Inserted is the amount of rows that were inserted.
+	Inserted int
+	// Failed are the chunks that were not inserted.
+	Failed []ChunkError
+}
+
+// Error implements error.
+func (e *BulkInsertError) Error() string {
+	return fmt.Sprintf("%d chunks failed to be inserted, %d rows were inserted, first failure: %v",
+		len(e.Failed), e.Inserted, e.Failed[0].Err)
+}
+
+// Unwrap returns the errors of the failed chunks, so errors.Is and errors.As look into them.
+func (e *BulkInsertError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i := range e.Failed {
+		errs[i] = e.Failed[i].Err
+	}
+	return errs
+}
+
+// BulkInsertChunked inserts the rows from source into table, with DB.BulkInsert, in chunks of
+// opts.ChunkSize rows, each in its own transaction (or savepoint if db is a transaction) so only
+// one chunk needs to be in memory and a failure does not undo the chunks already inserted.
+// If any chunk fails a *BulkInsertError tells which ones, so they can be retried.
+func BulkInsertChunked(ctx context.Context, db DB, table string, columns []string, source BulkSource,
+	opts BulkInsertOptions) error {
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultBulkChunkSize
+	}
+	bulkErr := &BulkInsertError{}
+	read := 0
+	chunk := make([][]interface{}, 0, chunkSize)
+	insert := func() bool {
+		err := WithTransaction(ctx, db, func(tx DB) error {
+			return fillTable(ctx, tx, table, columns, chunk)
+		})
+		if err != nil {
+			bulkErr.Failed = append(bulkErr.Failed, ChunkError{
+				Start: read - len(chunk),
+				End:   read,
+				Rows:  chunk,
+				Err:   err,
+			})
+			// the rows are now referenced by the error.
+			chunk = make([][]interface{}, 0, chunkSize)
+			return opts.ContinueOnError
+		}
+		bulkErr.Inserted += len(chunk)
+		chunk = chunk[:0]
+		return true
+	}
+
+	for source.Next() {
+		values, err := source.Values()
+		if err != nil {
+			return errors.Wrapf(err, "reading row %d from source", read)
+		}
+		read++
+		chunk = append(chunk, values)
+		if len(chunk) == chunkSize && !insert() {
+			return bulkErr
+		}
+	}
+	if err := source.Err(); err != nil {
+		return errors.Wrapf(err, "reading row %d from source", read)
+	}
+	if len(chunk) > 0 {
+		insert()
+	}
+	if len(bulkErr.Failed) > 0 {
+		return bulkErr
+	}
+	return nil
+}
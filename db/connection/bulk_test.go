@@ -0,0 +1,246 @@
+package connection
+
+import (
+	"context"
+	stdErrors "errors"
+	"strings"
+	"testing"
+
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+)
+
+type bulkConn struct {
+	fakeConn
+	noCopy     bool
+	statements []string
+	args       [][]interface{}
+	copied     int
+	// failCopies are the COPYs, by order, that fail
+	failCopies map[int]bool
+	copies     int
+}
+
+func (b *bulkConn) BeginTransaction(ctx context.Context) (DB, error) {
+	b.fakeConn.BeginTransaction(ctx)
+	return b, nil
+}
+
+func (b *bulkConn) Exec(ctx context.Context, statement string, args ...interface{}) error {
+	b.statements = append(b.statements, statement)
+	b.args = append(b.args, args)
+	return nil
+}
+
+func (b *bulkConn) Query(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetch, error) {
+	b.statements = append(b.statements, statement)
+	b.args = append(b.args, args)
+	return func(interface{}) error { return nil }, nil
+}
+
+func (b *bulkConn) BulkInsert(ctx context.Context, tableName string, columns []string, values [][]interface{}) error {
+	if b.noCopy {
+		return gaumErrors.NotImplemented
+	}
+	b.statements = append(b.statements, "COPY "+tableName)
+	b.copies++
+	if b.failCopies[b.copies] {
+		return errors.New("copy failed")
+	}
+	b.copied += len(values)
+	return nil
+}
+
+func rows(n int) [][]interface{} {
+	values := make([][]interface{}, n)
+	for i := range values {
+		values[i] = []interface{}{i, "description"}
+	}
+	return values
+}
+
+func TestBulkInsertReturningValues(t *testing.T) {
+	b := &bulkConn{}
+	var ids []int
+	err := BulkInsertReturning(context.Background(), b, "justforfun", []string{"id", "description"},
+		rows(2), []string{"id"}, &ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"INSERT INTO justforfun (id, description) VALUES ($1, $2), ($3, $4) RETURNING id"}
+	if diff := deep.Equal(b.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(b.args, [][]interface{}{{0, "description", 1, "description"}}); diff != nil {
+		t.Error(diff)
+	}
+	if b.begin != 0 {
+		t.Errorf("no transaction is needed for a single statement")
+	}
+}
+
+func TestBulkInsertReturningTempTable(t *testing.T) {
+	for _, noCopy := range []bool{false, true} {
+		b := &bulkConn{noCopy: noCopy}
+		var ids []int
+		err := BulkInsertReturning(context.Background(), b, "justforfun", []string{"id", "description"},
+			rows(bulkValuesMaxRows+1), []string{"id"}, &ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.begin != 1 || b.commit != 1 {
+			t.Errorf("expected one transaction to be committed got %d begins and %d commits", b.begin, b.commit)
+		}
+		if len(b.statements) < 3 {
+			t.Fatalf("expected at least 3 statements got %v", b.statements)
+		}
+		tempTable := strings.Fields(b.statements[0])[3]
+		expected := "CREATE TEMPORARY TABLE " + tempTable + " ON COMMIT DROP AS SELECT id, description FROM justforfun WITH NO DATA"
+		if b.statements[0] != expected {
+			t.Errorf("expected %q got %q", expected, b.statements[0])
+		}
+		if noCopy {
+			// 2 chunks of multi VALUES inserts
+			if len(b.statements) != 4 || !strings.HasPrefix(b.statements[1], "INSERT INTO "+tempTable+" ") {
+				t.Fatalf("expected the temporary table to be filled with 2 inserts got %v", b.statements)
+			}
+		} else if b.copied != bulkValuesMaxRows+1 {
+			t.Errorf("expected %d rows copied got %d", bulkValuesMaxRows+1, b.copied)
+		}
+		expected = "INSERT INTO justforfun (id, description) SELECT id, description FROM " + tempTable + " RETURNING id"
+		if last := b.statements[len(b.statements)-1]; last != expected {
+			t.Errorf("expected %q got %q", expected, last)
+		}
+	}
+}
+
+func TestBulkUpsert(t *testing.T) {
+	for updateCols, action := range map[string]string{
+		"":            "DO NOTHING",
+		"description": "DO UPDATE SET description = EXCLUDED.description",
+	} {
+		b := &bulkConn{}
+		var update []string
+		if updateCols != "" {
+			update = strings.Split(updateCols, ",")
+		}
+		err := BulkUpsert(context.Background(), b, "justforfun", []string{"id", "description"},
+			[]string{"id"}, update, rows(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b.statements) != 3 {
+			t.Fatalf("expected 3 statements got %v", b.statements)
+		}
+		tempTable := strings.Fields(b.statements[0])[3]
+		expected := "INSERT INTO justforfun (id, description) SELECT id, description FROM " + tempTable +
+			" ON CONFLICT (id) " + action
+		if b.statements[2] != expected {
+			t.Errorf("expected %q got %q", expected, b.statements[2])
+		}
+		if b.commit != 1 {
+			t.Errorf("expected the transaction to be committed")
+		}
+	}
+
+	err := BulkUpsert(context.Background(), &bulkConn{}, "justforfun", []string{"id"}, nil, nil, rows(1))
+	if err == nil {
+		t.Errorf("expected an error when no conflict target is passed")
+	}
+}
+
+func TestBulkUpdate(t *testing.T) {
+	b := &bulkConn{}
+	err := BulkUpdate(context.Background(), b, "justforfun", []string{"id"}, []string{"description"}, rows(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"UPDATE justforfun SET description = gaum_v.description FROM " +
+		"(SELECT id, description FROM justforfun WHERE false UNION ALL SELECT $1, $2 UNION ALL SELECT $3, $4) AS gaum_v " +
+		"WHERE justforfun.id = gaum_v.id"}
+	if diff := deep.Equal(b.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	b = &bulkConn{}
+	err = BulkUpdate(context.Background(), b, "justforfun", []string{"id"}, []string{"description"},
+		rows(bulkValuesMaxRows+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.statements) != 3 {
+		t.Fatalf("expected 3 statements got %v", b.statements)
+	}
+	tempTable := strings.Fields(b.statements[0])[3]
+	expected = []string{"UPDATE justforfun SET description = gaum_v.description FROM " + tempTable + " AS gaum_v " +
+		"WHERE justforfun.id = gaum_v.id"}
+	if diff := deep.Equal(b.statements[2:], expected); diff != nil {
+		t.Error(diff)
+	}
+	if b.commit != 1 {
+		t.Errorf("expected the transaction to be committed")
+	}
+}
+
+func TestBulkInsertChunked(t *testing.T) {
+	ctx := context.Background()
+	b := &bulkConn{}
+	err := BulkInsertChunked(ctx, b, "justforfun", []string{"id", "description"}, BulkSourceFromRows(rows(25)),
+		BulkInsertOptions{ChunkSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.copies != 3 || b.copied != 25 || b.commit != 3 {
+		t.Errorf("expected 3 chunks with 25 rows, each committed, got %d chunks with %d rows and %d commits",
+			b.copies, b.copied, b.commit)
+	}
+
+	b = &bulkConn{failCopies: map[int]bool{2: true}}
+	err = BulkInsertChunked(ctx, b, "justforfun", []string{"id", "description"}, BulkSourceFromRows(rows(25)),
+		BulkInsertOptions{ChunkSize: 10})
+	bulkErr, ok := err.(*BulkInsertError)
+	if !ok {
+		t.Fatalf("expected a *BulkInsertError got %v", err)
+	}
+	if b.copies != 2 || bulkErr.Inserted != 10 || len(bulkErr.Failed) != 1 {
+		t.Errorf("expected to stop after the failed chunk, got %d chunks and %d rows inserted",
+			b.copies, bulkErr.Inserted)
+	}
+
+	b = &bulkConn{failCopies: map[int]bool{2: true}}
+	err = BulkInsertChunked(ctx, b, "justforfun", []string{"id", "description"}, BulkSourceFromRows(rows(25)),
+		BulkInsertOptions{ChunkSize: 10, ContinueOnError: true})
+	bulkErr, ok = err.(*BulkInsertError)
+	if !ok {
+		t.Fatalf("expected a *BulkInsertError got %v", err)
+	}
+	if b.copies != 3 || bulkErr.Inserted != 15 || b.rollback != 1 {
+		t.Errorf("expected to go on after the failed chunk, got %d chunks and %d rows inserted",
+			b.copies, bulkErr.Inserted)
+	}
+	failed := bulkErr.Failed[0]
+	if failed.Start != 10 || failed.End != 20 || len(failed.Rows) != 10 || failed.Rows[0][0] != 10 {
+		t.Errorf("unexpected failed chunk %d to %d with %d rows", failed.Start, failed.End, len(failed.Rows))
+	}
+}
+
+func TestRenderValuesWrongRowLength(t *testing.T) {
+	_, _, err := renderValues(2, [][]interface{}{{1, "a"}, {2}}, 0)
+	if err == nil {
+		t.Errorf("expected an error for a row with missing values")
+	}
+}
+
+func TestBulkInsertErrorUnwrap(t *testing.T) {
+	b := &bulkConn{failCopies: map[int]bool{1: true}}
+	err := BulkInsertChunked(context.Background(), b, "justforfun", []string{"id", "description"},
+		BulkSourceFromRows(rows(5)), BulkInsertOptions{ChunkSize: 10})
+	var bulkErr *BulkInsertError
+	if !stdErrors.As(errors.Wrap(err, "loading"), &bulkErr) || len(bulkErr.Failed) != 1 {
+		t.Fatalf("expected a wrapped *BulkInsertError got %v", err)
+	}
+	if !stdErrors.Is(err, bulkErr.Failed[0].Err) {
+		t.Errorf("expected errors.Is to find the chunk error")
+	}
+}
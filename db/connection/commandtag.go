@@ -0,0 +1,57 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CommandTag is the outcome of a statement as reported by the server.
+type CommandTag struct {
+	// Tag is the raw command tag, ie "INSERT 0 1" or "CREATE TABLE".
+	Tag string
+	// Operation is the command the tag refers to, ie "INSERT" or "CREATE TABLE".
+	Operation string
+	// RowsAffected is the amount of rows inserted, updated, deleted, selected, etc.
+	RowsAffected int64
+	// OID is the oid of the inserted row for single row inserts into tables with oids, it is
+	// 0 for any other statement.
+	OID uint32
+}
+
+// ParseCommandTag returns the CommandTag for a raw command tag as sent by postgres.
+func ParseCommandTag(tag string) CommandTag {
+	ct := CommandTag{Tag: tag}
+	fields := strings.Fields(tag)
+	// the counts, if any, follow the operation.
+	operation := len(fields)
+	for operation > 0 {
+		if _, err := strconv.ParseUint(fields[operation-1], 10, 64); err != nil {
+			break
+		}
+		operation--
+	}
+	ct.Operation = strings.Join(fields[:operation], " ")
+	counts := fields[operation:]
+	if len(counts) > 0 {
+		ct.RowsAffected, _ = strconv.ParseInt(counts[len(counts)-1], 10, 64)
+	}
+	if ct.Operation == "INSERT" && len(counts) == 2 {
+		oid, _ := strconv.ParseUint(counts[0], 10, 32)
+		ct.OID = uint32(oid)
+	}
+	return ct
+}
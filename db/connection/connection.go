@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -45,9 +46,47 @@ var (
 	None LogLevel = "none"
 )
 
+// StatementCacheMode indicates how the statements in the cache are prepared.
+type StatementCacheMode string
+
+const (
+	// StatementCachePrepare creates named prepared statements in the server.
+	StatementCachePrepare StatementCacheMode = "prepare"
+	// StatementCacheDescribe uses the unnamed prepared statement to only describe the statements,
+	// useful where named prepared statements are not possible (ie, behind PgBouncer).
+	StatementCacheDescribe StatementCacheMode = "describe"
+)
+
+// StatementCache configures the automatic prepared statement cache of each connection.
+type StatementCache struct {
+	// Disabled turns off the cache.
+	Disabled bool
+	// Mode defaults to StatementCachePrepare.
+	Mode StatementCacheMode
+	// Capacity is the max amount of statements cached per connection, 0 means the driver
+	// default.
+	Capacity int
+}
+
 // Information contains all required information to create a connection into a db.
 // Copied almost verbatim from https://godoc.org/github.com/jackc/pgx#ConnConfig
 type Information struct {
+	// Host can be a hostname, an ip or the absolute path to a unix socket directory.
+	// Several hosts can be passed separated by comma, optionally with their own port
+	// (ie, "db1:5432,db2:5433"), and they will be tried in order.
+	Host string
+	// Port is the port where the db is listening, 0 means the driver default.
+	Port uint16
+	// TargetSessionAttrs can be "any" or "read-write", the later will skip hosts that are
+	// not a primary, which combined with multiple hosts allows following a failover.
+	TargetSessionAttrs string
+	// SSLMode is one of disable, allow, prefer, require, verify-ca or verify-full. Empty
+	// means the driver default.
+	SSLMode string
+	// Params holds any extra connection string parameters (ie, connect_timeout) that
+	// will be passed verbatim to the driver.
+	Params map[string]string
+
 	Database        string
 	User            string
 	Password        string
@@ -59,8 +98,79 @@ type Information struct {
 	// a pool can have.
 	MaxConnPoolConns int
 
+	// WarmUpConns, if positive, makes Open establish that many connections of the pool, up to
+	// MaxConnPoolConns, before returning so the first queries don't wait for them.
+	WarmUpConns int
+	// WarmUpQuery, if not empty, is run in each of the WarmUpConns as they are established to
+	// validate them (ie, "SELECT 1"), Open fails if it does.
+	WarmUpQuery string
+
+	// StatementCache, if not nil, overrides the driver defaults for prepared statement caching.
+	StatementCache *StatementCache
+
+	// RetryReads makes read only queries (see IsReadOnly) that are not in a transaction be
+	// retried once in a fresh connection if the one in use broke while running them.
+	RetryReads bool
+
+	// TransactionRetry, if not nil, is the retry policy used by WithTransaction for transactions
+	// that fail with a retryable error, see errors.IsRetryable.
+	TransactionRetry *Backoff
+
+	// ConnectRetry, if not nil, makes Open retry connecting to the db, useful when the
+	// application might start before the db is reachable.
+	ConnectRetry *Backoff
+
+	// LeakDetection, if not nil, enables reporting the query results and transactions that are
+	// not consumed or closed in time, holding a connection of the pool.
+	LeakDetection *LeakDetection
+
+	// Metrics, if not nil, receives the count, duration, rows and errors of every operation run,
+	// see the metrics package for a Prometheus implementation.
+	Metrics Metrics
+
+	// SlowQueryThreshold, if positive, makes the statements that take longer than it to run be
+	// logged at Warn level, along with their argument count and duration, regardless of
+	// LogLevel.
+	SlowQueryThreshold time.Duration
+
+	// AttachQueryToErrors makes the errors of running statements be *errors.QueryError, which
+	// carries the statement, argument count, operation and duration, for errors.As to find.
+	// Argument values are never attached.
+	AttachQueryToErrors bool
+
+	// QueryNoRows makes the fetches of Query and QueryPrimitive return errors.ErrNoRows, after
+	// setting the destination to an empty slice, when the statement yields no rows, as the single
+	// row methods do. It is off by default since an empty slice is a valid result for them.
+	QueryNoRows bool
+
+	// SlicesAsArrays makes chains and the raw query helpers pass slice arguments to the driver
+	// as they are, to be bound as postgres arrays, instead of expanding them into one placeholder
+	// per item, so `IN (?)` needs to be written `= ANY(?)` (see chain.InAny). See
+	// SliceArrayBinder.
+	SlicesAsArrays bool
+
+	// RuntimeParams are set as the session defaults of every connection (ie, application_name,
+	// search_path, timezone or statement_timeout), they take precedence over those in the
+	// connection string.
+	RuntimeParams map[string]string
+
+	// PreferSimpleProtocol makes all queries use the simple query protocol instead of the
+	// extended one, for proxies that don't support the later. To do so only for some queries
+	// see WithSimpleProtocol.
+	PreferSimpleProtocol bool
+
+	// PgBouncer makes the connections work behind PgBouncer in transaction pooling mode, as
+	// server connections change from one transaction to the next: no statements are prepared
+	// (the statement cache is disabled and the simple protocol is used) and only the
+	// RuntimeParams that PgBouncer tracks for each client are accepted, see
+	// ValidatePgBouncerParams. Session features such as Listen will not work in this mode.
+	PgBouncer bool
+
 	Logger   logging.Logger
 	LogLevel LogLevel
+	// LogRedaction, if not nil, says which query arguments are redacted in the logs, by
+	// default they are only truncated if huge.
+	LogRedaction *logging.Redaction
 }
 
 // DatabaseHandler represents the boundary with a db.
@@ -71,23 +181,41 @@ type DatabaseHandler interface {
 
 // ResultFetchIter represents a closure that receives a receiver struct that will get the
 // results assigned for one row and returns a tuple of `next item present`, `close function`, error
+// Once the context passed to the query is done the rows are closed and its error returned.
 type ResultFetchIter func(interface{}) (bool, func(), error)
 
 // ResultFetch represents a closure that receives a receiver struct and wil assign all the results
 // it is expected that it receives a slice.
+// Once the context passed to the query is done the rows are closed and its error returned.
 type ResultFetch func(interface{}) error
 
+// Notification is a message received on a channel the DB is listening to (see DB.Listen).
+type Notification struct {
+	// PID is the id of the backend process that sent the notification.
+	PID     uint32
+	Channel string
+	Payload string
+}
+
 // DB represents an active database connection.
 type DB interface {
 	// Clone returns a stateful copy of this connection.
 	Clone() DB
 	// Close does so for all the underlying connections and returns an error if the driver provides one.
 	Close() error
-	// QueryIter returns closure allowing to load/fetch roads one by one.
+	// CloseIdle closes the connections of the pool that are not in use, new ones are
+	// established as needed.
+	CloseIdle(ctx context.Context) error
+	// SetLogLevel changes the level of the query logs of this DB, and of those sharing its
+	// connections, without reconnecting.
+	SetLogLevel(level LogLevel) error
+	// QueryIter returns closure allowing to load/fetch roads one by one, or errors.ErrNoRows if
+	// there are none.
 	QueryIter(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetchIter, error)
 	// EQueryIter is QueryIter but will use EscapeArgs.
 	EQueryIter(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetchIter, error)
-	// Query returns a closure that allows fetching of the results of the query.
+	// Query returns a closure that allows fetching of the results of the query, no rows yield
+	// an empty slice and, if Information.QueryNoRows is set, errors.ErrNoRows.
 	Query(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetch, error)
 	// EQuery is Query but will use EscapeArgs.
 	EQuery(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetch, error)
@@ -96,8 +224,12 @@ type DB interface {
 	QueryPrimitive(ctx context.Context, statement string, field string, args ...interface{}) (ResultFetch, error)
 	// EQueryPrimitive is QueryPrimitive but will use EscapeArgs
 	EQueryPrimitive(ctx context.Context, statement string, field string, args ...interface{}) (ResultFetch, error)
+	// Get runs statement and scans its only resulting row into dst, a pointer to a struct, it
+	// returns errors.ErrNoRows if there is none.
+	Get(ctx context.Context, dst interface{}, statement string, args ...interface{}) error
 	// Raw ins intended to be an all raw query that runs statement with args and tries
-	// to retrieve the results into fields without much magic whatsoever.
+	// to retrieve the results into fields without much magic whatsoever, it returns
+	// errors.ErrNoRows if there are none.
 	Raw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error
 	// ERaw is Raw but will use EscapeArgs
 	ERaw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error
@@ -105,9 +237,16 @@ type DB interface {
 	Exec(ctx context.Context, statement string, args ...interface{}) error
 	// ExecResult is intended for queries that modify data and respond with how many rows were affected.
 	ExecResult(ctx context.Context, statement string, args ...interface{}) (int64, error)
+	// ExecInfo is intended for queries that modify data and responds with the command tag of the
+	// statement.
+	ExecInfo(ctx context.Context, statement string, args ...interface{}) (CommandTag, error)
+	// ExecBatch runs all the statements in one go, either all of them are applied or, if one
+	// fails, none, the result of each is returned in the same order.
+	ExecBatch(ctx context.Context, statements []Statement) ([]BatchResult, error)
 	// EExec is Exec but will use EscapeArgs.
 	EExec(ctx context.Context, statement string, args ...interface{}) error
-	// BeginTransaction returns a new DB that will use the transaction instead of the basic conn.
+	// BeginTransaction returns a new DB that will use the transaction instead of the basic conn,
+	// if the DB is already a transaction the returned one should be backed by a savepoint.
 	BeginTransaction(ctx context.Context) (DB, error)
 	// CommitTransaction commits the transaction
 	CommitTransaction(ctx context.Context) error
@@ -115,10 +254,51 @@ type DB interface {
 	RollbackTransaction(ctx context.Context) error
 	// IsTransaction indicates if the DB is in the middle of a transaction.
 	IsTransaction() bool
-	// Set allows to change settings for the current transaction.
+	// PrepareTransaction prepares the transaction for a two-phase commit with the gid identifier,
+	// the DB is no longer a usable transaction after this.
+	PrepareTransaction(ctx context.Context, gid string) error
+	// CommitPrepared commits the prepared transaction gid, it can't run in a transaction.
+	CommitPrepared(ctx context.Context, gid string) error
+	// RollbackPrepared rolls back the prepared transaction gid, it can't run in a transaction.
+	RollbackPrepared(ctx context.Context, gid string) error
+	// Set allows to change settings for the current transaction, set is passed verbatim to
+	// SET LOCAL so it must never contain user input, see SetParam.
 	Set(ctx context.Context, set string) error
+	// SetParam sets the configuration parameter name to value for the current transaction.
+	SetParam(ctx context.Context, name, value string) error
 	// BulkInsert Inserts in the most efficient way possible a lot of data.
 	BulkInsert(ctx context.Context, tableName string, columns []string, values [][]interface{}) (execError error)
+	// BulkInsertReturning inserts a lot of data and fetches the returning columns of the inserted
+	// rows into dst, which must be a pointer to a slice.
+	BulkInsertReturning(ctx context.Context, tableName string, columns []string, values [][]interface{},
+		returning []string, dst interface{}) error
+	// BulkUpsert inserts a lot of data updating the updateCols of the rows that conflict on
+	// conflictTarget.
+	BulkUpsert(ctx context.Context, tableName string, columns, conflictTarget, updateCols []string,
+		values [][]interface{}) error
+	// BulkUpdate sets the setCols of the rows matching the keyCols of each of rows, which has
+	// the key values followed by the set ones.
+	BulkUpdate(ctx context.Context, tableName string, keyCols, setCols []string, rows [][]interface{}) error
+	// BulkInsertChunked inserts the rows from source in chunks, so they don't need to be all in
+	// memory and a failure only affects its chunk.
+	BulkInsertChunked(ctx context.Context, tableName string, columns []string, source BulkSource,
+		opts BulkInsertOptions) error
+	// Deallocate releases the prepared statement with the passed name, drivers that can't reach
+	// the connections that prepared it return errors.NotImplemented.
+	Deallocate(ctx context.Context, name string) error
+	// DeallocateAll releases all the prepared statements, including the cached ones.
+	DeallocateAll(ctx context.Context) error
+	// Listen returns the notifications sent to channel until ctx is done, when the returned
+	// go channel is closed.
+	Listen(ctx context.Context, channel string) (<-chan Notification, error)
+	// Notify sends payload to all the listeners of channel.
+	Notify(ctx context.Context, channel, payload string) error
+	// NextVal advances the sequence seq and returns its new value.
+	NextVal(ctx context.Context, seq string) (int64, error)
+	// CurrVal returns the value most recently obtained by NextVal for seq in this session.
+	CurrVal(ctx context.Context, seq string) (int64, error)
+	// SetVal sets the current value of seq, if isCalled is false the next NextVal returns value.
+	SetVal(ctx context.Context, seq string, value int64, isCalled bool) error
 }
 
 var _ DB = (*FlexibleTransaction)(nil)
@@ -212,6 +392,25 @@ func (f *FlexibleTransaction) RollbackTransaction(ctx context.Context) error {
 	return nil
 }
 
+// SlicesAsArrays implements SliceArrayBinder for FlexibleTransaction, it returns what the
+// wrapped DB does.
+func (f *FlexibleTransaction) SlicesAsArrays() bool {
+	return BindsSlicesAsArrays(f.DB)
+}
+
+// SliceArrayBinder is implemented by the DBs that can be configured to bind slice arguments
+// as postgres arrays (see Information.SlicesAsArrays).
+type SliceArrayBinder interface {
+	SlicesAsArrays() bool
+}
+
+// BindsSlicesAsArrays returns true if db binds slice arguments as arrays, so they must not be
+// expanded.
+func BindsSlicesAsArrays(db DB) bool {
+	binder, ok := db.(SliceArrayBinder)
+	return ok && binder.SlicesAsArrays()
+}
+
 // EscapeArgs return the query and args with the argument placeholder escaped.
 //
 // The argument placeholder is `?`. If you need an actual `?` in the output, you
@@ -221,9 +420,12 @@ func EscapeArgs(query string, args []interface{}) (string, []interface{}, error)
 	queryWithArgs := &strings.Builder{}
 	argCounter := 1
 	escaped := false
-	for _, queryChar := range query {
+	// the marks are ASCII so going byte by byte is safe and leaves the rest of the query, even
+	// invalid UTF-8, untouched.
+	for i := 0; i < len(query); i++ {
+		queryChar := query[i]
 		if escaped {
-			queryWithArgs.WriteRune(queryChar)
+			queryWithArgs.WriteByte(queryChar)
 			escaped = false
 		} else {
 			switch queryChar {
@@ -234,7 +436,7 @@ func EscapeArgs(query string, args []interface{}) (string, []interface{}, error)
 				queryWithArgs.WriteString(strconv.Itoa(argCounter))
 				argCounter++
 			default:
-				queryWithArgs.WriteRune(queryChar)
+				queryWithArgs.WriteByte(queryChar)
 			}
 		}
 	}
@@ -247,3 +449,49 @@ func EscapeArgs(query string, args []interface{}) (string, []interface{}, error)
 	}
 	return queryWithArgs.String(), args, nil
 }
+
+// QuoteLiteral returns s as a SQL string literal, for the few statements that don't take
+// arguments (ie, PREPARE TRANSACTION).
+func QuoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// QuoteIdentifier returns s as a quoted SQL identifier (ie, a table or schema name) so it can
+// be safely used in a statement.
+func QuoteIdentifier(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// paramNameRe matches the names of configuration parameters, including custom ones that are
+// qualified by a prefix (ie, "myapp.tenant").
+var paramNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ValidateParamName returns an error if name is not a valid configuration parameter name.
+func ValidateParamName(name string) error {
+	if !paramNameRe.MatchString(name) {
+		return errors.Errorf("%q is not a valid parameter name", name)
+	}
+	return nil
+}
+
+// pgBouncerTrackedParams are the runtime params that PgBouncer keeps for each client and sets
+// on the server connection it is assigned.
+var pgBouncerTrackedParams = map[string]bool{
+	"client_encoding":             true,
+	"datestyle":                   true,
+	"timezone":                    true,
+	"standard_conforming_strings": true,
+	"application_name":            true,
+}
+
+// ValidatePgBouncerParams returns an error if any of the passed runtime params would be lost
+// behind PgBouncer in transaction pooling mode, those need to be set in each transaction
+// instead (see DB.SetParam).
+func ValidatePgBouncerParams(params map[string]string) error {
+	for name := range params {
+		if !pgBouncerTrackedParams[strings.ToLower(name)] {
+			return errors.Errorf("runtime param %q is not kept by PgBouncer in transaction pooling mode", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+	"runtime"
+)
+
+type parallelDecodingKey struct{}
+
+// WithParallelDecoding returns a context that makes the queries run with it that fetch into a
+// slice (see DB.Query) decode their rows in workers goroutines, or as many as GOMAXPROCS if
+// workers is not positive, while the rows are read from the connection. The rows keep their
+// order. This pays off for result sets of hundreds of thousands of rows, where decoding them
+// dominates, DBs that don't support it (ie, postgrespq) decode them as usual.
+func WithParallelDecoding(ctx context.Context, workers int) context.Context {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return context.WithValue(ctx, parallelDecodingKey{}, workers)
+}
+
+// ParallelDecodingWorkers returns the amount of goroutines ctx asks rows to be decoded with,
+// see WithParallelDecoding, 0 if it does not.
+func ParallelDecodingWorkers(ctx context.Context) int {
+	workers, _ := ctx.Value(parallelDecodingKey{}).(int)
+	return workers
+}
@@ -0,0 +1,165 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgpassfile"
+	"github.com/pkg/errors"
+)
+
+// env vars that map to a connection string parameter instead of a field of Information.
+var envParams = map[string]string{
+	"PGAPPNAME":            "application_name",
+	"PGCONNECT_TIMEOUT":    "connect_timeout",
+	"PGTARGETSESSIONATTRS": "target_session_attrs",
+	"PGSSLCERT":            "sslcert",
+	"PGSSLKEY":             "sslkey",
+	"PGSSLROOTCERT":        "sslrootcert",
+}
+
+// InformationFromEnv builds an Information from the environment, following the libpq
+// conventions: DATABASE_URL is read first and then PGHOST, PGPORT, PGDATABASE, PGUSER,
+// PGPASSWORD, PGSSLMODE (and a few others that become Params) override its parts.
+// Every variable name is looked up with prefix prepended (ie, prefix "APP_" reads
+// APP_PGHOST), pass "" for the standard names.
+// If no password was found, the passfile pointed by PGPASSFILE or ~/.pgpass is consulted,
+// a missing passfile is not an error.
+func InformationFromEnv(prefix string) (*Information, error) {
+	ci := &Information{}
+	getenv := func(name string) string {
+		return os.Getenv(prefix + name)
+	}
+
+	if dbURL := getenv("DATABASE_URL"); dbURL != "" {
+		if err := ci.fromURL(dbURL); err != nil {
+			return nil, errors.Wrapf(err, "parsing %sDATABASE_URL", prefix)
+		}
+	}
+
+	if host := getenv("PGHOST"); host != "" {
+		ci.Host = host
+	}
+	if port := getenv("PGPORT"); port != "" {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %sPGPORT", prefix)
+		}
+		ci.Port = uint16(p)
+	}
+	if database := getenv("PGDATABASE"); database != "" {
+		ci.Database = database
+	}
+	if user := getenv("PGUSER"); user != "" {
+		ci.User = user
+	}
+	if password := getenv("PGPASSWORD"); password != "" {
+		ci.Password = password
+	}
+	if sslMode := getenv("PGSSLMODE"); sslMode != "" {
+		ci.SSLMode = sslMode
+	}
+	for envName, param := range envParams {
+		if value := getenv(envName); value != "" {
+			if ci.Params == nil {
+				ci.Params = map[string]string{}
+			}
+			ci.Params[param] = value
+		}
+	}
+
+	if ci.Password == "" {
+		password, err := passwordFromPassfile(getenv("PGPASSFILE"), ci)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading passfile")
+		}
+		ci.Password = password
+	}
+	return ci, nil
+}
+
+// fromURL fills the structural fields of Information from a postgres URL.
+func (i *Information) fromURL(dbURL string) error {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return errors.Errorf("invalid scheme %q", u.Scheme)
+	}
+	if u.User != nil {
+		i.User = u.User.Username()
+		i.Password, _ = u.User.Password()
+	}
+	i.Host = u.Hostname()
+	if port := u.Port(); port != "" {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return errors.Wrap(err, "parsing port")
+		}
+		i.Port = uint16(p)
+	}
+	i.Database = strings.TrimPrefix(u.Path, "/")
+	for k, v := range u.Query() {
+		if len(v) == 0 {
+			continue
+		}
+		switch k {
+		case "sslmode":
+			i.SSLMode = v[0]
+		case "host":
+			i.Host = v[0]
+		default:
+			if i.Params == nil {
+				i.Params = map[string]string{}
+			}
+			i.Params[k] = v[0]
+		}
+	}
+	return nil
+}
+
+// passwordFromPassfile returns the password matching ci in the passfile or "" if there
+// is none.
+func passwordFromPassfile(path string, ci *Information) (string, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		path = filepath.Join(home, ".pgpass")
+	}
+	passfile, err := pgpassfile.ReadPassfile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	host := ci.Host
+	if host == "" || strings.HasPrefix(host, "/") {
+		host = "localhost"
+	}
+	port := "5432"
+	if ci.Port != 0 {
+		port = strconv.Itoa(int(ci.Port))
+	}
+	return passfile.FindPassword(host, port, ci.Database, ci.User), nil
+}
@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package connection
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzEscapeArgs(f *testing.F) {
+	for _, seed := range []string{
+		"SELECT * FROM t WHERE id = ?",
+		`data \? 'key' AND id = ?`,
+		`\\? = ?`,
+		`ends with \`,
+		"név = ? AND 名前 = ?",
+	} {
+		f.Add(seed, uint8(2))
+	}
+	f.Fuzz(func(t *testing.T, query string, n uint8) {
+		args := make([]interface{}, int(n%16))
+		escaped, escapedArgs, err := EscapeArgs(query, args)
+		if err != nil {
+			return
+		}
+		if len(escapedArgs) != len(args) {
+			t.Fatalf("got %d args back for %d", len(escapedArgs), len(args))
+		}
+		if !strings.ContainsAny(query, `\?`) && escaped != query {
+			t.Fatalf("query without marks nor escapes %q changed into %q", query, escaped)
+		}
+		if utf8.ValidString(query) && !utf8.ValidString(escaped) {
+			t.Fatalf("valid query %q escaped into invalid UTF-8 %q", query, escaped)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+	"reflect"
+
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/pkg/errors"
+)
+
+// Get runs statement with db and scans the only row it yields into dst, a pointer to a struct,
+// matching the columns to the fields as QueryIter does. It returns errors.ErrNoRows if there are
+// no results and errors.ErrTooManyRows if there is more than one.
+func Get(ctx context.Context, db DB, dst interface{}, statement string, args ...interface{}) error {
+	iter, err := db.QueryIter(ctx, statement, nil, args...)
+	if err != nil {
+		if gaumErrors.IsNoRows(err) {
+			return gaumErrors.ErrNoRows
+		}
+		return errors.Wrap(err, "querying for one row")
+	}
+	next, closer, err := iter(dst)
+	defer closer()
+	if err != nil {
+		return errors.Wrap(err, "fetching one row")
+	}
+	if next {
+		return gaumErrors.ErrTooManyRows
+	}
+	return nil
+}
+
+// NoRowsFetch returns fetch made to return errors.ErrNoRows when it leaves its destination, a
+// pointer to a slice, empty, if enabled is set, see Information.QueryNoRows.
+func NoRowsFetch(enabled bool, fetch ResultFetch) ResultFetch {
+	if !enabled {
+		return fetch
+	}
+	return func(destination interface{}) error {
+		if err := fetch(destination); err != nil {
+			return err
+		}
+		if v := reflect.ValueOf(destination); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice &&
+			v.Elem().Len() == 0 {
+			return gaumErrors.ErrNoRows
+		}
+		return nil
+	}
+}
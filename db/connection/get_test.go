@@ -0,0 +1,35 @@
+package connection
+
+import (
+	stdErrors "errors"
+	"testing"
+
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/pkg/errors"
+)
+
+func TestNoRowsFetch(t *testing.T) {
+	fetchInto := func(ids ...int) ResultFetch {
+		return func(destination interface{}) error {
+			*(destination.(*[]int)) = append([]int{}, ids...)
+			return nil
+		}
+	}
+
+	var ids []int
+	if err := NoRowsFetch(false, fetchInto())(&ids); err != nil || ids == nil {
+		t.Errorf("expected no error and an empty slice when disabled got %v and %v", err, ids)
+	}
+	if err := NoRowsFetch(true, fetchInto())(&ids); !stdErrors.Is(err, gaumErrors.ErrNoRows) || ids == nil {
+		t.Errorf("expected ErrNoRows and an empty slice got %v and %v", err, ids)
+	}
+	if err := NoRowsFetch(true, fetchInto(1, 2))(&ids); err != nil || len(ids) != 2 {
+		t.Errorf("expected no error and two rows got %v and %v", err, ids)
+	}
+
+	failure := errors.New("boom")
+	failing := func(interface{}) error { return failure }
+	if err := NoRowsFetch(true, failing)(&ids); err != failure {
+		t.Errorf("expected the fetch error untouched got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const maskedPassword = "xxxxx"
+
+// DSN returns a postgres URL connection string built from the structural fields of
+// Information, suitable for the drivers to parse. Fields that are empty are left out so
+// the driver defaults (or environment) apply.
+func (i *Information) DSN() string {
+	return i.dsn(i.Password)
+}
+
+// String implements fmt.Stringer, it returns the same as DSN but with the password masked
+// so Information can be safely logged.
+func (i *Information) String() string {
+	if i.Password == "" {
+		return i.dsn("")
+	}
+	return i.dsn(maskedPassword)
+}
+
+func (i *Information) dsn(password string) string {
+	u := url.URL{
+		Scheme: "postgres",
+	}
+	if i.User != "" {
+		if password != "" {
+			u.User = url.UserPassword(i.User, password)
+		} else {
+			u.User = url.User(i.User)
+		}
+	}
+
+	query := url.Values{}
+	for k, v := range i.Params {
+		query.Set(k, v)
+	}
+	if i.SSLMode != "" {
+		query.Set("sslmode", i.SSLMode)
+	}
+	if i.TargetSessionAttrs != "" {
+		query.Set("target_session_attrs", i.TargetSessionAttrs)
+	}
+
+	// unix sockets are paths and cannot be expressed as the host of a URL
+	host := i.Host
+	if strings.HasPrefix(host, "/") {
+		query.Set("host", host)
+		host = ""
+	}
+	switch {
+	case i.Port != 0 && host != "":
+		hosts := strings.Split(host, ",")
+		for j := range hosts {
+			if _, _, err := net.SplitHostPort(hosts[j]); err != nil {
+				hosts[j] = net.JoinHostPort(hosts[j], strconv.Itoa(int(i.Port)))
+			}
+		}
+		u.Host = strings.Join(hosts, ",")
+	case i.Port != 0:
+		query.Set("port", strconv.Itoa(int(i.Port)))
+	default:
+		u.Host = host
+	}
+
+	if i.Database != "" {
+		u.Path = "/" + i.Database
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,158 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/logging"
+)
+
+// DefaultLeakThreshold is used by LeakDetection if no Threshold is set.
+const DefaultLeakThreshold = time.Minute
+
+// LeakDetection configures the detection of results and transactions that hold a connection
+// for too long, most likely because they were abandoned without being consumed or closed.
+type LeakDetection struct {
+	// Threshold is how long a result or transaction can be open before being reported,
+	// DefaultLeakThreshold if 0.
+	Threshold time.Duration
+	// OnLeak, if not nil, is called for each leak found instead of logging it.
+	OnLeak func(Leak)
+}
+
+// Leak describes a result or transaction that was not closed in time.
+type Leak struct {
+	// Kind is one of "iterator", "fetch" or "transaction".
+	Kind string
+	// Statement is the query that produced the result, empty for transactions.
+	Statement string
+	// CallSite is the place, outside gaum, where it was created.
+	CallSite string
+	// Created is when it was created.
+	Created time.Time
+}
+
+// String implements fmt.Stringer
+func (l Leak) String() string {
+	return fmt.Sprintf("%s created at %s by %s still open after %s: %q",
+		l.Kind, l.Created.Format(time.RFC3339), l.CallSite, time.Since(l.Created), l.Statement)
+}
+
+// LeakDetector tracks results and transactions and reports those open for longer than the
+// configured threshold. A nil *LeakDetector tracks nothing so drivers can use it regardless
+// of the detection being enabled.
+type LeakDetector struct {
+	threshold time.Duration
+	onLeak    func(Leak)
+}
+
+// NewLeakDetector returns a LeakDetector for the passed configuration, leaks are logged to
+// logger unless the configuration says otherwise. It returns nil if cfg is nil.
+func NewLeakDetector(cfg *LeakDetection, logger logging.Logger) *LeakDetector {
+	if cfg == nil {
+		return nil
+	}
+	l := &LeakDetector{
+		threshold: cfg.Threshold,
+		onLeak:    cfg.OnLeak,
+	}
+	if l.threshold <= 0 {
+		l.threshold = DefaultLeakThreshold
+	}
+	if l.onLeak == nil {
+		l.onLeak = func(leak Leak) {
+			if logger != nil {
+				logger.Warn("possible connection leak", "kind", leak.Kind, "statement", leak.Statement,
+					"call_site", leak.CallSite, "created", leak.Created)
+			}
+		}
+	}
+	return l
+}
+
+// Track starts tracking something of kind created to run statement, the returned function
+// must be called once it is closed, it can be called more than once.
+func (l *LeakDetector) Track(kind, statement string) func() {
+	if l == nil {
+		return func() {}
+	}
+	leak := Leak{
+		Kind:      kind,
+		Statement: statement,
+		CallSite:  callSite(),
+		Created:   time.Now(),
+	}
+	timer := time.AfterFunc(l.threshold, func() { l.onLeak(leak) })
+	var once sync.Once
+	return func() {
+		once.Do(func() { timer.Stop() })
+	}
+}
+
+// TrackIter returns iter tracked until it is exhausted, fails or its closer is called.
+func (l *LeakDetector) TrackIter(statement string, iter ResultFetchIter) ResultFetchIter {
+	if l == nil {
+		return iter
+	}
+	release := l.Track("iterator", statement)
+	return func(destination interface{}) (bool, func(), error) {
+		next, closer, err := iter(destination)
+		if !next || err != nil {
+			release()
+		}
+		return next, func() {
+			release()
+			closer()
+		}, err
+	}
+}
+
+// TrackFetch returns fetch tracked until it is called.
+func (l *LeakDetector) TrackFetch(statement string, fetch ResultFetch) ResultFetch {
+	if l == nil {
+		return fetch
+	}
+	release := l.Track("fetch", statement)
+	return func(destination interface{}) error {
+		defer release()
+		return fetch(destination)
+	}
+}
+
+// gaumPackages is the prefix of the functions that are not reported as call sites.
+const gaumPackages = "github.com/ShiftLeftSecurity/gaum/v2/db/"
+
+// callSite returns the first caller that is not gaum itself.
+func callSite() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	site := "unknown"
+	for {
+		frame, more := frames.Next()
+		site = fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line)
+		if !strings.HasPrefix(frame.Function, gaumPackages) || strings.HasSuffix(frame.File, "_test.go") {
+			return site
+		}
+		if !more {
+			return site
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package connection
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeakDetector(t *testing.T) {
+	leaks := make(chan Leak, 2)
+	l := NewLeakDetector(&LeakDetection{
+		Threshold: 10 * time.Millisecond,
+		OnLeak:    func(leak Leak) { leaks <- leak },
+	}, nil)
+
+	iter := func(interface{}) (bool, func(), error) { return true, func() {}, nil }
+	leaked := l.TrackIter("SELECT 1", iter)
+	if _, _, err := leaked(nil); err != nil {
+		t.Fatal(err)
+	}
+	closed := l.TrackIter("SELECT 2", iter)
+	_, closer, _ := closed(nil)
+	closer()
+	fetched := l.TrackFetch("SELECT 3", func(interface{}) error { return nil })
+	if err := fetched(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case leak := <-leaks:
+		if leak.Kind != "iterator" || leak.Statement != "SELECT 1" {
+			t.Errorf("unexpected leak %v", leak)
+		}
+		if !strings.Contains(leak.CallSite, "leak_test.go") {
+			t.Errorf("expected the call site to be the test got %q", leak.CallSite)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the leak was not reported")
+	}
+	select {
+	case leak := <-leaks:
+		t.Errorf("only one leak was expected, got %v", leak)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var nilDetector *LeakDetector
+	nilDetector.Track("transaction", "")()
+	if NewLeakDetector(nil, nil) != nil {
+		t.Errorf("expected no detector without configuration")
+	}
+}
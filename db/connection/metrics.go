@@ -0,0 +1,115 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Metrics receives the measurements of the operations run by the DBs, see
+// Information.Metrics. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveQuery is called once each operation finishes, rows is the amount of rows fetched
+	// or affected, -1 if unknown, and err the error it failed with, if any.
+	ObserveQuery(operation string, duration time.Duration, rows int64, err error)
+}
+
+// PoolStats is a snapshot of the state of a connection pool.
+type PoolStats struct {
+	// Max is the maximum amount of connections, 0 if unlimited.
+	Max int64
+	// Open is the amount of connections established, in use or idle.
+	Open int64
+	// InUse is the amount of connections running something.
+	InUse int64
+	// Idle is the amount of connections waiting to be used.
+	Idle int64
+}
+
+// PoolStater is implemented by the DBs that can report the state of their pool.
+type PoolStater interface {
+	PoolStats() PoolStats
+}
+
+type operationKey struct{}
+
+// WithOperation returns a context that makes the operations run with it be reported to
+// Metrics as operation instead of the name of the DB method (ie, "select" instead of
+// "query"), the chain uses it to report the kind of statement.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// operation returns the operation set in ctx, if any, or fallback.
+func operation(ctx context.Context, fallback string) string {
+	if op, ok := ctx.Value(operationKey{}).(string); ok && op != "" {
+		return op
+	}
+	return fallback
+}
+
+// ObserveQuery reports to m, which can be nil, the operation that started at start.
+func ObserveQuery(ctx context.Context, m Metrics, op string, start time.Time, rows int64, err error) {
+	if m == nil {
+		return
+	}
+	m.ObserveQuery(operation(ctx, op), time.Since(start), rows, err)
+}
+
+// MeasureIter returns iter reported to m once it is exhausted, fails or its closer is
+// called, rows being the amount of rows fetched.
+func MeasureIter(ctx context.Context, m Metrics, op string, start time.Time, iter ResultFetchIter) ResultFetchIter {
+	if m == nil {
+		return iter
+	}
+	var rows int64
+	var once sync.Once
+	observe := func(err error) {
+		once.Do(func() { ObserveQuery(ctx, m, op, start, rows, err) })
+	}
+	return func(destination interface{}) (bool, func(), error) {
+		next, closer, err := iter(destination)
+		if err == nil {
+			rows++
+		}
+		if !next || err != nil {
+			observe(err)
+		}
+		return next, func() {
+			observe(nil)
+			closer()
+		}, err
+	}
+}
+
+// MeasureFetch returns fetch reported to m once called, rows being the length of the slice
+// the results were fetched into.
+func MeasureFetch(ctx context.Context, m Metrics, op string, start time.Time, fetch ResultFetch) ResultFetch {
+	if m == nil {
+		return fetch
+	}
+	return func(destination interface{}) error {
+		err := fetch(destination)
+		rows := int64(-1)
+		if v := reflect.ValueOf(destination); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice {
+			rows = int64(v.Elem().Len())
+		}
+		ObserveQuery(ctx, m, op, start, rows, err)
+		return err
+	}
+}
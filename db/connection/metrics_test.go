@@ -0,0 +1,57 @@
+package connection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+type observation struct {
+	operation string
+	rows      int64
+	failed    bool
+}
+
+type recordingMetrics struct {
+	observations []observation
+}
+
+func (r *recordingMetrics) ObserveQuery(operation string, _ time.Duration, rows int64, err error) {
+	r.observations = append(r.observations, observation{operation: operation, rows: rows, failed: err != nil})
+}
+
+func TestMeasure(t *testing.T) {
+	m := &recordingMetrics{}
+	ctx := context.Background()
+
+	remaining := 3
+	iter := MeasureIter(ctx, m, "query_iter", time.Now(), func(interface{}) (bool, func(), error) {
+		remaining--
+		return remaining > 0, func() {}, nil
+	})
+	for next := true; next; {
+		var closer func()
+		next, closer, _ = iter(nil)
+		closer()
+	}
+
+	fetch := MeasureFetch(WithOperation(ctx, "select"), m, "query", time.Now(), func(dst interface{}) error {
+		*(dst.(*[]int)) = []int{1, 2}
+		return nil
+	})
+	var dst []int
+	if err := fetch(&dst); err != nil {
+		t.Fatal(err)
+	}
+	ObserveQuery(ctx, nil, "exec", time.Now(), 1, nil)
+
+	expected := []observation{
+		{operation: "query_iter", rows: 3},
+		{operation: "select", rows: 2},
+	}
+	if diff := deep.Equal(m.observations, expected); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,373 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+var _ DB = (*MiddlewareDB)(nil)
+
+// Call is an operation about to be run by a MiddlewareDB.
+type Call struct {
+	// Method is the name of the DB method being run (ie, "Query" or "Exec").
+	Method string
+	// Statement and Args are those that will be run, middlewares can change them.
+	Statement string
+	Args      []interface{}
+	// Batch holds the statements of ExecBatch.
+	Batch []Statement
+	// Table is the table of BulkInsert, which has no statement.
+	Table string
+}
+
+// Executor runs call.
+type Executor func(ctx context.Context, call *Call) error
+
+// Middleware wraps an Executor to do something before and/or after the next one runs, it can
+// also modify the call or not run it at all by returning an error.
+type Middleware func(next Executor) Executor
+
+// MiddlewareDB is a DB that runs every statement through a chain of middlewares before it
+// reaches the wrapped DB, which allows adding logging, metrics, query rewriting, etc. to any DB.
+// Transactions started from it use the same middlewares. Only the operations that run
+// statements (queries, execs, Raw, Get, ExecBatch and the bulk ones) go through them.
+type MiddlewareDB struct {
+	db          DB
+	middlewares []Middleware
+}
+
+// Use returns db wrapped so every statement goes through middlewares, the first one being
+// the outermost.
+func Use(db DB, middlewares ...Middleware) *MiddlewareDB {
+	if mdb, ok := db.(*MiddlewareDB); ok {
+		all := make([]Middleware, 0, len(mdb.middlewares)+len(middlewares))
+		all = append(all, mdb.middlewares...)
+		return &MiddlewareDB{db: mdb.db, middlewares: append(all, middlewares...)}
+	}
+	return &MiddlewareDB{db: db, middlewares: middlewares}
+}
+
+// Unwrap returns the DB wrapped by the middlewares.
+func (m *MiddlewareDB) Unwrap() DB {
+	return m.db
+}
+
+// run passes call through the middlewares and then to final.
+func (m *MiddlewareDB) run(ctx context.Context, call *Call, final Executor) error {
+	exec := final
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		exec = m.middlewares[i](exec)
+	}
+	return exec(ctx, call)
+}
+
+// Clone implements DB for MiddlewareDB
+func (m *MiddlewareDB) Clone() DB {
+	return &MiddlewareDB{db: m.db.Clone(), middlewares: m.middlewares}
+}
+
+// Close implements DB for MiddlewareDB
+func (m *MiddlewareDB) Close() error {
+	return m.db.Close()
+}
+
+// CloseIdle implements DB for MiddlewareDB
+func (m *MiddlewareDB) CloseIdle(ctx context.Context) error {
+	return m.db.CloseIdle(ctx)
+}
+
+// SetLogLevel implements DB for MiddlewareDB
+func (m *MiddlewareDB) SetLogLevel(level LogLevel) error {
+	return m.db.SetLogLevel(level)
+}
+
+// QueryIter implements DB for MiddlewareDB
+func (m *MiddlewareDB) QueryIter(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetchIter, error) {
+	var iter ResultFetchIter
+	err := m.run(ctx, &Call{Method: "QueryIter", Statement: statement, Args: args},
+		func(ctx context.Context, call *Call) error {
+			var err error
+			iter, err = m.db.QueryIter(ctx, call.Statement, fields, call.Args...)
+			return err
+		})
+	if iter == nil {
+		iter = func(interface{}) (bool, func(), error) { return false, func() {}, nil }
+	}
+	return iter, err
+}
+
+// EQueryIter implements DB for MiddlewareDB
+func (m *MiddlewareDB) EQueryIter(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetchIter, error) {
+	s, a, err := EscapeArgs(statement, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "escaping arguments")
+	}
+	return m.QueryIter(ctx, s, fields, a...)
+}
+
+// Query implements DB for MiddlewareDB
+func (m *MiddlewareDB) Query(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetch, error) {
+	var fetch ResultFetch
+	err := m.run(ctx, &Call{Method: "Query", Statement: statement, Args: args},
+		func(ctx context.Context, call *Call) error {
+			var err error
+			fetch, err = m.db.Query(ctx, call.Statement, fields, call.Args...)
+			return err
+		})
+	if fetch == nil {
+		fetch = func(interface{}) error { return nil }
+	}
+	return fetch, err
+}
+
+// EQuery implements DB for MiddlewareDB
+func (m *MiddlewareDB) EQuery(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetch, error) {
+	s, a, err := EscapeArgs(statement, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "escaping arguments")
+	}
+	return m.Query(ctx, s, fields, a...)
+}
+
+// QueryPrimitive implements DB for MiddlewareDB
+func (m *MiddlewareDB) QueryPrimitive(ctx context.Context, statement string, field string, args ...interface{}) (ResultFetch, error) {
+	var fetch ResultFetch
+	err := m.run(ctx, &Call{Method: "QueryPrimitive", Statement: statement, Args: args},
+		func(ctx context.Context, call *Call) error {
+			var err error
+			fetch, err = m.db.QueryPrimitive(ctx, call.Statement, field, call.Args...)
+			return err
+		})
+	if fetch == nil {
+		fetch = func(interface{}) error { return nil }
+	}
+	return fetch, err
+}
+
+// EQueryPrimitive implements DB for MiddlewareDB
+func (m *MiddlewareDB) EQueryPrimitive(ctx context.Context, statement string, field string, args ...interface{}) (ResultFetch, error) {
+	s, a, err := EscapeArgs(statement, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "escaping arguments")
+	}
+	return m.QueryPrimitive(ctx, s, field, a...)
+}
+
+// Get implements DB for MiddlewareDB, the statement goes through the middlewares as QueryIter.
+func (m *MiddlewareDB) Get(ctx context.Context, dst interface{}, statement string, args ...interface{}) error {
+	return Get(ctx, m, dst, statement, args...)
+}
+
+// Raw implements DB for MiddlewareDB
+func (m *MiddlewareDB) Raw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error {
+	return m.run(ctx, &Call{Method: "Raw", Statement: statement, Args: args},
+		func(ctx context.Context, call *Call) error {
+			return m.db.Raw(ctx, call.Statement, call.Args, fields...)
+		})
+}
+
+// ERaw implements DB for MiddlewareDB
+func (m *MiddlewareDB) ERaw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error {
+	s, a, err := EscapeArgs(statement, args)
+	if err != nil {
+		return errors.Wrap(err, "escaping arguments")
+	}
+	return m.Raw(ctx, s, a, fields...)
+}
+
+// Exec implements DB for MiddlewareDB
+func (m *MiddlewareDB) Exec(ctx context.Context, statement string, args ...interface{}) error {
+	return m.run(ctx, &Call{Method: "Exec", Statement: statement, Args: args},
+		func(ctx context.Context, call *Call) error {
+			return m.db.Exec(ctx, call.Statement, call.Args...)
+		})
+}
+
+// ExecResult implements DB for MiddlewareDB
+func (m *MiddlewareDB) ExecResult(ctx context.Context, statement string, args ...interface{}) (int64, error) {
+	var rowsAffected int64
+	err := m.run(ctx, &Call{Method: "ExecResult", Statement: statement, Args: args},
+		func(ctx context.Context, call *Call) error {
+			var err error
+			rowsAffected, err = m.db.ExecResult(ctx, call.Statement, call.Args...)
+			return err
+		})
+	return rowsAffected, err
+}
+
+// ExecInfo implements DB for MiddlewareDB
+func (m *MiddlewareDB) ExecInfo(ctx context.Context, statement string, args ...interface{}) (CommandTag, error) {
+	var tag CommandTag
+	err := m.run(ctx, &Call{Method: "ExecInfo", Statement: statement, Args: args},
+		func(ctx context.Context, call *Call) error {
+			var err error
+			tag, err = m.db.ExecInfo(ctx, call.Statement, call.Args...)
+			return err
+		})
+	return tag, err
+}
+
+// ExecBatch implements DB for MiddlewareDB, the whole batch goes through the middlewares
+// once, in Call.Batch.
+func (m *MiddlewareDB) ExecBatch(ctx context.Context, statements []Statement) ([]BatchResult, error) {
+	var results []BatchResult
+	err := m.run(ctx, &Call{Method: "ExecBatch", Batch: statements},
+		func(ctx context.Context, call *Call) error {
+			var err error
+			results, err = m.db.ExecBatch(ctx, call.Batch)
+			return err
+		})
+	return results, err
+}
+
+// EExec implements DB for MiddlewareDB
+func (m *MiddlewareDB) EExec(ctx context.Context, statement string, args ...interface{}) error {
+	s, a, err := EscapeArgs(statement, args)
+	if err != nil {
+		return errors.Wrap(err, "escaping arguments")
+	}
+	return m.Exec(ctx, s, a...)
+}
+
+// BeginTransaction implements DB for MiddlewareDB, the transaction uses the same middlewares.
+func (m *MiddlewareDB) BeginTransaction(ctx context.Context) (DB, error) {
+	tx, err := m.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &MiddlewareDB{db: tx, middlewares: m.middlewares}, nil
+}
+
+// TransactionRetry implements TransactionRetrier, it returns the policy of the wrapped DB, if any.
+func (m *MiddlewareDB) TransactionRetry() *Backoff {
+	if retrier, ok := m.db.(TransactionRetrier); ok {
+		return retrier.TransactionRetry()
+	}
+	return nil
+}
+
+// SlicesAsArrays implements SliceArrayBinder, it returns what the wrapped DB does.
+func (m *MiddlewareDB) SlicesAsArrays() bool {
+	return BindsSlicesAsArrays(m.db)
+}
+
+// CommitTransaction implements DB for MiddlewareDB
+func (m *MiddlewareDB) CommitTransaction(ctx context.Context) error {
+	return m.db.CommitTransaction(ctx)
+}
+
+// RollbackTransaction implements DB for MiddlewareDB
+func (m *MiddlewareDB) RollbackTransaction(ctx context.Context) error {
+	return m.db.RollbackTransaction(ctx)
+}
+
+// IsTransaction implements DB for MiddlewareDB
+func (m *MiddlewareDB) IsTransaction() bool {
+	return m.db.IsTransaction()
+}
+
+// PrepareTransaction implements DB for MiddlewareDB
+func (m *MiddlewareDB) PrepareTransaction(ctx context.Context, gid string) error {
+	return m.db.PrepareTransaction(ctx, gid)
+}
+
+// CommitPrepared implements DB for MiddlewareDB
+func (m *MiddlewareDB) CommitPrepared(ctx context.Context, gid string) error {
+	return m.db.CommitPrepared(ctx, gid)
+}
+
+// RollbackPrepared implements DB for MiddlewareDB
+func (m *MiddlewareDB) RollbackPrepared(ctx context.Context, gid string) error {
+	return m.db.RollbackPrepared(ctx, gid)
+}
+
+// Set implements DB for MiddlewareDB
+func (m *MiddlewareDB) Set(ctx context.Context, set string) error {
+	return m.db.Set(ctx, set)
+}
+
+// SetParam implements DB for MiddlewareDB
+func (m *MiddlewareDB) SetParam(ctx context.Context, name, value string) error {
+	return m.db.SetParam(ctx, name, value)
+}
+
+// BulkInsert implements DB for MiddlewareDB, the call has no statement but Table.
+func (m *MiddlewareDB) BulkInsert(ctx context.Context, tableName string, columns []string, values [][]interface{}) error {
+	return m.run(ctx, &Call{Method: "BulkInsert", Table: tableName},
+		func(ctx context.Context, call *Call) error {
+			return m.db.BulkInsert(ctx, call.Table, columns, values)
+		})
+}
+
+// BulkInsertReturning implements DB for MiddlewareDB, its statements go through the middlewares.
+func (m *MiddlewareDB) BulkInsertReturning(ctx context.Context, tableName string, columns []string, values [][]interface{},
+	returning []string, dst interface{}) error {
+	return BulkInsertReturning(ctx, m, tableName, columns, values, returning, dst)
+}
+
+// BulkUpsert implements DB for MiddlewareDB, its statements go through the middlewares.
+func (m *MiddlewareDB) BulkUpsert(ctx context.Context, tableName string, columns, conflictTarget, updateCols []string,
+	values [][]interface{}) error {
+	return BulkUpsert(ctx, m, tableName, columns, conflictTarget, updateCols, values)
+}
+
+// BulkUpdate implements DB for MiddlewareDB, its statements go through the middlewares.
+func (m *MiddlewareDB) BulkUpdate(ctx context.Context, tableName string, keyCols, setCols []string, rows [][]interface{}) error {
+	return BulkUpdate(ctx, m, tableName, keyCols, setCols, rows)
+}
+
+// BulkInsertChunked implements DB for MiddlewareDB, its statements go through the middlewares.
+func (m *MiddlewareDB) BulkInsertChunked(ctx context.Context, tableName string, columns []string, source BulkSource,
+	opts BulkInsertOptions) error {
+	return BulkInsertChunked(ctx, m, tableName, columns, source, opts)
+}
+
+// Deallocate implements DB for MiddlewareDB
+func (m *MiddlewareDB) Deallocate(ctx context.Context, name string) error {
+	return m.db.Deallocate(ctx, name)
+}
+
+// DeallocateAll implements DB for MiddlewareDB
+func (m *MiddlewareDB) DeallocateAll(ctx context.Context) error {
+	return m.db.DeallocateAll(ctx)
+}
+
+// Listen implements DB for MiddlewareDB
+func (m *MiddlewareDB) Listen(ctx context.Context, channel string) (<-chan Notification, error) {
+	return m.db.Listen(ctx, channel)
+}
+
+// Notify implements DB for MiddlewareDB
+func (m *MiddlewareDB) Notify(ctx context.Context, channel, payload string) error {
+	return m.db.Notify(ctx, channel, payload)
+}
+
+// NextVal implements DB for MiddlewareDB, its statement goes through the middlewares.
+func (m *MiddlewareDB) NextVal(ctx context.Context, seq string) (int64, error) {
+	return NextVal(ctx, m, seq)
+}
+
+// CurrVal implements DB for MiddlewareDB, its statement goes through the middlewares.
+func (m *MiddlewareDB) CurrVal(ctx context.Context, seq string) (int64, error) {
+	return CurrVal(ctx, m, seq)
+}
+
+// SetVal implements DB for MiddlewareDB, its statement goes through the middlewares.
+func (m *MiddlewareDB) SetVal(ctx context.Context, seq string, value int64, isCalled bool) error {
+	return SetVal(ctx, m, seq, value, isCalled)
+}
@@ -0,0 +1,94 @@
+package connection
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+)
+
+type statementConn struct {
+	DB
+	statements []string
+}
+
+func (s *statementConn) Exec(ctx context.Context, statement string, args ...interface{}) error {
+	s.statements = append(s.statements, statement)
+	return nil
+}
+
+func (s *statementConn) BeginTransaction(ctx context.Context) (DB, error) {
+	return s, nil
+}
+
+func TestMiddlewareDB(t *testing.T) {
+	conn := &statementConn{}
+	var trace []string
+	tracing := func(name string) Middleware {
+		return func(next Executor) Executor {
+			return func(ctx context.Context, call *Call) error {
+				trace = append(trace, name+" "+call.Method)
+				return next(ctx, call)
+			}
+		}
+	}
+	rewrite := func(next Executor) Executor {
+		return func(ctx context.Context, call *Call) error {
+			call.Statement = "/* gaum */ " + call.Statement
+			return next(ctx, call)
+		}
+	}
+	db := Use(Use(conn, tracing("outer")), rewrite, tracing("inner"))
+	ctx := context.Background()
+
+	if err := db.Exec(ctx, "DELETE FROM justforfun"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.BeginTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Exec(ctx, "UPDATE justforfun SET description = ''"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(trace, []string{"outer Exec", "inner Exec", "outer Exec", "inner Exec"}); diff != nil {
+		t.Error(diff)
+	}
+	for _, statement := range conn.statements {
+		if !strings.HasPrefix(statement, "/* gaum */ ") {
+			t.Errorf("expected the statement to be rewritten got %q", statement)
+		}
+	}
+
+	blocked := errors.New("circuit open")
+	breaker := Use(conn, func(next Executor) Executor {
+		return func(ctx context.Context, call *Call) error { return blocked }
+	})
+	if err := breaker.Exec(ctx, "SELECT 1"); err != blocked {
+		t.Errorf("expected the middleware error got %v", err)
+	}
+	if len(conn.statements) != 2 {
+		t.Errorf("the blocked statement must not be run")
+	}
+	if _, err := breaker.Query(ctx, "SELECT 1", nil); err != blocked {
+		t.Errorf("expected the middleware error got %v", err)
+	}
+}
+
+// arraysConn binds slices as arrays.
+type arraysConn struct {
+	DB
+}
+
+func (arraysConn) SlicesAsArrays() bool { return true }
+
+func TestBindsSlicesAsArrays(t *testing.T) {
+	if BindsSlicesAsArrays(&statementConn{}) || BindsSlicesAsArrays(nil) {
+		t.Errorf("expected a DB without the option to not bind slices as arrays")
+	}
+	if !BindsSlicesAsArrays(Use(arraysConn{})) || !BindsSlicesAsArrays(NewRoutedDB(arraysConn{}, &statementConn{})) {
+		t.Errorf("expected the wrapping DBs to follow the wrapped one")
+	}
+}
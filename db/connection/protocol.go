@@ -0,0 +1,32 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import "context"
+
+type simpleProtocolKey struct{}
+
+// WithSimpleProtocol returns a context that makes the queries run with it use the simple query
+// protocol, regardless of Information.PreferSimpleProtocol. Arguments are then interpolated
+// client side and nothing is prepared in the server.
+func WithSimpleProtocol(ctx context.Context) context.Context {
+	return context.WithValue(ctx, simpleProtocolKey{}, true)
+}
+
+// UsesSimpleProtocol returns true if ctx was obtained from WithSimpleProtocol.
+func UsesSimpleProtocol(ctx context.Context) bool {
+	simple, _ := ctx.Value(simpleProtocolKey{}).(bool)
+	return simple
+}
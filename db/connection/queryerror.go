@@ -0,0 +1,67 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+)
+
+// AttachQuery returns err as a *errors.QueryError for the op operation running statement since
+// start if attach is set, see Information.AttachQueryToErrors. ErrNoRows, which is not a
+// failure, and errors that already carry their query are returned as they are.
+func AttachQuery(ctx context.Context, attach bool, op, statement string, argCount int, start time.Time,
+	err error) error {
+	if !attach || err == nil || err == gaumErrors.ErrNoRows {
+		return err
+	}
+	var queryErr *gaumErrors.QueryError
+	if errors.As(err, &queryErr) {
+		return err
+	}
+	return &gaumErrors.QueryError{
+		Operation: operation(ctx, op),
+		Statement: statement,
+		ArgCount:  argCount,
+		Duration:  time.Since(start),
+		Err:       err,
+	}
+}
+
+// AttachQueryIter returns iter with its errors passed through AttachQuery.
+func AttachQueryIter(ctx context.Context, attach bool, op, statement string, argCount int, start time.Time,
+	iter ResultFetchIter) ResultFetchIter {
+	if !attach {
+		return iter
+	}
+	return func(destination interface{}) (bool, func(), error) {
+		next, closer, err := iter(destination)
+		return next, closer, AttachQuery(ctx, attach, op, statement, argCount, start, err)
+	}
+}
+
+// AttachQueryFetch returns fetch with its errors passed through AttachQuery.
+func AttachQueryFetch(ctx context.Context, attach bool, op, statement string, argCount int, start time.Time,
+	fetch ResultFetch) ResultFetch {
+	if !attach {
+		return fetch
+	}
+	return func(destination interface{}) error {
+		return AttachQuery(ctx, attach, op, statement, argCount, start, fetch(destination))
+	}
+}
@@ -0,0 +1,48 @@
+package connection
+
+import (
+	"context"
+	stdErrors "errors"
+	"testing"
+	"time"
+
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/pkg/errors"
+)
+
+func TestAttachQuery(t *testing.T) {
+	ctx := WithOperation(context.Background(), "select")
+	start := time.Now()
+	failure := errors.New("boom")
+	statement := "SELECT * FROM justforfun WHERE id = $1"
+
+	if err := AttachQuery(ctx, false, "query", statement, 1, start, failure); err != failure {
+		t.Errorf("expected the error untouched when not attaching got %v", err)
+	}
+	for _, err := range []error{nil, gaumErrors.ErrNoRows} {
+		if got := AttachQuery(ctx, true, "query", statement, 1, start, err); got != err {
+			t.Errorf("expected %v untouched got %v", err, got)
+		}
+	}
+
+	err := AttachQuery(ctx, true, "query", statement, 1, start, failure)
+	var queryErr *gaumErrors.QueryError
+	if !stdErrors.As(errors.Wrap(err, "fetching"), &queryErr) {
+		t.Fatalf("expected a *QueryError got %v", err)
+	}
+	if queryErr.Operation != "select" || queryErr.Statement != statement || queryErr.ArgCount != 1 {
+		t.Errorf("unexpected payload %+v", queryErr)
+	}
+	if err.Error() != "boom" || !stdErrors.Is(err, failure) {
+		t.Errorf("expected the error to read and unwrap as the original one")
+	}
+	if again := AttachQuery(ctx, true, "query", "SELECT 1", 0, start, errors.Wrap(err, "again")); !stdErrors.As(again, &queryErr) ||
+		queryErr.Statement != statement {
+		t.Errorf("expected the first attached query to be kept")
+	}
+
+	fetch := AttachQueryFetch(ctx, true, "query", statement, 1, start, func(interface{}) error { return failure })
+	if !stdErrors.As(fetch(nil), &queryErr) {
+		t.Errorf("expected fetch errors to carry the query")
+	}
+}
@@ -0,0 +1,342 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/selectparse"
+)
+
+var _ DB = (*RoutedDB)(nil)
+
+// RoutedDB is a DB that sends read only queries to a set of replicas, in a round robin fashion,
+// and everything else to the primary.
+// A query is considered read only as described in IsReadOnly, anything else, including all
+// queries in a transaction started from RoutedDB, goes to the primary. Queries calling
+// functions that write must be run in Primary() explicitly.
+type RoutedDB struct {
+	primary  DB
+	replicas []DB
+	next     *uint32
+}
+
+// NewRoutedDB returns a RoutedDB that uses primary for writes and replicas for reads, if no
+// replicas are passed all goes to primary.
+func NewRoutedDB(primary DB, replicas ...DB) *RoutedDB {
+	return &RoutedDB{
+		primary:  primary,
+		replicas: replicas,
+		next:     new(uint32),
+	}
+}
+
+// writingFunctions are the built in functions that change the state of the database, a SELECT
+// calling them can't run in a replica.
+var writingFunctions = map[string]bool{
+	"nextval": true, "setval": true, "txid_current": true, "pg_current_xact_id": true, "pg_notify": true,
+	"pg_advisory_lock": true, "pg_advisory_lock_shared": true,
+	"pg_advisory_xact_lock": true, "pg_advisory_xact_lock_shared": true,
+	"pg_try_advisory_lock": true, "pg_try_advisory_lock_shared": true,
+	"pg_try_advisory_xact_lock": true, "pg_try_advisory_xact_lock_shared": true,
+	"pg_advisory_unlock": true, "pg_advisory_unlock_shared": true, "pg_advisory_unlock_all": true,
+	"lo_create": true, "lo_creat": true, "lo_import": true, "lo_unlink": true, "lo_put": true,
+	"lo_from_bytea": true,
+}
+
+// IsReadOnly returns true if the statement only reads data, so it can be safely run in a
+// read only replica or retried.
+// That is a SELECT, or a WITH containing no data modifying statement, without INTO, locking
+// clauses (FOR UPDATE and the like) nor calls to the built in functions that write, such as
+// nextval or pg_advisory_lock. Functions defined by the user can't be told apart, a SELECT
+// calling one that writes is still reported as read only.
+func IsReadOnly(statement string) bool {
+	var words []selectparse.Token
+	for _, token := range selectparse.Tokenize(statement) {
+		if token.Kind != selectparse.Space && token.Kind != selectparse.Comment {
+			words = append(words, token)
+		}
+	}
+	if len(words) == 0 || !(words[0].Is("SELECT") || words[0].Is("WITH")) {
+		return false
+	}
+	for i, token := range words {
+		if token.Kind != selectparse.Word {
+			continue
+		}
+		var next selectparse.Token
+		if i+1 < len(words) {
+			next = words[i+1]
+		}
+		switch {
+		case token.Is("INSERT"), token.Is("UPDATE"), token.Is("DELETE"), token.Is("INTO"):
+			return false
+		case token.Is("FOR") && (next.Is("SHARE") || next.Is("NO") || next.Is("KEY")):
+			return false
+		case next.Kind == selectparse.OpenParens && writingFunctions[strings.ToLower(token.Text)]:
+			return false
+		}
+	}
+	return true
+}
+
+// route returns the DB that should run statement.
+func (r *RoutedDB) route(statement string) DB {
+	if len(r.replicas) == 0 || !IsReadOnly(statement) {
+		return r.primary
+	}
+	n := atomic.AddUint32(r.next, 1)
+	return r.replicas[(n-1)%uint32(len(r.replicas))]
+}
+
+// Primary returns the DB used for writes.
+func (r *RoutedDB) Primary() DB {
+	return r.primary
+}
+
+// Replicas returns the DBs used for reads.
+func (r *RoutedDB) Replicas() []DB {
+	return r.replicas
+}
+
+// SlicesAsArrays implements SliceArrayBinder, replicas are expected to be configured as the
+// primary is.
+func (r *RoutedDB) SlicesAsArrays() bool {
+	return BindsSlicesAsArrays(r.primary)
+}
+
+// Clone implements DB for RoutedDB
+func (r *RoutedDB) Clone() DB {
+	replicas := make([]DB, len(r.replicas))
+	for i := range r.replicas {
+		replicas[i] = r.replicas[i].Clone()
+	}
+	return &RoutedDB{
+		primary:  r.primary.Clone(),
+		replicas: replicas,
+		next:     r.next,
+	}
+}
+
+// Close implements DB for RoutedDB, it closes primary and replicas and returns the first
+// error found.
+func (r *RoutedDB) Close() error {
+	return r.all(func(db DB) error { return db.Close() })
+}
+
+// CloseIdle implements DB for RoutedDB, it runs in the primary and all replicas.
+func (r *RoutedDB) CloseIdle(ctx context.Context) error {
+	return r.all(func(db DB) error { return db.CloseIdle(ctx) })
+}
+
+// SetLogLevel implements DB for RoutedDB, it runs in the primary and all replicas.
+func (r *RoutedDB) SetLogLevel(level LogLevel) error {
+	return r.all(func(db DB) error { return db.SetLogLevel(level) })
+}
+
+// QueryIter implements DB for RoutedDB
+func (r *RoutedDB) QueryIter(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetchIter, error) {
+	return r.route(statement).QueryIter(ctx, statement, fields, args...)
+}
+
+// EQueryIter implements DB for RoutedDB
+func (r *RoutedDB) EQueryIter(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetchIter, error) {
+	return r.route(statement).EQueryIter(ctx, statement, fields, args...)
+}
+
+// Query implements DB for RoutedDB
+func (r *RoutedDB) Query(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetch, error) {
+	return r.route(statement).Query(ctx, statement, fields, args...)
+}
+
+// EQuery implements DB for RoutedDB
+func (r *RoutedDB) EQuery(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetch, error) {
+	return r.route(statement).EQuery(ctx, statement, fields, args...)
+}
+
+// QueryPrimitive implements DB for RoutedDB
+func (r *RoutedDB) QueryPrimitive(ctx context.Context, statement string, field string, args ...interface{}) (ResultFetch, error) {
+	return r.route(statement).QueryPrimitive(ctx, statement, field, args...)
+}
+
+// EQueryPrimitive implements DB for RoutedDB
+func (r *RoutedDB) EQueryPrimitive(ctx context.Context, statement string, field string, args ...interface{}) (ResultFetch, error) {
+	return r.route(statement).EQueryPrimitive(ctx, statement, field, args...)
+}
+
+// Get implements DB for RoutedDB
+func (r *RoutedDB) Get(ctx context.Context, dst interface{}, statement string, args ...interface{}) error {
+	return r.route(statement).Get(ctx, dst, statement, args...)
+}
+
+// Raw implements DB for RoutedDB
+func (r *RoutedDB) Raw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error {
+	return r.route(statement).Raw(ctx, statement, args, fields...)
+}
+
+// ERaw implements DB for RoutedDB
+func (r *RoutedDB) ERaw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error {
+	return r.route(statement).ERaw(ctx, statement, args, fields...)
+}
+
+// Exec implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) Exec(ctx context.Context, statement string, args ...interface{}) error {
+	return r.primary.Exec(ctx, statement, args...)
+}
+
+// ExecResult implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) ExecResult(ctx context.Context, statement string, args ...interface{}) (int64, error) {
+	return r.primary.ExecResult(ctx, statement, args...)
+}
+
+// ExecInfo implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) ExecInfo(ctx context.Context, statement string, args ...interface{}) (CommandTag, error) {
+	return r.primary.ExecInfo(ctx, statement, args...)
+}
+
+// ExecBatch implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) ExecBatch(ctx context.Context, statements []Statement) ([]BatchResult, error) {
+	return r.primary.ExecBatch(ctx, statements)
+}
+
+// EExec implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) EExec(ctx context.Context, statement string, args ...interface{}) error {
+	return r.primary.EExec(ctx, statement, args...)
+}
+
+// BeginTransaction implements DB for RoutedDB, the returned DB is a transaction on the primary.
+func (r *RoutedDB) BeginTransaction(ctx context.Context) (DB, error) {
+	return r.primary.BeginTransaction(ctx)
+}
+
+// CommitTransaction implements DB for RoutedDB, RoutedDB is never a transaction so this will
+// yield whatever error the primary returns for this case.
+func (r *RoutedDB) CommitTransaction(ctx context.Context) error {
+	return r.primary.CommitTransaction(ctx)
+}
+
+// RollbackTransaction implements DB for RoutedDB, RoutedDB is never a transaction so this will
+// yield whatever error the primary returns for this case.
+func (r *RoutedDB) RollbackTransaction(ctx context.Context) error {
+	return r.primary.RollbackTransaction(ctx)
+}
+
+// IsTransaction implements DB for RoutedDB
+func (r *RoutedDB) IsTransaction() bool {
+	return r.primary.IsTransaction()
+}
+
+// PrepareTransaction implements DB for RoutedDB, RoutedDB is never a transaction so this will
+// yield whatever error the primary returns for this case.
+func (r *RoutedDB) PrepareTransaction(ctx context.Context, gid string) error {
+	return r.primary.PrepareTransaction(ctx, gid)
+}
+
+// CommitPrepared implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) CommitPrepared(ctx context.Context, gid string) error {
+	return r.primary.CommitPrepared(ctx, gid)
+}
+
+// RollbackPrepared implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) RollbackPrepared(ctx context.Context, gid string) error {
+	return r.primary.RollbackPrepared(ctx, gid)
+}
+
+// Set implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) Set(ctx context.Context, set string) error {
+	return r.primary.Set(ctx, set)
+}
+
+// SetParam implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) SetParam(ctx context.Context, name, value string) error {
+	return r.primary.SetParam(ctx, name, value)
+}
+
+// BulkInsert implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) BulkInsert(ctx context.Context, tableName string, columns []string, values [][]interface{}) error {
+	return r.primary.BulkInsert(ctx, tableName, columns, values)
+}
+
+// BulkInsertReturning implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) BulkInsertReturning(ctx context.Context, tableName string, columns []string, values [][]interface{},
+	returning []string, dst interface{}) error {
+	return r.primary.BulkInsertReturning(ctx, tableName, columns, values, returning, dst)
+}
+
+// BulkUpsert implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) BulkUpsert(ctx context.Context, tableName string, columns, conflictTarget, updateCols []string,
+	values [][]interface{}) error {
+	return r.primary.BulkUpsert(ctx, tableName, columns, conflictTarget, updateCols, values)
+}
+
+// BulkUpdate implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) BulkUpdate(ctx context.Context, tableName string, keyCols, setCols []string, rows [][]interface{}) error {
+	return r.primary.BulkUpdate(ctx, tableName, keyCols, setCols, rows)
+}
+
+// BulkInsertChunked implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) BulkInsertChunked(ctx context.Context, tableName string, columns []string, source BulkSource,
+	opts BulkInsertOptions) error {
+	return r.primary.BulkInsertChunked(ctx, tableName, columns, source, opts)
+}
+
+// Deallocate implements DB for RoutedDB, it runs in the primary and all replicas.
+func (r *RoutedDB) Deallocate(ctx context.Context, name string) error {
+	return r.all(func(db DB) error { return db.Deallocate(ctx, name) })
+}
+
+// DeallocateAll implements DB for RoutedDB, it runs in the primary and all replicas.
+func (r *RoutedDB) DeallocateAll(ctx context.Context) error {
+	return r.all(func(db DB) error { return db.DeallocateAll(ctx) })
+}
+
+// Listen implements DB for RoutedDB, it always uses the primary as replicas can't receive
+// notifications.
+func (r *RoutedDB) Listen(ctx context.Context, channel string) (<-chan Notification, error) {
+	return r.primary.Listen(ctx, channel)
+}
+
+// Notify implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) Notify(ctx context.Context, channel, payload string) error {
+	return r.primary.Notify(ctx, channel, payload)
+}
+
+// NextVal implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) NextVal(ctx context.Context, seq string) (int64, error) {
+	return r.primary.NextVal(ctx, seq)
+}
+
+// CurrVal implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) CurrVal(ctx context.Context, seq string) (int64, error) {
+	return r.primary.CurrVal(ctx, seq)
+}
+
+// SetVal implements DB for RoutedDB, it always uses the primary.
+func (r *RoutedDB) SetVal(ctx context.Context, seq string, value int64, isCalled bool) error {
+	return r.primary.SetVal(ctx, seq, value, isCalled)
+}
+
+// all runs f for the primary and each replica, returning the first error found.
+func (r *RoutedDB) all(f func(DB) error) error {
+	err := f(r.primary)
+	for _, replica := range r.replicas {
+		if rErr := f(replica); rErr != nil && err == nil {
+			err = rErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package connection
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+type recordingConn struct {
+	DB
+	name  string
+	calls *[]string
+}
+
+func (r *recordingConn) Query(ctx context.Context, statement string, fields []string, args ...interface{}) (ResultFetch, error) {
+	*r.calls = append(*r.calls, r.name)
+	return nil, nil
+}
+
+func (r *recordingConn) Exec(ctx context.Context, statement string, args ...interface{}) error {
+	*r.calls = append(*r.calls, r.name)
+	return nil
+}
+
+func (r *recordingConn) BeginTransaction(ctx context.Context) (DB, error) {
+	*r.calls = append(*r.calls, r.name+" begin")
+	return r, nil
+}
+
+func TestIsReadOnly(t *testing.T) {
+	for statement, readOnly := range map[string]bool{
+		"SELECT * FROM justforfun":                                        true,
+		"  select id from justforfun where id = $1":                       true,
+		"WITH ids AS (SELECT id FROM justforfun) SELECT * FROM ids":       true,
+		"SELECT * FROM justforfun FOR UPDATE":                             false,
+		"SELECT * FROM justforfun FOR NO KEY UPDATE":                      false,
+		"SELECT * FROM justforfun for share":                              false,
+		"WITH d AS (DELETE FROM justforfun RETURNING id) SELECT * FROM d": false,
+		"INSERT INTO justforfun (id) VALUES (1) RETURNING id":             false,
+		"UPDATE justforfun SET description = 'a'":                         false,
+		"SELECT * INTO newtable FROM justforfun":                          false,
+		"SELECT id, description INTO TEMP t FROM justforfun":              false,
+		"SELECT nextval('justforfun_id_seq')":                             false,
+		"SELECT pg_catalog.setval('justforfun_id_seq', 1)":                false,
+		"SELECT pg_advisory_lock(1)":                                      false,
+		"SELECT pg_try_advisory_xact_lock(1, 2)":                          false,
+		"SELECT * FROM justforfun FOR KEY SHARE":                          false,
+		"SELECT 'insert into' FROM justforfun -- for update":              true,
+		"SELECT \"into\", nextval FROM justforfun":                        true,
+		"/* comment */ SELECT count(*) FROM justforfun":                   true,
+	} {
+		if got := IsReadOnly(statement); got != readOnly {
+			t.Errorf("IsReadOnly(%q) = %v, expected %v", statement, got, readOnly)
+		}
+	}
+}
+
+func TestRoutedDB(t *testing.T) {
+	calls := []string{}
+	r := NewRoutedDB(&recordingConn{name: "primary", calls: &calls},
+		&recordingConn{name: "replica1", calls: &calls},
+		&recordingConn{name: "replica2", calls: &calls})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.Query(ctx, "SELECT * FROM justforfun", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.Query(ctx, "INSERT INTO justforfun (id) VALUES (1) RETURNING id", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Exec(ctx, "DELETE FROM justforfun"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := r.BeginTransaction(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Query(ctx, "SELECT * FROM justforfun", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"replica1", "replica2", "replica1", "primary", "primary", "primary begin", "primary"}
+	if diff := deep.Equal(calls, expected); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,51 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// NextVal advances seq and returns its new value.
+func NextVal(ctx context.Context, db DB, seq string) (int64, error) {
+	var value int64
+	if err := db.Raw(ctx, "SELECT nextval($1::text::regclass)", []interface{}{seq}, &value); err != nil {
+		return 0, errors.Wrapf(err, "getting next value of sequence %q", seq)
+	}
+	return value, nil
+}
+
+// CurrVal returns the value most recently obtained by NextVal for seq in the current session,
+// it fails if NextVal was not called for seq in it.
+func CurrVal(ctx context.Context, db DB, seq string) (int64, error) {
+	var value int64
+	if err := db.Raw(ctx, "SELECT currval($1::text::regclass)", []interface{}{seq}, &value); err != nil {
+		return 0, errors.Wrapf(err, "getting current value of sequence %q", seq)
+	}
+	return value, nil
+}
+
+// SetVal sets the current value of seq, if isCalled is true the next NextVal returns value
+// plus the sequence increment, otherwise it returns value.
+func SetVal(ctx context.Context, db DB, seq string, value int64, isCalled bool) error {
+	var set int64
+	err := db.Raw(ctx, "SELECT setval($1::text::regclass, $2, $3)", []interface{}{seq, value, isCalled}, &set)
+	if err != nil {
+		return errors.Wrapf(err, "setting value of sequence %q", seq)
+	}
+	return nil
+}
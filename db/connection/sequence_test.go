@@ -0,0 +1,51 @@
+package connection
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+type sequenceConn struct {
+	fakeConn
+	statements []string
+	args       [][]interface{}
+}
+
+func (s *sequenceConn) Raw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error {
+	s.statements = append(s.statements, statement)
+	s.args = append(s.args, args)
+	*(fields[0].(*int64)) = 42
+	return nil
+}
+
+func TestSequences(t *testing.T) {
+	ctx := context.Background()
+	s := &sequenceConn{}
+	next, err := NextVal(ctx, s, "ids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := CurrVal(ctx, s, "ids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 42 || current != 42 {
+		t.Errorf("expected the scanned values got %d and %d", next, current)
+	}
+	if err := SetVal(ctx, s, "ids", 100, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"SELECT nextval($1::text::regclass)",
+		"SELECT currval($1::text::regclass)",
+		"SELECT setval($1::text::regclass, $2, $3)",
+	}
+	if diff := deep.Equal(s.statements, expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(s.args[2], []interface{}{"ids", int64(100), false}); diff != nil {
+		t.Error(diff)
+	}
+}
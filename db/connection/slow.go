@@ -0,0 +1,48 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/logging"
+)
+
+// SlowQueryLog logs, at Warn level, the statements that take longer than a threshold to run.
+// A nil *SlowQueryLog logs nothing so drivers can use it regardless of it being enabled.
+type SlowQueryLog struct {
+	threshold time.Duration
+	logger    logging.Logger
+}
+
+// NewSlowQueryLog returns a SlowQueryLog for threshold that logs to logger, it returns nil if
+// threshold is not positive or there is no logger.
+func NewSlowQueryLog(threshold time.Duration, logger logging.Logger) *SlowQueryLog {
+	if threshold <= 0 || logger == nil {
+		return nil
+	}
+	return &SlowQueryLog{threshold: threshold, logger: logger}
+}
+
+// Check logs statement, which ran with argCount arguments, if it took longer than the
+// threshold since start.
+func (s *SlowQueryLog) Check(statement string, argCount int, start time.Time) {
+	if s == nil {
+		return
+	}
+	if duration := time.Since(start); duration > s.threshold {
+		s.logger.Warn("slow query", "statement", statement, "args", argCount, "duration", duration)
+	}
+}
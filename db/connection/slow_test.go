@@ -0,0 +1,32 @@
+package connection
+
+import (
+	"testing"
+	"time"
+)
+
+type warnLogger struct {
+	warnings []string
+}
+
+func (w *warnLogger) Debug(msg string, ctx ...interface{}) {}
+func (w *warnLogger) Info(msg string, ctx ...interface{})  {}
+func (w *warnLogger) Warn(msg string, ctx ...interface{})  { w.warnings = append(w.warnings, msg) }
+func (w *warnLogger) Error(msg string, ctx ...interface{}) {}
+func (w *warnLogger) Crit(msg string, ctx ...interface{})  {}
+
+func TestSlowQueryLog(t *testing.T) {
+	logger := &warnLogger{}
+	slow := NewSlowQueryLog(time.Minute, logger)
+	slow.Check("SELECT 1", 0, time.Now())
+	slow.Check("SELECT pg_sleep(120)", 0, time.Now().Add(-2*time.Minute))
+	if len(logger.warnings) != 1 {
+		t.Errorf("expected only the slow query to be logged got %v", logger.warnings)
+	}
+
+	if NewSlowQueryLog(0, logger) != nil {
+		t.Errorf("expected no slow query log without threshold")
+	}
+	var disabled *SlowQueryLog
+	disabled.Check("SELECT 1", 0, time.Now().Add(-time.Hour))
+}
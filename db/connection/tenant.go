@@ -0,0 +1,224 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Tenant describes where the data of a tenant lives.
+type Tenant struct {
+	// Information, if not nil, is used to connect to the tenant (ie, if it has its own
+	// database or server) instead of the router defaults. Tenants with their own Information
+	// always get their own pool.
+	Information *Information
+	// Schema, if not empty, is set as the search_path of the tenant connections so unqualified
+	// tables are those of the tenant schema.
+	Schema string
+}
+
+// TenantResolver returns the Tenant for the passed tenant id.
+type TenantResolver func(ctx context.Context, tenantID string) (*Tenant, error)
+
+// TenantRouter hands the DB for a given tenant, opening it on first use.
+// As search_path is set for the whole session, each schema gets its own pool of connections,
+// tenants using the default Information and the same schema share it.
+type TenantRouter struct {
+	handler  DatabaseHandler
+	defaults *Information
+	resolve  TenantResolver
+
+	lock sync.Mutex
+	// pools holds the pools by key and tenants the key of the pool each tenant was handed.
+	pools   map[string]*tenantPool
+	tenants map[string]string
+}
+
+// tenantPool is a DB shared by the tenants that use the same key.
+type tenantPool struct {
+	// ready is closed once the DB was opened, or failed to, which is held in err.
+	ready chan struct{}
+	db    DB
+	err   error
+	// users is the amount of tenants that were handed the DB.
+	users int
+}
+
+// NewTenantRouter returns a TenantRouter that uses handler to open the tenant DBs, defaults
+// is the Information used for those tenants that don't have their own.
+func NewTenantRouter(handler DatabaseHandler, defaults *Information, resolve TenantResolver) *TenantRouter {
+	return &TenantRouter{
+		handler:  handler,
+		defaults: defaults,
+		resolve:  resolve,
+		pools:    map[string]*tenantPool{},
+		tenants:  map[string]string{},
+	}
+}
+
+// DB returns the DB for tenantID, the tenant is resolved only the first time, or after it was
+// forgotten. DBs are opened without blocking the other tenants.
+func (r *TenantRouter) DB(ctx context.Context, tenantID string) (DB, error) {
+	r.lock.Lock()
+	if key, ok := r.tenants[tenantID]; ok {
+		pool := r.pools[key]
+		r.lock.Unlock()
+		return pool.wait(ctx, tenantID)
+	}
+	r.lock.Unlock()
+
+	tenant, err := r.resolve(ctx, tenantID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving tenant %q", tenantID)
+	}
+	if tenant == nil {
+		return nil, errors.Errorf("tenant %q not found", tenantID)
+	}
+	key := tenantKey(tenantID, tenant)
+
+	r.lock.Lock()
+	if known, ok := r.tenants[tenantID]; ok {
+		// it was resolved concurrently
+		pool := r.pools[known]
+		r.lock.Unlock()
+		return pool.wait(ctx, tenantID)
+	}
+	pool, open := r.pools[key], false
+	if pool == nil {
+		pool, open = &tenantPool{ready: make(chan struct{})}, true
+		r.pools[key] = pool
+	}
+	pool.users++
+	r.tenants[tenantID] = key
+	r.lock.Unlock()
+
+	if open {
+		ci := tenant.Information
+		if ci == nil {
+			ci = r.defaults
+		}
+		pool.db, pool.err = r.handler.Open(ctx, tenantInformation(ci, tenant.Schema))
+		if pool.err != nil {
+			r.drop(key, pool)
+		}
+		close(pool.ready)
+	}
+	return pool.wait(ctx, tenantID)
+}
+
+// drop removes pool, that failed to open, and the tenants handed it so they are tried again.
+func (r *TenantRouter) drop(key string, pool *tenantPool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.pools[key] != pool {
+		return
+	}
+	delete(r.pools, key)
+	for tenantID, tenantKey := range r.tenants {
+		if tenantKey == key {
+			delete(r.tenants, tenantID)
+		}
+	}
+}
+
+// wait returns the DB of the pool once it is opened.
+func (p *tenantPool) wait(ctx context.Context, tenantID string) (DB, error) {
+	select {
+	case <-p.ready:
+	case <-ctx.Done():
+		return nil, errors.Wrapf(ctx.Err(), "waiting for the db of tenant %q", tenantID)
+	}
+	if p.err != nil {
+		return nil, errors.Wrapf(p.err, "opening db for tenant %q", tenantID)
+	}
+	return p.db, nil
+}
+
+// close closes the DB of the pool once it is opened.
+func (p *tenantPool) close() error {
+	<-p.ready
+	if p.err != nil {
+		return nil
+	}
+	return p.db.Close()
+}
+
+// Forget makes the next call to DB resolve tenantID again, useful when it was moved or
+// deleted. The DB it was handed is closed once no other tenant uses it, so DBs shared by the
+// tenants of a schema stay open while one of them is forgotten.
+func (r *TenantRouter) Forget(ctx context.Context, tenantID string) error {
+	r.lock.Lock()
+	key, ok := r.tenants[tenantID]
+	if !ok {
+		r.lock.Unlock()
+		return nil
+	}
+	delete(r.tenants, tenantID)
+	pool := r.pools[key]
+	pool.users--
+	if pool.users > 0 {
+		r.lock.Unlock()
+		return nil
+	}
+	delete(r.pools, key)
+	r.lock.Unlock()
+	return errors.Wrapf(pool.close(), "closing db of tenant %q", tenantID)
+}
+
+// Close closes all the open tenant DBs and returns the first error found.
+func (r *TenantRouter) Close() error {
+	r.lock.Lock()
+	pools := r.pools
+	r.pools = map[string]*tenantPool{}
+	r.tenants = map[string]string{}
+	r.lock.Unlock()
+	var err error
+	for _, pool := range pools {
+		if cErr := pool.close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// tenantKey returns the key of the pool used by tenant.
+func tenantKey(tenantID string, tenant *Tenant) string {
+	if tenant.Information != nil {
+		return "tenant:" + tenantID
+	}
+	return "schema:" + tenant.Schema
+}
+
+// tenantInformation returns a copy of ci that sets schema as search_path.
+func tenantInformation(ci *Information, schema string) *Information {
+	if schema == "" {
+		return ci
+	}
+	tenantCI := Information{}
+	if ci != nil {
+		tenantCI = *ci
+	}
+	tenantCI.RuntimeParams = make(map[string]string, len(tenantCI.RuntimeParams)+1)
+	if ci != nil {
+		for k, v := range ci.RuntimeParams {
+			tenantCI.RuntimeParams[k] = v
+		}
+	}
+	tenantCI.RuntimeParams["search_path"] = QuoteIdentifier(schema)
+	return &tenantCI
+}
@@ -0,0 +1,209 @@
+package connection
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type tenantConn struct {
+	DB
+	ci     *Information
+	closed bool
+}
+
+func (c *tenantConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+type tenantHandler struct {
+	lock   sync.Mutex
+	opened []*tenantConn
+	// blocked makes opening the DBs of the databases in it wait until it is closed.
+	blocked map[string]chan struct{}
+}
+
+func (h *tenantHandler) Open(ctx context.Context, ci *Information) (DB, error) {
+	if wait, ok := h.blocked[ci.Database]; ok {
+		<-wait
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	conn := &tenantConn{ci: ci}
+	h.opened = append(h.opened, conn)
+	return conn, nil
+}
+
+func TestTenantRouter(t *testing.T) {
+	defaults := &Information{Database: "shared", RuntimeParams: map[string]string{"application_name": "gaum"}}
+	own := &Information{Database: "big_tenant"}
+	tenants := map[string]*Tenant{
+		"1": {Schema: "tenant_1"},
+		"2": {Schema: "tenant_1"},
+		"3": {Schema: "tenant_3"},
+		"4": {Information: own},
+	}
+	h := &tenantHandler{}
+	r := NewTenantRouter(h, defaults, func(ctx context.Context, tenantID string) (*Tenant, error) {
+		if tenant, ok := tenants[tenantID]; ok {
+			return tenant, nil
+		}
+		return nil, errors.New("unknown tenant")
+	})
+	ctx := context.Background()
+
+	dbs := map[string]DB{}
+	for _, id := range []string{"1", "2", "3", "4", "1"} {
+		db, err := r.DB(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbs[id] = db
+	}
+	if len(h.opened) != 3 {
+		t.Fatalf("expected 3 DBs to be opened got %d", len(h.opened))
+	}
+	if dbs["1"] != dbs["2"] {
+		t.Errorf("expected tenants in the same schema to share the DB")
+	}
+	ci := dbs["3"].(*tenantConn).ci
+	if ci.Database != "shared" || ci.RuntimeParams["search_path"] != `"tenant_3"` ||
+		ci.RuntimeParams["application_name"] != "gaum" {
+		t.Errorf("unexpected information for a schema tenant %+v", ci)
+	}
+	if _, ok := defaults.RuntimeParams["search_path"]; ok {
+		t.Errorf("the default information must not be modified")
+	}
+	if dbs["4"].(*tenantConn).ci != own {
+		t.Errorf("expected the tenant own information to be used")
+	}
+
+	if _, err := r.DB(ctx, "5"); err == nil {
+		t.Errorf("expected an error for an unknown tenant")
+	}
+
+	if err := r.Forget(ctx, "3"); err != nil {
+		t.Fatal(err)
+	}
+	if !dbs["3"].(*tenantConn).closed {
+		t.Errorf("expected the forgotten tenant DB to be closed")
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range h.opened {
+		if !conn.closed {
+			t.Errorf("expected all DBs to be closed")
+		}
+	}
+}
+
+func TestTenantRouter_Forget(t *testing.T) {
+	tenants := map[string]*Tenant{
+		"1": {Schema: "tenant_1"},
+		"2": {Schema: "tenant_1"},
+		"3": {Schema: "tenant_3"},
+	}
+	h := &tenantHandler{}
+	r := NewTenantRouter(h, &Information{Database: "shared"}, func(ctx context.Context, tenantID string) (*Tenant, error) {
+		return tenants[tenantID], nil
+	})
+	ctx := context.Background()
+
+	dbs := map[string]DB{}
+	for _, id := range []string{"1", "2", "3"} {
+		db, err := r.DB(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbs[id] = db
+	}
+
+	// the schema is shared so it is closed only once both tenants are forgotten
+	if err := r.Forget(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if dbs["1"].(*tenantConn).closed {
+		t.Fatalf("expected the DB shared with another tenant to stay open")
+	}
+	if db, err := r.DB(ctx, "2"); err != nil || db != dbs["2"] {
+		t.Errorf("expected the remaining tenant to keep its DB got %v", err)
+	}
+	if err := r.Forget(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if dbs["1"].(*tenantConn).closed {
+		t.Fatalf("expected forgetting a tenant twice not to close the shared DB")
+	}
+	if err := r.Forget(ctx, "2"); err != nil {
+		t.Fatal(err)
+	}
+	if !dbs["2"].(*tenantConn).closed {
+		t.Errorf("expected the DB to be closed once all its tenants are forgotten")
+	}
+
+	// the tenant moved so the resolver no longer knows where it was
+	tenants["3"] = &Tenant{Schema: "tenant_3_moved"}
+	if err := r.Forget(ctx, "3"); err != nil {
+		t.Fatal(err)
+	}
+	if !dbs["3"].(*tenantConn).closed {
+		t.Errorf("expected the DB of the moved tenant to be closed")
+	}
+	db, err := r.DB(ctx, "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.(*tenantConn).ci.RuntimeParams["search_path"] != `"tenant_3_moved"` {
+		t.Errorf("expected the moved tenant to be resolved again got %+v", db.(*tenantConn).ci)
+	}
+
+	// a deleted tenant is forgotten too
+	delete(tenants, "3")
+	if err := r.Forget(ctx, "3"); err != nil {
+		t.Fatal(err)
+	}
+	if !db.(*tenantConn).closed {
+		t.Errorf("expected the DB of the deleted tenant to be closed")
+	}
+	if _, err := r.DB(ctx, "3"); err == nil {
+		t.Errorf("expected an error for a deleted tenant")
+	}
+}
+
+func TestTenantRouter_SlowOpen(t *testing.T) {
+	tenants := map[string]*Tenant{
+		"slow": {Information: &Information{Database: "slow"}},
+		"fast": {Schema: "fast"},
+	}
+	release := make(chan struct{})
+	h := &tenantHandler{blocked: map[string]chan struct{}{"slow": release}}
+	r := NewTenantRouter(h, &Information{Database: "shared"}, func(ctx context.Context, tenantID string) (*Tenant, error) {
+		return tenants[tenantID], nil
+	})
+	ctx := context.Background()
+
+	slow := make(chan DB, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			db, err := r.DB(ctx, "slow")
+			if err != nil {
+				t.Error(err)
+			}
+			slow <- db
+		}()
+	}
+	if _, err := r.DB(ctx, "fast"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if first, second := <-slow, <-slow; first == nil || first != second {
+		t.Errorf("expected the slow tenant to get the same DB")
+	}
+	if len(h.opened) != 2 {
+		t.Errorf("expected 2 DBs to be opened got %d", len(h.opened))
+	}
+}
@@ -0,0 +1,88 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/pkg/errors"
+)
+
+// TXFunc is the body of a transaction run by WithTransaction.
+type TXFunc func(tx DB) error
+
+// TransactionRetrier is implemented by the DBs that have a retry policy for transactions
+// configured (see Information.TransactionRetry).
+type TransactionRetrier interface {
+	TransactionRetry() *Backoff
+}
+
+// WithTransaction runs fn in a transaction, committing it if fn returns nil and rolling it back if
+// it returns an error or panics (the panic is then re-raised).
+// The transaction is obtained with BeginTransaction, so if db is already a transaction fn runs in it
+// and the outcome is left to whoever started it, fn returning an error marks a FlexibleTransaction
+// for rollback.
+// If db implements TransactionRetrier this behaves as WithTransactionRetry with its policy.
+func WithTransaction(ctx context.Context, db DB, fn TXFunc) error {
+	if retrier, ok := db.(TransactionRetrier); ok {
+		return WithTransactionRetry(ctx, db, retrier.TransactionRetry(), fn)
+	}
+	return withTransaction(ctx, db, fn)
+}
+
+// WithTransactionRetry is WithTransaction but if the transaction fails with an error that
+// errors.IsRetryable accepts (ie, a serialization failure or a deadlock) it is run again, from
+// the start, as retry describes. fn must then be safe to run more than once.
+// Retrying is not possible if db is already a transaction, as the error aborts the outer one, so
+// fn is run only once in that case.
+func WithTransactionRetry(ctx context.Context, db DB, retry *Backoff, fn TXFunc) error {
+	if db.IsTransaction() {
+		retry = nil
+	}
+	return retry.Retry(ctx, gaumErrors.IsRetryable, func() error {
+		return withTransaction(ctx, db, fn)
+	})
+}
+
+func withTransaction(ctx context.Context, db DB, fn TXFunc) error {
+	tx, finish, err := BeginTransaction(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.RollbackTransaction(ctx)
+			_, _, _ = finish(ctx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.RollbackTransaction(ctx); rollbackErr != nil {
+			return errors.Wrapf(err, "rolling back the transaction also failed: %v", rollbackErr)
+		}
+		if _, _, finishErr := finish(ctx); finishErr != nil {
+			return errors.Wrapf(err, "rolling back the transaction also failed: %v", finishErr)
+		}
+		return err
+	}
+
+	if _, _, err := finish(ctx); err != nil {
+		return errors.Wrap(err, "finishing transaction")
+	}
+	return nil
+}
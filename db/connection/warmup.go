@@ -0,0 +1,53 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection
+
+import (
+	"context"
+	"sync"
+)
+
+// WarmUp establishes n connections of a pool at the same time so they are ready before the
+// first queries need them (see Information.WarmUpConns). acquire takes a connection from the
+// pool, validating it if so configured, and returns the function that gives it back, none is
+// given back until all were acquired so each of them is a different connection.
+// The first error found is returned once all the acquired connections were given back.
+func WarmUp(ctx context.Context, n int, acquire func(context.Context) (release func(), err error)) error {
+	if n <= 0 {
+		return nil
+	}
+	releases := make([]func(), n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			releases[i], errs[i] = acquire(ctx)
+		}(i)
+	}
+	wg.Wait()
+	for _, release := range releases {
+		if release != nil {
+			release()
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package connection
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestWarmUp(t *testing.T) {
+	var mu sync.Mutex
+	held, maxHeld, acquired := 0, 0, 0
+	acquire := func(fail bool) func(context.Context) (func(), error) {
+		return func(context.Context) (func(), error) {
+			mu.Lock()
+			defer mu.Unlock()
+			acquired++
+			if fail && acquired == 2 {
+				return nil, errors.New("validation failed")
+			}
+			held++
+			if held > maxHeld {
+				maxHeld = held
+			}
+			return func() {
+				mu.Lock()
+				held--
+				mu.Unlock()
+			}, nil
+		}
+	}
+
+	if err := WarmUp(context.Background(), 5, acquire(false)); err != nil {
+		t.Fatal(err)
+	}
+	if maxHeld != 5 || held != 0 {
+		t.Errorf("expected 5 connections held at once and then released, got %d held at most and %d still held",
+			maxHeld, held)
+	}
+
+	held, maxHeld, acquired = 0, 0, 0
+	if err := WarmUp(context.Background(), 3, acquire(true)); err == nil {
+		t.Errorf("expected the failure to acquire a connection to be returned")
+	}
+	if held != 0 {
+		t.Errorf("expected the acquired connections to be released, %d are still held", held)
+	}
+}
@@ -0,0 +1,177 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection_testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/chain"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+)
+
+// NewBenchDB returns a DB for the test database to be used in benchmarks.
+type NewBenchDB func(b *testing.B) connection.DB
+
+// benchRows is how many rows the scanning benchmarks read.
+const benchRows = 10000
+
+// benchInsertRows is how many rows the insert benchmarks write each time.
+const benchInsertRows = 1000
+
+// benchRowsQuery yields benchRows rows without the need for a table.
+const benchRowsQuery = "SELECT i AS id, 'description ' || i AS description FROM generate_series(1, 10000) AS i"
+
+type benchRow struct {
+	ID          int `gaum:"field_name:id"`
+	Description string
+}
+
+// DobenchmarkConnector runs the same set of benchmarks for any driver so they can be compared
+// with each other and with previous versions of themselves.
+func DobenchmarkConnector(b *testing.B, newDB NewBenchDB) {
+	db := newDB(b)
+	defer db.Close()
+	ctx := context.TODO()
+
+	b.Run("SimpleSelect", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var rows []benchRow
+			err := chain.New(db).Select("id, description").Table(Table).AndWhere("id = ?", 1).Fetch(ctx, &rows)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("StructScan10k", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fetch, err := db.Query(ctx, benchRowsQuery, []string{"id", "description"})
+			if err != nil {
+				b.Fatal(err)
+			}
+			var rows []benchRow
+			if err := fetch(&rows); err != nil {
+				b.Fatal(err)
+			}
+			if len(rows) != benchRows {
+				b.Fatalf("expected %d rows got %d", benchRows, len(rows))
+			}
+		}
+	})
+
+	b.Run("QueryIter10k", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			iter, err := db.QueryIter(ctx, benchRowsQuery, []string{"id", "description"})
+			if err != nil {
+				b.Fatal(err)
+			}
+			var row benchRow
+			for next := true; next; {
+				var closer func()
+				next, closer, err = iter(&row)
+				if err != nil {
+					closer()
+					b.Fatal(err)
+				}
+				if !next {
+					closer()
+				}
+			}
+		}
+	})
+
+	// Query, QueryIter and Raw reading the same single row.
+	statement := "SELECT id, description FROM " + Table + " WHERE id = $1"
+	b.Run("Query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fetch, err := db.Query(ctx, statement, []string{"id", "description"}, 1)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var rows []benchRow
+			if err := fetch(&rows); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("QueryIter", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			iter, err := db.QueryIter(ctx, statement, []string{"id", "description"}, 1)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var row benchRow
+			_, closer, err := iter(&row)
+			closer()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Raw", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var row benchRow
+			if err := db.Raw(ctx, statement, []interface{}{1}, &row.ID, &row.Description); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	ids := make([]interface{}, benchInsertRows)
+	descriptions := make([]interface{}, benchInsertRows)
+	values := make([][]interface{}, benchInsertRows)
+	for i := range values {
+		ids[i] = 100000 + i
+		descriptions[i] = "bench"
+		values[i] = []interface{}{ids[i], descriptions[i]}
+	}
+	b.Run("InsertMulti", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			query, err := chain.New(db).InsertMulti(map[string][]interface{}{"id": ids, "description": descriptions})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := query.Table(Table).Exec(ctx); err != nil {
+				b.Fatal(err)
+			}
+			b.StopTimer()
+			Cleanup(b, db)
+			b.StartTimer()
+		}
+	})
+	b.Run("BulkInsert", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := db.BulkInsert(ctx, Table, []string{"id", "description"}, values); err != nil {
+				b.Fatal(err)
+			}
+			b.StopTimer()
+			Cleanup(b, db)
+			b.StartTimer()
+		}
+	})
+
+	b.Run("RenderOnly", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			query := chain.New(db).Select("j.id, j.description, o.description AS other").
+				Table(Table+" AS j").Join(Table+" AS o", "o.id = j.id + ?", 1).
+				AndWhere("j.id IN (?)", []int{1, 2, 3, 4, 5}).AndWhere("j.description <> ?", "nope").
+				OrderBy(chain.Asc("j.id")).Limit(10)
+			if _, _, err := query.Render(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -16,22 +16,33 @@ package connection_testing
 
 import (
 	"context"
+	"database/sql"
+	stdErrors "errors"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ShiftLeftSecurity/gaum/v2/db/chain"
 	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/logging"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+	"github.com/go-test/deep"
 	"github.com/jackc/pgconn"
 	"github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
 )
 
 // Cleanup deletes everything created for a test in the db
-func Cleanup(t *testing.T, db connection.DB) {
+func Cleanup(t testing.TB, db connection.DB) {
 	query := chain.New(db)
-	query.Delete().Table("justforfun").AndWhere("id > ?", 10)
+	query.Delete().Table(Table).AndWhere("id > ?", 10)
 	err := query.Exec(context.TODO())
 	if err != nil {
 		t.Logf("failed cleanup queries: %v", err)
@@ -103,12 +114,199 @@ func DotestconnectorExecresult(t *testing.T, newDB NewDB) {
 	testconnectorExecresult(t, newDB)
 }
 
+func DotestconnectorNestedTransaction(t *testing.T, newDB NewDB) {
+	testconnectorNestedTransaction(t, newDB)
+}
+
+func DotestconnectorFetchCancelledContext(t *testing.T, newDB NewDB) {
+	testconnectorFetchCancelledContext(t, newDB)
+}
+
+func DotestconnectorDeallocate(t *testing.T, newDB NewDB) {
+	testconnectorDeallocate(t, newDB)
+}
+
+func DotestconnectorSimpleProtocol(t *testing.T, newDB NewDB) {
+	testconnectorSimpleProtocol(t, newDB)
+}
+
+func DotestconnectorListenNotify(t *testing.T, newDB NewDB) {
+	testconnectorListenNotify(t, newDB)
+}
+
+func DotestconnectorBulkInsertReturning(t *testing.T, newDB NewDB) {
+	testconnectorBulkInsertReturning(t, newDB)
+}
+
+func DotestconnectorBulkUpsert(t *testing.T, newDB NewDB) {
+	testconnectorBulkUpsert(t, newDB)
+}
+
+func DotestconnectorBulkUpdate(t *testing.T, newDB NewDB) {
+	testconnectorBulkUpdate(t, newDB)
+}
+
+func DotestconnectorBulkInsertChunked(t *testing.T, newDB NewDB) {
+	testconnectorBulkInsertChunked(t, newDB)
+}
+
+func DotestconnectorQueryCursor(t *testing.T, newDB NewDB) {
+	testconnectorQueryCursor(t, newDB)
+}
+
+func DotestconnectorExecInfo(t *testing.T, newDB NewDB) {
+	testconnectorExecInfo(t, newDB)
+}
+
+func DotestconnectorGet(t *testing.T, newDB NewDB) {
+	testconnectorGet(t, newDB)
+}
+
+func DotestconnectorExecBatch(t *testing.T, newDB NewDB) {
+	testconnectorExecBatch(t, newDB)
+}
+
+func DotestconnectorTwoPhaseCommit(t *testing.T, newDB NewDB) {
+	testconnectorTwoPhaseCommit(t, newDB)
+}
+
+func DotestconnectorSetParam(t *testing.T, newDB NewDB) {
+	testconnectorSetParam(t, newDB)
+}
+
+// DotestconnectorRuntimeParams runs a test to verify Information.RuntimeParams are applied.
+func DotestconnectorRuntimeParams(t *testing.T, openDB OpenDB) {
+	testconnectorRuntimeParams(t, openDB)
+}
+
+// DotestconnectorPgBouncer runs a test to verify queries work in PgBouncer mode.
+func DotestconnectorPgBouncer(t *testing.T, openDB OpenDB) {
+	testconnectorPgBouncer(t, openDB)
+}
+
+// DotestconnectorCloseIdle runs a test to verify the DB can be used after closing idle
+// connections.
+func DotestconnectorCloseIdle(t *testing.T, newDB NewDB) {
+	testconnectorCloseIdle(t, newDB)
+}
+
+// DotestconnectorLeakDetection runs a test to verify abandoned iterators are reported.
+func DotestconnectorLeakDetection(t *testing.T, openDB OpenDB) {
+	testconnectorLeakDetection(t, openDB)
+}
+
+// DotestconnectorMetrics runs a test to verify queries are reported to the configured metrics.
+func DotestconnectorMetrics(t *testing.T, openDB OpenDB) {
+	testconnectorMetrics(t, openDB)
+}
+
+// DotestconnectorSetLogLevel tests changing the log level of an open DB.
+func DotestconnectorSetLogLevel(t *testing.T, openDB OpenDB) {
+	testconnectorSetLogLevel(t, openDB)
+}
+
+// DotestconnectorConstraintViolation tests that constraint violations are recognized.
+func DotestconnectorConstraintViolation(t *testing.T, newDB NewDB) {
+	testconnectorConstraintViolation(t, newDB)
+}
+
+// DotestconnectorNoRows tests that all the single row queries report no rows the same way.
+func DotestconnectorNoRows(t *testing.T, newDB NewDB) {
+	testconnectorNoRows(t, newDB)
+}
+
+// DotestconnectorQueryNoRowsError tests that Query reports no rows as ErrNoRows when asked to.
+func DotestconnectorQueryNoRowsError(t *testing.T, openDB OpenDB) {
+	testconnectorQueryNoRowsError(t, openDB)
+}
+
+// DotestconnectorAttachQueryToErrors tests that failed statements carry their query.
+func DotestconnectorAttachQueryToErrors(t *testing.T, openDB OpenDB) {
+	testconnectorAttachQueryToErrors(t, openDB)
+}
+
+// DotestconnectorExpandStars tests that table.* select items are expanded with the columns of
+// the table.
+func DotestconnectorExpandStars(t *testing.T, newDB NewDB) {
+	testconnectorExpandStars(t, newDB)
+}
+
+// DotestconnectorLoadFixtures tests loading rows from YAML and JSON fixture files.
+func DotestconnectorLoadFixtures(t *testing.T, newDB NewDB) {
+	testconnectorLoadFixtures(t, newDB)
+}
+
+// DotestconnectorSeed tests inserting generated rows.
+func DotestconnectorSeed(t *testing.T, newDB NewDB) {
+	testconnectorSeed(t, newDB)
+}
+
+// DotestconnectorVerify tests comparing structs with the schema of their table.
+func DotestconnectorVerify(t *testing.T, newDB NewDB) {
+	testconnectorVerify(t, newDB)
+}
+
+// DotestconnectorDDL tests the statements built by the DDL builders.
+func DotestconnectorDDL(t *testing.T, newDB NewDB) {
+	testconnectorDDL(t, newDB)
+}
+
+// DotestconnectorViews tests creating views from chains and refreshing materialized ones.
+func DotestconnectorViews(t *testing.T, newDB NewDB) {
+	testconnectorViews(t, newDB)
+}
+
+// DotestconnectorPartitions tests creating, attaching and detaching partitions.
+func DotestconnectorPartitions(t *testing.T, newDB NewDB) {
+	testconnectorPartitions(t, newDB)
+}
+
+// DotestconnectorSequences tests NextVal, CurrVal, SetVal and chain.NextVal.
+func DotestconnectorSequences(t *testing.T, newDB NewDB) {
+	testconnectorSequences(t, newDB)
+}
+
+// DotestconnectorIntoTemp tests creating a temporary table from a chain and querying it.
+func DotestconnectorIntoTemp(t *testing.T, newDB NewDB) {
+	testconnectorIntoTemp(t, newDB)
+}
+
+// DotestconnectorCreateTableLike tests cloning a table, with and without its rows.
+func DotestconnectorCreateTableLike(t *testing.T, newDB NewDB) {
+	testconnectorCreateTableLike(t, newDB)
+}
+
+// DotestconnectorConstraints tests adding, validating and dropping table constraints.
+func DotestconnectorConstraints(t *testing.T, newDB NewDB) {
+	testconnectorConstraints(t, newDB)
+}
+
+// DotestconnectorPreload tests loading the relations of registered models.
+func DotestconnectorPreload(t *testing.T, newDB NewDB) {
+	testconnectorPreload(t, newDB)
+}
+
+// DotestconnectorParallelDecoding tests that rows decoded in parallel are the same, in the same
+// order, as those decoded as usual.
+func DotestconnectorParallelDecoding(t *testing.T, newDB NewDB) {
+	testconnectorParallelDecoding(t, newDB)
+}
+
+// DotestconnectorScannable tests that receivers implementing srm.Scannable scan themselves.
+func DotestconnectorScannable(t *testing.T, newDB NewDB) {
+	testconnectorScannable(t, newDB)
+}
+
 type NewDB func(t *testing.T) connection.DB
 
+// OpenDB returns a DB for the test database opened with the passed information, to which the
+// driver can add what it needs to connect.
+type OpenDB func(t *testing.T, ci *connection.Information) connection.DB
+
 func testconnectorQueryiter(t *testing.T, newDB NewDB) {
 	db := newDB(t)
 	query := chain.New(db)
-	query.Select("id, description").Table("justforfun").AndWhere("id = ?", 1)
+	query.Select("id, description").Table(Table).AndWhere("id = ?", 1)
 
 	// Debug print query
 	q, args, err := query.Render()
@@ -149,7 +347,7 @@ func testconnectorQueryiter(t *testing.T, newDB NewDB) {
 
 	// Test Multiple row Iterator
 	query = chain.New(db)
-	query.Select("id, description").Table("justforfun").OrderBy(chain.Asc("id"))
+	query.Select("id, description").Table(Table).OrderBy(chain.Asc("id"))
 	iter, err = query.QueryIter(context.TODO())
 	if err != nil {
 		t.Errorf("failed to query: %v", err)
@@ -215,7 +413,7 @@ func testconnectorQueryreflection(t *testing.T, newDB NewDB) {
 
 	// Test Multiple row Iterator
 	query := chain.New(db)
-	query.Select("*").Table("justforfun").OrderBy(chain.Asc("id"))
+	query.Select("*").Table(Table).OrderBy(chain.Asc("id"))
 	fetcher, err := query.Query(context.TODO())
 	if err != nil {
 		t.Errorf("failed to query: %v", err)
@@ -310,7 +508,7 @@ func testconnectorQuery(t *testing.T, newDB NewDB) {
 
 	// Test Multiple row Iterator
 	query := chain.New(db)
-	query.Select("id, description").Table("justforfun").OrderBy(chain.Asc("id"))
+	query.Select("id, description").Table(Table).OrderBy(chain.Asc("id"))
 	fetcher, err := query.Query(context.TODO())
 	if err != nil {
 		t.Errorf("failed to query: %v", err)
@@ -402,7 +600,7 @@ func testconnectorQuerystar(t *testing.T, newDB NewDB) {
 
 	// Test Multiple row Iterator
 	query := chain.New(db)
-	query.Select("*").Table("justforfun").OrderBy(chain.Asc("id"))
+	query.Select("*").Table(Table).OrderBy(chain.Asc("id"))
 	fetcher, err := query.Query(context.TODO())
 	if err != nil {
 		t.Errorf("failed to query: %v", err)
@@ -459,7 +657,7 @@ func testconnectorQueryreturningwitherror(t *testing.T, newDB NewDB) {
 		"id":          1,
 		"description": "this id already exists",
 	}).
-		Table("justforfun").
+		Table(Table).
 		Returning("*")
 
 	fetcher, err := query.Query(context.TODO())
@@ -507,7 +705,7 @@ func testconnectorQuerynorows(t *testing.T, newDB NewDB) {
 	}
 
 	query := chain.New(db)
-	query.Select("*").AndWhere("id = ?", 99999999).Table("justforfun")
+	query.Select("*").AndWhere("id = ?", 99999999).Table(Table)
 
 	fetcher, err := query.Query(context.TODO())
 	if err != nil {
@@ -532,8 +730,8 @@ func testconnectorDistinct(t *testing.T, newDB NewDB) {
 
 	// Test Multiple row Iterator
 	query := chain.New(db)
-	prefix := chain.TablePrefix("justforfun")
-	query.Select(chain.Distinct(prefix("id")), prefix("description")).Table("justforfun").OrderBy(chain.Asc("id"))
+	prefix := chain.TablePrefix(Table)
+	query.Select(chain.Distinct(prefix("id")), prefix("description")).Table(Table).OrderBy(chain.Asc("id"))
 	fetcher, err := query.Query(context.TODO())
 	if err != nil {
 		t.Errorf("failed to query: %v", err)
@@ -569,8 +767,8 @@ func testconnectorDistinctas(t *testing.T, newDB NewDB) {
 
 	// Test Multiple row Iterator
 	query := chain.New(db)
-	prefix := chain.TablePrefix("justforfun")
-	query.Select(chain.As(chain.Distinct(prefix("id")), "renamed"), prefix("description")).Table("justforfun").OrderBy(chain.Asc("id"))
+	prefix := chain.TablePrefix(Table)
+	query.Select(chain.As(chain.Distinct(prefix("id")), "renamed"), prefix("description")).Table(Table).OrderBy(chain.Asc("id"))
 	fetcher, err := query.Query(context.TODO())
 	if err != nil {
 		t.Errorf("failed to query: %v", err)
@@ -605,7 +803,7 @@ func testconnectorRaw(t *testing.T, newDB NewDB) {
 	aRow := row{}
 	// Test Multiple row Iterator
 	query := chain.New(db)
-	query.Select("id, description").Table("justforfun").AndWhere("id = ?", 1)
+	query.Select("id, description").Table(Table).AndWhere("id = ?", 1)
 	err := query.Raw(context.TODO(), &aRow.Id, &aRow.Description)
 	if err != nil {
 		t.Errorf("failed to query: %v", err)
@@ -641,7 +839,7 @@ func testconnectorInsert(t *testing.T, newDB NewDB) {
 	query := chain.New(db)
 	tempDescriptionUUID := uuid.NewV4()
 	tempDescription := tempDescriptionUUID.String()
-	query.Select("id, description").Table("justforfun").AndWhere("description = ?", tempDescription)
+	query.Select("id, description").Table(Table).AndWhere("description = ?", tempDescription)
 	err := query.Raw(context.TODO(), &aRow.Id, &aRow.Description)
 	if err == nil {
 		t.Log("querying for our description should fail, this record should not exist")
@@ -652,7 +850,7 @@ func testconnectorInsert(t *testing.T, newDB NewDB) {
 
 	insertQuery := chain.New(db)
 	insertQuery.Insert(map[string]interface{}{"id": tempID, "description": tempDescription}).
-		Table("justforfun")
+		Table(Table)
 	err = insertQuery.Exec(context.TODO())
 	if err != nil {
 		t.Logf("failed to insert: %v", err)
@@ -688,14 +886,14 @@ func testconnectorMultiinsert(t *testing.T, newDB NewDB) {
 	query1 := query.Clone()
 	tempDescription := uuid.NewV4().String()
 	tempDescription1 := uuid.NewV4().String()
-	query.Select("id, description").Table("justforfun").AndWhere("description = ?", tempDescription)
+	query.Select("id, description").Table(Table).AndWhere("description = ?", tempDescription)
 	err := query.Raw(context.TODO(), &aRow.Id, &aRow.Description)
 	if err == nil {
 		t.Log("querying for our description should fail, this record should not exist")
 		t.FailNow()
 	}
 
-	query1.Select("id, description").Table("justforfun").AndWhere("description = ?", tempDescription1)
+	query1.Select("id, description").Table(Table).AndWhere("description = ?", tempDescription1)
 	err = query1.Raw(context.TODO(), &aRow.Id, &aRow.Description)
 	if err == nil {
 		t.Log("querying for our second description should fail, this record should not exist")
@@ -711,7 +909,7 @@ func testconnectorMultiinsert(t *testing.T, newDB NewDB) {
 		"description": {tempDescription, tempDescription1},
 		"id":          {tempID, tempID1},
 	})
-	insertQuery.Table("justforfun")
+	insertQuery.Table(Table)
 	err = insertQuery.Exec(context.TODO())
 	if err != nil {
 		t.Logf("failed to insert: %v", err)
@@ -759,7 +957,7 @@ func testconnectorInsertconstraint(t *testing.T, newDB NewDB) {
 	query := chain.New(db)
 	tempDescriptionUUID := uuid.NewV4()
 	tempDescription := tempDescriptionUUID.String()
-	query.Select("id, description").Table("justforfun").AndWhere("description = ?", tempDescription)
+	query.Select("id, description").Table(Table).AndWhere("description = ?", tempDescription)
 	err := query.Raw(context.TODO(), &aRow.Id, &aRow.Description)
 	if err == nil {
 		t.Log("querying for our description should fail, this record should not exist")
@@ -771,7 +969,7 @@ func testconnectorInsertconstraint(t *testing.T, newDB NewDB) {
 	// First insert, this is to have a colliding value
 	insertQuery := chain.New(db)
 	insertQuery.Insert(map[string]interface{}{"id": tempID, "description": tempDescription}).
-		Table("justforfun")
+		Table(Table)
 	err = insertQuery.Exec(context.TODO())
 	if err != nil {
 		t.Logf("failed to insert to test constraint: %v", err)
@@ -794,7 +992,7 @@ func testconnectorInsertconstraint(t *testing.T, newDB NewDB) {
 
 	// Second attempt at inserting, this should fail
 	insertQuery.Insert(map[string]interface{}{"id": tempID, "description": tempDescription}).
-		Table("justforfun")
+		Table(Table)
 	queryString, queryArgs, _ := insertQuery.Render()
 	t.Logf("conflicting insert query: %s", queryString)
 	t.Logf("conflicting insert args: %v", queryArgs)
@@ -806,10 +1004,10 @@ func testconnectorInsertconstraint(t *testing.T, newDB NewDB) {
 
 	// Third attempt, this should work
 	insertQuery.OnConflict(func(c *chain.OnConflict) {
-		c.OnConstraint("therecanbeonlyone").DoNothing()
+		c.OnConstraint(Constraint).DoNothing()
 	})
 	insertQuery.Insert(map[string]interface{}{"id": tempID, "description": tempDescription}).
-		Table("justforfun")
+		Table(Table)
 	queryString, queryArgs, _ = insertQuery.Render()
 	t.Logf("conflicting insert query: %s", queryString)
 	t.Logf("conflicting insert args: %v", queryArgs)
@@ -831,7 +1029,7 @@ func testconnectorTransaction(t *testing.T, newDB NewDB) {
 	query := chain.New(db)
 	tempDescriptionUUID := uuid.NewV4()
 	tempDescription := tempDescriptionUUID.String()
-	query.Select("id, description").Table("justforfun").AndWhere("description = ?", tempDescription)
+	query.Select("id, description").Table(Table).AndWhere("description = ?", tempDescription)
 	err := query.Raw(context.TODO(), &aRow.Id, &aRow.Description)
 	if err == nil {
 		t.Log("querying for our description should fail, this record should not exist")
@@ -848,7 +1046,7 @@ func testconnectorTransaction(t *testing.T, newDB NewDB) {
 	// Let's try this with transactions
 	insertQuery := chain.New(transactionalDB)
 	insertQuery.Insert(map[string]interface{}{"id": tempID, "description": tempDescription}).
-		Table("justforfun")
+		Table(Table)
 	err = insertQuery.Exec(context.TODO())
 	if err != nil {
 		t.Logf("an insert in a transaction was attempted but failed: %v", err)
@@ -926,7 +1124,7 @@ func testconnectorQueryprimitives(t *testing.T, newDB NewDB) {
 
 	// Test Multiple row Iterator
 	query := chain.New(db)
-	query.Select("id").Table("justforfun").OrderBy(chain.Asc("id"))
+	query.Select("id").Table(Table).OrderBy(chain.Asc("id"))
 	fetcher, err := query.QueryPrimitive(context.TODO())
 	if err != nil {
 		t.Errorf("failed to query: %v", err)
@@ -974,7 +1172,7 @@ func testconnectorRegressionReturning(t *testing.T, newDB NewDB) {
 		"id":          11,
 		"description": "this should be in the db",
 	}).
-		Table("justforfun").Exec(context.TODO())
+		Table(Table).Exec(context.TODO())
 	if err != nil {
 		t.Errorf("failed to query: %v", err)
 	}
@@ -983,9 +1181,9 @@ func testconnectorRegressionReturning(t *testing.T, newDB NewDB) {
 		"id":          11,
 		"description": "this should be the updated value",
 	}).
-		Table("justforfun").
+		Table(Table).
 		OnConflict(func(c *chain.OnConflict) {
-			c.OnConstraint("therecanbeonlyone").DoUpdate().Set("description",
+			c.OnConstraint(Constraint).DoUpdate().Set("description",
 				"this should be the updated value")
 		}).
 		Returning("id, description")
@@ -1022,7 +1220,7 @@ func testconnectorExecresult(t *testing.T, newDB NewDB) {
 			"id":          {tempID1, tempID2, tempID3},
 			"description": {initialDesc1, initialDesc2And3, initialDesc2And3},
 		})
-	insertQuery.Table("justforfun")
+	insertQuery.Table(Table)
 	if err != nil {
 		t.Logf("failed to generate insertQuery: %v", err)
 		t.FailNow()
@@ -1043,7 +1241,7 @@ func testconnectorExecresult(t *testing.T, newDB NewDB) {
 	// First test 0 rows affected.
 	updateQuery := chain.New(db)
 	updateQuery.UpdateMap(map[string]interface{}{"description": newDesc1}).
-		Table("justforfun").
+		Table(Table).
 		AndWhere("id = ?", tempID1).
 		AndWhere("description = ?", "expect that this description does not exist")
 	rowsAffected, err = updateQuery.ExecResult(context.TODO())
@@ -1059,7 +1257,7 @@ func testconnectorExecresult(t *testing.T, newDB NewDB) {
 	// test 1 rows affected.
 	updateQuery = chain.New(db)
 	updateQuery.UpdateMap(map[string]interface{}{"id": tempID1, "description": newDesc1}).
-		Table("justforfun").
+		Table(Table).
 		AndWhere("id = ?", tempID1).
 		AndWhere("description = ?", initialDesc1)
 	rowsAffected, err = updateQuery.ExecResult(context.TODO())
@@ -1076,7 +1274,7 @@ func testconnectorExecresult(t *testing.T, newDB NewDB) {
 	updateQuery = chain.New(db)
 	updateQuery = chain.New(db)
 	updateQuery.UpdateMap(map[string]interface{}{"description": newDesc2And3}).
-		Table("justforfun").
+		Table(Table).
 		AndWhere("id = ? OR id = ?", tempID2, tempID3).
 		AndWhere("description = ?", initialDesc2And3)
 	rowsAffected, err = updateQuery.ExecResult(context.TODO())
@@ -1110,3 +1308,1782 @@ func testconnectorExecresult(t *testing.T, newDB NewDB) {
 		t.FailNow()
 	}
 }
+
+func testconnectorNestedTransaction(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	rand.Seed(time.Now().UnixNano())
+	outerID := rand.Intn(11000) + 11
+	rolledID := outerID + 1
+	releasedID := outerID + 2
+	insert := func(tx connection.DB, id int) {
+		err := chain.New(tx).Insert(map[string]interface{}{"id": id, "description": "nested"}).
+			Table(Table).Exec(ctx)
+		if err != nil {
+			t.Logf("inserting %d: %v", id, err)
+			t.FailNow()
+		}
+	}
+
+	tx, err := db.BeginTransaction(ctx)
+	if err != nil {
+		t.Logf("attempting to begin a transaction: %v", err)
+		t.FailNow()
+	}
+	insert(tx, outerID)
+
+	rolled, err := tx.BeginTransaction(ctx)
+	if err != nil {
+		t.Logf("attempting to begin a nested transaction: %v", err)
+		t.FailNow()
+	}
+	insert(rolled, rolledID)
+	if err := rolled.RollbackTransaction(ctx); err != nil {
+		t.Logf("attempting to rollback a nested transaction: %v", err)
+		t.FailNow()
+	}
+
+	released, err := tx.BeginTransaction(ctx)
+	if err != nil {
+		t.Logf("attempting to begin a nested transaction: %v", err)
+		t.FailNow()
+	}
+	insert(released, releasedID)
+	if err := released.CommitTransaction(ctx); err != nil {
+		t.Logf("attempting to commit a nested transaction: %v", err)
+		t.FailNow()
+	}
+
+	if err := tx.CommitTransaction(ctx); err != nil {
+		t.Logf("attempting to commit a transaction: %v", err)
+		t.FailNow()
+	}
+
+	var ids []int
+	query := chain.New(db)
+	query.Select("id").Table(Table).
+		AndWhere(chain.In("id", outerID, rolledID, releasedID)).
+		OrderBy(chain.Asc("id"))
+	if err := query.FetchIntoPrimitive(ctx, &ids); err != nil {
+		t.Logf("fetching inserted ids: %v", err)
+		t.FailNow()
+	}
+	if diff := deep.Equal(ids, []int{outerID, releasedID}); diff != nil {
+		t.Logf("only the rows from the outer and committed nested transaction should exist: %v", diff)
+		t.FailNow()
+	}
+}
+
+func testconnectorFetchCancelledContext(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	type row struct {
+		Id          int
+		Description string
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	query := chain.New(db)
+	query.Select("id, description").Table(Table).OrderBy(chain.Asc("id"))
+	fetcher, err := query.Query(ctx)
+	if err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	cancel()
+	var rows []row
+	if err := fetcher(&rows); err == nil {
+		t.Log("fetching with a cancelled context should fail")
+		t.FailNow()
+	}
+
+	ctx, cancel = context.WithCancel(context.TODO())
+	iterQuery := chain.New(db)
+	iterQuery.Select("id, description").Table(Table).OrderBy(chain.Asc("id"))
+	iterFetcher, err := iterQuery.QueryIter(ctx)
+	if err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	cancel()
+	aRow := row{}
+	_, closer, err := iterFetcher(&aRow)
+	closer()
+	if err == nil {
+		t.Log("iterating with a cancelled context should fail")
+		t.FailNow()
+	}
+}
+
+func testconnectorDeallocate(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	query := func() {
+		var ids []int
+		q := chain.New(db)
+		q.Select("id").Table(Table).AndWhere("id < ?", 5)
+		if err := q.FetchIntoPrimitive(ctx, &ids); err != nil {
+			t.Logf("failed to query: %v", err)
+			t.FailNow()
+		}
+		if len(ids) != 4 {
+			t.Logf("expected 4 results got %d", len(ids))
+			t.FailNow()
+		}
+	}
+
+	query()
+	if err := db.Deallocate(ctx, "not_prepared"); err != nil && err != gaumErrors.NotImplemented {
+		t.Logf("deallocating a statement that does not exist: %v", err)
+		t.FailNow()
+	}
+	if err := db.DeallocateAll(ctx); err != nil {
+		t.Logf("deallocating all statements: %v", err)
+		t.FailNow()
+	}
+	// the cache must have been cleared too or this will fail.
+	query()
+}
+
+func testconnectorSimpleProtocol(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := connection.WithSimpleProtocol(context.TODO())
+
+	var ids []int
+	q := chain.New(db)
+	q.Select("id").Table(Table).AndWhere("id < ?", 5)
+	if err := q.FetchIntoPrimitive(ctx, &ids); err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if len(ids) != 4 {
+		t.Logf("expected 4 results got %d", len(ids))
+		t.FailNow()
+	}
+
+	var description string
+	err := db.Raw(ctx, "SELECT description FROM "+Table+" WHERE id = $1", []interface{}{1}, &description)
+	if err != nil {
+		t.Logf("failed to run raw query: %v", err)
+		t.FailNow()
+	}
+	if description != "first" {
+		t.Logf("expected description to be \"first\" got %q", description)
+		t.FailNow()
+	}
+
+	affected, err := db.ExecResult(ctx, "UPDATE "+Table+" SET description = $1 WHERE id = $2", "first", 1)
+	if err != nil {
+		t.Logf("failed to exec: %v", err)
+		t.FailNow()
+	}
+	if affected != 1 {
+		t.Logf("expected 1 row affected got %d", affected)
+		t.FailNow()
+	}
+}
+
+func testconnectorListenNotify(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	notifications, err := db.Listen(ctx, "gaum test")
+	if err != nil {
+		t.Logf("failed to listen: %v", err)
+		t.FailNow()
+	}
+	if err := db.Notify(ctx, "gaum test", "a payload"); err != nil {
+		t.Logf("failed to notify: %v", err)
+		t.FailNow()
+	}
+
+	// notifications sent in a transaction are only delivered once it commits.
+	tx, err := db.BeginTransaction(ctx)
+	if err != nil {
+		t.Logf("failed to begin transaction: %v", err)
+		t.FailNow()
+	}
+	if err := tx.Notify(ctx, "gaum test", "another payload"); err != nil {
+		t.Logf("failed to notify in transaction: %v", err)
+		t.FailNow()
+	}
+	if err := tx.CommitTransaction(ctx); err != nil {
+		t.Logf("failed to commit transaction: %v", err)
+		t.FailNow()
+	}
+
+	for _, payload := range []string{"a payload", "another payload"} {
+		select {
+		case n := <-notifications:
+			if n.Channel != "gaum test" || n.Payload != payload {
+				t.Logf("expected %q in \"gaum test\" got %q in %q", payload, n.Payload, n.Channel)
+				t.FailNow()
+			}
+		case <-ctx.Done():
+			t.Logf("timed out waiting for notification %q", payload)
+			t.FailNow()
+		}
+	}
+
+	cancel()
+	// the channel must be closed once ctx is done.
+	for range notifications {
+	}
+}
+
+func testconnectorBulkInsertReturning(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	type row struct {
+		Id          int
+		Description string
+	}
+	// the first is inserted with a single statement and the second through a temporary table.
+	for _, amount := range []int{3, 1500} {
+		firstID := 20000 + amount*10
+		values := make([][]interface{}, amount)
+		for i := range values {
+			values[i] = []interface{}{firstID + i, fmt.Sprintf("bulk %d", firstID+i)}
+		}
+		var inserted []row
+		err := db.BulkInsertReturning(context.TODO(), Table, []string{"id", "description"}, values,
+			[]string{"id", "description"}, &inserted)
+		if err != nil {
+			t.Logf("failed to bulk insert %d rows: %v", amount, err)
+			t.FailNow()
+		}
+		if len(inserted) != amount {
+			t.Logf("expected %d rows returned got %d", amount, len(inserted))
+			t.FailNow()
+		}
+		for _, r := range inserted {
+			if r.Id < firstID || r.Id >= firstID+amount || r.Description != fmt.Sprintf("bulk %d", r.Id) {
+				t.Logf("unexpected row returned %#v", r)
+				t.FailNow()
+			}
+		}
+	}
+}
+
+func testconnectorBulkUpsert(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	values := [][]interface{}{{30001, "upserted 1"}, {30002, "upserted 2"}}
+	err := db.Exec(ctx, "INSERT INTO "+Table+" (id, description) VALUES ($1, $2)", 30001, "original")
+	if err != nil {
+		t.Logf("failed to insert the row to conflict with: %v", err)
+		t.FailNow()
+	}
+
+	err = db.BulkUpsert(ctx, Table, []string{"id", "description"}, []string{"id"},
+		[]string{"description"}, values)
+	if err != nil {
+		t.Logf("failed to bulk upsert: %v", err)
+		t.FailNow()
+	}
+
+	var descriptions []string
+	q := chain.New(db)
+	q.Select("description").Table(Table).AndWhere("id IN (?)", []int{30001, 30002}).
+		OrderBy(chain.Asc("id"))
+	if err := q.FetchIntoPrimitive(ctx, &descriptions); err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if diff := deep.Equal(descriptions, []string{"upserted 1", "upserted 2"}); diff != nil {
+		t.Logf("unexpected rows after upsert: %v", diff)
+		t.FailNow()
+	}
+}
+
+func testconnectorBulkUpdate(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	// the first is updated with a single statement and the second through a temporary table.
+	for _, amount := range []int{3, 1500} {
+		firstID := 40000 + amount*10
+		values := make([][]interface{}, amount)
+		updates := make([][]interface{}, amount)
+		for i := range values {
+			values[i] = []interface{}{firstID + i, "original"}
+			updates[i] = []interface{}{firstID + i, fmt.Sprintf("updated %d", firstID+i)}
+		}
+		var ids []int
+		err := db.BulkInsertReturning(ctx, Table, []string{"id", "description"}, values,
+			[]string{"id"}, &ids)
+		if err != nil {
+			t.Logf("failed to insert the rows to update: %v", err)
+			t.FailNow()
+		}
+
+		err = db.BulkUpdate(ctx, Table, []string{"id"}, []string{"description"}, updates)
+		if err != nil {
+			t.Logf("failed to bulk update %d rows: %v", amount, err)
+			t.FailNow()
+		}
+
+		var updated []int
+		q := chain.New(db)
+		q.Select("id").Table(Table).
+			AndWhere("id >= ? AND id < ?", firstID, firstID+amount).
+			AndWhere("description = 'updated ' || id")
+		if err := q.FetchIntoPrimitive(ctx, &updated); err != nil {
+			t.Logf("failed to query: %v", err)
+			t.FailNow()
+		}
+		if len(updated) != amount {
+			t.Logf("expected %d rows updated got %d", amount, len(updated))
+			t.FailNow()
+		}
+	}
+}
+
+func testconnectorBulkInsertChunked(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	values := make([][]interface{}, 25)
+	for i := range values {
+		values[i] = []interface{}{50000 + i, "chunked"}
+	}
+	// the second chunk will fail as it repeats the first id.
+	values[15][0] = 50000
+
+	err := db.BulkInsertChunked(ctx, Table, []string{"id", "description"},
+		connection.BulkSourceFromRows(values),
+		connection.BulkInsertOptions{ChunkSize: 10, ContinueOnError: true})
+	bulkErr, ok := err.(*connection.BulkInsertError)
+	if !ok {
+		t.Logf("expected a *connection.BulkInsertError got %v", err)
+		t.FailNow()
+	}
+	if bulkErr.Inserted != 15 || len(bulkErr.Failed) != 1 || bulkErr.Failed[0].Start != 10 {
+		t.Logf("expected only the second chunk to fail got %v", bulkErr)
+		t.FailNow()
+	}
+
+	var ids []int
+	q := chain.New(db)
+	q.Select("id").Table(Table).AndWhere("description = ?", "chunked")
+	if err := q.FetchIntoPrimitive(ctx, &ids); err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if len(ids) != 15 {
+		t.Logf("expected 15 rows inserted got %d", len(ids))
+		t.FailNow()
+	}
+}
+
+func testconnectorQueryCursor(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	type row struct {
+		Id          int
+		Description string
+	}
+	readAll := func(db connection.DB) []int {
+		q := chain.New(db)
+		q.Select("id, description").Table(Table).AndWhere("id < ?", 10).OrderBy(chain.Asc("id"))
+		// 9 rows in batches of 4 so the last batch is partial.
+		iter, err := q.QueryCursor(ctx, 4)
+		if err != nil {
+			t.Logf("failed to query with cursor: %v", err)
+			t.FailNow()
+		}
+		var ids []int
+		for {
+			var r row
+			next, closer, err := iter(&r)
+			if err != nil {
+				t.Logf("failed to iterate cursor: %v", err)
+				t.FailNow()
+			}
+			ids = append(ids, r.Id)
+			if !next {
+				closer()
+				break
+			}
+		}
+		return ids
+	}
+
+	expected := []int{1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if diff := deep.Equal(readAll(db), expected); diff != nil {
+		t.Logf("unexpected rows read with cursor: %v", diff)
+		t.FailNow()
+	}
+
+	// in a transaction the cursor uses it and leaves it open.
+	tx, err := db.BeginTransaction(ctx)
+	if err != nil {
+		t.Logf("failed to begin transaction: %v", err)
+		t.FailNow()
+	}
+	if diff := deep.Equal(readAll(tx), expected); diff != nil {
+		t.Logf("unexpected rows read with cursor in transaction: %v", diff)
+		t.FailNow()
+	}
+	if err := tx.CommitTransaction(ctx); err != nil {
+		t.Logf("failed to commit transaction: %v", err)
+		t.FailNow()
+	}
+}
+
+func testconnectorExecInfo(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+
+	insert := chain.New(db)
+	insert.Insert(map[string]interface{}{"id": 60000, "description": "exec info"}).Table(Table)
+	tag, err := insert.ExecInfo(ctx)
+	if err != nil {
+		t.Logf("failed to insert: %v", err)
+		t.FailNow()
+	}
+	expected := connection.CommandTag{Tag: "INSERT 0 1", Operation: "INSERT", RowsAffected: 1}
+	if diff := deep.Equal(tag, expected); diff != nil {
+		t.Logf("unexpected command tag for insert: %v", diff)
+		t.FailNow()
+	}
+
+	tag, err = db.ExecInfo(ctx, "UPDATE "+Table+" SET description = $1 WHERE id = $2", "exec info", 60001)
+	if err != nil {
+		t.Logf("failed to update: %v", err)
+		t.FailNow()
+	}
+	expected = connection.CommandTag{Tag: "UPDATE 0", Operation: "UPDATE"}
+	if diff := deep.Equal(tag, expected); diff != nil {
+		t.Logf("unexpected command tag for update: %v", diff)
+		t.FailNow()
+	}
+}
+
+func testconnectorGet(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	type row struct {
+		Id          int
+		Description string
+	}
+	var aRow row
+	if err := db.Get(ctx, &aRow, "SELECT id, description FROM "+Table+" WHERE id = $1", 1); err != nil {
+		t.Logf("failed to get row: %v", err)
+		t.FailNow()
+	}
+	if diff := deep.Equal(aRow, row{Id: 1, Description: "first"}); diff != nil {
+		t.Logf("unexpected row: %v", diff)
+		t.FailNow()
+	}
+
+	err := db.Get(ctx, &aRow, "SELECT id, description FROM "+Table+" WHERE id = $1", 99999)
+	if err != gaumErrors.ErrNoRows {
+		t.Logf("expected ErrNoRows got %v", err)
+		t.FailNow()
+	}
+	err = db.Get(ctx, &aRow, "SELECT id, description FROM "+Table+" WHERE id < $1", 3)
+	if err != gaumErrors.ErrTooManyRows {
+		t.Logf("expected ErrTooManyRows got %v", err)
+		t.FailNow()
+	}
+}
+
+func testconnectorExecBatch(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	results, err := db.ExecBatch(ctx, []connection.Statement{
+		{SQL: "INSERT INTO " + Table + " (id, description) VALUES ($1, $2)", Args: []interface{}{70000, "batch"}},
+		{SQL: "INSERT INTO " + Table + " (id, description) VALUES ($1, $2)", Args: []interface{}{70001, "batch"}},
+		{SQL: "UPDATE " + Table + " SET description = $1 WHERE description = $2", Args: []interface{}{"batched", "batch"}},
+	})
+	if err != nil {
+		t.Logf("failed to run batch: %v", err)
+		t.FailNow()
+	}
+	expected := []connection.BatchResult{{RowsAffected: 1}, {RowsAffected: 1}, {RowsAffected: 2}}
+	if diff := deep.Equal(results, expected); diff != nil {
+		t.Logf("unexpected batch results: %v", diff)
+		t.FailNow()
+	}
+
+	// the second one conflicts so none is applied.
+	results, err = db.ExecBatch(ctx, []connection.Statement{
+		{SQL: "INSERT INTO " + Table + " (id, description) VALUES ($1, $2)", Args: []interface{}{70002, "batch"}},
+		{SQL: "INSERT INTO " + Table + " (id, description) VALUES ($1, $2)", Args: []interface{}{70000, "batch"}},
+		{SQL: "DELETE FROM " + Table + " WHERE id = $1", Args: []interface{}{70001}},
+	})
+	if err == nil {
+		t.Log("expected the batch to fail")
+		t.FailNow()
+	}
+	if len(results) != 3 || results[0].Err != nil || results[1].Err == nil ||
+		results[2].Err != gaumErrors.ErrBatchAborted {
+		t.Logf("unexpected results for failed batch %#v", results)
+		t.FailNow()
+	}
+	var ids []int
+	q := chain.New(db)
+	q.Select("id").Table(Table).AndWhere("id >= ?", 70000).OrderBy(chain.Asc("id"))
+	if err := q.FetchIntoPrimitive(ctx, &ids); err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if diff := deep.Equal(ids, []int{70000, 70001}); diff != nil {
+		t.Logf("the failed batch was partially applied: %v", diff)
+		t.FailNow()
+	}
+}
+
+// testconnectorTwoPhaseCommit requires the server to run with max_prepared_transactions > 0.
+func testconnectorTwoPhaseCommit(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	count := func() int {
+		var ids []int
+		q := chain.New(db)
+		q.Select("id").Table(Table).AndWhere("id IN (?)", []int{80000, 80001})
+		if err := q.FetchIntoPrimitive(ctx, &ids); err != nil {
+			t.Logf("failed to query: %v", err)
+			t.FailNow()
+		}
+		return len(ids)
+	}
+	prepare := func(id int, gid string) {
+		tx, err := db.BeginTransaction(ctx)
+		if err != nil {
+			t.Logf("failed to begin transaction: %v", err)
+			t.FailNow()
+		}
+		err = tx.Exec(ctx, "INSERT INTO "+Table+" (id, description) VALUES ($1, $2)", id, "two phase")
+		if err != nil {
+			t.Logf("failed to insert: %v", err)
+			t.FailNow()
+		}
+		if err := tx.PrepareTransaction(ctx, gid); err != nil {
+			t.Logf("failed to prepare transaction: %v", err)
+			t.FailNow()
+		}
+	}
+
+	prepare(80000, "gaum test commit")
+	prepare(80001, "gaum test rollback")
+	if n := count(); n != 0 {
+		t.Logf("prepared transactions should not be visible, found %d rows", n)
+		t.FailNow()
+	}
+	if err := db.CommitPrepared(ctx, "gaum test commit"); err != nil {
+		t.Logf("failed to commit prepared transaction: %v", err)
+		t.FailNow()
+	}
+	if err := db.RollbackPrepared(ctx, "gaum test rollback"); err != nil {
+		t.Logf("failed to roll back prepared transaction: %v", err)
+		t.FailNow()
+	}
+	if n := count(); n != 1 {
+		t.Logf("expected only the committed row, found %d rows", n)
+		t.FailNow()
+	}
+}
+
+func testconnectorSetParam(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	if err := db.SetParam(ctx, "application_name", "gaum"); err != gaumErrors.NoTX {
+		t.Logf("expected NoTX outside of a transaction got %v", err)
+		t.FailNow()
+	}
+
+	tx, err := db.BeginTransaction(ctx)
+	if err != nil {
+		t.Logf("failed to begin transaction: %v", err)
+		t.FailNow()
+	}
+	defer tx.RollbackTransaction(ctx)
+	value := "it's gaum'; SELECT 1; --"
+	if err := tx.SetParam(ctx, "application_name", value); err != nil {
+		t.Logf("failed to set parameter: %v", err)
+		t.FailNow()
+	}
+	var current string
+	if err := tx.Raw(ctx, "SELECT current_setting('application_name')", nil, &current); err != nil {
+		t.Logf("failed to read parameter: %v", err)
+		t.FailNow()
+	}
+	if current != value {
+		t.Logf("expected application_name to be %q got %q", value, current)
+		t.FailNow()
+	}
+	if err := tx.SetParam(ctx, "application_name = 'x'; --", value); err == nil {
+		t.Log("expected an invalid parameter name to be rejected")
+		t.FailNow()
+	}
+}
+
+func testconnectorRuntimeParams(t *testing.T, openDB OpenDB) {
+	params := map[string]string{
+		"application_name": "gaum_tests",
+		"search_path":      "pg_catalog, public",
+	}
+	db := openDB(t, &connection.Information{RuntimeParams: params})
+	defer db.Close()
+	ctx := context.TODO()
+	for name, value := range params {
+		var current string
+		if err := db.Raw(ctx, "SELECT current_setting($1)", []interface{}{name}, &current); err != nil {
+			t.Logf("failed to read parameter %s: %v", name, err)
+			t.FailNow()
+		}
+		if current != value {
+			t.Logf("expected %s to be %q got %q", name, value, current)
+			t.FailNow()
+		}
+	}
+}
+
+func testconnectorPgBouncer(t *testing.T, openDB OpenDB) {
+	db := openDB(t, &connection.Information{PgBouncer: true})
+	defer db.Close()
+	ctx := context.TODO()
+	// without statement cache the same query must be runnable over and over.
+	for i := 0; i < 3; i++ {
+		var description string
+		err := db.Raw(ctx, "SELECT description FROM "+Table+" WHERE id = $1", []interface{}{1}, &description)
+		if err != nil {
+			t.Logf("failed to query in PgBouncer mode: %v", err)
+			t.FailNow()
+		}
+	}
+}
+
+func testconnectorCloseIdle(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	ctx := context.TODO()
+	var count int64
+	for i := 0; i < 2; i++ {
+		if err := db.Raw(ctx, "SELECT count(*) FROM "+Table, nil, &count); err != nil {
+			t.Logf("failed to query: %v", err)
+			t.FailNow()
+		}
+		if err := db.CloseIdle(ctx); err != nil {
+			t.Logf("failed to close idle connections: %v", err)
+			t.FailNow()
+		}
+	}
+	if count != 10 {
+		t.Logf("expected 10 rows got %d", count)
+		t.FailNow()
+	}
+}
+
+func testconnectorLeakDetection(t *testing.T, openDB OpenDB) {
+	leaks := make(chan connection.Leak, 1)
+	db := openDB(t, &connection.Information{
+		LeakDetection: &connection.LeakDetection{
+			Threshold: 100 * time.Millisecond,
+			OnLeak:    func(leak connection.Leak) { leaks <- leak },
+		},
+	})
+	defer db.Close()
+	ctx := context.TODO()
+	statement := "SELECT id, description FROM " + Table + " ORDER BY id"
+	iter, err := db.QueryIter(ctx, statement, []string{"id", "description"})
+	if err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	row := struct {
+		ID          int `gaum:"field_name:id"`
+		Description string
+	}{}
+	_, closer, err := iter(&row)
+	if err != nil {
+		t.Logf("failed to fetch row: %v", err)
+		t.FailNow()
+	}
+	select {
+	case leak := <-leaks:
+		if leak.Kind != "iterator" || leak.Statement != statement {
+			t.Logf("unexpected leak reported: %v", leak)
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("the abandoned iterator was not reported")
+		t.FailNow()
+	}
+	closer()
+}
+
+type recordingMetrics struct {
+	lock sync.Mutex
+	rows map[string]int64
+}
+
+func (r *recordingMetrics) ObserveQuery(operation string, _ time.Duration, rows int64, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err == nil {
+		r.rows[operation] += rows
+	}
+}
+
+func testconnectorMetrics(t *testing.T, openDB OpenDB) {
+	metrics := &recordingMetrics{rows: map[string]int64{}}
+	db := openDB(t, &connection.Information{Metrics: metrics})
+	defer db.Close()
+	ctx := context.TODO()
+	for _, id := range []int{11, 12} {
+		err := chain.New(db).Insert(map[string]interface{}{"id": id, "description": "measured"}).
+			Table(Table).Exec(ctx)
+		if err != nil {
+			t.Logf("failed to insert: %v", err)
+			t.FailNow()
+		}
+	}
+	err := chain.New(db).UpdateMap(map[string]interface{}{"description": "measured again"}).
+		Table(Table).AndWhere("id > ?", 10).Exec(ctx)
+	if err != nil {
+		t.Logf("failed to update: %v", err)
+		t.FailNow()
+	}
+	var rows []struct {
+		ID int `gaum:"field_name:id"`
+	}
+	if err := chain.New(db).Select("id").From(Table).Fetch(ctx, &rows); err != nil {
+		t.Logf("failed to fetch: %v", err)
+		t.FailNow()
+	}
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	if metrics.rows["insert"] != 2 || metrics.rows["update"] != 2 || metrics.rows["select"] != 12 {
+		t.Logf("expected 2 rows inserted, 2 updated and 12 selected got %v", metrics.rows)
+		t.FailNow()
+	}
+}
+
+// queryLogger counts the messages logged at Info.
+type queryLogger struct {
+	logging.Logger
+	lock  sync.Mutex
+	infos int
+}
+
+func (q *queryLogger) Info(msg string, ctx ...interface{}) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.infos++
+}
+
+func (q *queryLogger) count() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.infos
+}
+
+func testconnectorSetLogLevel(t *testing.T, openDB OpenDB) {
+	logger := &queryLogger{Logger: logging.NewGoLogger(log.New(os.Stdout, "logger: ", log.Lshortfile))}
+	db := openDB(t, &connection.Information{Logger: logger, LogLevel: connection.Error})
+	defer db.Close()
+	ctx := context.TODO()
+	var id int
+	if err := db.Raw(ctx, "SELECT id FROM "+Table+" WHERE id = $1", []interface{}{1}, &id); err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if infos := logger.count(); infos != 0 {
+		t.Logf("expected nothing logged at info level got %d messages", infos)
+		t.FailNow()
+	}
+
+	if err := db.SetLogLevel(connection.Info); err != nil {
+		t.Logf("failed to set log level: %v", err)
+		t.FailNow()
+	}
+	if err := db.Raw(ctx, "SELECT id FROM "+Table+" WHERE id = $1", []interface{}{1}, &id); err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if logger.count() == 0 {
+		t.Errorf("expected the query to be logged at info level")
+	}
+
+	if err := db.SetLogLevel(connection.LogLevel("verbose")); err == nil {
+		t.Errorf("expected an error for an invalid log level")
+	}
+}
+
+func testconnectorConstraintViolation(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	err := db.Exec(context.TODO(), "INSERT INTO "+Table+" (id, description) VALUES ($1, $2)", 1, "again")
+	if !gaumErrors.IsUniqueViolation(err) {
+		t.Logf("expected a unique violation got: %v", err)
+		t.FailNow()
+	}
+	violation, _ := gaumErrors.AsConstraintViolation(err)
+	if violation.Constraint != Constraint || violation.Table != Table {
+		t.Errorf("unexpected constraint %q on table %q", violation.Constraint, violation.Table)
+	}
+	if diff := deep.Equal(violation.Columns, []string{"id"}); diff != nil {
+		t.Error(diff)
+	}
+	var dbErr *gaumErrors.DBError
+	if !stdErrors.As(err, &dbErr) || dbErr.Code != "23505" || dbErr.Severity != "ERROR" {
+		t.Errorf("expected the connector to translate the error into a DBError got %v", err)
+	}
+}
+
+func testconnectorNoRows(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	statement := "SELECT id, description FROM " + Table + " WHERE id = $1"
+	row := struct {
+		ID          int `gaum:"field_name:id"`
+		Description string
+	}{}
+
+	_, err := db.QueryIter(ctx, statement, []string{"id", "description"}, 1000)
+	if err != gaumErrors.ErrNoRows {
+		t.Errorf("expected QueryIter to return ErrNoRows got %v", err)
+	}
+	var id int
+	if err := db.Raw(ctx, "SELECT id FROM "+Table+" WHERE id = $1", []interface{}{1000}, &id); err != gaumErrors.ErrNoRows {
+		t.Errorf("expected Raw to return ErrNoRows got %v", err)
+	}
+	if err := db.Get(ctx, &row, statement, 1000); err != gaumErrors.ErrNoRows {
+		t.Errorf("expected Get to return ErrNoRows got %v", err)
+	}
+	if !stdErrors.Is(gaumErrors.ErrNoRows, sql.ErrNoRows) {
+		t.Errorf("expected ErrNoRows to be sql.ErrNoRows for older callers")
+	}
+
+	var rows []struct {
+		ID          int `gaum:"field_name:id"`
+		Description string
+	}
+	fetch, err := db.Query(ctx, statement, []string{"id", "description"}, 1000)
+	if err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if err := fetch(&rows); err != nil || len(rows) != 0 {
+		t.Errorf("expected no error and no rows for a slice got %v and %d rows", err, len(rows))
+	}
+}
+
+func testconnectorQueryNoRowsError(t *testing.T, openDB OpenDB) {
+	db := openDB(t, &connection.Information{QueryNoRows: true})
+	defer db.Close()
+	ctx := context.TODO()
+	statement := "SELECT id, description FROM " + Table + " WHERE id = $1"
+
+	var rows []struct {
+		ID          int `gaum:"field_name:id"`
+		Description string
+	}
+	fetch, err := db.Query(ctx, statement, []string{"id", "description"}, 1000)
+	if err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if err := fetch(&rows); !stdErrors.Is(err, gaumErrors.ErrNoRows) || rows == nil || len(rows) != 0 {
+		t.Errorf("expected ErrNoRows and an empty slice got %v and %v", err, rows)
+	}
+	fetch, err = db.Query(ctx, statement, []string{"id", "description"}, 1)
+	if err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if err := fetch(&rows); err != nil || len(rows) != 1 {
+		t.Errorf("expected no error and one row got %v and %d rows", err, len(rows))
+	}
+
+	var ids []int
+	fetch, err = db.QueryPrimitive(ctx, "SELECT id FROM "+Table+" WHERE id = $1", "id", 1000)
+	if err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if err := fetch(&ids); !stdErrors.Is(err, gaumErrors.ErrNoRows) {
+		t.Errorf("expected QueryPrimitive to return ErrNoRows got %v", err)
+	}
+
+	err = chain.New(db).Select("id, description").From(Table).AndWhere("id = ?", 1000).Fetch(ctx, &rows)
+	if !stdErrors.Is(err, gaumErrors.ErrNoRows) {
+		t.Errorf("expected chain Fetch to return ErrNoRows got %v", err)
+	}
+}
+
+func testconnectorAttachQueryToErrors(t *testing.T, openDB OpenDB) {
+	db := openDB(t, &connection.Information{AttachQueryToErrors: true})
+	defer db.Close()
+	ctx := context.TODO()
+	statement := "INSERT INTO " + Table + " (id, description) VALUES ($1, $2)"
+	err := db.Exec(ctx, statement, 1, "again")
+	var queryErr *gaumErrors.QueryError
+	if !stdErrors.As(err, &queryErr) {
+		t.Logf("expected a *QueryError got %v", err)
+		t.FailNow()
+	}
+	if queryErr.Statement != statement || queryErr.ArgCount != 2 || queryErr.Operation != "exec" {
+		t.Errorf("unexpected query error payload %+v", queryErr)
+	}
+	if !gaumErrors.IsUniqueViolation(err) {
+		t.Errorf("expected the original error to still be found, got %v", err)
+	}
+
+	var id int
+	if err := db.Raw(ctx, "SELECT id FROM "+Table+" WHERE id = $1", []interface{}{1000}, &id); err != gaumErrors.ErrNoRows {
+		t.Errorf("expected no rows to be left as is got %v", err)
+	}
+
+	_, err = db.ExecBatch(ctx, []connection.Statement{
+		{SQL: "INSERT INTO " + Table + " (id, description) VALUES ($1, $2)", Args: []interface{}{70000, "batch"}},
+		{SQL: statement, Args: []interface{}{1, "again"}},
+	})
+	queryErr = nil
+	if !stdErrors.As(err, &queryErr) {
+		t.Logf("expected a *QueryError from the failed batch got %v", err)
+		t.FailNow()
+	}
+	if queryErr.Statement != statement || queryErr.ArgCount != 2 || !gaumErrors.IsUniqueViolation(err) {
+		t.Errorf("unexpected query error payload for the batch %+v", queryErr)
+	}
+}
+
+func testconnectorExpandStars(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	cache := chain.NewColumnCache()
+	columns, err := cache.Columns(ctx, db, Table)
+	if err != nil {
+		t.Logf("failed to introspect %s: %v", Table, err)
+		t.FailNow()
+	}
+	if diff := deep.Equal(columns, []string{"id", "description", "not_used", "not_used_time"}); diff != nil {
+		t.Errorf("unexpected %s columns: %v", Table, diff)
+	}
+	if _, err := cache.Columns(ctx, db, "doesnotexist"); err == nil {
+		t.Errorf("expected an error introspecting a table that does not exist")
+	}
+
+	var rows []struct {
+		ID          int `gaum:"field_name:id"`
+		Description string
+		NotUsed     *string
+		NotUsedTime *time.Time
+		Other       string `gaum:"field_name:other"`
+	}
+	err = chain.New(db).Select("j.*, o.description AS other").Table(Table+" AS j").
+		Join(Table+" AS o", "o.id = j.id + 1").AndWhere("j.id < ?", 3).OrderBy(chain.Asc("j.id")).
+		ExpandStars(cache).Fetch(ctx, &rows)
+	if err != nil {
+		t.Logf("failed to fetch: %v", err)
+		t.FailNow()
+	}
+	if len(rows) != 2 || rows[0].ID != 1 || rows[0].Description != "first" || rows[0].Other != "second" ||
+		rows[1].NotUsed == nil || *rows[1].NotUsed != "meh" {
+		t.Errorf("unexpected rows %#v", rows)
+	}
+}
+
+func testconnectorLoadFixtures(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	other := Table + "_fixtures"
+	if err := db.Exec(ctx, "CREATE TABLE IF NOT EXISTS "+other+" (id int, data text)"); err != nil {
+		t.Logf("failed to create %s: %v", other, err)
+		t.FailNow()
+	}
+	defer db.Exec(ctx, "DROP TABLE IF EXISTS "+other)
+	if err := db.Exec(ctx, "INSERT INTO "+other+" (id, data) VALUES (99, 'stale')"); err != nil {
+		t.Logf("failed to insert in %s: %v", other, err)
+		t.FailNow()
+	}
+
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "fixtures.yaml")
+	yamlFixtures := "- table: " + Table + "\n" +
+		"  rows:\n" +
+		"    - {id: 11, description: eleventh}\n" +
+		"    - {id: 12, description: twelfth, not_used: {nested: [1, 2]}}\n" +
+		"- table: " + other + "\n" +
+		"  truncate: true\n" +
+		"  rows:\n" +
+		"    - {id: 1, data: from yaml}\n"
+	if err := ioutil.WriteFile(yamlPath, []byte(yamlFixtures), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFixtures(ctx, db, yamlPath); err != nil {
+		t.Logf("failed to load %s: %v", yamlPath, err)
+		t.FailNow()
+	}
+
+	var rows []struct {
+		ID          int `gaum:"field_name:id"`
+		Description string
+		NotUsed     *string
+	}
+	err := chain.New(db).Select("id, description, not_used").Table(Table).AndWhere("id > ?", 10).
+		OrderBy(chain.Asc("id")).Fetch(ctx, &rows)
+	if err != nil {
+		t.Logf("failed to fetch: %v", err)
+		t.FailNow()
+	}
+	if len(rows) != 2 || rows[0].Description != "eleventh" || rows[1].NotUsed == nil ||
+		*rows[1].NotUsed != `{"nested":[1,2]}` {
+		t.Errorf("unexpected rows %#v", rows)
+	}
+	var ids []int
+	if err := chain.New(db).Select("id").Table(other).OrderBy(chain.Asc("id")).FetchIntoPrimitive(ctx, &ids); err != nil {
+		t.Logf("failed to fetch %s: %v", other, err)
+		t.FailNow()
+	}
+	if diff := deep.Equal(ids, []int{1}); diff != nil {
+		t.Errorf("expected %s to be truncated: %v", other, diff)
+	}
+
+	jsonPath := filepath.Join(dir, "fixtures.json")
+	jsonFixtures := `[{"table": "` + other + `", "rows": [{"id": 2, "data": "from json"}]}]`
+	if err := ioutil.WriteFile(jsonPath, []byte(jsonFixtures), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFixtures(ctx, db, jsonPath); err != nil {
+		t.Logf("failed to load %s: %v", jsonPath, err)
+		t.FailNow()
+	}
+	ids = nil
+	if err := chain.New(db).Select("id").Table(other).OrderBy(chain.Asc("id")).FetchIntoPrimitive(ctx, &ids); err != nil {
+		t.Logf("failed to fetch %s: %v", other, err)
+		t.FailNow()
+	}
+	if diff := deep.Equal(ids, []int{1, 2}); diff != nil {
+		t.Errorf("expected the json rows to be appended: %v", diff)
+	}
+
+	// a failing fixture leaves nothing behind.
+	badPath := filepath.Join(dir, "bad.json")
+	badFixtures := `[{"table": "` + other + `", "rows": [{"id": 3}]}, {"table": "doesnotexist", "rows": [{"id": 1}]}]`
+	if err := ioutil.WriteFile(badPath, []byte(badFixtures), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadFixtures(ctx, db, badPath); err == nil {
+		t.Errorf("expected an error loading into a table that does not exist")
+	}
+	var count int64
+	if err := chain.New(db).Select("count(*)").Table(other).AndWhere("id = ?", 3).Raw(ctx, &count); err != nil {
+		t.Logf("failed to count: %v", err)
+		t.FailNow()
+	}
+	if count != 0 {
+		t.Errorf("expected the failed load to be rolled back")
+	}
+}
+
+func testconnectorSeed(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	seeded := Table + "_seed"
+	if err := db.Exec(ctx, "CREATE TABLE IF NOT EXISTS "+seeded+" (id int, description text, created timestamp)"); err != nil {
+		t.Logf("failed to create %s: %v", seeded, err)
+		t.FailNow()
+	}
+	defer db.Exec(ctx, "DROP TABLE IF EXISTS "+seeded)
+
+	n := SeedChunkSize + 500
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := Seed(ctx, db, seeded, n, func(i int) map[string]interface{} {
+		return map[string]interface{}{
+			"id":          i,
+			"description": fmt.Sprintf("row %d", i),
+			"created":     created.Add(time.Duration(i) * time.Minute),
+		}
+	})
+	if err != nil {
+		t.Logf("failed to seed: %v", err)
+		t.FailNow()
+	}
+	var count int64
+	if err := chain.New(db).Select("count(*)").Table(seeded).Raw(ctx, &count); err != nil {
+		t.Logf("failed to count: %v", err)
+		t.FailNow()
+	}
+	if count != int64(n) {
+		t.Errorf("expected %d rows got %d", n, count)
+	}
+	var descriptions []string
+	err = chain.New(db).Select("description").Table(seeded).AndWhere("id = ?", n-1).
+		FetchIntoPrimitive(ctx, &descriptions)
+	if err != nil {
+		t.Logf("failed to query: %v", err)
+		t.FailNow()
+	}
+	if diff := deep.Equal(descriptions, []string{fmt.Sprintf("row %d", n-1)}); diff != nil {
+		t.Errorf("unexpected last row: %v", diff)
+	}
+
+	// a row with other columns stops the seeding and nothing is left behind.
+	err = Seed(ctx, db, seeded, 10, func(i int) map[string]interface{} {
+		if i == 5 {
+			return map[string]interface{}{"id": i}
+		}
+		return map[string]interface{}{"id": n + i, "description": "bad"}
+	})
+	if err == nil {
+		t.Errorf("expected an error for a row with different columns")
+	}
+	count = 0
+	if err := chain.New(db).Select("count(*)").Table(seeded).Raw(ctx, &count); err != nil {
+		t.Logf("failed to count: %v", err)
+		t.FailNow()
+	}
+	if count != int64(n) {
+		t.Errorf("expected the failed seed to be rolled back, got %d rows", count)
+	}
+}
+
+func testconnectorVerify(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	type matching struct {
+		ID          *int `gaum:"field_name:id"`
+		Description *string
+		NotUsed     *string
+		NotUsedTime *time.Time
+	}
+	if err := chain.Verify(ctx, db, &matching{}, Table); err != nil {
+		t.Errorf("expected no drift got %v", err)
+	}
+
+	type drifted struct {
+		ID          *int64 `gaum:"field_name:id"`
+		Description string
+		NotUsed     *bool
+		Extra       string
+	}
+	err := chain.Verify(ctx, db, &drifted{}, Table)
+	var driftErr *chain.SchemaDriftError
+	if !stdErrors.As(err, &driftErr) {
+		t.Logf("expected a *chain.SchemaDriftError got %v", err)
+		t.FailNow()
+	}
+	kinds := map[string]chain.DriftKind{}
+	for _, drift := range driftErr.Drifts {
+		kinds[drift.Column] = drift.Kind
+	}
+	expected := map[string]chain.DriftKind{
+		"description":   chain.DriftNullable,
+		"not_used":      chain.DriftType,
+		"not_used_time": chain.DriftMissingField,
+		"extra":         chain.DriftMissingColumn,
+	}
+	if diff := deep.Equal(kinds, expected); diff != nil {
+		t.Errorf("unexpected drifts %v: %v", driftErr.Drifts, diff)
+	}
+
+	if err := chain.Verify(ctx, db, &matching{}, "doesnotexist"); err == nil || stdErrors.As(err, &driftErr) {
+		t.Errorf("expected an introspection error for a table that does not exist got %v", err)
+	}
+}
+
+func testconnectorDDL(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	table := Table + "_ddl"
+	type ddlRow struct {
+		ID      int64  `gaum:"field_name:id;type:bigserial;pk"`
+		Email   string `gaum:"unique"`
+		Tenant  int32  `gaum:"index"`
+		Nick    *string
+		Created time.Time `gaum:"default:now()"`
+	}
+	defer db.Exec(ctx, "DROP TABLE IF EXISTS "+table)
+	create, err := chain.CreateTableFromStruct(table, &ddlRow{})
+	if err != nil {
+		t.Logf("failed to build create table: %v", err)
+		t.FailNow()
+	}
+	for i := 0; i < 2; i++ {
+		if err := create.IfNotExists().Exec(ctx, db); err != nil {
+			t.Logf("failed to create %s: %v", table, err)
+			t.FailNow()
+		}
+	}
+	if err := chain.Verify(ctx, db, &ddlRow{}, table); err != nil {
+		t.Errorf("expected the created table to match its struct: %v", err)
+	}
+
+	err = chain.CreateIndex(table+"_nick_idx").On(table, "lower(nick)").Where("nick IS NOT NULL").
+		Concurrently().Exec(ctx, db)
+	if err != nil {
+		t.Errorf("failed to create index concurrently: %v", err)
+	}
+
+	err = chain.AlterTable(table).AddColumn("score", "int").SetDefault("score", "?", 10).Exec(ctx, db)
+	if err != nil {
+		t.Logf("failed to alter %s: %v", table, err)
+		t.FailNow()
+	}
+	err = chain.New(db).Insert(map[string]interface{}{"email": "a@example.com", "tenant": 1}).Table(table).Exec(ctx)
+	if err != nil {
+		t.Logf("failed to insert: %v", err)
+		t.FailNow()
+	}
+	var scores []int
+	if err := chain.New(db).Select("score").Table(table).FetchIntoPrimitive(ctx, &scores); err != nil {
+		t.Logf("failed to fetch: %v", err)
+		t.FailNow()
+	}
+	if diff := deep.Equal(scores, []int{10}); diff != nil {
+		t.Errorf("expected the default score: %v", diff)
+	}
+
+	// existing rows are not checked by a NOT VALID constraint, only when validating it.
+	check := table + "_score_check"
+	err = chain.AlterTable(table).AddConstraintNotValid(check, "CHECK (score > 20)").Exec(ctx, db)
+	if err != nil {
+		t.Logf("failed to add a not valid constraint: %v", err)
+		t.FailNow()
+	}
+	if err := chain.AlterTable(table).ValidateConstraint(check).Exec(ctx, db); err == nil {
+		t.Errorf("expected validating the constraint to fail for the existing row")
+	}
+	if err := chain.New(db).UpdateMap(map[string]interface{}{"score": 30}).Table(table).Exec(ctx); err != nil {
+		t.Logf("failed to update: %v", err)
+		t.FailNow()
+	}
+	if err := chain.AlterTable(table).ValidateConstraint(check).Exec(ctx, db); err != nil {
+		t.Errorf("failed to validate the constraint: %v", err)
+	}
+	if err := chain.AlterTable(table).DropConstraint(check).DropColumn("score").Exec(ctx, db); err != nil {
+		t.Errorf("failed to drop the constraint and column: %v", err)
+	}
+}
+
+func testconnectorViews(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	view, matview := Table+"_view", Table+"_matview"
+	defer db.Exec(ctx, "DROP VIEW IF EXISTS "+view)
+	defer db.Exec(ctx, "DROP MATERIALIZED VIEW IF EXISTS "+matview)
+	query := chain.NewNoDB().Select("id, description").Table(Table).AndWhere("id IN (?)", []int{1, 2, 3})
+
+	if err := chain.CreateViewFromChain(view, query).OrReplace().Exec(ctx, db); err != nil {
+		t.Logf("failed to create view: %v", err)
+		t.FailNow()
+	}
+	if err := chain.CreateMaterializedView(matview, query).IfNotExists().Exec(ctx, db); err != nil {
+		t.Logf("failed to create materialized view: %v", err)
+		t.FailNow()
+	}
+	if err := chain.CreateIndex(matview+"_id_key").On(matview, "id").Unique().Exec(ctx, db); err != nil {
+		t.Logf("failed to index materialized view: %v", err)
+		t.FailNow()
+	}
+	count := func(from string) int64 {
+		var count int64
+		if err := chain.New(db).Select("count(*)").Table(from).Raw(ctx, &count); err != nil {
+			t.Logf("failed to count %s: %v", from, err)
+			t.FailNow()
+		}
+		return count
+	}
+	if count(view) != 3 || count(matview) != 3 {
+		t.Errorf("expected 3 rows in the views")
+	}
+
+	if err := chain.New(db).Delete().Table(Table).AndWhere("id = ?", 3).Exec(ctx); err != nil {
+		t.Logf("failed to delete: %v", err)
+		t.FailNow()
+	}
+	if count(view) != 2 || count(matview) != 3 {
+		t.Errorf("expected only the view to see the deleted row gone")
+	}
+	for _, concurrently := range []bool{false, true} {
+		if err := chain.RefreshMaterializedView(ctx, db, matview, concurrently); err != nil {
+			t.Errorf("failed to refresh (concurrently: %v): %v", concurrently, err)
+		}
+	}
+	if count(matview) != 2 {
+		t.Errorf("expected the materialized view to be refreshed")
+	}
+	// put the seed row back.
+	Setup(t, db)
+}
+
+func testconnectorPartitions(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	events := Table + "_events"
+	defer db.Exec(ctx, "DROP TABLE IF EXISTS "+events)
+	err := chain.CreateTable(events).IfNotExists().Column("id", "int").Column("created", "timestamptz", "NOT NULL").
+		PartitionBy("RANGE", "created").Exec(ctx, db)
+	if err != nil {
+		t.Logf("failed to create partitioned table: %v", err)
+		t.FailNow()
+	}
+	january := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	february := january.AddDate(0, 1, 0)
+	for _, month := range []time.Time{january, february} {
+		if err := chain.CreateMonthlyPartition(events, month).IfNotExists().Exec(ctx, db); err != nil {
+			t.Logf("failed to create partition: %v", err)
+			t.FailNow()
+		}
+	}
+	err = Seed(ctx, db, events, 59, func(i int) map[string]interface{} {
+		return map[string]interface{}{"id": i, "created": january.AddDate(0, 0, i)}
+	})
+	if err != nil {
+		t.Logf("failed to insert events: %v", err)
+		t.FailNow()
+	}
+	count := func(from string) int64 {
+		var count int64
+		if err := chain.New(db).Select("count(*)").Table(from).Raw(ctx, &count); err != nil {
+			t.Logf("failed to count %s: %v", from, err)
+			t.FailNow()
+		}
+		return count
+	}
+	februaryPartition := chain.MonthlyPartitionName(events, february)
+	if count(chain.MonthlyPartitionName(events, january)) != 31 || count(februaryPartition) != 28 {
+		t.Errorf("expected the events to be split by month")
+	}
+
+	if err := chain.DetachPartition(events, februaryPartition, false).Exec(ctx, db); err != nil {
+		t.Logf("failed to detach partition: %v", err)
+		t.FailNow()
+	}
+	if count(events) != 31 {
+		t.Errorf("expected the detached partition rows to be gone from %s", events)
+	}
+	if err := chain.AttachPartition(events, februaryPartition, february, chain.MaxValue).Exec(ctx, db); err != nil {
+		t.Logf("failed to attach partition: %v", err)
+		t.FailNow()
+	}
+	if count(events) != 59 {
+		t.Errorf("expected the attached partition rows to be back in %s", events)
+	}
+}
+
+func testconnectorSequences(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	seq := Table + "_seq"
+	defer db.Exec(ctx, "DROP SEQUENCE IF EXISTS "+seq)
+	if err := db.Exec(ctx, "CREATE SEQUENCE IF NOT EXISTS "+seq); err != nil {
+		t.Logf("failed to create sequence: %v", err)
+		t.FailNow()
+	}
+	if err := db.SetVal(ctx, seq, 100, false); err != nil {
+		t.Logf("failed to set sequence value: %v", err)
+		t.FailNow()
+	}
+	err := connection.WithTransaction(ctx, db, func(tx connection.DB) error {
+		next, err := tx.NextVal(ctx, seq)
+		if err != nil {
+			return err
+		}
+		current, err := tx.CurrVal(ctx, seq)
+		if err != nil {
+			return err
+		}
+		if next != 100 || current != 100 {
+			t.Errorf("expected nextval and currval to be 100 got %d and %d", next, current)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Logf("failed to read sequence: %v", err)
+		t.FailNow()
+	}
+
+	err = chain.New(db).Insert(map[string]interface{}{"id": chain.NextVal(seq), "description": "from sequence"}).
+		Table(Table).Exec(ctx)
+	if err != nil {
+		t.Logf("failed to insert with nextval: %v", err)
+		t.FailNow()
+	}
+	defer db.Exec(ctx, "DELETE FROM "+Table+" WHERE id = $1", 101)
+	var description string
+	err = chain.New(db).Select("description").Table(Table).AndWhere("id = ?", 101).Raw(ctx, &description)
+	if err != nil {
+		t.Logf("failed to fetch the row inserted with nextval: %v", err)
+		t.FailNow()
+	}
+	if description != "from sequence" {
+		t.Errorf("expected the row inserted with nextval to have id 101")
+	}
+}
+
+func testconnectorIntoTemp(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	var total int64
+	if err := chain.New(db).Select("count(*)").Table(Table).Raw(ctx, &total); err != nil {
+		t.Logf("failed to count: %v", err)
+		t.FailNow()
+	}
+	err := connection.WithTransaction(ctx, db, func(tx connection.DB) error {
+		temp, err := chain.New(tx).Select("id", "description").Table(Table).IntoTemp(ctx, "gaum_stage", chain.OnCommitDrop)
+		if err != nil {
+			return err
+		}
+		var count int64
+		if err := temp.Select("count(*)").Raw(ctx, &count); err != nil {
+			return err
+		}
+		if count != total {
+			t.Errorf("expected %d rows in the temporary table got %d", total, count)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Logf("failed to use temporary table: %v", err)
+		t.FailNow()
+	}
+	var exists bool
+	if err := db.Raw(ctx, "SELECT to_regclass('pg_temp.gaum_stage') IS NOT NULL", nil, &exists); err != nil {
+		t.Logf("failed to look for the temporary table: %v", err)
+		t.FailNow()
+	}
+	if exists {
+		t.Errorf("expected the temporary table to be dropped on commit")
+	}
+}
+
+func testconnectorCreateTableLike(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	clone := Table + "_clone"
+	defer db.Exec(ctx, "DROP TABLE IF EXISTS "+clone)
+	count := func(from string) int64 {
+		var count int64
+		if err := chain.New(db).Select("count(*)").Table(from).Raw(ctx, &count); err != nil {
+			t.Logf("failed to count %s: %v", from, err)
+			t.FailNow()
+		}
+		return count
+	}
+
+	if err := chain.CreateTableLike(clone, Table, true).Exec(ctx, db); err != nil {
+		t.Logf("failed to clone table: %v", err)
+		t.FailNow()
+	}
+	if count(clone) != 0 {
+		t.Errorf("expected the clone without data to be empty")
+	}
+	if err := db.Exec(ctx, "DROP TABLE "+clone); err != nil {
+		t.Logf("failed to drop the clone: %v", err)
+		t.FailNow()
+	}
+
+	if err := chain.CreateTableLike(clone, Table, true).WithData().Exec(ctx, db); err != nil {
+		t.Logf("failed to clone table with data: %v", err)
+		t.FailNow()
+	}
+	if rows := count(Table); count(clone) != rows {
+		t.Errorf("expected the clone to have the %d rows of %s", rows, Table)
+	}
+	// INCLUDING ALL copies the unique constraint on id.
+	err := chain.New(db).Insert(map[string]interface{}{"id": 1, "description": "dup"}).Table(clone).Exec(ctx)
+	if err == nil {
+		err = chain.New(db).Insert(map[string]interface{}{"id": 1, "description": "dup"}).Table(clone).Exec(ctx)
+	}
+	if err == nil {
+		t.Errorf("expected the clone to have the constraints of %s", Table)
+	}
+}
+
+func testconnectorConstraints(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	owners := Table + "_owners"
+	pets := Table + "_pets"
+	defer db.Exec(ctx, "DROP TABLE IF EXISTS "+pets+", "+owners)
+	for _, table := range []*chain.CreateTableStatement{
+		chain.CreateTable(owners).IfNotExists().Column("id", "int").PrimaryKey("id"),
+		chain.CreateTable(pets).IfNotExists().Column("id", "int").Column("owner_id", "int").Column("name", "text"),
+	} {
+		if err := table.Exec(ctx, db); err != nil {
+			t.Logf("failed to create table: %v", err)
+			t.FailNow()
+		}
+	}
+	insertPet := func(id, ownerID int, name string) error {
+		return chain.New(db).Insert(map[string]interface{}{"id": id, "owner_id": ownerID, "name": name}).Table(pets).Exec(ctx)
+	}
+	if err := chain.New(db).Insert(map[string]interface{}{"id": 1}).Table(owners).Exec(ctx); err != nil {
+		t.Logf("failed to insert owner: %v", err)
+		t.FailNow()
+	}
+	if err := insertPet(1, 1, "rex"); err != nil {
+		t.Logf("failed to insert pet: %v", err)
+		t.FailNow()
+	}
+
+	fk := chain.ForeignKey("owner_id").References(owners, "id").OnDelete(chain.Cascade)
+	if err := chain.AddConstraintTwoPhase(ctx, db, pets, pets+"_owner_fk", fk); err != nil {
+		t.Logf("failed to add foreign key: %v", err)
+		t.FailNow()
+	}
+	if err := chain.AddConstraintTwoPhase(ctx, db, pets, pets+"_name_key", chain.Unique("name")); err != nil {
+		t.Logf("failed to add unique constraint: %v", err)
+		t.FailNow()
+	}
+	err := chain.AlterTable(pets).AddTableConstraint(pets+"_name_check", chain.Check("name <> ?", "")).Exec(ctx, db)
+	if err != nil {
+		t.Logf("failed to add check constraint: %v", err)
+		t.FailNow()
+	}
+	if err := insertPet(2, 2, "fido"); err == nil {
+		t.Errorf("expected the foreign key to reject a pet without owner")
+	}
+	if err := insertPet(2, 1, "rex"); err == nil {
+		t.Errorf("expected the unique constraint to reject a repeated name")
+	}
+	if err := insertPet(2, 1, ""); err == nil {
+		t.Errorf("expected the check constraint to reject an empty name")
+	}
+
+	// a NOT VALID check is not enforced on the existing rows until validated.
+	err = chain.AlterTable(pets).AddTableConstraint(pets+"_long_name", chain.Check("length(name) > 3").NotValid()).Exec(ctx, db)
+	if err != nil {
+		t.Logf("failed to add not valid check constraint: %v", err)
+		t.FailNow()
+	}
+	if err := chain.AlterTable(pets).ValidateConstraint(pets+"_long_name").Exec(ctx, db); err == nil {
+		t.Errorf("expected the validation to fail for the existing rows")
+	}
+	if err := chain.AlterTable(pets).DropConstraint(pets+"_long_name").Exec(ctx, db); err != nil {
+		t.Logf("failed to drop constraint: %v", err)
+		t.FailNow()
+	}
+
+	if err := db.Exec(ctx, "DELETE FROM "+owners); err != nil {
+		t.Logf("failed to delete owners: %v", err)
+		t.FailNow()
+	}
+	var count int64
+	if err := chain.New(db).Select("count(*)").Table(pets).Raw(ctx, &count); err != nil {
+		t.Logf("failed to count pets: %v", err)
+		t.FailNow()
+	}
+	if count != 0 {
+		t.Errorf("expected the pets to be deleted along with their owner")
+	}
+}
+
+type preloadAuthor struct {
+	ID    int `gaum:"field_name:id"`
+	Name  string
+	Books []preloadBook `gaum:"has_many:author_id"`
+}
+
+type preloadBook struct {
+	ID       int `gaum:"field_name:id"`
+	AuthorID int `gaum:"field_name:author_id"`
+	Title    string
+	Author   *preloadAuthor `gaum:"belongs_to:author_id"`
+}
+
+func testconnectorPreload(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	ctx := context.TODO()
+	authors := Table + "_authors"
+	books := Table + "_books"
+	defer db.Exec(ctx, "DROP TABLE IF EXISTS "+books+", "+authors)
+	for table, m := range map[string]interface{}{authors: &preloadAuthor{}, books: &preloadBook{}} {
+		statement, err := chain.CreateTableFromStruct(table, m)
+		if err != nil {
+			t.Logf("failed to build table %s: %v", table, err)
+			t.FailNow()
+		}
+		if err := statement.IfNotExists().Exec(ctx, db); err != nil {
+			t.Logf("failed to create table %s: %v", table, err)
+			t.FailNow()
+		}
+		if err := model.Register(m, model.Table(table)); err != nil {
+			t.Logf("failed to register %s: %v", table, err)
+			t.FailNow()
+		}
+		defer model.Unregister(m)
+	}
+	err := Seed(ctx, db, authors, 2, func(i int) map[string]interface{} {
+		return map[string]interface{}{"id": i, "name": fmt.Sprintf("author %d", i)}
+	})
+	if err == nil {
+		// author 0 has 2 books and author 1 none.
+		err = Seed(ctx, db, books, 2, func(i int) map[string]interface{} {
+			return map[string]interface{}{"id": i, "author_id": 0, "title": fmt.Sprintf("book %d", i)}
+		})
+	}
+	if err != nil {
+		t.Logf("failed to insert rows: %v", err)
+		t.FailNow()
+	}
+
+	var fetched []preloadAuthor
+	err = chain.New(db).Select("*").OrderBy(chain.Asc("id")).Preload("Books", "Books.Author").Fetch(ctx, &fetched)
+	if err != nil {
+		t.Logf("failed to fetch with preload: %v", err)
+		t.FailNow()
+	}
+	if len(fetched) != 2 || len(fetched[0].Books) != 2 || len(fetched[1].Books) != 0 {
+		t.Fatalf("expected the books to be loaded into their authors got %+v", fetched)
+	}
+	if author := fetched[0].Books[0].Author; author == nil || author.Name != "author 0" {
+		t.Errorf("expected the author of the books to be loaded got %+v", author)
+	}
+}
+
+func testconnectorParallelDecoding(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	type row struct {
+		ID          int `gaum:"field_name:id"`
+		Description string
+		Nothing     *string
+		Created     time.Time
+	}
+	// enough rows for several batches, and a last one that is not full.
+	statement := "SELECT g AS id, 'row ' || g AS description, NULL::text AS nothing, " +
+		"'2020-01-01'::timestamptz + g * interval '1 second' AS created FROM generate_series(1, 1000) AS g"
+	fetchRows := func(ctx context.Context, receiver interface{}) {
+		fetch, err := db.Query(ctx, statement, nil)
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		if err := fetch(receiver); err != nil {
+			t.Fatalf("failed to fetch: %v", err)
+		}
+	}
+	var expected, got []row
+	fetchRows(context.TODO(), &expected)
+	fetchRows(connection.WithParallelDecoding(context.TODO(), 4), &got)
+	if len(expected) != 1000 {
+		t.Fatalf("expected 1000 rows got %d", len(expected))
+	}
+	if diff := deep.Equal(got, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	var gotPtrs []*row
+	fetchRows(connection.WithParallelDecoding(context.TODO(), 4), &gotPtrs)
+	if len(gotPtrs) != len(expected) {
+		t.Fatalf("expected %d rows got %d", len(expected), len(gotPtrs))
+	}
+	for i, got := range gotPtrs {
+		if got == nil || got.ID != expected[i].ID {
+			t.Fatalf("expected row %d to be %+v got %+v", i, expected[i], got)
+		}
+	}
+	if diff := deep.Equal(*gotPtrs[999], expected[999]); diff != nil {
+		t.Error(diff)
+	}
+
+	// a row that can't be decoded, in a batch after the first, fails the whole fetch.
+	type number struct {
+		Number int
+	}
+	fetch, err := db.Query(connection.WithParallelDecoding(context.TODO(), 4),
+		"SELECT CASE WHEN g = 700 THEN NULL ELSE g END AS number FROM generate_series(1, 1000) AS g", nil)
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	var numbers []number
+	if err := fetch(&numbers); err == nil {
+		t.Errorf("expected decoding a NULL into an int to fail")
+	}
+
+	// a context cancelled while fetching stops it.
+	ctx, cancel := context.WithCancel(connection.WithParallelDecoding(context.TODO(), 4))
+	fetch, err = db.Query(ctx, statement, nil)
+	if err != nil {
+		cancel()
+		t.Fatalf("failed to query: %v", err)
+	}
+	cancel()
+	var cancelled []row
+	if err := fetch(&cancelled); err == nil {
+		t.Errorf("expected fetching with a cancelled context to fail")
+	}
+
+	// the connection is still usable after the failures.
+	got = nil
+	fetchRows(connection.WithParallelDecoding(context.TODO(), 4), &got)
+	if len(got) != len(expected) {
+		t.Errorf("expected %d rows after the failures got %d", len(expected), len(got))
+	}
+}
+
+// scannableRow binds its own fields, Scanned tells it apart from a row filled by reflection.
+type scannableRow struct {
+	ID          int
+	Description string
+	Scanned     bool
+}
+
+func (s *scannableRow) GaumScan(fields []string, scan func(dest ...interface{}) error) error {
+	recipients := make([]interface{}, len(fields))
+	for i, field := range fields {
+		switch field {
+		case "id":
+			recipients[i] = &s.ID
+		case "description":
+			recipients[i] = &s.Description
+		default:
+			return errors.Errorf("unexpected field %q", field)
+		}
+	}
+	s.Scanned = true
+	return scan(recipients...)
+}
+
+func testconnectorScannable(t *testing.T, newDB NewDB) {
+	db := newDB(t)
+	defer db.Close()
+	statement := "SELECT g AS id, 'row ' || g AS description FROM generate_series(1, 300) AS g"
+	expected := scannableRow{ID: 300, Description: "row 300", Scanned: true}
+
+	for _, ctx := range []context.Context{context.TODO(), connection.WithParallelDecoding(context.TODO(), 4)} {
+		fetch, err := db.Query(ctx, statement, nil)
+		if err != nil {
+			t.Fatalf("failed to query: %v", err)
+		}
+		var got []*scannableRow
+		if err := fetch(&got); err != nil {
+			t.Fatalf("failed to fetch: %v", err)
+		}
+		if len(got) != 300 {
+			t.Fatalf("expected 300 rows got %d", len(got))
+		}
+		if diff := deep.Equal(*got[299], expected); diff != nil {
+			t.Error(diff)
+		}
+	}
+
+	iter, err := db.QueryIter(context.TODO(), statement, []string{"id", "description"})
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	var row scannableRow
+	next, closer, err := iter(&row)
+	defer closer()
+	if err != nil {
+		t.Fatalf("failed to fetch: %v", err)
+	}
+	if !next || !row.Scanned || row.ID != 1 {
+		t.Errorf("expected the first row to scan itself got %+v", row)
+	}
+}
@@ -0,0 +1,130 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection_testing
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultImage is the docker image StartPostgres uses unless GAUM_TEST_IMAGE says otherwise.
+const DefaultImage = "postgres"
+
+// postgresStartTimeout is how long StartPostgres waits for the database to accept connections.
+const postgresStartTimeout = time.Minute
+
+// RunWithDB runs the tests in m against the database of DSN, if there is none, that is
+// GAUM_TEST_DSN is not set and nothing listens at DefaultDSN, an ephemeral one is started with
+// StartPostgres, for the duration of the tests, if docker is available.
+// It is meant to be used as TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(connection_testing.RunWithDB(m))
+//	}
+func RunWithDB(m *testing.M) int {
+	if os.Getenv("GAUM_TEST_DSN") != "" || reachable(DefaultDSN) {
+		return m.Run()
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		fmt.Fprintln(os.Stderr, "no test database found and docker is not available to start one")
+		return m.Run()
+	}
+	dsn, stop, err := StartPostgres()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "starting a test database: %v\n", err)
+		return 1
+	}
+	defer stop()
+	if err := os.Setenv("GAUM_TEST_DSN", dsn); err != nil {
+		fmt.Fprintf(os.Stderr, "pointing the tests to the test database: %v\n", err)
+		return 1
+	}
+	return m.Run()
+}
+
+// StartPostgres starts a throwaway postgres in a docker container and returns its DSN once
+// it accepts connections, stop removes the container.
+// The schema is not created, Setup does that.
+func StartPostgres() (dsn string, stop func(), err error) {
+	image := envOr("GAUM_TEST_IMAGE", DefaultImage)
+	password := "mysecretpassword"
+	id, err := docker("run", "--detach", "--rm", "--publish", "127.0.0.1::5432",
+		"--env", "POSTGRES_PASSWORD="+password, image, "-c", "max_prepared_transactions=10")
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "running %s", image)
+	}
+	stop = func() {
+		_, _ = docker("rm", "--force", id)
+	}
+
+	address, err := docker("port", id, "5432/tcp")
+	if err != nil {
+		stop()
+		return "", nil, errors.Wrap(err, "finding the published port")
+	}
+	// there can be one line per published address.
+	address = strings.SplitN(address, "\n", 2)[0]
+
+	deadline := time.Now().Add(postgresStartTimeout)
+	for {
+		// the server started while the database is initialized only listens on the unix socket
+		// so this waits for the final one.
+		if _, err = docker("exec", id, "pg_isready", "--host", "127.0.0.1", "--username", "postgres"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			stop()
+			return "", nil, errors.Wrapf(err, "waiting %s for postgres to start", postgresStartTimeout)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	dsn = fmt.Sprintf("postgres://postgres:%s@%s/postgres?sslmode=disable", password, address)
+	return dsn, stop, nil
+}
+
+// docker runs the docker cli with args and returns its trimmed output.
+func docker(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "docker %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// reachable returns true if something listens at the host of dsn.
+func reachable(dsn string) bool {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return false
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, time.Second)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
@@ -0,0 +1,197 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection_testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/chain"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Fixture holds rows to be inserted in a table by LoadFixtures.
+type Fixture struct {
+	Table string `json:"table" yaml:"table"`
+	// Truncate empties the table before inserting the rows.
+	Truncate bool `json:"truncate" yaml:"truncate"`
+	// Rows are column name to value maps, columns left out take their default value.
+	Rows []map[string]interface{} `json:"rows" yaml:"rows"`
+}
+
+// LoadFixtures inserts the rows described in the YAML (.yaml or .yml) or JSON (.json) file at
+// path, in a transaction. The file holds a list of fixtures, which are loaded in order:
+//
+//	# fixtures.yaml
+//	- table: users
+//	  truncate: true
+//	  rows:
+//	    - {id: 1, name: alice}
+//	    - {id: 2, name: bob, settings: {theme: dark}}
+//
+// Nested objects and lists are inserted as JSON, for array columns use a postgres array literal
+// such as '{1,2}'.
+func LoadFixtures(ctx context.Context, db connection.DB, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "reading fixtures")
+	}
+	fixtures, err := parseFixtures(data, filepath.Ext(path))
+	if err != nil {
+		return errors.Wrapf(err, "parsing fixtures from %s", path)
+	}
+	return connection.WithTransaction(ctx, db, func(tx connection.DB) error {
+		for _, fixture := range fixtures {
+			if err := loadFixture(ctx, tx, fixture); err != nil {
+				return errors.Wrapf(err, "loading fixtures of %s", fixture.Table)
+			}
+		}
+		return nil
+	})
+}
+
+func parseFixtures(data []byte, extension string) ([]Fixture, error) {
+	var fixtures []Fixture
+	switch strings.ToLower(extension) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fixtures); err != nil {
+			return nil, err
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &fixtures); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unknown fixtures format %q, expected .yaml, .yml or .json", extension)
+	}
+	for i, fixture := range fixtures {
+		if fixture.Table == "" {
+			return nil, errors.Errorf("fixture %d has no table", i)
+		}
+		for _, row := range fixture.Rows {
+			for column, value := range row {
+				converted, err := fixtureValue(value)
+				if err != nil {
+					return nil, errors.Wrapf(err, "converting %s.%s", fixture.Table, column)
+				}
+				row[column] = converted
+			}
+		}
+	}
+	return fixtures, nil
+}
+
+// fixtureValue converts what the decoders produce into something the drivers can take.
+func fixtureValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case map[string]interface{}, []interface{}, map[interface{}]interface{}:
+		encoded, err := json.Marshal(jsonable(v))
+		if err != nil {
+			return nil, err
+		}
+		return string(encoded), nil
+	}
+	return value, nil
+}
+
+// jsonable makes the maps yaml decodes, which have interface{} keys, encodable as JSON.
+func jsonable(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonable(value)
+		}
+		return m
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = jsonable(value)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonable(v[i])
+		}
+		return v
+	}
+	return value
+}
+
+func loadFixture(ctx context.Context, db connection.DB, fixture Fixture) error {
+	if fixture.Truncate {
+		if err := db.Exec(ctx, "TRUNCATE "+fixture.Table); err != nil {
+			return errors.Wrap(err, "truncating")
+		}
+	}
+	// consecutive rows with the same columns go in one INSERT.
+	for start := 0; start < len(fixture.Rows); {
+		columns := rowColumns(fixture.Rows[start])
+		end := start + 1
+		for end < len(fixture.Rows) && sameColumns(columns, rowColumns(fixture.Rows[end])) {
+			end++
+		}
+		values := make(map[string][]interface{}, len(columns))
+		for _, row := range fixture.Rows[start:end] {
+			for _, column := range columns {
+				values[column] = append(values[column], row[column])
+			}
+		}
+		query, err := chain.New(db).InsertMulti(values)
+		if err != nil {
+			return errors.Wrap(err, "building insert")
+		}
+		if err := query.Table(fixture.Table).Exec(ctx); err != nil {
+			return errors.Wrapf(err, "inserting rows %d to %d", start, end-1)
+		}
+		start = end
+	}
+	return nil
+}
+
+func rowColumns(row map[string]interface{}) []string {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
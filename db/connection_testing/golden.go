@@ -0,0 +1,79 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection_testing
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/chain"
+)
+
+// UpdateGoldenEnv is the environment variable that, set to any value, makes AssertGolden write
+// the golden files instead of comparing against them:
+//
+//	GAUM_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "GAUM_UPDATE_GOLDEN"
+
+// AssertGolden renders query and compares the SQL and arguments against the golden file at path
+// (usually under testdata/), failing the test with both versions if they differ. Golden files
+// are created or rewritten by running the tests with UpdateGoldenEnv set, the changes are then
+// reviewed like any other code.
+func AssertGolden(t testing.TB, query *chain.ExpressionChain, path string) {
+	t.Helper()
+	got, err := RenderGolden(query)
+	if err != nil {
+		t.Fatalf("rendering query for %s: %v", path, err)
+	}
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating directory for %s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("updating %s: %v", path, err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with %s=1 to create it): %v", UpdateGoldenEnv, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("query does not match %s (run with %s=1 to update it)\nexpected:\n%s\ngot:\n%s",
+			path, UpdateGoldenEnv, want, got)
+	}
+}
+
+// RenderGolden returns the contents of the golden file for query: the rendered SQL followed by
+// one line per argument with its position, type and value.
+func RenderGolden(query *chain.ExpressionChain) ([]byte, error) {
+	q, args, err := query.Render()
+	if err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	fmt.Fprintln(out, q)
+	if len(args) > 0 {
+		fmt.Fprintln(out, "-- args")
+	}
+	for i, arg := range args {
+		fmt.Fprintf(out, "$%d %T: %v\n", i+1, arg, arg)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,93 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package connection_testing
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/pkg/errors"
+)
+
+// SeedChunkSize is the amount of rows Seed inserts at a time.
+const SeedChunkSize = 10000
+
+// Seed inserts n rows, produced by generator for i from 0 to n-1, into table using
+// BulkInsert (COPY for the drivers that support it), SeedChunkSize rows at a time so they
+// never need to be all in memory. Every row must have the columns of the first one.
+// It all happens in one transaction so if any row fails nothing is inserted.
+func Seed(ctx context.Context, db connection.DB, table string, n int, generator func(i int) map[string]interface{}) error {
+	if n <= 0 {
+		return nil
+	}
+	first := generator(0)
+	if len(first) == 0 {
+		return errors.Errorf("the first row for %s has no columns", table)
+	}
+	columns := make([]string, 0, len(first))
+	for column := range first {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	source := &seedSource{columns: columns, n: n, generator: generator, current: first, i: -1}
+	err := connection.WithTransaction(ctx, db, func(tx connection.DB) error {
+		return tx.BulkInsertChunked(ctx, table, columns, source, connection.BulkInsertOptions{ChunkSize: SeedChunkSize})
+	})
+	if err != nil {
+		return errors.Wrapf(err, "seeding %s", table)
+	}
+	return nil
+}
+
+// seedSource is a connection.BulkSource that calls the generator as rows are needed.
+type seedSource struct {
+	columns   []string
+	n         int
+	generator func(i int) map[string]interface{}
+	// current is the row i, the first one is generated in advance to know the columns.
+	current map[string]interface{}
+	i       int
+}
+
+func (s *seedSource) Next() bool {
+	if s.i+1 >= s.n {
+		return false
+	}
+	s.i++
+	if s.i > 0 {
+		s.current = s.generator(s.i)
+	}
+	return true
+}
+
+func (s *seedSource) Values() ([]interface{}, error) {
+	if len(s.current) != len(s.columns) {
+		return nil, errors.Errorf("row %d has %d columns, expected %v", s.i, len(s.current), s.columns)
+	}
+	values := make([]interface{}, len(s.columns))
+	for i, column := range s.columns {
+		value, ok := s.current[column]
+		if !ok {
+			return nil, errors.Errorf("row %d has no %s column", s.i, column)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (s *seedSource) Err() error {
+	return nil
+}
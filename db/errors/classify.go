@@ -0,0 +1,135 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package errors
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net"
+	"strings"
+)
+
+// SQLSTATE codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	codeReadOnlySQLTransaction = "25006"
+	codeAdminShutdown          = "57P01"
+	codeCrashShutdown          = "57P02"
+	codeCannotConnectNow       = "57P03"
+	codeSerializationFailure   = "40001"
+	codeDeadlockDetected       = "40P01"
+	codeTooManyConnections     = "53300"
+)
+
+// pgconnClosed is the message of the error pgconn returns when using a closed connection,
+// the error type is not exported.
+const pgconnClosed = "conn closed"
+
+// unwrap returns the error wrapped by err, either with pkg/errors or go 1.13 wrapping.
+func unwrap(err error) error {
+	switch wrapped := err.(type) {
+	case interface{ Cause() error }:
+		return wrapped.Cause()
+	case interface{ Unwrap() error }:
+		return wrapped.Unwrap()
+	}
+	return nil
+}
+
+// IsFailover returns true if err indicates that the server we are talking to is no longer
+// a primary we can write to, either because it was demoted to a read only replica or because
+// it is shutting down.
+func IsFailover(err error) bool {
+	dbErr, ok := AsDBError(err)
+	if !ok {
+		return false
+	}
+	switch dbErr.Code {
+	case codeReadOnlySQLTransaction, codeAdminShutdown, codeCrashShutdown, codeCannotConnectNow:
+		return true
+	}
+	return false
+}
+
+// isTimeout returns true if err is, or wraps, the error of a cancelled or expired context or a
+// network timeout, which say nothing about the state of the connection.
+func isTimeout(err error) bool {
+	for ; err != nil; err = unwrap(err) {
+		if err == context.Canceled || err == context.DeadlineExceeded {
+			return true
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsConnectionError returns true if err indicates that the connection to the db broke or
+// could not be established, in which case the operation can be attempted again in a new
+// connection. Timeouts and context errors are not connection errors.
+func IsConnectionError(err error) bool {
+	if isTimeout(err) {
+		return false
+	}
+	if dbErr, ok := AsDBError(err); ok {
+		// Class 08 — Connection Exception
+		return strings.HasPrefix(dbErr.Code, "08") ||
+			dbErr.Code == codeAdminShutdown || dbErr.Code == codeCrashShutdown || dbErr.Code == codeCannotConnectNow
+	}
+	for err != nil {
+		if _, ok := err.(net.Error); ok {
+			return true
+		}
+		if err == driver.ErrBadConn || err == io.EOF || err == io.ErrUnexpectedEOF || err.Error() == pgconnClosed {
+			return true
+		}
+		err = unwrap(err)
+	}
+	return false
+}
+
+// IsSerializationFailure returns true if err is a serialization failure, the transaction
+// was rolled back by the server and can be retried.
+func IsSerializationFailure(err error) bool {
+	dbErr, ok := AsDBError(err)
+	return ok && dbErr.Code == codeSerializationFailure
+}
+
+// IsDeadlock returns true if err indicates the transaction was aborted by the server to break
+// a deadlock, it can be retried.
+func IsDeadlock(err error) bool {
+	dbErr, ok := AsDBError(err)
+	return ok && dbErr.Code == codeDeadlockDetected
+}
+
+// IsRetryable returns true if the operation that failed with err can be attempted again and
+// might succeed: serialization failures, deadlocks, servers shutting down or out of
+// connections and broken connections.
+// It is the classification used by the bundled retry helpers, beware that a connection broken
+// while committing leaves unknown whether the transaction was committed, so what is retried
+// must be safe to run more than once.
+// Errors of a cancelled or expired context, and timeouts, are never retryable as a retry would
+// run past what the caller allowed.
+func IsRetryable(err error) bool {
+	if isTimeout(err) {
+		return false
+	}
+	if IsSerializationFailure(err) || IsDeadlock(err) || IsConnectionError(err) {
+		return true
+	}
+	dbErr, ok := AsDBError(err)
+	return ok && dbErr.Code == codeTooManyConnections
+}
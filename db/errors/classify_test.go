@@ -0,0 +1,102 @@
+package errors
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	pkgErrors "github.com/pkg/errors"
+)
+
+func TestIsFailover(t *testing.T) {
+	readOnly := &pgconn.PgError{Code: "25006"}
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "plain", err: pkgErrors.New("boom"), expected: false},
+		{name: "read only", err: readOnly, expected: true},
+		{name: "wrapped", err: pkgErrors.Wrap(readOnly, "querying database"), expected: true},
+		{name: "go wrapped", err: fmt.Errorf("beginning: %w", pkgErrors.Wrap(readOnly, "querying")), expected: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsFailover(tc.err); got != tc.expected {
+				t.Errorf("IsFailover(%v) = %v, expected %v", tc.err, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "plain", err: pkgErrors.New("boom"), expected: false},
+		{name: "eof", err: pkgErrors.Wrap(io.ErrUnexpectedEOF, "querying database"), expected: true},
+		{name: "bad conn", err: fmt.Errorf("querying: %w", driver.ErrBadConn), expected: true},
+		{name: "net", err: pkgErrors.Wrap(&net.OpError{Op: "read", Err: io.EOF}, "querying database"), expected: true},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, expected: true},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01"}, expected: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, expected: false},
+		{name: "deadline exceeded", err: pkgErrors.Wrap(context.DeadlineExceeded, "querying database"), expected: false},
+		{name: "canceled", err: fmt.Errorf("querying: %w", context.Canceled), expected: false},
+		{name: "net timeout", err: pkgErrors.Wrap(&net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, "querying"), expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsConnectionError(tc.err); got != tc.expected {
+				t.Errorf("IsConnectionError(%v) = %v, expected %v", tc.err, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestIsSerializationFailureAndDeadlock(t *testing.T) {
+	serialization := pkgErrors.Wrap(&pgconn.PgError{Code: "40001"}, "committing")
+	deadlock := fmt.Errorf("updating: %w", &pgconn.PgError{Code: "40P01"})
+	if !IsSerializationFailure(serialization) || IsSerializationFailure(deadlock) {
+		t.Error("IsSerializationFailure should only match 40001")
+	}
+	if !IsDeadlock(deadlock) || IsDeadlock(serialization) {
+		t.Error("IsDeadlock should only match 40P01")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "nil", err: nil, expected: false},
+		{name: "plain", err: pkgErrors.New("boom"), expected: false},
+		{name: "serialization", err: pkgErrors.Wrap(&pgconn.PgError{Code: "40001"}, "committing"), expected: true},
+		{name: "deadlock", err: fmt.Errorf("updating: %w", &pgconn.PgError{Code: "40P01"}), expected: true},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01"}, expected: true},
+		{name: "too many connections", err: &pgconn.PgError{Code: "53300"}, expected: true},
+		{name: "reset", err: pkgErrors.Wrap(&net.OpError{Op: "read", Err: io.EOF}, "querying"), expected: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, expected: false},
+		{name: "syntax error", err: &pgconn.PgError{Code: "42601"}, expected: false},
+		{name: "deadline exceeded", err: pkgErrors.Wrap(context.DeadlineExceeded, "committing"), expected: false},
+		{name: "canceled", err: fmt.Errorf("beginning: %w", context.Canceled), expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsRetryable(tc.err); got != tc.expected {
+				t.Errorf("IsRetryable(%v) = %v, expected %v", tc.err, got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package errors
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SQLSTATE codes of the integrity constraint violations, class 23.
+const (
+	codeNotNullViolation    = "23502"
+	codeForeignKeyViolation = "23503"
+	codeUniqueViolation     = "23505"
+	codeCheckViolation      = "23514"
+	codeExclusionViolation  = "23P01"
+)
+
+// ConstraintViolation is an integrity constraint violation reported by the server, regardless
+// of the driver used.
+type ConstraintViolation struct {
+	// Code is the SQLSTATE of the error.
+	Code string
+	// Constraint is the name of the violated constraint, empty for not null violations.
+	Constraint string
+	// Schema and Table are those of the table the constraint belongs to.
+	Schema string
+	Table  string
+	// Columns are the columns involved, when the server reports them.
+	Columns []string
+	// Message and Detail are those of the server error.
+	Message string
+	Detail  string
+
+	err error
+}
+
+// Error implements error
+func (c *ConstraintViolation) Error() string {
+	return c.err.Error()
+}
+
+// Unwrap returns the DBError.
+func (c *ConstraintViolation) Unwrap() error {
+	return c.err
+}
+
+// keyDetailRe matches the columns in the detail of unique, foreign key and exclusion violations
+// such as "Key (id, name)=(1, foo) already exists."
+var keyDetailRe = regexp.MustCompile(`^Key \(([^)]*)\)=`)
+
+// AsConstraintViolation returns the constraint violation err is or wraps, false if it is not
+// one.
+func AsConstraintViolation(err error) (*ConstraintViolation, bool) {
+	dbErr, ok := AsDBError(err)
+	if !ok || !strings.HasPrefix(dbErr.Code, "23") {
+		return nil, false
+	}
+	violation := &ConstraintViolation{
+		Code:       dbErr.Code,
+		Constraint: dbErr.Constraint,
+		Schema:     dbErr.Schema,
+		Table:      dbErr.Table,
+		Message:    dbErr.Message,
+		Detail:     dbErr.Detail,
+		err:        dbErr,
+	}
+	if dbErr.Column != "" {
+		violation.Columns = []string{dbErr.Column}
+	} else if match := keyDetailRe.FindStringSubmatch(dbErr.Detail); match != nil {
+		for _, column := range strings.Split(match[1], ",") {
+			violation.Columns = append(violation.Columns, strings.TrimSpace(column))
+		}
+	}
+	return violation, true
+}
+
+func isViolation(err error, code string) bool {
+	violation, ok := AsConstraintViolation(err)
+	return ok && violation.Code == code
+}
+
+// IsUniqueViolation returns true if err is a unique or primary key constraint violation.
+func IsUniqueViolation(err error) bool {
+	return isViolation(err, codeUniqueViolation)
+}
+
+// IsForeignKeyViolation returns true if err is a foreign key constraint violation.
+func IsForeignKeyViolation(err error) bool {
+	return isViolation(err, codeForeignKeyViolation)
+}
+
+// IsCheckViolation returns true if err is a check constraint violation.
+func IsCheckViolation(err error) bool {
+	return isViolation(err, codeCheckViolation)
+}
+
+// IsNotNullViolation returns true if err is a not null constraint violation.
+func IsNotNullViolation(err error) bool {
+	return isViolation(err, codeNotNullViolation)
+}
+
+// IsExclusionViolation returns true if err is an exclusion constraint violation.
+func IsExclusionViolation(err error) bool {
+	return isViolation(err, codeExclusionViolation)
+}
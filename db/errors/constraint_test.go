@@ -0,0 +1,52 @@
+package errors
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/jackc/pgconn"
+	pkgErrors "github.com/pkg/errors"
+)
+
+func TestConstraintViolation(t *testing.T) {
+	unique := pkgErrors.Wrap(&pgconn.PgError{
+		Code:           "23505",
+		ConstraintName: "users_email_key",
+		SchemaName:     "public",
+		TableName:      "users",
+		Detail:         "Key (tenant, email)=(1, a@b.c) already exists.",
+	}, "inserting user")
+	if !IsUniqueViolation(unique) || IsForeignKeyViolation(unique) || IsCheckViolation(unique) {
+		t.Error("expected only a unique violation")
+	}
+	violation, ok := AsConstraintViolation(unique)
+	if !ok {
+		t.Fatal("expected a constraint violation")
+	}
+	if violation.Constraint != "users_email_key" || violation.Table != "users" {
+		t.Errorf("unexpected constraint %q on table %q", violation.Constraint, violation.Table)
+	}
+	if diff := deep.Equal(violation.Columns, []string{"tenant", "email"}); diff != nil {
+		t.Error(diff)
+	}
+
+	notNull := fmt.Errorf("inserting: %w", &pgconn.PgError{Code: "23502", ColumnName: "name"})
+	violation, ok = AsConstraintViolation(notNull)
+	if !ok || !IsNotNullViolation(notNull) {
+		t.Fatal("expected a not null violation")
+	}
+	if diff := deep.Equal(violation.Columns, []string{"name"}); diff != nil {
+		t.Error(diff)
+	}
+
+	for _, err := range []error{nil, pkgErrors.New("boom"), &pgconn.PgError{Code: "40001"}} {
+		if _, ok := AsConstraintViolation(err); ok {
+			t.Errorf("%v is not a constraint violation", err)
+		}
+	}
+	if !IsForeignKeyViolation(&pgconn.PgError{Code: "23503"}) || !IsCheckViolation(&pgconn.PgError{Code: "23514"}) ||
+		!IsExclusionViolation(&pgconn.PgError{Code: "23P01"}) {
+		t.Error("expected the violations to be classified by code")
+	}
+}
@@ -0,0 +1,131 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package errors
+
+import (
+	"sync"
+
+	"github.com/jackc/pgconn"
+)
+
+// DBError is an error reported by the database server, as translated by the connector from
+// its driver's native error, so it can be handled the same way whatever the driver.
+type DBError struct {
+	// Code is the SQLSTATE of the error, see
+	// https://www.postgresql.org/docs/current/errcodes-appendix.html
+	Code string
+	// Severity is ERROR, FATAL or PANIC.
+	Severity string
+	Message  string
+	Detail   string
+	Hint     string
+	// Schema, Table, Column and Constraint are the objects involved, when reported.
+	Schema     string
+	Table      string
+	Column     string
+	Constraint string
+
+	err error
+}
+
+// Error implements error, it is the message of the translated error.
+func (d *DBError) Error() string {
+	return d.err.Error()
+}
+
+// Unwrap returns the translated error, so the native one can still be found.
+func (d *DBError) Unwrap() error {
+	return d.err
+}
+
+// Translator returns the DBError for err if it is a native error of the driver it knows,
+// nil otherwise. It must not look into the errors wrapped by err.
+type Translator func(err error) *DBError
+
+var (
+	translatorsLock sync.RWMutex
+	translators     = []Translator{translatePgconn}
+)
+
+// RegisterTranslator adds t to the translators used by AsDBError and Translate, it is meant
+// for the connectors of drivers other than the bundled ones.
+func RegisterTranslator(t Translator) {
+	translatorsLock.Lock()
+	defer translatorsLock.Unlock()
+	translators = append(translators, t)
+}
+
+// translatePgconn translates the errors of pgx, used by both bundled connectors.
+func translatePgconn(err error) *DBError {
+	pgErr, ok := err.(*pgconn.PgError)
+	if !ok {
+		return nil
+	}
+	return &DBError{
+		Code:       pgErr.Code,
+		Severity:   pgErr.Severity,
+		Message:    pgErr.Message,
+		Detail:     pgErr.Detail,
+		Hint:       pgErr.Hint,
+		Schema:     pgErr.SchemaName,
+		Table:      pgErr.TableName,
+		Column:     pgErr.ColumnName,
+		Constraint: pgErr.ConstraintName,
+		err:        pgErr,
+	}
+}
+
+func translate(err error) *DBError {
+	translatorsLock.RLock()
+	defer translatorsLock.RUnlock()
+	for _, t := range translators {
+		if dbErr := t(err); dbErr != nil {
+			return dbErr
+		}
+	}
+	return nil
+}
+
+// AsDBError returns the DBError err is or wraps, translating the native driver error found
+// if the connector did not, false if err did not come from the server.
+func AsDBError(err error) (*DBError, bool) {
+	for err != nil {
+		if dbErr, ok := err.(*DBError); ok {
+			return dbErr, true
+		}
+		if dbErr := translate(err); dbErr != nil {
+			return dbErr, true
+		}
+		err = unwrap(err)
+	}
+	return nil, false
+}
+
+// Translate returns err wrapped into a DBError if it wraps a native driver error, so errors.As
+// finds it, and as it is otherwise.
+func Translate(err error) error {
+	for wrapped := err; wrapped != nil; wrapped = unwrap(wrapped) {
+		if _, ok := wrapped.(*DBError); ok {
+			return err
+		}
+	}
+	dbErr, ok := AsDBError(err)
+	if !ok {
+		return err
+	}
+	// it is a new translation, not shared.
+	dbErr.err = err
+	return dbErr
+}
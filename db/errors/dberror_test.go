@@ -0,0 +1,54 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	pkgErrors "github.com/pkg/errors"
+)
+
+type otherDriverError struct {
+	state string
+}
+
+func (o *otherDriverError) Error() string {
+	return "other driver failed with " + o.state
+}
+
+func TestDBError(t *testing.T) {
+	pgErr := &pgconn.PgError{Code: "23505", Severity: "ERROR", TableName: "users", ConstraintName: "users_pkey"}
+	err := Translate(pkgErrors.Wrap(pgErr, "querying database"))
+	var dbErr *DBError
+	if !errors.As(fmt.Errorf("inserting: %w", err), &dbErr) {
+		t.Fatalf("expected a *DBError got %v", err)
+	}
+	if dbErr.Code != "23505" || dbErr.Severity != "ERROR" || dbErr.Table != "users" || dbErr.Constraint != "users_pkey" {
+		t.Errorf("unexpected translation %+v", dbErr)
+	}
+	if err.Error() != "querying database: "+pgErr.Error() {
+		t.Errorf("expected the message to be kept got %q", err.Error())
+	}
+	var native *pgconn.PgError
+	if !errors.As(err, &native) || native != pgErr {
+		t.Errorf("expected the native error to be found")
+	}
+	if again := Translate(pkgErrors.Wrap(err, "again")); !errors.As(again, &dbErr) || errors.Unwrap(again) == nil {
+		t.Errorf("expected translated errors to be left as they are")
+	}
+	if plain := pkgErrors.New("boom"); Translate(plain) != plain {
+		t.Errorf("expected errors not from the server to be left as they are")
+	}
+
+	RegisterTranslator(func(err error) *DBError {
+		if o, ok := err.(*otherDriverError); ok {
+			return &DBError{Code: o.state, err: o}
+		}
+		return nil
+	})
+	other := pkgErrors.Wrap(&otherDriverError{state: "40001"}, "committing")
+	if !IsSerializationFailure(other) || !IsRetryable(other) {
+		t.Errorf("expected the registered translator to be used for classification")
+	}
+}
@@ -14,10 +14,43 @@
 
 package errors
 
-import pkgErrors "github.com/pkg/errors"
+import (
+	"database/sql"
 
-// ErrNoRows should be returned when a query that is supposed to yield results does not.
-var ErrNoRows = pkgErrors.New("no rows in result set")
+	"github.com/jackc/pgx/v4"
+	pkgErrors "github.com/pkg/errors"
+)
+
+// ErrNoRows is returned by the bundled drivers, whatever library they use underneath, when a
+// query that is supposed to yield results does not. Queries into slices yield an empty slice
+// instead, as no rows is a valid result for them, unless connection.Information.QueryNoRows is set.
+// For the sake of older callers errors.Is(ErrNoRows, sql.ErrNoRows) is true too.
+var ErrNoRows error = noRows{}
+
+type noRows struct{}
+
+func (noRows) Error() string {
+	return "no rows in result set"
+}
+
+// Is makes ErrNoRows match the no rows errors of database/sql and pgx.
+func (noRows) Is(target error) bool {
+	return target == sql.ErrNoRows || target == pgx.ErrNoRows
+}
+
+// IsNoRows returns true if err is or wraps ErrNoRows or the no rows error of database/sql or pgx.
+func IsNoRows(err error) bool {
+	for err != nil {
+		if err == ErrNoRows || err == sql.ErrNoRows || err == pgx.ErrNoRows {
+			return true
+		}
+		err = unwrap(err)
+	}
+	return false
+}
+
+// ErrTooManyRows should be returned when a query that is supposed to yield one result yields more.
+var ErrTooManyRows = pkgErrors.New("more than one row in result set")
 
 // NoTX is encountered when an operation is done that assumes a transaction exists, but isn't present
 var NoTX = pkgErrors.New("transaction does not exist")
@@ -25,8 +58,13 @@ var NoTX = pkgErrors.New("transaction does not exist")
 // NoDB is encountered when an operation is preformed without a valid transaction or connection to the DB
 var NoDB = pkgErrors.New("neither transaction or database connection exists")
 
-// AlreadyInTX is encountered when one attempts to start a transaction within a transaction, recursive transactions are not supported at this time.
+// AlreadyInTX is encountered when one attempts to start a transaction within a transaction, the bundled drivers
+// use savepoints for nested transactions instead so it is only kept for other implementations of connection.DB.
 var AlreadyInTX = pkgErrors.New("cannot begin a transaction within a transaction")
 
+// ErrBatchAborted is the error for the statements of a batch that were not run because a previous
+// one failed.
+var ErrBatchAborted = pkgErrors.New("not run, a previous statement of the batch failed")
+
 // NotImplemented is returned when a feature not on a driver is invoked
 var NotImplemented = pkgErrors.New("not implemented for this driver")
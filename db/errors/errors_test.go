@@ -0,0 +1,54 @@
+package errors
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	pkgErrors "github.com/pkg/errors"
+)
+
+func TestIsNoRows(t *testing.T) {
+	for _, err := range []error{
+		ErrNoRows,
+		sql.ErrNoRows,
+		pgx.ErrNoRows,
+		pkgErrors.Wrap(ErrNoRows, "fetching"),
+		fmt.Errorf("querying: %w", pkgErrors.Wrap(sql.ErrNoRows, "fetching")),
+	} {
+		if !IsNoRows(err) {
+			t.Errorf("expected %v to be no rows", err)
+		}
+	}
+	if IsNoRows(nil) || IsNoRows(ErrTooManyRows) {
+		t.Errorf("expected only no rows errors to be no rows")
+	}
+	if !errors.Is(ErrNoRows, sql.ErrNoRows) || !errors.Is(fmt.Errorf("querying: %w", ErrNoRows), pgx.ErrNoRows) {
+		t.Errorf("expected ErrNoRows to match the database/sql and pgx errors")
+	}
+}
+
+func TestStandardInspection(t *testing.T) {
+	pgErr := &pgconn.PgError{Code: "23505"}
+	wrapped := fmt.Errorf("inserting: %w", pkgErrors.Wrapf(pkgErrors.WithMessage(pgErr, "copying"), "chunk %d", 1))
+	var target *pgconn.PgError
+	if !errors.As(wrapped, &target) || target != pgErr {
+		t.Errorf("expected errors.As to find the postgres error through all the wrapping")
+	}
+	if !errors.Is(pkgErrors.Wrap(ErrTooManyRows, "fetching"), ErrTooManyRows) {
+		t.Errorf("expected errors.Is to find gaum errors wrapped with pkg/errors")
+	}
+}
+
+func TestIsStaleObject(t *testing.T) {
+	err := fmt.Errorf("saving: %w", pkgErrors.Wrap(&StaleObjectError{Table: "users", Version: 2}, "updating"))
+	if !IsStaleObject(err) || !errors.Is(err, ErrStaleObject) {
+		t.Errorf("expected %v to be a stale object", err)
+	}
+	if IsStaleObject(nil) || IsStaleObject(ErrNoRows) {
+		t.Errorf("expected only stale object errors to be stale objects")
+	}
+}
@@ -0,0 +1,45 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package errors
+
+import (
+	"time"
+)
+
+// QueryError is an error running a statement along with what was run, for the error reporting
+// pipelines that need it in a structured way, see connection.Information.AttachQueryToErrors.
+// Its message is that of the wrapped error so it does not leak the statement.
+type QueryError struct {
+	// Operation is the kind of operation run, as reported to the metrics (ie, "select" or
+	// "exec").
+	Operation string
+	// Statement is the rendered SQL, with placeholders.
+	Statement string
+	// ArgCount is the amount of arguments passed, their values are not kept.
+	ArgCount int
+	// Duration is the time between the operation start and the error.
+	Duration time.Duration
+	Err      error
+}
+
+// Error implements error
+func (q *QueryError) Error() string {
+	return q.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (q *QueryError) Unwrap() error {
+	return q.Err
+}
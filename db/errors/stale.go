@@ -0,0 +1,55 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package errors
+
+import (
+	"fmt"
+
+	pkgErrors "github.com/pkg/errors"
+)
+
+// ErrStaleObject is matched, with errors.Is, by the StaleObjectError returned when updating a
+// versioned struct that was modified by someone else since it was read.
+var ErrStaleObject = pkgErrors.New("stale object")
+
+// StaleObjectError is returned when the update of a struct with a version column affects no
+// rows, because the row is no longer at the version the struct holds or it was deleted.
+type StaleObjectError struct {
+	// Table is the table of the struct.
+	Table string
+	// Version is the version the struct holds.
+	Version interface{}
+}
+
+// Error implements error
+func (s *StaleObjectError) Error() string {
+	return fmt.Sprintf("%s: the row is no longer at version %v", s.Table, s.Version)
+}
+
+// Is makes StaleObjectError match ErrStaleObject.
+func (s *StaleObjectError) Is(target error) bool {
+	return target == ErrStaleObject
+}
+
+// IsStaleObject returns true if err is or wraps a StaleObjectError.
+func IsStaleObject(err error) bool {
+	for err != nil {
+		if _, ok := err.(*StaleObjectError); ok || err == ErrStaleObject {
+			return true
+		}
+		err = unwrap(err)
+	}
+	return false
+}
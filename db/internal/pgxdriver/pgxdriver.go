@@ -0,0 +1,60 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package pgxdriver has what the pgx based DB implementations share.
+package pgxdriver
+
+import (
+	"context"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgconn/stmtcache"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+// DefaultStatementCacheCapacity is the pgx default.
+const DefaultStatementCacheCapacity = 512
+
+// StatementCacheBuilder returns the pgx statement cache builder that follows sc.
+func StatementCacheBuilder(sc *connection.StatementCache) (pgx.BuildStatementCacheFunc, error) {
+	if sc.Disabled {
+		return nil, nil
+	}
+	mode := stmtcache.ModePrepare
+	switch sc.Mode {
+	case "", connection.StatementCachePrepare:
+	case connection.StatementCacheDescribe:
+		mode = stmtcache.ModeDescribe
+	default:
+		return nil, errors.Errorf("unknown statement cache mode %q", sc.Mode)
+	}
+	capacity := DefaultStatementCacheCapacity
+	if sc.Capacity > 0 {
+		capacity = sc.Capacity
+	}
+	return func(conn *pgconn.PgConn) stmtcache.Cache {
+		return stmtcache.New(conn, mode, capacity)
+	}, nil
+}
+
+// ProtocolArgs prepends the pgx flag that forces the simple protocol to args if ctx asks for it
+// (see connection.WithSimpleProtocol).
+func ProtocolArgs(ctx context.Context, args []interface{}) []interface{} {
+	if !connection.UsesSimpleProtocol(ctx) {
+		return args
+	}
+	return append([]interface{}{pgx.QuerySimpleProtocol(true)}, args...)
+}
@@ -0,0 +1,73 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package logging
+
+// Noop returns a Logger that discards everything.
+func Noop() Logger {
+	return noopLogger{}
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Debug(msg string, ctx ...interface{}) {}
+func (noopLogger) Info(msg string, ctx ...interface{})  {}
+func (noopLogger) Warn(msg string, ctx ...interface{})  {}
+func (noopLogger) Error(msg string, ctx ...interface{}) {}
+func (noopLogger) Crit(msg string, ctx ...interface{})  {}
+
+// LevelFilter returns a Logger that passes to inner only the messages at min level or above.
+func LevelFilter(inner Logger, min Level) Logger {
+	return &levelFilter{inner: inner, min: min}
+}
+
+type levelFilter struct {
+	inner Logger
+	min   Level
+}
+
+// Debug implements Logger
+func (l *levelFilter) Debug(msg string, ctx ...interface{}) {
+	if l.min <= LevelDebug {
+		l.inner.Debug(msg, ctx...)
+	}
+}
+
+// Info implements Logger
+func (l *levelFilter) Info(msg string, ctx ...interface{}) {
+	if l.min <= LevelInfo {
+		l.inner.Info(msg, ctx...)
+	}
+}
+
+// Warn implements Logger
+func (l *levelFilter) Warn(msg string, ctx ...interface{}) {
+	if l.min <= LevelWarn {
+		l.inner.Warn(msg, ctx...)
+	}
+}
+
+// Error implements Logger
+func (l *levelFilter) Error(msg string, ctx ...interface{}) {
+	if l.min <= LevelError {
+		l.inner.Error(msg, ctx...)
+	}
+}
+
+// Crit implements Logger
+func (l *levelFilter) Crit(msg string, ctx ...interface{}) {
+	if l.min <= LevelCrit {
+		l.inner.Crit(msg, ctx...)
+	}
+}
@@ -0,0 +1,22 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestLevelFilter(t *testing.T) {
+	inner := &recordingLogger{}
+	for _, logger := range []Logger{LevelFilter(inner, LevelWarn), Noop()} {
+		logger.Debug("scanning")
+		logger.Info("query")
+		logger.Warn("careful")
+		logger.Error("failed")
+		logger.Crit("broken")
+	}
+	expected := []string{"warn: careful", "error: failed", "crit: broken"}
+	if diff := deep.Equal(inner.messages, expected); diff != nil {
+		t.Error(diff)
+	}
+}
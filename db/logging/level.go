@@ -0,0 +1,73 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package logging
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Level is the severity of a log message, it is used to filter messages independently of the
+// logging library behind Logger.
+type Level int32
+
+const (
+	// LevelTrace is for the most verbose messages, Logger has no Trace so they are logged as
+	// Debug.
+	LevelTrace Level = iota
+	// LevelDebug is the level of Logger.Debug
+	LevelDebug
+	// LevelInfo is the level of Logger.Info
+	LevelInfo
+	// LevelWarn is the level of Logger.Warn
+	LevelWarn
+	// LevelError is the level of Logger.Error
+	LevelError
+	// LevelCrit is the level of Logger.Crit
+	LevelCrit
+	// LevelNone is above all levels, filtering at it logs nothing.
+	LevelNone
+)
+
+var levelNames = map[Level]string{
+	LevelTrace: "trace",
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+	LevelCrit:  "crit",
+	LevelNone:  "none",
+}
+
+// String returns the name of the level.
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "invalid level " + strconv.Itoa(int(l))
+}
+
+// ParseLevel returns the Level with the passed name, as returned by String.
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(name)
+	for level, levelName := range levelNames {
+		if levelName == name {
+			return level, nil
+		}
+	}
+	return LevelNone, errors.Errorf("invalid log level %q", name)
+}
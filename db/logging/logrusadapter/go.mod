@@ -0,0 +1,7 @@
+module github.com/ShiftLeftSecurity/gaum/db/logging/logrusadapter
+
+go 1.17
+
+require github.com/sirupsen/logrus v1.9.3
+
+require golang.org/x/sys v0.7.0 // indirect
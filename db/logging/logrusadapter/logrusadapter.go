@@ -0,0 +1,76 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package logrusadapter adapts a logrus logger to gaum's logging.Logger, it is a module of its
+// own so gaum doesn't depend on logrus.
+package logrusadapter
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger wraps a logrus.FieldLogger, ie a *logrus.Logger or *logrus.Entry, into a gaum
+// logging.Logger, the key/value pairs become fields of the entry.
+type Logger struct {
+	logger logrus.FieldLogger
+}
+
+// NewLogger returns a Logger that logs through l.
+func NewLogger(l logrus.FieldLogger) *Logger {
+	return &Logger{logger: l}
+}
+
+// Debug implements logging.Logger
+func (l *Logger) Debug(msg string, ctx ...interface{}) {
+	l.logger.WithFields(fields(ctx)).Debug(msg)
+}
+
+// Info implements logging.Logger
+func (l *Logger) Info(msg string, ctx ...interface{}) {
+	l.logger.WithFields(fields(ctx)).Info(msg)
+}
+
+// Warn implements logging.Logger
+func (l *Logger) Warn(msg string, ctx ...interface{}) {
+	l.logger.WithFields(fields(ctx)).Warn(msg)
+}
+
+// Error implements logging.Logger
+func (l *Logger) Error(msg string, ctx ...interface{}) {
+	l.logger.WithFields(fields(ctx)).Error(msg)
+}
+
+// Crit implements logging.Logger, it logs at fatal level without exiting.
+func (l *Logger) Crit(msg string, ctx ...interface{}) {
+	l.logger.WithFields(fields(ctx)).Log(logrus.FatalLevel, msg)
+}
+
+// fields returns the key/value pairs of ctx as logrus fields, a trailing key without value is
+// kept under "EXTRA_VALUE_AT_END".
+func fields(ctx []interface{}) logrus.Fields {
+	f := make(logrus.Fields, len(ctx)/2+1)
+	for i := 0; i+1 < len(ctx); i += 2 {
+		key, ok := ctx[i].(string)
+		if !ok {
+			key = fmt.Sprint(ctx[i])
+		}
+		f[key] = ctx[i+1]
+	}
+	if len(ctx)%2 != 0 {
+		f["EXTRA_VALUE_AT_END"] = ctx[len(ctx)-1]
+	}
+	return f
+}
@@ -0,0 +1,58 @@
+package logrusadapter
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// gaumLogger is gaum's logging.Logger, this module does not depend on gaum.
+type gaumLogger interface {
+	Debug(msg string, ctx ...interface{})
+	Info(msg string, ctx ...interface{})
+	Warn(msg string, ctx ...interface{})
+	Error(msg string, ctx ...interface{})
+	Crit(msg string, ctx ...interface{})
+}
+
+var _ gaumLogger = &Logger{}
+
+func TestLogger(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	l := NewLogger(logger)
+
+	l.Debug("debug", "sql", "SELECT 1")
+	l.Info("info", "sql", "SELECT 1")
+	l.Warn("warn", "sql", "SELECT 1")
+	l.Error("error", "sql", "SELECT 1")
+	l.Crit("crit", "sql", "SELECT 1", "dangling")
+
+	expected := []struct {
+		level logrus.Level
+		msg   string
+	}{
+		{logrus.DebugLevel, "debug"},
+		{logrus.InfoLevel, "info"},
+		{logrus.WarnLevel, "warn"},
+		{logrus.ErrorLevel, "error"},
+		{logrus.FatalLevel, "crit"},
+	}
+	entries := hook.AllEntries()
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries got %d", len(expected), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Level != expected[i].level || entry.Message != expected[i].msg {
+			t.Errorf("expected %q at level %s got %q at level %s",
+				expected[i].msg, expected[i].level, entry.Message, entry.Level)
+		}
+		if entry.Data["sql"] != "SELECT 1" {
+			t.Errorf("expected the key/value pairs to be fields got %v", entry.Data)
+		}
+	}
+	if entries[4].Data["EXTRA_VALUE_AT_END"] != "dangling" {
+		t.Errorf("expected a trailing key to be kept got %v", entries[4].Data)
+	}
+}
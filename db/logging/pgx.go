@@ -0,0 +1,89 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package logging
+
+import (
+	"sync/atomic"
+)
+
+// NewPgxLogAdapter returns a PgxLogAdapter wrapping the passed Logger, it logs all levels until
+// told otherwise with WithLevel or SetLevel.
+func NewPgxLogAdapter(l Logger) *PgxLogAdapter {
+	return &PgxLogAdapter{logger: l, level: int32(LevelTrace)}
+}
+
+// PgxLogAdapter wraps anything that satisfies Logger into a pgx logger. It is not tied to a
+// pgx major version: each one supported has its Log method (see pgxv4.go) translating its
+// levels into ours and calling LogAt.
+// Filtering by level happens here, so pgx can be told to log everything and the level changed
+// while the connections are open.
+type PgxLogAdapter struct {
+	logger    Logger
+	redaction *Redaction
+	level     int32
+}
+
+// WithRedaction makes the adapter sanitize the query arguments logged by pgx following r, by
+// default they are only truncated.
+func (l *PgxLogAdapter) WithRedaction(r *Redaction) *PgxLogAdapter {
+	l.redaction = r
+	return l
+}
+
+// WithLevel makes the adapter drop the messages below level.
+func (l *PgxLogAdapter) WithLevel(level Level) *PgxLogAdapter {
+	l.SetLevel(level)
+	return l
+}
+
+// SetLevel changes the minimum level logged, it is safe to call while logging.
+func (l *PgxLogAdapter) SetLevel(level Level) {
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+// Level returns the minimum level logged.
+func (l *PgxLogAdapter) Level() Level {
+	return Level(atomic.LoadInt32(&l.level))
+}
+
+// LogAt logs msg and data at level, if it is not filtered.
+func (l *PgxLogAdapter) LogAt(level Level, msg string, data map[string]interface{}) {
+	if l.logger == nil || level < l.Level() {
+		return
+	}
+	logArgs := make([]interface{}, 0, len(data)*2)
+	for k, v := range data {
+		if args, ok := v.([]interface{}); ok && k == "args" {
+			statement, _ := data["sql"].(string)
+			v = l.redaction.Sanitize(statement, args)
+		}
+		logArgs = append(logArgs, k, v)
+	}
+
+	switch level {
+	case LevelTrace:
+		l.logger.Debug(msg, append(logArgs, "PGX_LOG_LEVEL", "trace")...)
+	case LevelDebug:
+		l.logger.Debug(msg, logArgs...)
+	case LevelInfo:
+		l.logger.Info(msg, logArgs...)
+	case LevelWarn:
+		l.logger.Warn(msg, logArgs...)
+	case LevelError:
+		l.logger.Error(msg, logArgs...)
+	case LevelCrit:
+		l.logger.Crit(msg, logArgs...)
+	}
+}
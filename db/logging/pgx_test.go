@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/jackc/pgx/v4"
+)
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (r *recordingLogger) record(level, msg string) {
+	r.messages = append(r.messages, level+": "+msg)
+}
+
+func (r *recordingLogger) Debug(msg string, ctx ...interface{}) { r.record("debug", msg) }
+func (r *recordingLogger) Info(msg string, ctx ...interface{})  { r.record("info", msg) }
+func (r *recordingLogger) Warn(msg string, ctx ...interface{})  { r.record("warn", msg) }
+func (r *recordingLogger) Error(msg string, ctx ...interface{}) { r.record("error", msg) }
+func (r *recordingLogger) Crit(msg string, ctx ...interface{})  { r.record("crit", msg) }
+
+func TestPgxLogAdapterLevel(t *testing.T) {
+	ctx := context.Background()
+	logger := &recordingLogger{}
+	adapter := NewPgxLogAdapter(logger).WithLevel(LevelWarn)
+	adapter.Log(ctx, pgx.LogLevelTrace, "trace", nil)
+	adapter.Log(ctx, pgx.LogLevelInfo, "query", nil)
+	adapter.Log(ctx, pgx.LogLevelWarn, "careful", nil)
+	adapter.Log(ctx, pgx.LogLevelError, "failed", nil)
+
+	adapter.SetLevel(LevelDebug)
+	adapter.Log(ctx, pgx.LogLevelTrace, "trace", nil)
+	adapter.Log(ctx, pgx.LogLevelInfo, "query", nil)
+
+	expected := []string{"warn: careful", "error: failed", "info: query"}
+	if diff := deep.Equal(logger.messages, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	// no logger, nothing to do.
+	NewPgxLogAdapter(nil).Log(ctx, pgx.LogLevelError, "failed", nil)
+}
+
+func TestParseLevel(t *testing.T) {
+	for level := LevelTrace; level <= LevelNone; level++ {
+		parsed, err := ParseLevel(level.String())
+		if err != nil || parsed != level {
+			t.Errorf("expected %v got %v (%v)", level, parsed, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("expected an error for an invalid level")
+	}
+}
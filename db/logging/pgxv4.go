@@ -0,0 +1,45 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package logging
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var _ pgx.Logger = &PgxLogAdapter{}
+
+// Log Satisfies pgx.Logger
+func (l *PgxLogAdapter) Log(_ context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	switch level {
+	case pgx.LogLevelTrace:
+		l.LogAt(LevelTrace, msg, data)
+	case pgx.LogLevelDebug:
+		l.LogAt(LevelDebug, msg, data)
+	case pgx.LogLevelInfo:
+		l.LogAt(LevelInfo, msg, data)
+	case pgx.LogLevelWarn:
+		l.LogAt(LevelWarn, msg, data)
+	case pgx.LogLevelError:
+		l.LogAt(LevelError, msg, data)
+	default:
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		data["INVALID_PGX_LOG_LEVEL"] = level
+		l.LogAt(LevelError, msg, data)
+	}
+}
@@ -0,0 +1,140 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Redacted replaces the values of sensitive arguments in the logs.
+const Redacted = "[redacted]"
+
+// DefaultMaxArgLength is the length from which strings and byte slices are truncated in the
+// logs if Redaction does not say otherwise.
+const DefaultMaxArgLength = 64
+
+// Redaction configures how the arguments of the queries are sanitized before being logged, it
+// must not be copied once used.
+type Redaction struct {
+	// MaxArgLength truncates the strings and byte slices longer than it, 0 means
+	// DefaultMaxArgLength and a negative value disables truncation.
+	MaxArgLength int
+	// Positions are the 1 based positions ($n) of the arguments that are always redacted.
+	Positions []int
+	// Columns are the names of columns whose values are redacted when they can be matched
+	// to an argument, that is, when compared to it (ie, "password = $2") or inserted
+	// (ie, "INSERT INTO users (email, password) VALUES ($1, $2)").
+	Columns []string
+
+	once         sync.Once
+	comparisonRe *regexp.Regexp
+}
+
+var (
+	insertColumnsRe = regexp.MustCompile(`(?is)^\s*insert\s+into\s+[^(]+\(([^)]*)\)\s*values\s*\(([^)]*)\)`)
+	placeholderRe   = regexp.MustCompile(`^\$(\d+)$`)
+)
+
+// sensitive returns the 1 based positions of the arguments of statement that must be redacted.
+func (r *Redaction) sensitive(statement string) map[int]bool {
+	positions := make(map[int]bool, len(r.Positions))
+	for _, p := range r.Positions {
+		positions[p] = true
+	}
+	if len(r.Columns) == 0 {
+		return positions
+	}
+	r.once.Do(func() {
+		quoted := make([]string, len(r.Columns))
+		for i := range r.Columns {
+			quoted[i] = regexp.QuoteMeta(r.Columns[i])
+		}
+		r.comparisonRe = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") +
+			`)"?\s*(?:=|<>|!=|>=|<=|>|<|\blike\b|\bilike\b)\s*\$(\d+)`)
+	})
+	for _, match := range r.comparisonRe.FindAllStringSubmatch(statement, -1) {
+		if p, err := strconv.Atoi(match[1]); err == nil {
+			positions[p] = true
+		}
+	}
+	if match := insertColumnsRe.FindStringSubmatch(statement); match != nil {
+		columns := strings.Split(match[1], ",")
+		values := strings.Split(match[2], ",")
+		for i := 0; i < len(columns) && i < len(values); i++ {
+			column := strings.Trim(strings.TrimSpace(columns[i]), `"`)
+			placeholder := placeholderRe.FindStringSubmatch(strings.TrimSpace(values[i]))
+			if placeholder == nil {
+				continue
+			}
+			for _, sensitive := range r.Columns {
+				if strings.EqualFold(column, sensitive) {
+					p, _ := strconv.Atoi(placeholder[1])
+					positions[p] = true
+				}
+			}
+		}
+	}
+	return positions
+}
+
+// Sanitize returns a copy of args, the arguments of statement, ready to be logged, with the
+// sensitive ones redacted and the huge ones truncated. A nil Redaction only truncates.
+func (r *Redaction) Sanitize(statement string, args []interface{}) []interface{} {
+	maxLength := DefaultMaxArgLength
+	var positions map[int]bool
+	if r != nil {
+		if r.MaxArgLength != 0 {
+			maxLength = r.MaxArgLength
+		}
+		positions = r.sensitive(statement)
+	}
+	sanitized := make([]interface{}, len(args))
+	for i, arg := range args {
+		if positions[i+1] {
+			sanitized[i] = Redacted
+			continue
+		}
+		sanitized[i] = truncate(arg, maxLength)
+	}
+	return sanitized
+}
+
+// truncate returns arg shortened to maxLength if it is a string or byte slice, strings are cut
+// at the rune boundary before maxLength so they stay valid UTF-8.
+func truncate(arg interface{}, maxLength int) interface{} {
+	if maxLength < 0 {
+		return arg
+	}
+	switch v := arg.(type) {
+	case string:
+		if len(v) > maxLength {
+			n := maxLength
+			for n > 0 && !utf8.RuneStart(v[n]) {
+				n--
+			}
+			return fmt.Sprintf("%s (truncated %d bytes)", v[:n], len(v)-n)
+		}
+	case []byte:
+		if len(v) > maxLength {
+			return fmt.Sprintf("%x (truncated %d bytes)", v[:maxLength], len(v)-maxLength)
+		}
+	}
+	return arg
+}
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/go-test/deep"
+)
+
+func TestRedactionSanitize(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	r := &Redaction{
+		Positions: []int{3},
+		Columns:   []string{"password", "email"},
+	}
+	for statement, expected := range map[string][]interface{}{
+		"SELECT * FROM users WHERE name = $1 AND password = $2 AND age > $3": {
+			long[:64] + " (truncated 36 bytes)", Redacted, Redacted,
+		},
+		"INSERT INTO users (name, email, age) VALUES ($1, $2, $3)": {
+			long[:64] + " (truncated 36 bytes)", Redacted, Redacted,
+		},
+	} {
+		got := r.Sanitize(statement, []interface{}{long, "secret", 42})
+		if diff := deep.Equal(got, expected); diff != nil {
+			t.Errorf("%s: %v", statement, diff)
+		}
+	}
+
+	var noRedaction *Redaction
+	got := noRedaction.Sanitize("SELECT $1, $2", []interface{}{[]byte(long), 1})
+	if diff := deep.Equal(got, []interface{}{strings.Repeat("61", 64) + " (truncated 36 bytes)", 1}); diff != nil {
+		t.Error(diff)
+	}
+
+	accented := "a" + strings.Repeat("é", 50)
+	got = noRedaction.Sanitize("SELECT $1", []interface{}{accented})
+	if diff := deep.Equal(got, []interface{}{accented[:63] + " (truncated 38 bytes)"}); diff != nil {
+		t.Error(diff)
+	}
+	if !utf8.ValidString(got[0].(string)) {
+		t.Errorf("expected the truncated value to be valid UTF-8 got %q", got[0])
+	}
+}
@@ -0,0 +1,54 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package zapadapter adapts a zap logger to gaum's logging.Logger, it is a module of its own so
+// gaum doesn't depend on zap.
+package zapadapter
+
+import "go.uber.org/zap"
+
+// Logger wraps a *zap.Logger into a gaum logging.Logger, the key/value pairs become zap fields.
+type Logger struct {
+	logger *zap.SugaredLogger
+}
+
+// NewLogger returns a Logger that logs through l, reporting the caller of the Logger methods.
+func NewLogger(l *zap.Logger) *Logger {
+	return &Logger{logger: l.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+// Debug implements logging.Logger
+func (l *Logger) Debug(msg string, ctx ...interface{}) {
+	l.logger.Debugw(msg, ctx...)
+}
+
+// Info implements logging.Logger
+func (l *Logger) Info(msg string, ctx ...interface{}) {
+	l.logger.Infow(msg, ctx...)
+}
+
+// Warn implements logging.Logger
+func (l *Logger) Warn(msg string, ctx ...interface{}) {
+	l.logger.Warnw(msg, ctx...)
+}
+
+// Error implements logging.Logger
+func (l *Logger) Error(msg string, ctx ...interface{}) {
+	l.logger.Errorw(msg, ctx...)
+}
+
+// Crit implements logging.Logger, it logs at zap DPanic level, which panics only in development.
+func (l *Logger) Crit(msg string, ctx ...interface{}) {
+	l.logger.DPanicw(msg, ctx...)
+}
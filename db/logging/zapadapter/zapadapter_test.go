@@ -0,0 +1,55 @@
+package zapadapter
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// gaumLogger is gaum's logging.Logger, this module does not depend on gaum.
+type gaumLogger interface {
+	Debug(msg string, ctx ...interface{})
+	Info(msg string, ctx ...interface{})
+	Warn(msg string, ctx ...interface{})
+	Error(msg string, ctx ...interface{})
+	Crit(msg string, ctx ...interface{})
+}
+
+var _ gaumLogger = &Logger{}
+
+func TestLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewLogger(zap.New(core))
+
+	l.Debug("debug", "sql", "SELECT 1")
+	l.Info("info", "sql", "SELECT 1")
+	l.Warn("warn", "sql", "SELECT 1")
+	l.Error("error", "sql", "SELECT 1")
+	l.Crit("crit", "sql", "SELECT 1")
+
+	expected := []struct {
+		level zapcore.Level
+		msg   string
+	}{
+		{zapcore.DebugLevel, "debug"},
+		{zapcore.InfoLevel, "info"},
+		{zapcore.WarnLevel, "warn"},
+		{zapcore.ErrorLevel, "error"},
+		{zapcore.DPanicLevel, "crit"},
+	}
+	entries := logs.AllUntimed()
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries got %d", len(expected), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Level != expected[i].level || entry.Message != expected[i].msg {
+			t.Errorf("expected %q at level %s got %q at level %s",
+				expected[i].msg, expected[i].level, entry.Message, entry.Level)
+		}
+		if sql := entry.ContextMap()["sql"]; sql != "SELECT 1" {
+			t.Errorf("expected the key/value pairs to be fields got %v", entry.ContextMap())
+		}
+	}
+}
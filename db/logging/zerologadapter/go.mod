@@ -0,0 +1,5 @@
+module github.com/ShiftLeftSecurity/gaum/db/logging/zerologadapter
+
+go 1.17
+
+require github.com/rs/zerolog v1.21.0
@@ -0,0 +1,62 @@
+package zerologadapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// gaumLogger is gaum's logging.Logger, this module does not depend on gaum.
+type gaumLogger interface {
+	Debug(msg string, ctx ...interface{})
+	Info(msg string, ctx ...interface{})
+	Warn(msg string, ctx ...interface{})
+	Error(msg string, ctx ...interface{})
+	Crit(msg string, ctx ...interface{})
+}
+
+var _ gaumLogger = &Logger{}
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	l := NewLogger(zerolog.New(&out))
+
+	l.Debug("debug", "sql", "SELECT 1")
+	l.Info("info", "sql", "SELECT 1")
+	l.Warn("warn", "sql", "SELECT 1")
+	l.Error("error", "sql", "SELECT 1")
+	l.Crit("crit", "sql", "SELECT 1", "dangling")
+
+	expected := []struct {
+		level string
+		msg   string
+	}{
+		{"debug", "debug"},
+		{"info", "info"},
+		{"warn", "warn"},
+		{"error", "error"},
+		{"fatal", "crit"},
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d events got %d: %s", len(expected), len(lines), out.String())
+	}
+	for i, line := range lines {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event["level"] != expected[i].level || event["message"] != expected[i].msg {
+			t.Errorf("expected %q at level %s got %s", expected[i].msg, expected[i].level, line)
+		}
+		if event["sql"] != "SELECT 1" {
+			t.Errorf("expected the key/value pairs to be fields got %s", line)
+		}
+	}
+	if !strings.Contains(lines[4], `"EXTRA_VALUE_AT_END":"dangling"`) {
+		t.Errorf("expected a trailing key to be kept got %s", lines[4])
+	}
+}
@@ -0,0 +1,213 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package metrics provides implementations of connection.Metrics.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+)
+
+var _ connection.Metrics = (*Prometheus)(nil)
+
+// DefaultBuckets are the upper bounds, in seconds, of the query duration histogram buckets.
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultNamespace prefixes the metric names if no namespace is passed.
+const DefaultNamespace = "gaum"
+
+// Prometheus is a connection.Metrics that keeps counters and histograms of the queries by
+// operation and exposes them, along with the state of the registered pools, in the
+// Prometheus text format. It is an http.Handler so it can be mounted as the scrape endpoint,
+// or its output added to an existing one with WriteTo.
+type Prometheus struct {
+	namespace string
+	buckets   []float64
+
+	lock       sync.Mutex
+	operations map[string]*operationMetrics
+	pools      map[string]connection.PoolStater
+}
+
+type operationMetrics struct {
+	count   uint64
+	errors  uint64
+	rows    uint64
+	sum     float64
+	buckets []uint64
+}
+
+// NewPrometheus returns a Prometheus whose metrics are prefixed with namespace and which
+// uses buckets for the duration histogram, if empty the defaults are used.
+func NewPrometheus(namespace string, buckets ...float64) *Prometheus {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+	return &Prometheus{
+		namespace:  namespace,
+		buckets:    sorted,
+		operations: map[string]*operationMetrics{},
+		pools:      map[string]connection.PoolStater{},
+	}
+}
+
+// ObserveQuery implements connection.Metrics
+func (p *Prometheus) ObserveQuery(operation string, duration time.Duration, rows int64, err error) {
+	seconds := duration.Seconds()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	om, ok := p.operations[operation]
+	if !ok {
+		om = &operationMetrics{buckets: make([]uint64, len(p.buckets))}
+		p.operations[operation] = om
+	}
+	om.count++
+	if err != nil {
+		om.errors++
+	}
+	if rows > 0 {
+		om.rows += uint64(rows)
+	}
+	om.sum += seconds
+	for i, bound := range p.buckets {
+		if seconds <= bound {
+			om.buckets[i]++
+		}
+	}
+}
+
+// RegisterPool adds the state of pool, usually a connection.DB that implements
+// connection.PoolStater, to the metrics under the passed name.
+func (p *Prometheus) RegisterPool(name string, pool connection.PoolStater) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.pools[name] = pool
+}
+
+// ServeHTTP implements http.Handler
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	p.lock.Lock()
+	operations := make([]string, 0, len(p.operations))
+	for op := range p.operations {
+		operations = append(operations, op)
+	}
+	sort.Strings(operations)
+	pools := make([]string, 0, len(p.pools))
+	for name := range p.pools {
+		pools = append(pools, name)
+	}
+	sort.Strings(pools)
+
+	p.header(cw, "queries_total", "counter", "Amount of queries run.")
+	for _, op := range operations {
+		p.sample(cw, "queries_total", float64(p.operations[op].count), "operation", op)
+	}
+	p.header(cw, "query_errors_total", "counter", "Amount of queries that failed.")
+	for _, op := range operations {
+		p.sample(cw, "query_errors_total", float64(p.operations[op].errors), "operation", op)
+	}
+	p.header(cw, "query_rows_total", "counter", "Amount of rows fetched or affected by the queries.")
+	for _, op := range operations {
+		p.sample(cw, "query_rows_total", float64(p.operations[op].rows), "operation", op)
+	}
+	p.header(cw, "query_duration_seconds", "histogram", "Duration of the queries.")
+	for _, op := range operations {
+		om := p.operations[op]
+		for i, bound := range p.buckets {
+			p.sample(cw, "query_duration_seconds_bucket", float64(om.buckets[i]),
+				"operation", op, "le", strconv.FormatFloat(bound, 'g', -1, 64))
+		}
+		p.sample(cw, "query_duration_seconds_bucket", float64(om.count), "operation", op, "le", "+Inf")
+		p.sample(cw, "query_duration_seconds_sum", om.sum, "operation", op)
+		p.sample(cw, "query_duration_seconds_count", float64(om.count), "operation", op)
+	}
+	stats := make([]connection.PoolStats, len(pools))
+	for i, name := range pools {
+		stats[i] = p.pools[name].PoolStats()
+	}
+	p.lock.Unlock()
+
+	if len(pools) != 0 {
+		p.header(cw, "pool_connections", "gauge", "Connections of the pool by state.")
+		for i, name := range pools {
+			p.sample(cw, "pool_connections", float64(stats[i].Open), "pool", name, "state", "open")
+			p.sample(cw, "pool_connections", float64(stats[i].InUse), "pool", name, "state", "in_use")
+			p.sample(cw, "pool_connections", float64(stats[i].Idle), "pool", name, "state", "idle")
+		}
+		p.header(cw, "pool_max_connections", "gauge", "Maximum connections of the pool, 0 if unlimited.")
+		for i, name := range pools {
+			p.sample(cw, "pool_max_connections", float64(stats[i].Max), "pool", name)
+		}
+	}
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+func (p *Prometheus) header(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s_%s %s\n# TYPE %s_%s %s\n", p.namespace, name, help, p.namespace, name, kind)
+}
+
+// sample writes a metric line, labels are pairs of name and value.
+func (p *Prometheus) sample(w io.Writer, name string, value float64, labels ...string) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+labelEscaper.Replace(labels[i+1])+`"`)
+	}
+	fmt.Fprintf(w, "%s_%s{%s} %s\n", p.namespace, name, strings.Join(pairs, ","),
+		strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// countingWriter keeps the amount of bytes written and the first error found.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/pkg/errors"
+)
+
+type fakePool struct{}
+
+func (fakePool) PoolStats() connection.PoolStats {
+	return connection.PoolStats{Max: 10, Open: 3, InUse: 1, Idle: 2}
+}
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus("", 0.01, 0.1)
+	p.ObserveQuery("select", 5*time.Millisecond, 3, nil)
+	p.ObserveQuery("select", 50*time.Millisecond, 2, nil)
+	p.ObserveQuery(`ins"ert`, time.Second, -1, errors.New("failed"))
+	p.RegisterPool("primary", fakePool{})
+
+	var out bytes.Buffer
+	if _, err := p.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"# TYPE gaum_queries_total counter",
+		`gaum_queries_total{operation="select"} 2`,
+		`gaum_query_errors_total{operation="ins\"ert"} 1`,
+		`gaum_query_rows_total{operation="select"} 5`,
+		`gaum_query_duration_seconds_bucket{operation="select",le="0.01"} 1`,
+		`gaum_query_duration_seconds_bucket{operation="select",le="0.1"} 2`,
+		`gaum_query_duration_seconds_bucket{operation="ins\"ert",le="+Inf"} 1`,
+		`gaum_query_duration_seconds_count{operation="select"} 2`,
+		`gaum_pool_connections{pool="primary",state="in_use"} 1`,
+		`gaum_pool_max_connections{pool="primary"} 10`,
+	} {
+		if !strings.Contains(out.String(), expected+"\n") {
+			t.Errorf("expected %q in:\n%s", expected, out.String())
+		}
+	}
+}
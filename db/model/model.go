@@ -0,0 +1,216 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+// Package model holds the registry that maps structs to the tables they are stored in, the
+// higher level helpers of chain and q use it to learn the table and keys of a type instead of
+// having them repeated in every query.
+package model
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+	"github.com/pkg/errors"
+)
+
+// Model describes how a registered struct is stored.
+type Model struct {
+	// Type is the struct type.
+	Type reflect.Type
+	// Table is the table the struct is stored in.
+	Table string
+	// PK are the columns of the primary key.
+	PK []string
+	// Relations are the relations of the model by field name.
+	Relations map[string]*Relation
+
+	references map[string]string
+}
+
+// Option configures a Model being registered.
+type Option func(*Model)
+
+// Table sets the table the model is stored in, it is required.
+func Table(name string) Option {
+	return func(m *Model) {
+		m.Table = name
+	}
+}
+
+// PK sets the columns of the primary key, by default those of the fields with the pk sub tag
+// or, if none has it, the id column if the struct has one.
+func PK(columns ...string) Option {
+	return func(m *Model) {
+		m.PK = columns
+	}
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[reflect.Type]*Model{}
+)
+
+// Register adds the struct of model, which can be a struct or a pointer to one, to the registry
+// replacing any previous registration of it, ie:
+//
+//	err := model.Register(&User{}, model.Table("users"), model.PK("id"))
+func Register(model interface{}, options ...Option) error {
+	modelType := structType(reflect.TypeOf(model))
+	if modelType == nil {
+		return errors.Errorf("expected a struct or a pointer to one got %T", model)
+	}
+	m := &Model{Type: modelType, references: map[string]string{}}
+	for _, option := range options {
+		option(m)
+	}
+	if m.Table == "" {
+		return errors.Errorf("model %s needs a table", modelType)
+	}
+	if err := m.resolveRelations(); err != nil {
+		return err
+	}
+	if len(m.PK) == 0 {
+		m.PK = defaultPK(m)
+	}
+	registryLock.Lock()
+	registry[modelType] = m
+	registryLock.Unlock()
+	return nil
+}
+
+// Unregister removes the struct of model from the registry.
+func Unregister(model interface{}) {
+	modelType := structType(typeOf(model))
+	registryLock.Lock()
+	delete(registry, modelType)
+	registryLock.Unlock()
+}
+
+// Lookup returns the Model of v, which can be a registered struct, a pointer to one, a slice of
+// either, a pointer to such slice or their reflect.Type, so the destination of a query can be
+// passed as it is.
+func Lookup(v interface{}) (*Model, bool) {
+	modelType := structType(typeOf(v))
+	if modelType == nil {
+		return nil, false
+	}
+	registryLock.RLock()
+	m, ok := registry[modelType]
+	registryLock.RUnlock()
+	return m, ok
+}
+
+// TableOf returns the table v, as accepted by Lookup, is stored in.
+func TableOf(v interface{}) (string, error) {
+	m, ok := Lookup(v)
+	if !ok {
+		return "", errors.Errorf("%T is not a registered model", v)
+	}
+	return m.Table, nil
+}
+
+// PrimaryKey returns the primary key columns of v, as accepted by Lookup, those it was
+// registered with or, if it is not registered, those of the fields with the pk sub tag or the id
+// column if none has it.
+func PrimaryKey(v interface{}) []string {
+	if m, ok := Lookup(v); ok {
+		return m.PK
+	}
+	modelType := structType(typeOf(v))
+	if modelType == nil {
+		return nil
+	}
+	return defaultPK(&Model{Type: modelType})
+}
+
+// PrimaryKeyValues returns the values of the primary key columns of record, a pointer to a
+// struct, in the order of PrimaryKey.
+func PrimaryKeyValues(record interface{}) ([]interface{}, error) {
+	value := reflect.ValueOf(record)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return nil, errors.Errorf("expected a pointer to a struct got %T", record)
+	}
+	value = value.Elem()
+	fields := map[string]string{}
+	for _, field := range ColumnFields(value.Type()) {
+		fields[srm.ColumnName(field)] = field.Name
+	}
+	pk := PrimaryKey(record)
+	if len(pk) == 0 {
+		return nil, errors.Errorf("%T has no primary key", record)
+	}
+	values := make([]interface{}, len(pk))
+	for i, column := range pk {
+		name, ok := fields[column]
+		if !ok {
+			return nil, errors.Errorf("%T has no field for the primary key column %s", record, column)
+		}
+		values[i] = value.FieldByName(name).Interface()
+	}
+	return values, nil
+}
+
+// TaggedFields returns the fields of v, as accepted by Lookup, that map to columns and have
+// the flag subTag, ie srm.SubTagNameAutoUpdate.
+func TaggedFields(v interface{}, subTag string) []reflect.StructField {
+	modelType := structType(typeOf(v))
+	if modelType == nil {
+		return nil
+	}
+	var fields []reflect.StructField
+	for _, field := range ColumnFields(modelType) {
+		if _, ok := srm.TagOptions(field)[subTag]; ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func typeOf(v interface{}) reflect.Type {
+	if t, ok := v.(reflect.Type); ok {
+		return t
+	}
+	return reflect.TypeOf(v)
+}
+
+// structType returns the struct type under pointers and slices, nil if there is none.
+func structType(t reflect.Type) reflect.Type {
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+func defaultPK(m *Model) []string {
+	var pk []string
+	hasID := false
+	for _, field := range srm.OrderedFields(m.Type) {
+		if _, ok := m.Relations[field.Name]; ok {
+			continue
+		}
+		column := srm.ColumnName(field)
+		if _, ok := srm.TagOptions(field)[srm.SubTagNamePrimaryKey]; ok {
+			pk = append(pk, column)
+		}
+		hasID = hasID || column == "id"
+	}
+	if len(pk) == 0 && hasID {
+		pk = []string{"id"}
+	}
+	return pk
+}
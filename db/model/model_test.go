@@ -0,0 +1,121 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+type user struct {
+	ID   int `gaum:"field_name:id"`
+	Name string
+}
+
+type membership struct {
+	UserID  int `gaum:"field_name:user_id;pk"`
+	GroupID int `gaum:"field_name:group_id;pk"`
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(&user{}, Table("users")); err != nil {
+		t.Fatal(err)
+	}
+	defer Unregister(&user{})
+	if err := Register(membership{}, Table("memberships")); err != nil {
+		t.Fatal(err)
+	}
+	defer Unregister(membership{})
+
+	for _, v := range []interface{}{user{}, &user{}, []user{}, &[]*user{}, reflect.TypeOf(user{})} {
+		m, ok := Lookup(v)
+		if !ok {
+			t.Fatalf("expected %T to be found", v)
+		}
+		if m.Table != "users" {
+			t.Errorf("expected users got %q", m.Table)
+		}
+		if diff := deep.Equal(m.PK, []string{"id"}); diff != nil {
+			t.Error(diff)
+		}
+	}
+	m, _ := Lookup(&membership{})
+	if diff := deep.Equal(m.PK, []string{"user_id", "group_id"}); diff != nil {
+		t.Error(diff)
+	}
+
+	values, err := PrimaryKeyValues(&membership{UserID: 1, GroupID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(values, []interface{}{1, 2}); diff != nil {
+		t.Error(diff)
+	}
+	if _, err := PrimaryKeyValues(user{}); err == nil {
+		t.Errorf("expected an error for primary key values of a struct that is not a pointer")
+	}
+
+	if table, err := TableOf(&[]user{}); err != nil || table != "users" {
+		t.Errorf("expected users got %q, %v", table, err)
+	}
+	if _, err := TableOf(1); err == nil {
+		t.Errorf("expected an error for an unregistered type")
+	}
+	if err := Register(&user{}); err == nil {
+		t.Errorf("expected an error registering without a table")
+	}
+	if err := Register(1, Table("ints")); err == nil {
+		t.Errorf("expected an error registering something that is not a struct")
+	}
+}
+
+type author struct {
+	ID    int     `gaum:"field_name:id"`
+	Books []*book `gaum:"has_many:author_id"`
+}
+
+type book struct {
+	ID       int `gaum:"field_name:id"`
+	AuthorID int `gaum:"field_name:author_id"`
+	Author   author
+}
+
+func TestRelations(t *testing.T) {
+	if err := Register(&author{}, Table("authors")); err != nil {
+		t.Fatal(err)
+	}
+	defer Unregister(&author{})
+	if err := Register(&book{}, Table("books"), BelongsTo("Author", "author_id"), References("Author", "id")); err != nil {
+		t.Fatal(err)
+	}
+	defer Unregister(&book{})
+
+	m, _ := Lookup(&author{})
+	expected := &Relation{Kind: RelationHasMany, Field: "Books", ForeignKey: "author_id", Target: reflect.TypeOf(book{})}
+	if diff := deep.Equal(m.Relations["Books"], expected); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(m.PK, []string{"id"}); diff != nil {
+		t.Error(diff)
+	}
+	m, _ = Lookup(&book{})
+	expected = &Relation{Kind: RelationBelongsTo, Field: "Author", ForeignKey: "author_id", References: "id",
+		Target: reflect.TypeOf(author{})}
+	if diff := deep.Equal(m.Relations["Author"], expected); diff != nil {
+		t.Error(diff)
+	}
+	var columns []string
+	for _, field := range ColumnFields(reflect.TypeOf(book{})) {
+		columns = append(columns, field.Name)
+	}
+	if diff := deep.Equal(columns, []string{"ID", "AuthorID"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := Register(&book{}, Table("books"), HasMany("Author", "author_id")); err == nil {
+		t.Errorf("expected an error for a has many relation that is not a slice")
+	}
+	if err := Register(&book{}, Table("books"), BelongsTo("Publisher", "publisher_id")); err == nil {
+		t.Errorf("expected an error for a relation without a field")
+	}
+}
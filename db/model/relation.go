@@ -0,0 +1,149 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package model
+
+import (
+	"reflect"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+	"github.com/pkg/errors"
+)
+
+// RelationKind is the kind of a Relation.
+type RelationKind int
+
+const (
+	// RelationHasMany is a relation where the rows of another table reference this one, its
+	// field is a slice of the related struct, or of pointers to it.
+	RelationHasMany RelationKind = iota
+	// RelationBelongsTo is a relation where this row references one of another table, its field
+	// is the related struct or a pointer to it.
+	RelationBelongsTo
+)
+
+// Relation describes how a field of a model is loaded from another table, the related struct
+// must be registered too.
+type Relation struct {
+	Kind RelationKind
+	// Field is the name of the struct field that holds the related rows.
+	Field string
+	// ForeignKey is the referencing column, in the related table for RelationHasMany and in
+	// this one for RelationBelongsTo.
+	ForeignKey string
+	// References is the referenced column, by default the first column of the primary key of
+	// the referenced model.
+	References string
+	// Target is the struct type of the related rows.
+	Target reflect.Type
+}
+
+// HasMany declares that the slice field holds the rows of another model whose foreignKey column
+// references the primary key of this one, same as the has_many sub tag, ie
+// HasMany("Orders", "user_id") or
+//
+//	Orders []Order `gaum:"has_many:user_id"`
+func HasMany(field, foreignKey string) Option {
+	return func(m *Model) {
+		m.addRelation(&Relation{Kind: RelationHasMany, Field: field, ForeignKey: foreignKey})
+	}
+}
+
+// BelongsTo declares that field holds the row of another model referenced by the foreignKey
+// column of this one, same as the belongs_to sub tag, ie BelongsTo("User", "user_id") or
+//
+//	User *User `gaum:"belongs_to:user_id"`
+func BelongsTo(field, foreignKey string) Option {
+	return func(m *Model) {
+		m.addRelation(&Relation{Kind: RelationBelongsTo, Field: field, ForeignKey: foreignKey})
+	}
+}
+
+// References sets the referenced column of the relation in field, by default the first column
+// of the primary key of the referenced model.
+func References(field, column string) Option {
+	return func(m *Model) {
+		m.references[field] = column
+	}
+}
+
+func (m *Model) addRelation(relation *Relation) {
+	if m.Relations == nil {
+		m.Relations = map[string]*Relation{}
+	}
+	m.Relations[relation.Field] = relation
+}
+
+// resolveRelations adds the relations declared with sub tags and checks all of them.
+func (m *Model) resolveRelations() error {
+	for _, field := range srm.OrderedFields(m.Type) {
+		if _, ok := m.Relations[field.Name]; ok {
+			continue
+		}
+		options := srm.TagOptions(field)
+		if foreignKey, ok := options[srm.SubTagNameHasMany]; ok {
+			m.addRelation(&Relation{Kind: RelationHasMany, Field: field.Name, ForeignKey: foreignKey})
+		} else if foreignKey, ok := options[srm.SubTagNameBelongsTo]; ok {
+			m.addRelation(&Relation{Kind: RelationBelongsTo, Field: field.Name, ForeignKey: foreignKey})
+		}
+	}
+	for name, relation := range m.Relations {
+		field, ok := m.Type.FieldByName(name)
+		if !ok {
+			return errors.Errorf("model %s has no field %s for a relation", m.Type, name)
+		}
+		if relation.ForeignKey == "" {
+			return errors.Errorf("relation %s of model %s needs a foreign key", name, m.Type)
+		}
+		fieldType := field.Type
+		if relation.Kind == RelationHasMany {
+			if fieldType.Kind() != reflect.Slice {
+				return errors.Errorf("has many relation %s of model %s must be a slice", name, m.Type)
+			}
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() != reflect.Struct {
+			return errors.Errorf("relation %s of model %s must hold structs", name, m.Type)
+		}
+		relation.Target = fieldType
+		relation.References = m.references[name]
+	}
+	return nil
+}
+
+// ColumnFields returns the fields of the struct type t, as srm.OrderedFields does, that map to
+// columns, that is, without the fields of relations.
+func ColumnFields(t reflect.Type) []reflect.StructField {
+	m, registered := Lookup(t)
+	var fields []reflect.StructField
+	for _, field := range srm.OrderedFields(t) {
+		if registered {
+			if _, ok := m.Relations[field.Name]; ok {
+				continue
+			}
+		}
+		options := srm.TagOptions(field)
+		if _, ok := options[srm.SubTagNameHasMany]; ok {
+			continue
+		}
+		if _, ok := options[srm.SubTagNameBelongsTo]; ok {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
@@ -16,16 +16,19 @@ package postgres
 
 import (
 	"context"
-	"database/sql"
 	"log"
+	"math"
 	"os"
 	"reflect"
+	"time"
 
 	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
 	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/internal/pgxdriver"
 	"github.com/ShiftLeftSecurity/gaum/v2/db/logging"
 	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/pkg/errors"
@@ -33,9 +36,12 @@ import (
 
 var _ connection.DatabaseHandler = &Connector{}
 var _ connection.DB = &DB{}
+var _ connection.TransactionRetrier = &DB{}
 
 // Connector implements connection.Handler
 type Connector struct {
+	// ConnectionString is passed to the driver, if empty one will be built from
+	// the Information passed to Open.
 	ConnectionString string
 }
 
@@ -45,17 +51,23 @@ const DefaultPGPoolMaxConn = 10
 // Open opens a connection to postgres and returns it wrapped into a connection.DB
 func (c *Connector) Open(ctx context.Context, ci *connection.Information) (connection.DB, error) {
 	// I'll be opinionated here and use the most efficient params.
-	config, err := pgxpool.ParseConfig(c.ConnectionString)
+	// the structural fields of Information are only used if no connection string was provided.
+	connString := c.ConnectionString
+	if connString == "" && ci != nil {
+		connString = ci.DSN()
+	}
+	config, err := pgxpool.ParseConfig(connString)
 	if err != nil {
 		return nil, errors.Wrap(err, "parsing connection string")
 	}
 
 	var conLogger logging.Logger
+	var pgxLogger *logging.PgxLogAdapter
 	cc := config.ConnConfig
 	if ci != nil {
-		llevel, llevelErr := pgx.LogLevelFromString(string(ci.LogLevel))
+		llevel, llevelErr := logging.ParseLevel(string(ci.LogLevel))
 		if llevelErr != nil {
-			llevel = pgx.LogLevelError
+			llevel = logging.LevelError
 		}
 		if ci.Database != "" {
 			cc.Database = ci.Database
@@ -66,57 +78,246 @@ func (c *Connector) Open(ctx context.Context, ci *connection.Information) (conne
 		if ci.Password != "" {
 			cc.Password = ci.Password
 		}
-		cc.Logger = logging.NewPgxLogAdapter(ci.Logger)
+		pgxLogger = logging.NewPgxLogAdapter(ci.Logger).WithRedaction(ci.LogRedaction).WithLevel(llevel)
+		cc.Logger = pgxLogger
 		conLogger = ci.Logger
-		cc.LogLevel = llevel
+		// the adapter filters by level.
+		cc.LogLevel = pgx.LogLevelTrace
 		if ci.MaxConnPoolConns > 0 {
 			config.MaxConns = int32(ci.MaxConnPoolConns)
 		}
 		if ci.CustomDial != nil {
 			cc.DialFunc = ci.CustomDial
 		}
+		cc.PreferSimpleProtocol = ci.PreferSimpleProtocol
+		if len(ci.RuntimeParams) > 0 && cc.RuntimeParams == nil {
+			cc.RuntimeParams = make(map[string]string, len(ci.RuntimeParams))
+		}
+		for k, v := range ci.RuntimeParams {
+			cc.RuntimeParams[k] = v
+		}
+		if ci.StatementCache != nil {
+			buildStatementCache, err := pgxdriver.StatementCacheBuilder(ci.StatementCache)
+			if err != nil {
+				return nil, errors.Wrap(err, "configuring statement cache")
+			}
+			cc.BuildStatementCache = buildStatementCache
+		}
+		if ci.PgBouncer {
+			if err := connection.ValidatePgBouncerParams(ci.RuntimeParams); err != nil {
+				return nil, err
+			}
+			cc.PreferSimpleProtocol = true
+			cc.BuildStatementCache = nil
+		}
 		if ci.ConnMaxLifetime != nil {
 			config.MaxConnLifetime = *ci.ConnMaxLifetime
 		}
 	} else {
 		defaultLogger := log.New(os.Stdout, "logger: ", log.Lshortfile)
-		cc.Logger = logging.NewPgxLogAdapter(logging.NewGoLogger(defaultLogger))
+		// pgx defaults to info.
+		pgxLogger = logging.NewPgxLogAdapter(logging.NewGoLogger(defaultLogger)).WithLevel(logging.LevelInfo)
+		cc.Logger = pgxLogger
+		cc.LogLevel = pgx.LogLevelTrace
 		conLogger = logging.NewGoLogger(defaultLogger)
 		config.MaxConns = DefaultPGPoolMaxConn
 	}
 
-	conn, err := pgxpool.ConnectConfig(ctx, config)
+	var retry *connection.Backoff
+	if ci != nil {
+		retry = ci.ConnectRetry
+	}
+	var conn *pgxpool.Pool
+	err = retry.Retry(ctx, nil, func() error {
+		var connErr error
+		conn, connErr = pgxpool.ConnectConfig(ctx, config.Copy())
+		if connErr != nil && conLogger != nil {
+			conLogger.Warn("could not connect to postgres database", "error", connErr)
+		}
+		return connErr
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "connecting to postgres database")
 	}
+	if ci != nil && ci.WarmUpConns > 0 {
+		warmUp := ci.WarmUpConns
+		if warmUp > int(config.MaxConns) {
+			// more would wait forever for a connection to be released.
+			warmUp = int(config.MaxConns)
+		}
+		err = connection.WarmUp(ctx, warmUp, func(ctx context.Context) (func(), error) {
+			pooled, err := conn.Acquire(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if ci.WarmUpQuery != "" {
+				if _, err := pooled.Exec(ctx, ci.WarmUpQuery); err != nil {
+					pooled.Release()
+					return nil, errors.Wrap(err, "running warm up query")
+				}
+			}
+			return pooled.Release, nil
+		})
+		if err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "warming up the connection pool")
+		}
+	}
 
+	var txRetry *connection.Backoff
+	var leaks *connection.LeakDetector
+	var metrics connection.Metrics
+	var slow *connection.SlowQueryLog
+	if ci != nil {
+		txRetry = ci.TransactionRetry
+		leaks = connection.NewLeakDetector(ci.LeakDetection, conLogger)
+		metrics = ci.Metrics
+		slow = connection.NewSlowQueryLog(ci.SlowQueryThreshold, conLogger)
+	}
 	return &DB{
-		conn:   conn,
-		logger: conLogger,
+		conn:           conn,
+		logger:         conLogger,
+		pgxLogger:      pgxLogger,
+		retryReads:     ci != nil && ci.RetryReads,
+		txRetry:        txRetry,
+		leaks:          leaks,
+		metrics:        metrics,
+		slow:           slow,
+		attachQuery:    ci != nil && ci.AttachQueryToErrors,
+		slicesAsArrays: ci != nil && ci.SlicesAsArrays,
+		queryNoRows:    ci != nil && ci.QueryNoRows,
 	}, nil
 }
 
 // DB wraps pgx.Conn into a struct that implements connection.DB
 type DB struct {
-	conn   *pgxpool.Pool
-	tx     pgx.Tx
-	logger logging.Logger
+	conn       *pgxpool.Pool
+	tx         pgx.Tx
+	logger     logging.Logger
+	pgxLogger  *logging.PgxLogAdapter
+	retryReads bool
+	txRetry    *connection.Backoff
+	leaks      *connection.LeakDetector
+	metrics    connection.Metrics
+	slow       *connection.SlowQueryLog
+	// attachQuery makes errors be *errors.QueryError, see Information.AttachQueryToErrors.
+	attachQuery bool
+	// slicesAsArrays is Information.SlicesAsArrays.
+	slicesAsArrays bool
+	// queryNoRows is Information.QueryNoRows.
+	queryNoRows bool
+	// txDone stops tracking the transaction for leaks, nil for nested ones.
+	txDone func()
+	// savepoint is true for nested transactions.
+	savepoint bool
 }
 
 // Clone returns a copy of DB with the same underlying Connection
 func (d *DB) Clone() connection.DB {
 	return &DB{
-		conn:   d.conn,
-		logger: d.logger,
+		conn:           d.conn,
+		logger:         d.logger,
+		pgxLogger:      d.pgxLogger,
+		retryReads:     d.retryReads,
+		txRetry:        d.txRetry,
+		leaks:          d.leaks,
+		metrics:        d.metrics,
+		slow:           d.slow,
+		attachQuery:    d.attachQuery,
+		slicesAsArrays: d.slicesAsArrays,
+		queryNoRows:    d.queryNoRows,
 	}
 }
 
 // Close closes the underlying connection, beware, this makes the DB useless.
 func (d *DB) Close() error {
+	if d.tx != nil {
+		return errors.New("cannot close a transaction, commit or roll it back instead")
+	}
 	d.conn.Close()
 	return nil
 }
 
+// CloseIdle closes the connections of the pool that are not in use.
+func (d *DB) CloseIdle(ctx context.Context) error {
+	d.refresh(ctx)
+	return nil
+}
+
+// SetLogLevel changes the level of the query logs, for all the DBs sharing the connection.
+func (d *DB) SetLogLevel(level connection.LogLevel) error {
+	llevel, err := logging.ParseLevel(string(level))
+	if err != nil {
+		return errors.Wrap(err, "setting log level")
+	}
+	d.pgxLogger.SetLevel(llevel)
+	return nil
+}
+
+// PoolStats implements connection.PoolStater
+func (d *DB) PoolStats() connection.PoolStats {
+	stat := d.conn.Stat()
+	return connection.PoolStats{
+		Max:   int64(stat.MaxConns()),
+		Open:  int64(stat.TotalConns()),
+		InUse: int64(stat.AcquiredConns()),
+		Idle:  int64(stat.IdleConns()),
+	}
+}
+
+// refresh destroys all the idle connections of the pool so new ones are established, after
+// a failover the pool can be holding connections to a server that is no longer the primary
+// and new connections will find the new one (see Information.TargetSessionAttrs).
+func (d *DB) refresh(ctx context.Context) {
+	if d.conn == nil {
+		return
+	}
+	for _, c := range d.conn.AcquireAllIdle(ctx) {
+		c.Conn().Close(ctx)
+		c.Release()
+	}
+}
+
+// checkConnection refreshes the pool if err indicates that the connection broke or that the
+// server is no longer the primary, if this happened in a transaction its connection is closed
+// too, as it can't be recovered.
+func (d *DB) checkConnection(err error) {
+	if !gaumErrors.IsFailover(err) && !gaumErrors.IsConnectionError(err) {
+		return
+	}
+	ctx := context.Background()
+	if d.tx != nil {
+		d.tx.Conn().Close(ctx)
+	}
+	d.refresh(ctx)
+}
+
+// canRetry returns true if statement, that failed with err, can be run again in a fresh
+// connection.
+func (d *DB) canRetry(statement string, err error) bool {
+	return d.retryReads && d.tx == nil &&
+		gaumErrors.IsConnectionError(err) && connection.IsReadOnly(statement)
+}
+
+// queryWithRetry runs the query with connQ and, if allowed, retries it once if the connection
+// broke.
+func (d *DB) queryWithRetry(ctx context.Context,
+	connQ func(context.Context, string, ...interface{}) (pgx.Rows, error),
+	statement string, args ...interface{}) (pgx.Rows, error) {
+	start := time.Now()
+	defer d.slow.Check(statement, len(args), start)
+	args = pgxdriver.ProtocolArgs(ctx, args)
+	rows, err := connQ(ctx, statement, args...)
+	if err != nil {
+		d.checkConnection(err)
+		if d.canRetry(statement, err) {
+			d.logger.Warn("connection broke while querying, retrying", "error", err)
+			rows, err = connQ(ctx, statement, args...)
+		}
+	}
+	return rows, err
+}
+
 // EQueryIter Calls EscapeArgs before invoking QueryIter
 func (d *DB) EQueryIter(ctx context.Context, statement string, fields []string, args ...interface{}) (connection.ResultFetchIter, error) {
 	s, a, err := connection.EscapeArgs(statement, args)
@@ -131,6 +332,7 @@ func (d *DB) EQueryIter(ctx context.Context, statement string, fields []string,
 // the passed fields are supposed to correspond to the fields being brought from the db, no
 // check is performed on this.
 func (d *DB) QueryIter(ctx context.Context, statement string, fields []string, args ...interface{}) (connection.ResultFetchIter, error) {
+	start := time.Now()
 	var rows pgx.Rows
 	var err error
 	var connQ func(context.Context, string, ...interface{}) (pgx.Rows, error)
@@ -142,21 +344,27 @@ func (d *DB) QueryIter(ctx context.Context, statement string, fields []string, a
 		return nil, gaumErrors.NoDB
 	}
 
-	if len(args) != 0 {
-		rows, err = connQ(ctx, statement, args...)
-	} else {
-		rows, err = connQ(ctx, statement)
-	}
+	rows, err = d.queryWithRetry(ctx, connQ, statement, args...)
 	if err != nil {
+		connection.ObserveQuery(ctx, d.metrics, "query_iter", start, 0, err)
 		return func(interface{}) (bool, func(), error) { return false, func() {}, nil },
-			errors.Wrap(err, "querying database")
+			d.queryErr(ctx, "query_iter", statement, len(args), start, errors.Wrap(err, "querying database"))
 	}
 
 	var fieldMap map[string]reflect.StructField
 	var typeName string
+	// the recipients are rebuilt only if the type of the destination changes.
+	var recipients *srm.Recipients
 	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			d.checkConnection(err)
+			connection.ObserveQuery(ctx, d.metrics, "query_iter", start, 0, err)
+			return func(interface{}) (bool, func(), error) { return false, func() {}, nil },
+				d.queryErr(ctx, "query_iter", statement, len(args), start, errors.Wrap(err, "querying database"))
+		}
+		connection.ObserveQuery(ctx, d.metrics, "query_iter", start, 0, nil)
 		return func(interface{}) (bool, func(), error) { return false, func() {}, nil },
-			sql.ErrNoRows
+			gaumErrors.ErrNoRows
 	}
 	if len(fields) == 0 || (len(fields) == 1 && fields[0] == "*") {
 		// This seems to make a query each time so perhaps it goes outside.
@@ -166,20 +374,35 @@ func (d *DB) QueryIter(ctx context.Context, statement string, fields []string, a
 			fields[i] = string(v.Name)
 		}
 	}
-	return func(destination interface{}) (bool, func(), error) {
+	return d.wrapIter(ctx, "query_iter", statement, len(args), start, func(destination interface{}) (bool, func(), error) {
+		if err := ctx.Err(); err != nil {
+			rows.Close()
+			return false, func() {}, errors.Wrap(err, "fetching results, rows were closed")
+		}
+		if scannable, ok := destination.(srm.Scannable); ok {
+			if err := scannable.GaumScan(fields, rows.Scan); err != nil {
+				rows.Close()
+				return false, func() {}, errors.Wrap(err,
+					"scanning values into recipient, connection was closed")
+			}
+			return rows.Next(), rows.Close, rows.Err()
+		}
 		var err error
 		if reflect.TypeOf(destination).Elem().Name() != typeName {
 			typeName, fieldMap, err = srm.MapFromPtrType(destination, []reflect.Kind{}, []reflect.Kind{
 				reflect.Map, reflect.Slice,
 			})
+			recipients = nil
 			if err != nil {
 				defer rows.Close()
 				return false, func() {}, errors.Wrapf(err, "cant fetch data into %T", destination)
 			}
 		}
-		fieldRecipients := srm.FieldRecipientsFromType(d.logger, fields, fieldMap, destination)
+		if recipients == nil {
+			recipients = srm.NewRecipients(d.logger, fields, fieldMap, reflect.TypeOf(destination).Elem())
+		}
 
-		err = rows.Scan(fieldRecipients...)
+		err = rows.Scan(recipients.For(reflect.ValueOf(destination).Elem())...)
 		if err != nil {
 			defer rows.Close()
 			return false, func() {}, errors.Wrap(err,
@@ -187,7 +410,29 @@ func (d *DB) QueryIter(ctx context.Context, statement string, fields []string, a
 		}
 
 		return rows.Next(), rows.Close, rows.Err()
-	}, nil
+	}), nil
+}
+
+// wrapIter adds leak detection, metrics and query errors to the iterator of statement.
+func (d *DB) wrapIter(ctx context.Context, operation, statement string, argCount int, start time.Time,
+	iter connection.ResultFetchIter) connection.ResultFetchIter {
+	iter = connection.AttachQueryIter(ctx, d.attachQuery, operation, statement, argCount, start, iter)
+	return d.leaks.TrackIter(statement, connection.MeasureIter(ctx, d.metrics, operation, start, iter))
+}
+
+// wrapFetch adds leak detection, metrics and query errors to the fetch function of statement.
+func (d *DB) wrapFetch(ctx context.Context, operation, statement string, argCount int, start time.Time,
+	fetch connection.ResultFetch) connection.ResultFetch {
+	fetch = connection.NoRowsFetch(d.queryNoRows, fetch)
+	fetch = connection.AttachQueryFetch(ctx, d.attachQuery, operation, statement, argCount, start, fetch)
+	return d.leaks.TrackFetch(statement, connection.MeasureFetch(ctx, d.metrics, operation, start, fetch))
+}
+
+// queryErr translates err into an errors.DBError, if it came from the server, and attaches
+// the statement to it if configured to, see connection.Information.AttachQueryToErrors.
+func (d *DB) queryErr(ctx context.Context, operation, statement string, argCount int, start time.Time,
+	err error) error {
+	return connection.AttachQuery(ctx, d.attachQuery, operation, statement, argCount, start, gaumErrors.Translate(err))
 }
 
 // EQueryPrimitive calls EscapeArgs before invoking QueryPrimitive.
@@ -202,6 +447,7 @@ func (d *DB) EQueryPrimitive(ctx context.Context, statement string, field string
 // QueryPrimitive returns a function that allows recovering the results of the query but to a slice
 // of a primitive type, only allowed if the query fetches one field.
 func (d *DB) QueryPrimitive(ctx context.Context, statement string, _ string, args ...interface{}) (connection.ResultFetch, error) {
+	start := time.Now()
 	var rows pgx.Rows
 	var err error
 	var connQ func(context.Context, string, ...interface{}) (pgx.Rows, error)
@@ -213,16 +459,13 @@ func (d *DB) QueryPrimitive(ctx context.Context, statement string, _ string, arg
 		return nil, gaumErrors.NoDB
 	}
 
-	if len(args) != 0 {
-		rows, err = connQ(ctx, statement, args...)
-	} else {
-		rows, err = connQ(ctx, statement)
-	}
+	rows, err = d.queryWithRetry(ctx, connQ, statement, args...)
 	if err != nil {
+		connection.ObserveQuery(ctx, d.metrics, "query_primitive", start, 0, err)
 		return func(interface{}) error { return nil },
-			errors.Wrap(err, "querying database")
+			d.queryErr(ctx, "query_primitive", statement, len(args), start, errors.Wrap(err, "querying database"))
 	}
-	return func(destination interface{}) error {
+	return d.wrapFetch(ctx, "query_primitive", statement, len(args), start, func(destination interface{}) error {
 		if reflect.TypeOf(destination).Kind() != reflect.Ptr {
 			return errors.Errorf("the passed receiver is not a pointer, connection is still open")
 		}
@@ -238,6 +481,9 @@ func (d *DB) QueryPrimitive(ctx context.Context, statement string, _ string, arg
 		tod := reflect.TypeOf(destination).Elem().Elem()
 
 		for rows.Next() {
+			if err := ctx.Err(); err != nil {
+				return errors.Wrap(err, "fetching results, rows were closed")
+			}
 			// Get a New ptr to the object of the type of the slice.
 			newElemPtr := reflect.New(tod)
 
@@ -252,7 +498,7 @@ func (d *DB) QueryPrimitive(ctx context.Context, statement string, _ string, arg
 			destinationSlice.Set(reflect.Append(destinationSlice, newElemPtr.Elem()))
 		}
 		return rows.Err()
-	}, nil
+	}), nil
 }
 
 // EQuery calls EscapeArgs before invoking Query
@@ -267,32 +513,46 @@ func (d *DB) EQuery(ctx context.Context, statement string, fields []string, args
 // Query returns a function that allows recovering the results of the query, beware the connection
 // is held until the returned closure is invoked.
 func (d *DB) Query(ctx context.Context, statement string, fields []string, args ...interface{}) (connection.ResultFetch, error) {
+	start := time.Now()
 	var rows pgx.Rows
 	var err error
 	var connQ func(context.Context, string, ...interface{}) (pgx.Rows, error)
+	// parallel decoding needs the type information of the connection running the query, so
+	// one is taken from the pool for it and released once the rows are closed.
+	workers := connection.ParallelDecodingWorkers(ctx)
+	var connInfo *pgtype.ConnInfo
+	release := func() {}
 	if d.tx != nil {
 		connQ = d.tx.Query
+		connInfo = d.tx.Conn().ConnInfo()
+	} else if d.conn != nil && workers > 1 {
+		conn, err := d.conn.Acquire(ctx)
+		if err != nil {
+			connection.ObserveQuery(ctx, d.metrics, "query", start, 0, err)
+			return func(interface{}) error { return nil },
+				d.queryErr(ctx, "query", statement, len(args), start, errors.Wrap(err, "acquiring connection"))
+		}
+		connQ, connInfo, release = conn.Query, conn.Conn().ConnInfo(), conn.Release
 	} else if d.conn != nil {
 		connQ = d.conn.Query
 	} else {
 		return nil, gaumErrors.NoDB
 	}
-	if len(args) != 0 {
-		rows, err = connQ(ctx, statement, args...)
-	} else {
-		rows, err = connQ(ctx, statement)
-	}
+	rows, err = d.queryWithRetry(ctx, connQ, statement, args...)
 	if err != nil {
+		release()
+		connection.ObserveQuery(ctx, d.metrics, "query", start, 0, err)
 		return func(interface{}) error { return nil },
-			errors.Wrap(err, "querying database")
+			d.queryErr(ctx, "query", statement, len(args), start, errors.Wrap(err, "querying database"))
 	}
 	var fieldMap map[string]reflect.StructField
 
-	return func(destination interface{}) error {
+	return d.wrapFetch(ctx, "query", statement, len(args), start, func(destination interface{}) error {
 		if reflect.TypeOf(destination).Kind() != reflect.Ptr {
 			return errors.Errorf("the passed receiver is not a pointer, connection is still open")
 		}
 		// TODO add a timer that closes rows if nothing is done.
+		defer release()
 		defer rows.Close()
 		var err error
 		reflect.ValueOf(destination).Elem().Set(reflect.MakeSlice(reflect.TypeOf(destination).Elem(), 0, 0))
@@ -312,17 +572,39 @@ func (d *DB) Query(ctx context.Context, statement string, fields []string, args
 			}
 		}
 
+		elementType := tod
+		if elementType.Kind() == reflect.Ptr {
+			elementType = elementType.Elem()
+		}
+		scannable := srm.IsScannable(elementType)
+
+		if workers > 1 {
+			if !scannable {
+				_, fieldMap, err = srm.MapFromTypeOf(elementType,
+					[]reflect.Kind{}, []reflect.Kind{
+						reflect.Map, reflect.Slice,
+					})
+				if err != nil {
+					return errors.Wrapf(err, "cant fetch data into %T", destination)
+				}
+			}
+			return d.decodeParallel(ctx, rows, connInfo, workers, fields, fieldMap, destinationSlice)
+		}
+
+		// the recipients of the fields are the same for all the rows, bound to each of them.
+		var recipients *srm.Recipients
 		for rows.Next() {
+			if err := ctx.Err(); err != nil {
+				return errors.Wrap(err, "fetching results, rows were closed")
+			}
 			// Get a New ptr to the object of the type of the slice.
 			newElemPtr := reflect.New(tod)
 			// Get the concrete object
 			var newElem reflect.Value
-			var newElemType reflect.Type
 			if tod.Kind() == reflect.Ptr {
 				// Handle slice of pointer
 				intermediatePtr := newElemPtr.Elem()
 				concrete := tod.Elem()
-				newElemType = concrete
 				// this will most likely always be the case, but let's be defensive
 				if intermediatePtr.IsNil() {
 					concreteInstancePtr := reflect.New(concrete)
@@ -330,28 +612,28 @@ func (d *DB) Query(ctx context.Context, statement string, fields []string, args
 				}
 				newElem = intermediatePtr.Elem()
 			} else {
-				newElemType = newElemPtr.Elem().Type()
 				newElem = newElemPtr.Elem()
 			}
-			// Get its type.
-			ttod := newElem.Type()
-
-			// map the fields of the type to their potential sql names, this is the only "magic"
-			fieldMap = make(map[string]reflect.StructField, ttod.NumField())
-			_, fieldMap, err = srm.MapFromTypeOf(newElemType,
-				[]reflect.Kind{}, []reflect.Kind{
-					reflect.Map, reflect.Slice,
-				})
-			if err != nil {
-				rows.Close()
-				return errors.Wrapf(err, "cant fetch data into %T", destination)
-			}
-
-			// Construct the recipient fields.
-			fieldRecipients := srm.FieldRecipientsFromValueOf(d.logger, fields, fieldMap, newElem)
+			if scannable {
+				// the type knows how to scan itself, no need for the "magic".
+				err = srm.ScanValueOf(newElem, fields, rows.Scan)
+			} else {
+				if recipients == nil {
+					// map the fields of the type to their potential sql names, this is the only "magic"
+					_, fieldMap, err = srm.MapFromTypeOf(elementType,
+						[]reflect.Kind{}, []reflect.Kind{
+							reflect.Map, reflect.Slice,
+						})
+					if err != nil {
+						rows.Close()
+						return errors.Wrapf(err, "cant fetch data into %T", destination)
+					}
+					recipients = srm.NewRecipients(d.logger, fields, fieldMap, elementType)
+				}
 
-			// Try to fetch the data
-			err = rows.Scan(fieldRecipients...)
+				// Try to fetch the data
+				err = rows.Scan(recipients.For(newElem)...)
+			}
 			if err != nil {
 				rows.Close()
 				return errors.Wrap(err, "scanning values into recipient, connection was closed")
@@ -361,7 +643,13 @@ func (d *DB) Query(ctx context.Context, statement string, fields []string, args
 			destinationSlice.Set(reflect.Append(destinationSlice, newElemPtr.Elem()))
 		}
 		return rows.Err()
-	}, nil
+	}), nil
+}
+
+// Get runs the statement and scans its only resulting row into dst, a pointer to a struct, it
+// returns errors.ErrNoRows if there is none and errors.ErrTooManyRows if there are more.
+func (d *DB) Get(ctx context.Context, dst interface{}, statement string, args ...interface{}) error {
+	return connection.Get(ctx, d, dst, statement, args...)
 }
 
 // ERaw calls EscapeArgs before invoking Raw
@@ -376,6 +664,22 @@ func (d *DB) ERaw(ctx context.Context, statement string, args []interface{}, fie
 // Raw will run the passed statement with the passed args and scan the first result, if any,
 // to the passed fields.
 func (d *DB) Raw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error {
+	start := time.Now()
+	err := d.raw(ctx, statement, args, fields...)
+	d.slow.Check(statement, len(args), start)
+	switch err {
+	case nil:
+		connection.ObserveQuery(ctx, d.metrics, "raw", start, 1, nil)
+	case gaumErrors.ErrNoRows:
+		connection.ObserveQuery(ctx, d.metrics, "raw", start, 0, nil)
+	default:
+		connection.ObserveQuery(ctx, d.metrics, "raw", start, 0, err)
+	}
+	return d.queryErr(ctx, "raw", statement, len(args), start, err)
+}
+
+func (d *DB) raw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error {
+	args = pgxdriver.ProtocolArgs(ctx, args)
 	var rows pgx.Row
 
 	if d.tx != nil {
@@ -388,6 +692,13 @@ func (d *DB) Raw(ctx context.Context, statement string, args []interface{}, fiel
 
 	// Try to fetch the data
 	err := rows.Scan(fields...)
+	if err != nil && err != pgx.ErrNoRows {
+		d.checkConnection(err)
+		if d.canRetry(statement, err) {
+			d.logger.Warn("connection broke while querying, retrying", "error", err)
+			err = d.conn.QueryRow(ctx, statement, args...).Scan(fields...)
+		}
+	}
 	if err == pgx.ErrNoRows {
 		return gaumErrors.ErrNoRows
 	}
@@ -421,7 +732,65 @@ func (d *DB) ExecResult(ctx context.Context, statement string, args ...interface
 	return connTag.RowsAffected(), nil
 }
 
+// ExecInfo will run the statement and return its command tag.
+func (d *DB) ExecInfo(ctx context.Context, statement string, args ...interface{}) (connection.CommandTag, error) {
+	connTag, err := d.exec(ctx, statement, args...)
+	if err != nil {
+		return connection.CommandTag{}, err
+	}
+	return connection.ParseCommandTag(string(connTag)), nil
+}
+
+// ExecBatch sends all the statements to the server in a single round trip, they run in an
+// implicit transaction so if one fails none is applied.
+// Each statement is reported to the metrics, slow query log and errors as an exec, timed from
+// when the batch was sent.
+func (d *DB) ExecBatch(ctx context.Context, statements []connection.Statement) ([]connection.BatchResult, error) {
+	start := time.Now()
+	batch := &pgx.Batch{}
+	for _, s := range statements {
+		batch.Queue(s.SQL, pgxdriver.ProtocolArgs(ctx, s.Args)...)
+	}
+	var br pgx.BatchResults
+	if d.tx != nil {
+		br = d.tx.SendBatch(ctx, batch)
+	} else if d.conn != nil {
+		br = d.conn.SendBatch(ctx, batch)
+	} else {
+		return nil, gaumErrors.NoDB
+	}
+
+	results := make([]connection.BatchResult, len(statements))
+	var failure error
+	for i, s := range statements {
+		if failure != nil {
+			results[i].Err = gaumErrors.ErrBatchAborted
+			continue
+		}
+		connTag, err := br.Exec()
+		connection.ObserveQuery(ctx, d.metrics, "exec", start, connTag.RowsAffected(), err)
+		d.slow.Check(s.SQL, len(s.Args), start)
+		if err != nil {
+			d.checkConnection(err)
+			results[i].Err = d.queryErr(ctx, "exec", s.SQL, len(s.Args), start,
+				errors.Wrapf(err, "querying database, obtained %v", connTag))
+			failure = errors.Wrapf(results[i].Err, "running statement %d of the batch", i)
+			continue
+		}
+		results[i].RowsAffected = connTag.RowsAffected()
+	}
+	if err := br.Close(); err != nil && failure == nil {
+		d.checkConnection(err)
+		failure = errors.Wrap(err, "closing batch")
+	}
+	return results, failure
+}
+
 func (d *DB) exec(ctx context.Context, statement string, args ...interface{}) (pgconn.CommandTag, error) {
+	start := time.Now()
+	defer d.slow.Check(statement, len(args), start)
+	argCount := len(args)
+	args = pgxdriver.ProtocolArgs(ctx, args)
 	var connTag pgconn.CommandTag
 	var err error
 
@@ -433,59 +802,149 @@ func (d *DB) exec(ctx context.Context, statement string, args ...interface{}) (p
 		return connTag, gaumErrors.NoDB
 	}
 
+	connection.ObserveQuery(ctx, d.metrics, "exec", start, connTag.RowsAffected(), err)
 	if err != nil {
-		return connTag, errors.Wrapf(err, "querying database, obtained %v", connTag)
+		d.checkConnection(err)
+		return connTag, d.queryErr(ctx, "exec", statement, argCount, start,
+			errors.Wrapf(err, "querying database, obtained %v", connTag))
 	}
 	return connTag, nil
 }
 
 // BeginTransaction returns a new DB that will use the transaction instead of the basic conn.
-// if the transaction is already started the same will be returned.
+// if the transaction is already started a savepoint is created and the returned DB will
+// release it on commit and roll back to it on rollback.
 func (d *DB) BeginTransaction(ctx context.Context) (connection.DB, error) {
 	if d.tx != nil {
-		return nil, gaumErrors.AlreadyInTX
+		savepoint, err := d.tx.Begin(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "trying to create a savepoint")
+		}
+		return &DB{
+			conn:           d.conn,
+			tx:             savepoint,
+			logger:         d.logger,
+			pgxLogger:      d.pgxLogger,
+			leaks:          d.leaks,
+			metrics:        d.metrics,
+			slow:           d.slow,
+			attachQuery:    d.attachQuery,
+			slicesAsArrays: d.slicesAsArrays,
+			queryNoRows:    d.queryNoRows,
+			savepoint:      true,
+		}, nil
 	}
 	tx, err := d.conn.Begin(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "trying to begin a transaction")
 	}
 	return &DB{
-		tx:     tx,
-		logger: d.logger,
+		conn:           d.conn,
+		tx:             tx,
+		logger:         d.logger,
+		pgxLogger:      d.pgxLogger,
+		leaks:          d.leaks,
+		metrics:        d.metrics,
+		slow:           d.slow,
+		attachQuery:    d.attachQuery,
+		slicesAsArrays: d.slicesAsArrays,
+		queryNoRows:    d.queryNoRows,
+		txDone:         d.leaks.Track("transaction", ""),
 	}, nil
 }
 
+// txFinished stops tracking the transaction for leaks.
+func (d *DB) txFinished() {
+	if d.txDone != nil {
+		d.txDone()
+	}
+}
+
+// TransactionRetry implements connection.TransactionRetrier, it returns the policy configured
+// in Information.TransactionRetry.
+func (d *DB) TransactionRetry() *connection.Backoff {
+	return d.txRetry
+}
+
+// SlicesAsArrays implements connection.SliceArrayBinder, it returns true if configured so in
+// Information.SlicesAsArrays.
+func (d *DB) SlicesAsArrays() bool {
+	return d.slicesAsArrays
+}
+
 // IsTransaction indicates if the DB is in the middle of a transaction.
 func (d *DB) IsTransaction() bool {
 	return d.tx != nil
 }
 
 // CommitTransaction commits the transaction if any is in course, behavior comes straight from
-// pgx.
+// pgx, if this is a nested transaction the savepoint is released.
 func (d *DB) CommitTransaction(ctx context.Context) error {
 	if d.tx == nil {
 		return gaumErrors.NoTX
 	}
-
+	defer d.txFinished()
 	return d.tx.Commit(ctx)
 }
 
 // RollbackTransaction rolls back the transaction if any is in course, behavior comes straight from
-// pgx.
+// pgx, if this is a nested transaction it rolls back to the savepoint.
 func (d *DB) RollbackTransaction(ctx context.Context) error {
 	if d.tx == nil {
 		return gaumErrors.NoTX
 	}
+	defer d.txFinished()
 	return d.tx.Rollback(ctx)
 }
 
+// PrepareTransaction prepares the transaction for a two-phase commit identified by gid, which
+// must be later committed or rolled back with CommitPrepared or RollbackPrepared, even from
+// another session. The connection is returned to the pool so this DB can't be used anymore.
+func (d *DB) PrepareTransaction(ctx context.Context, gid string) error {
+	if d.tx == nil {
+		return gaumErrors.NoTX
+	}
+	if d.savepoint {
+		return errors.New("cannot prepare a nested transaction, only the outermost one")
+	}
+	if _, err := d.tx.Exec(ctx, "PREPARE TRANSACTION "+connection.QuoteLiteral(gid)); err != nil {
+		return errors.Wrapf(err, "preparing transaction %q", gid)
+	}
+	// the session is no longer in a transaction, this just releases the connection.
+	defer d.txFinished()
+	if err := d.tx.Commit(ctx); err != nil {
+		return errors.Wrap(err, "releasing the connection of the prepared transaction")
+	}
+	return nil
+}
+
+// CommitPrepared commits the transaction prepared with gid, possibly by another session.
+func (d *DB) CommitPrepared(ctx context.Context, gid string) error {
+	return d.finishPrepared(ctx, "COMMIT PREPARED", gid)
+}
+
+// RollbackPrepared rolls back the transaction prepared with gid, possibly by another session.
+func (d *DB) RollbackPrepared(ctx context.Context, gid string) error {
+	return d.finishPrepared(ctx, "ROLLBACK PREPARED", gid)
+}
+
+func (d *DB) finishPrepared(ctx context.Context, command, gid string) error {
+	if d.tx != nil {
+		return errors.Errorf("%s cannot run in a transaction", command)
+	}
+	if _, err := d.exec(ctx, command+" "+connection.QuoteLiteral(gid)); err != nil {
+		return errors.Wrapf(err, "finishing prepared transaction %q", gid)
+	}
+	return nil
+}
+
 // Set tries to run `SET LOCAL` with the passed parameters if there is an ongoing transaction.
+// set is not escaped in any way so, unless it is a constant, use SetParam instead.
 // https://www.postgresql.org/docs/9.2/static/sql-set.html
 func (d *DB) Set(ctx context.Context, set string) error {
 	if d.tx == nil {
 		return gaumErrors.NoTX
 	}
-	// TODO check if this will work in the `SET LOCAL $1` arg format
 	cTag, err := d.tx.Exec(ctx, "SET LOCAL "+set)
 	if err != nil {
 		return errors.Wrapf(err, "trying to set local, returned: %s", cTag)
@@ -493,10 +952,33 @@ func (d *DB) Set(ctx context.Context, set string) error {
 	return nil
 }
 
+// SetParam sets the configuration parameter name to value for the ongoing transaction, as
+// `SET LOCAL` would, but passing value as an argument so it needs no escaping.
+func (d *DB) SetParam(ctx context.Context, name, value string) error {
+	if d.tx == nil {
+		return gaumErrors.NoTX
+	}
+	if err := connection.ValidateParamName(name); err != nil {
+		return err
+	}
+	if _, err := d.tx.Exec(ctx, "SELECT set_config($1, $2, true)", name, value); err != nil {
+		return errors.Wrapf(err, "setting %s", name)
+	}
+	return nil
+}
+
 // BulkInsert will use postgres copy function to try to insert a lot of data.
 // You might need to use pgx types for the values to reduce probability of failure.
 // https://godoc.org/github.com/jackc/pgx#Conn.CopyFrom
 func (d *DB) BulkInsert(ctx context.Context, tableName string, columns []string, values [][]interface{}) (execError error) {
+	start := time.Now()
+	defer func() {
+		rows := int64(len(values))
+		if execError != nil {
+			rows = 0
+		}
+		connection.ObserveQuery(ctx, d.metrics, "bulk_insert", start, rows, execError)
+	}()
 	tx := d.tx
 	if d.tx == nil {
 		var err error
@@ -527,3 +1009,195 @@ func (d *DB) BulkInsert(ctx context.Context, tableName string, columns []string,
 	}
 	return nil
 }
+
+// BulkInsertReturning inserts values into tableName and fetches the returning columns of the
+// inserted rows into dst, big sets are copied into a temporary table first.
+// See connection.BulkInsertReturning.
+func (d *DB) BulkInsertReturning(ctx context.Context, tableName string, columns []string, values [][]interface{},
+	returning []string, dst interface{}) error {
+	return connection.BulkInsertReturning(ctx, d, tableName, columns, values, returning, dst)
+}
+
+// BulkUpsert copies values into a temporary table and then upserts them into tableName.
+// See connection.BulkUpsert.
+func (d *DB) BulkUpsert(ctx context.Context, tableName string, columns, conflictTarget, updateCols []string,
+	values [][]interface{}) error {
+	return connection.BulkUpsert(ctx, d, tableName, columns, conflictTarget, updateCols, values)
+}
+
+// BulkUpdate updates the setCols of the rows of tableName matching the keyCols of each of rows.
+// See connection.BulkUpdate.
+func (d *DB) BulkUpdate(ctx context.Context, tableName string, keyCols, setCols []string, rows [][]interface{}) error {
+	return connection.BulkUpdate(ctx, d, tableName, keyCols, setCols, rows)
+}
+
+// BulkInsertChunked inserts the rows from source into tableName with copy, one chunk of rows per
+// transaction. See connection.BulkInsertChunked.
+func (d *DB) BulkInsertChunked(ctx context.Context, tableName string, columns []string, source connection.BulkSource,
+	opts connection.BulkInsertOptions) error {
+	return connection.BulkInsertChunked(ctx, d, tableName, columns, source, opts)
+}
+
+// codeInvalidSQLStatementName is returned when deallocating a statement that does not exist.
+const codeInvalidSQLStatementName = "26000"
+
+// Deallocate releases the prepared statement name in the connection of the transaction or, if not
+// in one, in all the idle connections of the pool, connections in use are not affected.
+func (d *DB) Deallocate(ctx context.Context, name string) error {
+	return d.eachConn(ctx, func(conn *pgx.Conn) error {
+		err := conn.Deallocate(ctx, name)
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == codeInvalidSQLStatementName {
+			// not all connections prepared it
+			return nil
+		}
+		return err
+	})
+}
+
+// DeallocateAll clears the statement cache and releases all prepared statements in the connection
+// of the transaction or, if not in one, in all the idle connections of the pool, connections in use
+// are not affected.
+func (d *DB) DeallocateAll(ctx context.Context) error {
+	return d.eachConn(ctx, func(conn *pgx.Conn) error {
+		if sc := conn.StatementCache(); sc != nil {
+			if err := sc.Clear(ctx); err != nil {
+				return errors.Wrap(err, "clearing statement cache")
+			}
+		}
+		// this goes straight to pgconn so the statement itself is not cached.
+		_, err := conn.PgConn().Exec(ctx, "DEALLOCATE ALL").ReadAll()
+		return err
+	})
+}
+
+// eachConn runs f with the connection of the transaction or, if not in one, with each of the idle
+// connections of the pool.
+func (d *DB) eachConn(ctx context.Context, f func(*pgx.Conn) error) error {
+	if d.tx != nil {
+		return f(d.tx.Conn())
+	}
+	if d.conn == nil {
+		return gaumErrors.NoDB
+	}
+	conns := d.conn.AcquireAllIdle(ctx)
+	defer func() {
+		for _, c := range conns {
+			c.Release()
+		}
+	}()
+	for _, c := range conns {
+		if err := f(c.Conn()); err != nil {
+			return errors.Wrap(err, "deallocating prepared statements")
+		}
+	}
+	return nil
+}
+
+// notificationsBuffer is the size of the go channel returned by Listen.
+const notificationsBuffer = 64
+
+// listenReconnect is how Listen tries to get a new connection once the one listening is lost, it
+// never gives up until the context is done.
+var listenReconnect = &connection.Backoff{
+	MaxAttempts: math.MaxInt32,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	Jitter:      0.2,
+}
+
+// Listen listens to channel in a connection of the pool dedicated to it, even if this DB is a
+// transaction, until ctx is done. The returned go channel receives the notifications and is
+// closed once ctx is done.
+// If the connection is lost a new one is established and channel listened again, notifications
+// sent in the meantime are lost.
+func (d *DB) Listen(ctx context.Context, channel string) (<-chan connection.Notification, error) {
+	if d.conn == nil {
+		return nil, gaumErrors.NoDB
+	}
+	conn, err := d.listenConn(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	notifications := make(chan connection.Notification, notificationsBuffer)
+	go func() {
+		defer close(notifications)
+		for {
+			err := d.waitNotifications(ctx, conn, notifications)
+			if ctx.Err() != nil {
+				return
+			}
+			// the pool might be holding connections to the same broken server
+			d.refresh(ctx)
+			if d.logger != nil {
+				d.logger.Warn("lost connection while listening, reconnecting", "channel", channel, "error", err)
+			}
+			err = listenReconnect.Retry(ctx, nil, func() error {
+				var connErr error
+				conn, connErr = d.listenConn(ctx, channel)
+				return connErr
+			})
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return notifications, nil
+}
+
+// listenConn acquires a connection from the pool and listens to channel in it.
+func (d *DB) listenConn(ctx context.Context, channel string) (*pgxpool.Conn, error) {
+	conn, err := d.conn.Acquire(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "acquiring connection to listen")
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		conn.Release()
+		return nil, errors.Wrapf(err, "listening to %q", channel)
+	}
+	return conn, nil
+}
+
+// waitNotifications sends the notifications received by conn to notifications until ctx is done
+// or the connection fails, conn is then closed, so it does not go back to the pool still
+// listening, and released.
+func (d *DB) waitNotifications(ctx context.Context, conn *pgxpool.Conn, notifications chan<- connection.Notification) error {
+	defer conn.Release()
+	defer conn.Conn().Close(context.Background())
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return errors.Wrap(err, "waiting for notification")
+		}
+		select {
+		case notifications <- connection.Notification{PID: n.PID, Channel: n.Channel, Payload: n.Payload}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Notify sends payload to the listeners of channel, if this DB is a transaction it will only be
+// delivered once it commits.
+func (d *DB) Notify(ctx context.Context, channel, payload string) error {
+	_, err := d.exec(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	if err != nil {
+		return errors.Wrapf(err, "notifying %q", channel)
+	}
+	return nil
+}
+
+// NextVal advances seq and returns its new value. See connection.NextVal.
+func (d *DB) NextVal(ctx context.Context, seq string) (int64, error) {
+	return connection.NextVal(ctx, d, seq)
+}
+
+// CurrVal returns the value most recently obtained by NextVal for seq in this session, as
+// sessions are pooled it is only reliable in a transaction. See connection.CurrVal.
+func (d *DB) CurrVal(ctx context.Context, seq string) (int64, error) {
+	return connection.CurrVal(ctx, d, seq)
+}
+
+// SetVal sets the current value of seq. See connection.SetVal.
+func (d *DB) SetVal(ctx context.Context, seq string, value int64, isCalled bool) error {
+	return connection.SetVal(ctx, d, seq, value, isCalled)
+}
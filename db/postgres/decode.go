@@ -0,0 +1,147 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/srm"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+// decodeBatchSize is the amount of rows handed to a decoding worker at a time.
+const decodeBatchSize = 256
+
+// rowBatch is a set of consecutive rows, as read from the connection, and the slice of
+// destination elements they are decoded into.
+type rowBatch struct {
+	rows     [][][]byte
+	elements reflect.Value
+}
+
+// decodeParallel appends rows to destinationSlice decoding them in workers goroutines while
+// this one reads them from the connection, see connection.WithParallelDecoding.
+// connInfo is the type information of the connection running the query, it is not safe for
+// concurrent use, values are decoded into shared instances, so each worker gets a copy.
+func (d *DB) decodeParallel(ctx context.Context, rows pgx.Rows, connInfo *pgtype.ConnInfo, workers int, fields []string,
+	fieldMap map[string]reflect.StructField, destinationSlice reflect.Value) error {
+	defer rows.Close()
+	fieldDescriptions := rows.FieldDescriptions()
+	sliceType := destinationSlice.Type()
+	elementType := sliceType.Elem()
+	if elementType.Kind() == reflect.Ptr {
+		elementType = elementType.Elem()
+	}
+	scannable := srm.IsScannable(elementType)
+
+	var failure error
+	var failOnce sync.Once
+	failed := make(chan struct{})
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failure = err
+			close(failed)
+		})
+	}
+
+	jobs := make(chan rowBatch, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		ci := connInfo.DeepCopy()
+		go func() {
+			defer wg.Done()
+			var recipients *srm.Recipients
+			if !scannable {
+				recipients = srm.NewRecipients(d.logger, fields, fieldMap, elementType)
+			}
+			for batch := range jobs {
+				for i, values := range batch.rows {
+					// once failed the rest of the batches are just drained.
+					select {
+					case <-failed:
+						continue
+					default:
+					}
+					element := batch.elements.Index(i)
+					if element.Kind() == reflect.Ptr {
+						element.Set(reflect.New(element.Type().Elem()))
+						element = element.Elem()
+					}
+					var err error
+					if scannable {
+						err = srm.ScanValueOf(element, fields, func(dest ...interface{}) error {
+							return pgx.ScanRow(ci, fieldDescriptions, values, dest...)
+						})
+					} else {
+						err = pgx.ScanRow(ci, fieldDescriptions, values, recipients.For(element)...)
+					}
+					if err != nil {
+						fail(errors.Wrap(err, "scanning values into recipient, connection was closed"))
+					}
+				}
+			}
+		}()
+	}
+
+	var batches []reflect.Value
+	pending := make([][][]byte, 0, decodeBatchSize)
+	send := func() {
+		elements := reflect.MakeSlice(sliceType, len(pending), len(pending))
+		batches = append(batches, elements)
+		jobs <- rowBatch{rows: pending, elements: elements}
+		pending = make([][][]byte, 0, decodeBatchSize)
+	}
+read:
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			fail(errors.Wrap(err, "fetching results, rows were closed"))
+		}
+		select {
+		case <-failed:
+			break read
+		default:
+		}
+		// the values outlive this row, the slice holding them does not.
+		raw := rows.RawValues()
+		values := make([][]byte, len(raw))
+		copy(values, raw)
+		pending = append(pending, values)
+		if len(pending) == decodeBatchSize {
+			send()
+		}
+	}
+	if len(pending) > 0 {
+		send()
+	}
+	close(jobs)
+	wg.Wait()
+	rows.Close()
+	if failure != nil {
+		return failure
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, elements := range batches {
+		destinationSlice.Set(reflect.AppendSlice(destinationSlice, elements))
+	}
+	return nil
+}
@@ -23,12 +23,18 @@ package q
 
 import (
 	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
 	c "github.com/ShiftLeftSecurity/gaum/v2/db/chain"
 	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
 	"github.com/ShiftLeftSecurity/gaum/v2/db/logging"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
 	"github.com/ShiftLeftSecurity/gaum/v2/db/postgres"
 	"github.com/ShiftLeftSecurity/gaum/v2/db/postgrespq"
 )
@@ -58,11 +64,11 @@ var driverConnectors = map[Driver]connConstructor{
 // the first value into <recipient>.RawQueryOne
 // <receiver> must be of a type that supports de-serialization of all columns into it.
 func RawQueryOne(ctx context.Context, db connection.DB, recipient interface{}, query string, args ...interface{}) error {
-	escapedQuery, explodedArgs, err := c.MarksToPlaceholders(query, args)
+	escapedQuery, explodedArgs, err := c.MarksToPlaceholdersFor(db, query, args)
 	if err != nil {
 		return errors.Wrap(err, "escaping question marks in query")
 	}
-	fetcher, err := db.QueryIter(ctx, escapedQuery, []string{}, explodedArgs)
+	fetcher, err := db.QueryIter(ctx, escapedQuery, []string{}, explodedArgs...)
 	if err != nil {
 		return errors.Wrap(err, "querying database")
 	}
@@ -78,11 +84,11 @@ func RawQueryOne(ctx context.Context, db connection.DB, recipient interface{}, q
 // the values into <recipientSlice> that must be a slice of a type that supports de-serialization
 // of all columns into it.
 func RawQuery(ctx context.Context, db connection.DB, recipientSlice interface{}, query string, args ...interface{}) error {
-	escapedQuery, explodedArgs, err := c.MarksToPlaceholders(query, args)
+	escapedQuery, explodedArgs, err := c.MarksToPlaceholdersFor(db, query, args)
 	if err != nil {
 		return errors.Wrap(err, "escaping question marks in query")
 	}
-	fetcher, err := db.Query(ctx, escapedQuery, []string{}, explodedArgs)
+	fetcher, err := db.Query(ctx, escapedQuery, []string{}, explodedArgs...)
 	if err != nil {
 		return errors.Wrap(err, "querying database")
 	}
@@ -96,11 +102,11 @@ func RawQuery(ctx context.Context, db connection.DB, recipientSlice interface{},
 // RawExec runs the passed in <query> with the safely inserted <args> through <db>, no values are
 // returned except for success/error.
 func RawExec(ctx context.Context, db connection.DB, query string, args ...interface{}) error {
-	escapedQuery, explodedArgs, err := c.MarksToPlaceholders(query, args)
+	escapedQuery, explodedArgs, err := c.MarksToPlaceholdersFor(db, query, args)
 	if err != nil {
 		return errors.Wrap(err, "escaping question marks in query")
 	}
-	err = db.Exec(ctx, escapedQuery, explodedArgs)
+	err = db.Exec(ctx, escapedQuery, explodedArgs...)
 	if err != nil {
 		return errors.Wrap(err, "executing statement")
 	}
@@ -162,7 +168,10 @@ func NewFromDB(dbConnection connection.DB) (*Q, error) {
 
 // Q is the intended struct for interaction with SQL Queries.
 type Q struct {
-	query *c.ExpressionChain
+	query   *c.ExpressionChain
+	preload []string
+	// statement is true once the statement, ie `Select` or `Insert`, was set.
+	statement bool
 }
 
 // Select converts the existing Q query into a `SELECT ...` SQL statement, query is the
@@ -170,6 +179,7 @@ type Q struct {
 // you can use `?` as a placeholder for values to be safely passed as variadic arguments after
 // the expression
 func (q *Q) Select(query string, args ...interface{}) *Q {
+	q.statement = true
 	if len(args) == 0 {
 		q.query.Select(query)
 		return q
@@ -189,6 +199,7 @@ func (q *Q) Select(query string, args ...interface{}) *Q {
 // not guaranteed given go's map implementation (of course key/value will always be in the
 // position corresponding with each other within the query)
 func (q *Q) Insert(insertPairs map[string]interface{}) *Q {
+	q.statement = true
 	q.query.Insert(insertPairs)
 	return q
 }
@@ -199,18 +210,72 @@ func (q *Q) Insert(insertPairs map[string]interface{}) *Q {
 // implementation so even if the resulting query of multiple calls might differ in the `SET`
 // section it will be equivalent.
 func (q *Q) Update(exprMap map[string]interface{}) *Q {
+	q.statement = true
 	q.query.UpdateMap(exprMap)
 	return q
 }
 
+// InsertStruct converts the existing Q query into an `INSERT INTO ...` SQL statement of the
+// columns of <record>, a pointer to a struct, running its insert hooks, please refer to the
+// documentation of `chain.InsertStruct`.
+func (q *Q) InsertStruct(record interface{}) *Q {
+	q.statement = true
+	q.query.InsertStruct(record)
+	return q
+}
+
+// UpdateStruct converts the existing Q query into an `UPDATE ...` SQL statement of the row of
+// <record>, a pointer to a struct, found by its primary key, running its update hooks, please
+// refer to the documentation of `chain.UpdateStruct`.
+func (q *Q) UpdateStruct(record interface{}) *Q {
+	q.statement = true
+	q.query.UpdateStruct(record)
+	return q
+}
+
 // Delete converts the existing Q query into an `DELETE FROM ...` SQL statement, be very mindful
 // when using this since it can easily create a WHERE-less DELETE if you forget to invoke proper
 // `AndWhere`/`OrWhere` statement before executing it.
+//
+// Deprecated: use `DeleteRecord` to delete a model by its primary key or `DeleteWhere`, which
+// refuses to run without a condition.
 func (q *Q) Delete() *Q {
+	q.statement = true
 	q.query.Delete()
 	return q
 }
 
+// DeleteRecord deletes the row of <record>, a pointer to a struct registered with
+// `model.Register`, found by its primary key using only the connection of the Q query. Models
+// with soft delete have the row marked as deleted instead, see `chain.Unscoped`.
+func (q *Q) DeleteRecord(ctx context.Context, record interface{}) error {
+	m, ok := model.Lookup(record)
+	if !ok {
+		return errors.Errorf("%T is not a registered model", record)
+	}
+	pk, err := model.PrimaryKeyValues(record)
+	if err != nil {
+		return errors.Wrap(err, "deleting")
+	}
+	query := c.New(q.DB()).Model(record).Delete()
+	for i, column := range m.PK {
+		query.AndWhere(column+" = ?", pk[i])
+	}
+	return query.Exec(ctx)
+}
+
+// DeleteWhere deletes the rows of the table of <m>, a model registered with `model.Register`,
+// that match <condition>, which can use `?` as a placeholder for <args>, using only the
+// connection of the Q query. An empty condition is refused so a table can't be emptied by
+// mistake. Models with soft delete have the rows marked as deleted instead, see
+// `chain.Unscoped`.
+func (q *Q) DeleteWhere(ctx context.Context, m interface{}, condition string, args ...interface{}) error {
+	if strings.TrimSpace(condition) == "" {
+		return errors.Errorf("refusing to delete every row of the table of %T, a condition is required", m)
+	}
+	return c.New(q.DB()).Model(m).Delete().AndWhere(condition, args...).Exec(ctx)
+}
+
 // From sets the table or tables in which the SQL statement defined by the Q query will operate
 // this method receives a free form string so you might as well pass a list of columns comma
 // separated or actually anything that is valid input for a SQL `FROM` statement.
@@ -219,6 +284,27 @@ func (q *Q) From(table string) *Q {
 	return q
 }
 
+// Model sets the table in which the SQL statement defined by the Q query will operate to the
+// one <m> is registered with, see `model.Register`.
+func (q *Q) Model(m interface{}) *Q {
+	q.query.Model(m)
+	return q
+}
+
+// Unscoped disables the soft delete of the model of the Q query, rows marked as deleted are
+// returned and `Delete` removes them, please refer to the documentation of `chain.Unscoped`.
+func (q *Q) Unscoped() *Q {
+	q.query.Unscoped()
+	return q
+}
+
+// Preload makes `QueryOne` and `QueryMany` load the passed relations of the fetched models,
+// please refer to the documentation of `chain.LoadRelations`.
+func (q *Q) Preload(relations ...string) *Q {
+	q.preload = append(q.preload, relations...)
+	return q
+}
+
 // Join adds a `JOIN <table> ON <expression>` SQL statement to your Q query, the <on> argument
 // can contain any valid SQL expression for the `ON` section of a JOIN
 // you can use `?` as a placeholder for values to be safely passed as variadic arguments after
@@ -348,9 +434,12 @@ func (q *Q) Returning(args ...string) *Q {
 // retrieved before dropping the result set.
 //
 // <receiver> must be of a type that supports de-serialization of all columns into it.
-// This works with `SELECT` and `INSERT INTO ... RETURNING ...`
+// This works with `SELECT` and `INSERT INTO ... RETURNING ...`, if `From` was not called the
+// table is the one <receiver> is registered with (see `Model`) and if `Select` was not the
+// columns of <receiver> are selected.
 func (q *Q) QueryOne(ctx context.Context, receiver interface{}) error {
-	fetcher, err := q.query.QueryIter(ctx)
+	q.selectReceiver(receiver)
+	fetcher, err := q.query.InferTable(receiver).QueryIter(ctx)
 	if err != nil {
 		return errors.Wrap(err, "running query")
 	}
@@ -358,16 +447,72 @@ func (q *Q) QueryOne(ctx context.Context, receiver interface{}) error {
 	if err != nil {
 		return errors.Wrap(err, "fetching data")
 	}
-	defer closer()
-	return nil
+	closer()
+	if err := c.LoadRelations(ctx, q.query.DB(), receiver, q.preload...); err != nil {
+		return errors.Wrap(err, "preloading")
+	}
+	return q.query.AfterFetch(ctx, receiver)
+}
+
+// Find fetches into <receiver>, a pointer to a struct registered with `model.Register`, the row
+// of its table whose primary key is <pk>, with the values in the order of the primary key
+// columns, loading the relations passed to `Preload`. Only the connection of the Q query is used,
+// the row is looked up with `SELECT columns FROM table WHERE pk = ? LIMIT 1`.
+// If there is no such row `errors.ErrNoRows` is returned.
+func (q *Q) Find(ctx context.Context, receiver interface{}, pk ...interface{}) error {
+	m, ok := model.Lookup(receiver)
+	if !ok {
+		return errors.Errorf("%T is not a registered model", receiver)
+	}
+	if len(pk) != len(m.PK) {
+		return errors.Errorf("%s has a primary key of %d columns but %d values were passed",
+			m.Table, len(m.PK), len(pk))
+	}
+	query := c.New(q.DB()).SelectStruct(receiver).Model(receiver).Limit(1)
+	for i, column := range m.PK {
+		query.AndWhere(column+" = ?", pk[i])
+	}
+	find := &Q{query: query, preload: q.preload, statement: true}
+	err := find.QueryOne(ctx, receiver)
+	if gaumErrors.IsNoRows(err) {
+		return gaumErrors.ErrNoRows
+	}
+	return err
+}
+
+// Save writes <record>, a pointer to a struct registered with `model.Register`, to its table
+// using only the connection of the Q query. If its primary key holds zero values it is inserted
+// and the row, with the generated key and defaults, is fetched back into it, otherwise the row
+// with its primary key is updated, please refer to the documentation of `chain.InsertStruct` and `chain.UpdateStruct`
+// for the tags, such as omit, readonly or version, and the hooks honored.
+func (q *Q) Save(ctx context.Context, record interface{}) error {
+	if _, ok := model.Lookup(record); !ok {
+		return errors.Errorf("%T is not a registered model", record)
+	}
+	pk, err := model.PrimaryKeyValues(record)
+	if err != nil {
+		return errors.Wrap(err, "saving")
+	}
+	isNew := true
+	for _, value := range pk {
+		isNew = isNew && reflect.ValueOf(value).IsZero()
+	}
+	if isNew {
+		err = c.New(q.DB()).InsertStruct(record).Returning("*").ExecInto(ctx, record)
+		return errors.Wrap(err, "inserting")
+	}
+	return errors.Wrap(c.New(q.DB()).UpdateStruct(record).Exec(ctx), "updating")
 }
 
 // QueryMany executes and fetches all results from a query into <receiverSlice> which is
 // expected to be a slice of a type that supports de-serialization of all columns into it.
 //
-// This works with `SELECT` and `INSERT INTO ... RETURNING ...`
+// This works with `SELECT` and `INSERT INTO ... RETURNING ...`, if `From` was not called the
+// table is the one <receiverSlice> is registered with (see `Model`) and if `Select` was not the
+// columns of its element are selected.
 func (q *Q) QueryMany(ctx context.Context, receiverSlice interface{}) error {
-	fetcher, err := q.query.Query(ctx)
+	q.selectReceiver(receiverSlice)
+	fetcher, err := q.query.InferTable(receiverSlice).Query(ctx)
 	if err != nil {
 		return errors.Wrap(err, "running query")
 	}
@@ -375,7 +520,76 @@ func (q *Q) QueryMany(ctx context.Context, receiverSlice interface{}) error {
 	if err != nil {
 		return errors.Wrap(err, "fetching data")
 	}
-	return nil
+	if err := c.LoadRelations(ctx, q.query.DB(), receiverSlice, q.preload...); err != nil {
+		return errors.Wrap(err, "preloading")
+	}
+	return q.query.AfterFetch(ctx, receiverSlice)
+}
+
+// Count returns the amount of rows the `SELECT` query in Q yields, please refer to the
+// documentation of `chain.Count`.
+func (q *Q) Count(ctx context.Context) (int64, error) {
+	return q.query.Count(ctx)
+}
+
+// Exists returns true if the `SELECT` query in Q yields at least one row.
+func (q *Q) Exists(ctx context.Context) (bool, error) {
+	return q.query.Exists(ctx)
+}
+
+// PageInfo describes a page of results fetched with `Page`.
+type PageInfo struct {
+	// Page is the number of the page, starting at 1, and Size the maximum rows it holds.
+	Page int64
+	Size int64
+	// Total is the amount of rows the query yields across all pages and Pages the amount of
+	// pages they fill.
+	Total int64
+	Pages int64
+	// HasNext and HasPrevious are true if there are pages after and before this one.
+	HasNext     bool
+	HasPrevious bool
+}
+
+// Page fetches into <receiverSlice>, as `QueryMany` does, the <page>th group of <size> rows the
+// `SELECT` query yields, starting at 1, and returns the information to fetch the others, the
+// query is run twice, once to count all the rows, ignoring any `Limit` and `Offset`, and once
+// for the page. The Q query is not modified. Use `OrderBy` so pages are consistent.
+func (q *Q) Page(ctx context.Context, page, size int64, receiverSlice interface{}) (PageInfo, error) {
+	if page < 1 || size < 1 {
+		return PageInfo{}, errors.Errorf("page and size must be positive got page %d of size %d", page, size)
+	}
+	pageQuery := &Q{query: q.query.Clone(), preload: q.preload, statement: q.statement}
+	pageQuery.selectReceiver(receiverSlice)
+	pageQuery.query.InferTable(receiverSlice)
+	total, err := pageQuery.query.Clone().Unpaginated().Count(ctx)
+	if err != nil {
+		return PageInfo{}, errors.Wrap(err, "counting rows to paginate")
+	}
+	pages := (total + size - 1) / size
+	info := PageInfo{
+		Page:        page,
+		Size:        size,
+		Total:       total,
+		Pages:       pages,
+		HasNext:     page < pages,
+		HasPrevious: page > 1,
+	}
+	pageQuery.Limit(size).Offset((page - 1) * size)
+	if err := pageQuery.QueryMany(ctx, receiverSlice); err != nil {
+		return info, errors.Wrap(err, "fetching page")
+	}
+	return info, nil
+}
+
+// selectReceiver makes the query select the columns of the struct of receiver if no statement
+// was set, so they don't need to be discovered for each row.
+func (q *Q) selectReceiver(receiver interface{}) {
+	if q.statement {
+		return
+	}
+	q.query.SelectStruct(receiver)
+	q.statement = true
 }
 
 // Exec executes the query in Q not expecting nor returning any results other than success/error
@@ -385,7 +599,79 @@ func (q *Q) Exec(ctx context.Context) error {
 	return q.query.Exec(ctx)
 }
 
+// Transaction runs <fn> in a transaction of the connection of the Q query, passing it a new Q
+// query bound to the transaction, which is committed if <fn> returns nil and rolled back if it
+// returns an error or panics. If the connection already is a transaction <fn> runs in it, please
+// refer to the documentation of `connection.WithTransaction` for nesting and retries.
+func (q *Q) Transaction(ctx context.Context, fn func(txq *Q) error) error {
+	return connection.WithTransaction(ctx, q.DB(), func(tx connection.DB) error {
+		return fn(&Q{query: c.New(tx)})
+	})
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// isRow returns true for the types that receive whole rows, structs that are not scanned as a
+// single value.
+func isRow(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PtrTo(t).Implements(scannerType)
+}
+
+// Raw runs the passed in <query>, with `?` as placeholders for the safely inserted <args>,
+// through the connection of the Q query and fetches the results into <recipient>. A pointer to
+// a slice of structs gets all the rows, as `RawQuery` does, a pointer to a struct the first one,
+// as `RawQueryOne` does, a pointer to a slice of anything else, including `time.Time` and
+// `sql.Scanner`s, the first column of all the rows and a pointer to one of those the first
+// column of the first row.
+func (q *Q) Raw(ctx context.Context, recipient interface{}, query string, args ...interface{}) error {
+	recipientType := reflect.TypeOf(recipient)
+	if recipientType == nil || recipientType.Kind() != reflect.Ptr {
+		return errors.Errorf("expected a pointer to receive the results got %T", recipient)
+	}
+	elem := recipientType.Elem()
+	switch {
+	case elem.Kind() == reflect.Slice && isRow(elem.Elem()):
+		return RawQuery(ctx, q.DB(), recipient, query, args...)
+	case isRow(elem):
+		return RawQueryOne(ctx, q.DB(), recipient, query, args...)
+	}
+	escapedQuery, explodedArgs, err := c.MarksToPlaceholdersFor(q.DB(), query, args)
+	if err != nil {
+		return errors.Wrap(err, "escaping question marks in query")
+	}
+	if elem.Kind() == reflect.Slice && elem.Elem().Kind() != reflect.Uint8 {
+		fetch, err := q.DB().QueryPrimitive(ctx, escapedQuery, "", explodedArgs...)
+		if err != nil {
+			return errors.Wrap(err, "querying database")
+		}
+		return errors.Wrap(fetch(recipient), "fetching data from database")
+	}
+	err = q.DB().Raw(ctx, escapedQuery, explodedArgs, recipient)
+	if err == gaumErrors.ErrNoRows {
+		return err
+	}
+	return errors.Wrap(err, "querying database")
+}
+
+// RawExec runs the passed in <query>, with `?` as placeholders for the safely inserted <args>,
+// through the connection of the Q query, see `RawExec`.
+func (q *Q) RawExec(ctx context.Context, query string, args ...interface{}) error {
+	return RawExec(ctx, q.DB(), query, args...)
+}
+
 // DB returns the `connection.DB` being used for this Q query execution.
 func (q *Q) DB() connection.DB {
 	return q.query.DB()
 }
+
+// SetLogLevel changes the level of the query logs of the connection used by Q, without
+// reconnecting.
+func (q *Q) SetLogLevel(level connection.LogLevel) error {
+	return q.query.DB().SetLogLevel(level)
+}
@@ -0,0 +1,455 @@
+package q
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	gaumErrors "github.com/ShiftLeftSecurity/gaum/v2/db/errors"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
+)
+
+// recordingDB records the statements it runs.
+type recordingDB struct {
+	connection.DB
+	// methods holds the methods of the connection that ran each statement.
+	methods    []string
+	statements []string
+	args       [][]interface{}
+	// noRows makes the queries yield no rows.
+	noRows bool
+	// returned fills the receiver of the rows returned by the queries.
+	returned func(receiver interface{})
+}
+
+func (r *recordingDB) record(method, statement string, args []interface{}) {
+	r.methods = append(r.methods, method)
+	r.statements = append(r.statements, statement)
+	r.args = append(r.args, args)
+}
+
+func (r *recordingDB) ExecResult(ctx context.Context, statement string, args ...interface{}) (int64, error) {
+	r.record("ExecResult", statement, args)
+	return 1, nil
+}
+
+func (r *recordingDB) Exec(ctx context.Context, statement string, args ...interface{}) error {
+	r.record("Exec", statement, args)
+	return nil
+}
+
+func (r *recordingDB) QueryIter(ctx context.Context, statement string, fields []string, args ...interface{}) (connection.ResultFetchIter, error) {
+	r.record("QueryIter", statement, args)
+	if r.noRows {
+		return nil, gaumErrors.ErrNoRows
+	}
+	return func(receiver interface{}) (bool, func(), error) {
+		r.returned(receiver)
+		return false, func() {}, nil
+	}, nil
+}
+
+func (r *recordingDB) Query(ctx context.Context, statement string, fields []string, args ...interface{}) (connection.ResultFetch, error) {
+	r.record("Query", statement, args)
+	return func(receiver interface{}) error {
+		if !r.noRows {
+			r.returned(receiver)
+		}
+		return nil
+	}, nil
+}
+
+func (r *recordingDB) QueryPrimitive(ctx context.Context, statement string, field string, args ...interface{}) (connection.ResultFetch, error) {
+	r.record("QueryPrimitive", statement, args)
+	return func(receiver interface{}) error {
+		if !r.noRows {
+			r.returned(receiver)
+		}
+		return nil
+	}, nil
+}
+
+func (r *recordingDB) Raw(ctx context.Context, statement string, args []interface{}, fields ...interface{}) error {
+	r.record("Raw", statement, args)
+	if r.noRows {
+		return gaumErrors.ErrNoRows
+	}
+	r.returned(fields[0])
+	return nil
+}
+
+func (r *recordingDB) IsTransaction() bool {
+	return false
+}
+
+func (r *recordingDB) BeginTransaction(ctx context.Context) (connection.DB, error) {
+	r.record("BeginTransaction", "BEGIN", nil)
+	return &recordingTX{r}, nil
+}
+
+// recordingTX is a transaction of a recordingDB, recording its statements there.
+type recordingTX struct {
+	*recordingDB
+}
+
+func (r *recordingTX) CommitTransaction(ctx context.Context) error {
+	r.record("CommitTransaction", "COMMIT", nil)
+	return nil
+}
+
+func (r *recordingTX) RollbackTransaction(ctx context.Context) error {
+	r.record("RollbackTransaction", "ROLLBACK", nil)
+	return nil
+}
+
+func (r *recordingTX) IsTransaction() bool {
+	return true
+}
+
+type user struct {
+	ID   int `gaum:"field_name:id"`
+	Name string
+}
+
+type softDeletedUser struct {
+	ID        int `gaum:"field_name:id"`
+	Name      string
+	DeletedAt *time.Time `gaum:"soft_delete"`
+}
+
+func registerModels(t *testing.T) func() {
+	t.Helper()
+	if err := model.Register(&user{}, model.Table("users")); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Register(&softDeletedUser{}, model.Table("archived_users")); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		model.Unregister(&user{})
+		model.Unregister(&softDeletedUser{})
+	}
+}
+
+func expectStatements(t *testing.T, db *recordingDB, statements []string, args [][]interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(db.statements, statements) {
+		t.Errorf("expected statements %q got %q", statements, db.statements)
+	}
+	if !reflect.DeepEqual(db.args, args) {
+		t.Errorf("expected arguments %v got %v", args, db.args)
+	}
+}
+
+func TestQ_Find(t *testing.T) {
+	defer registerModels(t)()
+	ctx := context.Background()
+
+	db := &recordingDB{returned: func(receiver interface{}) {
+		receiver.(*user).Name = "jane"
+	}}
+	q, _ := NewFromDB(db)
+	found := &user{}
+	if err := q.Find(ctx, found, 7); err != nil {
+		t.Fatal(err)
+	}
+	if found.Name != "jane" {
+		t.Errorf("expected the row to be fetched got %+v", found)
+	}
+	expectStatements(t, db,
+		[]string{"SELECT id, name FROM users WHERE id = $1 LIMIT 1"},
+		[][]interface{}{{7}})
+
+	db.noRows = true
+	if err := q.Find(ctx, &user{}, 8); err != gaumErrors.ErrNoRows {
+		t.Errorf("expected ErrNoRows for a missing row got %v", err)
+	}
+
+	db = &recordingDB{}
+	q, _ = NewFromDB(db)
+	if err := q.Find(ctx, &user{}); err == nil {
+		t.Error("expected finding without a primary key value to fail")
+	}
+	if err := q.Find(ctx, &user{}, 1, 2); err == nil {
+		t.Error("expected finding with too many primary key values to fail")
+	}
+	if err := q.Find(ctx, &struct{ ID int }{}, 1); err == nil {
+		t.Error("expected finding an unregistered type to fail")
+	}
+	if len(db.statements) != 0 {
+		t.Errorf("expected no queries for invalid finds got %q", db.statements)
+	}
+}
+
+func TestQ_Page(t *testing.T) {
+	defer registerModels(t)()
+	ctx := context.Background()
+
+	db := &recordingDB{returned: func(receiver interface{}) {
+		switch r := receiver.(type) {
+		case *int64:
+			*r = 45
+		case *[]user:
+			*r = []user{{ID: 21}, {ID: 22}}
+		}
+	}}
+	q, _ := NewFromDB(db)
+	q.AndWhere("name = ?", "jane").Limit(5).Offset(15)
+	before := q.query.String()
+
+	var users []user
+	info, err := q.Page(ctx, 3, 10, &users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := PageInfo{Page: 3, Size: 10, Total: 45, Pages: 5, HasNext: true, HasPrevious: true}
+	if info != expected {
+		t.Errorf("expected page info %+v got %+v", expected, info)
+	}
+	if len(users) != 2 {
+		t.Errorf("expected the rows of the page to be fetched got %+v", users)
+	}
+	expectStatements(t, db,
+		[]string{
+			"SELECT count(*) FROM (SELECT id, name FROM users WHERE name = $1) AS gaum_count",
+			"SELECT id, name FROM users WHERE name = $1 LIMIT 10 OFFSET 20",
+		},
+		[][]interface{}{{"jane"}, {"jane"}})
+	if after := q.query.String(); after != before || q.statement {
+		t.Errorf("expected the query not to be modified, it was %q and is %q", before, after)
+	}
+
+	for _, invalid := range [][2]int64{{0, 10}, {1, 0}} {
+		if _, err := q.Page(ctx, invalid[0], invalid[1], &users); err == nil {
+			t.Errorf("expected page %d of size %d to be refused", invalid[0], invalid[1])
+		}
+	}
+}
+
+func TestQ_Raw(t *testing.T) {
+	ctx := context.Background()
+
+	db := &recordingDB{returned: func(receiver interface{}) {
+		switch r := receiver.(type) {
+		case *user:
+			r.Name = "jane"
+		case *[]user:
+			*r = []user{{Name: "jane"}, {Name: "john"}}
+		case *[]string:
+			*r = []string{"jane", "john"}
+		case *int64:
+			*r = 2
+		case *[]byte:
+			*r = []byte("jane")
+		}
+	}}
+	q, _ := NewFromDB(db)
+
+	var one user
+	if err := q.Raw(ctx, &one, "SELECT * FROM users WHERE id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	var many []user
+	if err := q.Raw(ctx, &many, "SELECT * FROM users WHERE id IN (?)", []int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	if err := q.Raw(ctx, &names, "SELECT name FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := q.Raw(ctx, &count, "SELECT count(*) FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	if err := q.Raw(ctx, &data, "SELECT data FROM users WHERE id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.RawExec(ctx, "DELETE FROM users WHERE id = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	if one.Name != "jane" || len(many) != 2 || len(names) != 2 || count != 2 || string(data) != "jane" {
+		t.Errorf("expected the results to be fetched got %+v, %+v, %v, %d and %q", one, many, names, count, data)
+	}
+	expectedMethods := []string{"QueryIter", "Query", "QueryPrimitive", "Raw", "Raw", "Exec"}
+	if !reflect.DeepEqual(db.methods, expectedMethods) {
+		t.Errorf("expected the queries to run with %v got %v", expectedMethods, db.methods)
+	}
+	expectStatements(t, db,
+		[]string{
+			"SELECT * FROM users WHERE id = $1",
+			"SELECT * FROM users WHERE id IN ($1, $2)",
+			"SELECT name FROM users",
+			"SELECT count(*) FROM users",
+			"SELECT data FROM users WHERE id = $1",
+			"DELETE FROM users WHERE id = $1",
+		},
+		[][]interface{}{{1}, {1, 2}, {}, {}, {1}, {1}})
+
+	if err := q.Raw(ctx, count, "SELECT count(*) FROM users"); err == nil {
+		t.Error("expected a recipient that is not a pointer to be refused")
+	}
+
+	db.noRows = true
+	if err := q.Raw(ctx, &count, "SELECT count(*) FROM users WHERE name = ?", "nobody"); err != gaumErrors.ErrNoRows {
+		t.Errorf("expected ErrNoRows for a value without rows got %v", err)
+	}
+	if err := q.Raw(ctx, &one, "SELECT * FROM users WHERE name = ?", "nobody"); !gaumErrors.IsNoRows(err) {
+		t.Errorf("expected ErrNoRows for a struct without rows got %v", err)
+	}
+	names = nil
+	if err := q.Raw(ctx, &names, "SELECT name FROM users WHERE name = ?", "nobody"); err != nil || len(names) != 0 {
+		t.Errorf("expected no values and no error for a slice without rows got %v and %v", names, err)
+	}
+}
+
+func TestQ_Transaction(t *testing.T) {
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name       string
+		fn         func(txq *Q) error
+		wantErr    error
+		wantPanic  bool
+		statements []string
+	}{
+		{
+			name: "commit",
+			fn: func(txq *Q) error {
+				return txq.RawExec(ctx, "DELETE FROM users WHERE id = ?", 1)
+			},
+			statements: []string{"BEGIN", "DELETE FROM users WHERE id = $1", "COMMIT"},
+		},
+		{
+			name: "rollback on error",
+			fn: func(txq *Q) error {
+				if err := txq.RawExec(ctx, "DELETE FROM users WHERE id = ?", 1); err != nil {
+					return err
+				}
+				return errFailed
+			},
+			wantErr:    errFailed,
+			statements: []string{"BEGIN", "DELETE FROM users WHERE id = $1", "ROLLBACK"},
+		},
+		{
+			name: "rollback on panic",
+			fn: func(txq *Q) error {
+				_ = txq.RawExec(ctx, "DELETE FROM users WHERE id = ?", 1)
+				panic(errFailed)
+			},
+			wantPanic:  true,
+			statements: []string{"BEGIN", "DELETE FROM users WHERE id = $1", "ROLLBACK"},
+		},
+		{
+			name: "nested",
+			fn: func(txq *Q) error {
+				if !txq.DB().IsTransaction() {
+					t.Error("expected the query to be bound to the transaction")
+				}
+				if err := txq.RawExec(ctx, "DELETE FROM users WHERE id = ?", 1); err != nil {
+					return err
+				}
+				return txq.Transaction(ctx, func(nested *Q) error {
+					return nested.RawExec(ctx, "DELETE FROM users WHERE id = ?", 2)
+				})
+			},
+			statements: []string{"BEGIN", "DELETE FROM users WHERE id = $1", "DELETE FROM users WHERE id = $1", "COMMIT"},
+		},
+		{
+			name: "nested rollback",
+			fn: func(txq *Q) error {
+				if err := txq.RawExec(ctx, "DELETE FROM users WHERE id = ?", 1); err != nil {
+					return err
+				}
+				return txq.Transaction(ctx, func(nested *Q) error {
+					return errFailed
+				})
+			},
+			wantErr:    errFailed,
+			statements: []string{"BEGIN", "DELETE FROM users WHERE id = $1", "ROLLBACK"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &recordingDB{}
+			q, _ := NewFromDB(db)
+			func() {
+				defer func() {
+					if p := recover(); (p != nil) != tt.wantPanic {
+						t.Errorf("expected panicking to be %v got %v", tt.wantPanic, p)
+					}
+				}()
+				if err := q.Transaction(ctx, tt.fn); !errors.Is(err, tt.wantErr) {
+					t.Errorf("expected error %v got %v", tt.wantErr, err)
+				}
+			}()
+			if !reflect.DeepEqual(db.statements, tt.statements) {
+				t.Errorf("expected statements %q got %q", tt.statements, db.statements)
+			}
+		})
+	}
+}
+
+func TestQ_DeleteRecord(t *testing.T) {
+	defer registerModels(t)()
+	ctx := context.Background()
+
+	db := &recordingDB{}
+	q, _ := NewFromDB(db)
+	if err := q.DeleteRecord(ctx, &user{ID: 7, Name: "jane"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.DeleteRecord(ctx, &softDeletedUser{ID: 9}); err != nil {
+		t.Fatal(err)
+	}
+	expectStatements(t, db,
+		[]string{
+			"DELETE FROM users WHERE id = $1",
+			"UPDATE archived_users SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND archived_users.deleted_at IS NULL",
+		},
+		[][]interface{}{{7}, {9}})
+
+	if err := q.DeleteRecord(ctx, &struct{ ID int }{ID: 1}); err == nil {
+		t.Error("expected deleting an unregistered type to fail")
+	}
+}
+
+func TestQ_DeleteWhere(t *testing.T) {
+	defer registerModels(t)()
+	ctx := context.Background()
+
+	db := &recordingDB{}
+	q, _ := NewFromDB(db)
+	for _, condition := range []string{"", "  "} {
+		if err := q.DeleteWhere(ctx, &user{}, condition); err == nil {
+			t.Errorf("expected deleting with the condition %q to be refused", condition)
+		}
+	}
+	if err := q.DeleteWhere(ctx, &user{}, "name = ?", "jane"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.DeleteWhere(ctx, &softDeletedUser{}, "name = ?", "john"); err != nil {
+		t.Fatal(err)
+	}
+	expectStatements(t, db,
+		[]string{
+			"DELETE FROM users WHERE name = $1",
+			"UPDATE archived_users SET deleted_at = CURRENT_TIMESTAMP WHERE name = $1 AND archived_users.deleted_at IS NULL",
+		},
+		[][]interface{}{{"jane"}, {"john"}})
+}
+
+func TestQ_Delete(t *testing.T) {
+	ctx := context.Background()
+
+	db := &recordingDB{}
+	q, _ := NewFromDB(db)
+	if err := q.Delete().From("users").AndWhere("id = ?", 3).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expectStatements(t, db, []string{"DELETE FROM users WHERE id = $1"}, [][]interface{}{{3}})
+}
@@ -0,0 +1,33 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package srm
+
+import "reflect"
+
+// RegisterHotType makes the recipients of the struct type of example (or the type it points to)
+// be found with precomputed field offsets and unsafe pointer arithmetic instead of reflection,
+// for types scanned so often that finding their fields shows in the profiles.
+// Fields reached through embedded pointers are found as usual.
+// Building with the gaum_nounsafe tag disables this, the types are then scanned as any other.
+func RegisterHotType(example interface{}) {
+	t := reflect.TypeOf(example)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	registerHotType(t)
+}
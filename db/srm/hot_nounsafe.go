@@ -0,0 +1,30 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+//go:build gaum_nounsafe
+// +build gaum_nounsafe
+
+package srm
+
+import "reflect"
+
+func registerHotType(reflect.Type) {}
+
+func fieldOffset(reflect.Type, []int) (uintptr, bool) {
+	return 0, false
+}
+
+func fieldPointer(reflect.Value, uintptr, reflect.Type) interface{} {
+	panic("hot types are disabled by the gaum_nounsafe build tag")
+}
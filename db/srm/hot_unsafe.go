@@ -0,0 +1,56 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+//go:build !gaum_nounsafe
+// +build !gaum_nounsafe
+
+package srm
+
+import (
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// hotTypes holds the types passed to RegisterHotType.
+var hotTypes sync.Map
+
+func registerHotType(t reflect.Type) {
+	hotTypes.Store(t, true)
+}
+
+// fieldOffset returns the offset, from the start of a value of t, of the field at index if t
+// is a hot type and the field is not behind a pointer.
+func fieldOffset(t reflect.Type, index []int) (uintptr, bool) {
+	if _, ok := hotTypes.Load(t); !ok {
+		return 0, false
+	}
+	var offset uintptr
+	for _, i := range index {
+		if t.Kind() != reflect.Struct {
+			return 0, false
+		}
+		field := t.Field(i)
+		offset += field.Offset
+		t = field.Type
+	}
+	return offset, true
+}
+
+// fieldPointer returns a pointer to the field of type fieldType at offset of vod, an addressable
+// struct value.
+func fieldPointer(vod reflect.Value, offset uintptr, fieldType reflect.Type) interface{} {
+	base := unsafe.Pointer(vod.UnsafeAddr())
+	return reflect.NewAt(fieldType, unsafe.Pointer(uintptr(base)+offset)).Interface()
+}
@@ -245,52 +245,98 @@ func (ns *nullScanner) Scan(src interface{}) error {
 // in reflect.Value.
 func FieldRecipientsFromValueOf(logger logging.Logger, sqlFields []string,
 	fieldMap map[string]reflect.StructField, vod reflect.Value) []interface{} {
-	fieldRecipients := make([]interface{}, len(sqlFields), len(sqlFields))
-	for i, field := range sqlFields {
+	return NewRecipients(logger, sqlFields, fieldMap, vod.Type()).For(vod)
+}
+
+// recipient describes how to obtain the recipient of a column from a struct value.
+type recipient struct {
+	// index of the struct field, nil if the column is not mapped.
+	index []int
+	// nullable is true if the field is wrapped in a nullScanner.
+	nullable bool
+	// hot is true if the field is found by its offset, see RegisterHotType.
+	hot       bool
+	offset    uintptr
+	fieldType reflect.Type
+}
 
+// Recipients holds the recipients for the columns of a result set, they are bound to each row's
+// receiver with For, which saves building them, and the wrappers some need, for every row.
+type Recipients struct {
+	columns    []recipient
+	recipients []interface{}
+	scanners   []nullScanner
+}
+
+// NewRecipients returns the Recipients for the sqlFields of a result set read into values of the
+// struct type aType, mapped to its fields by fieldMap.
+func NewRecipients(logger logging.Logger, sqlFields []string,
+	fieldMap map[string]reflect.StructField, aType reflect.Type) *Recipients {
+	r := &Recipients{
+		columns:    make([]recipient, len(sqlFields)),
+		recipients: make([]interface{}, len(sqlFields)),
+		scanners:   make([]nullScanner, len(sqlFields)),
+	}
+	for i, field := range sqlFields {
 		// TODO, check datatype compatibility or let it burn?
 		fVal, ok := fieldMap[field]
 		if !ok {
-			empty := noopScanner{logger: logger, field: field}
-			fieldRecipients[i] = empty
+			r.recipients[i] = noopScanner{logger: logger, field: field}
 			continue
 		}
 		// We do this by name to be able to work around Anonymous fields (embedded structs) which
 		// are not as transparent to reflect as they are to basic syntax.
-		fieldI := vod.FieldByName(fVal.Name).Interface()
-		fieldPtrI := vod.FieldByName(fVal.Name).Addr().Interface()
+		structField, ok := aType.FieldByName(fVal.Name)
+		if !ok {
+			r.recipients[i] = noopScanner{logger: logger, field: field}
+			continue
+		}
+		r.columns[i].index = structField.Index
+		if offset, ok := fieldOffset(aType, structField.Index); ok {
+			r.columns[i].hot = true
+			r.columns[i].offset = offset
+			r.columns[i].fieldType = structField.Type
+		}
 
 		// pointer to string and time.Time are usually a declaration of intention to
 		// scan nullable fields of said types given that this is how gorm handles it
 		// so we wrap those in bubblewrap since sql.Scan does not know how to map
 		// nil to a pointer... I kid you not. `storing driver.Value type <nil> into type *time.Time`
-		switch fieldI.(type) {
-		case *string:
-			fieldRecipients[i] = &nullScanner{
-				fieldPtr: fieldPtrI,
-				logger:   logger,
-			}
-			continue
-		case string:
-			fieldRecipients[i] = &nullScanner{
-				fieldPtr: fieldPtrI,
-				logger:   logger,
-			}
-			continue
-		case *time.Time:
-			fieldRecipients[i] = &nullScanner{
-				fieldPtr: fieldPtrI,
-				logger:   logger,
-			}
+		switch structField.Type {
+		case stringType, stringPtrType, timeType, timePtrType:
+			r.columns[i].nullable = true
+			r.scanners[i].logger = logger
+			r.recipients[i] = &r.scanners[i]
+		}
+	}
+	return r
+}
+
+var (
+	stringType    = reflect.TypeOf("")
+	stringPtrType = reflect.PtrTo(stringType)
+	timeType      = reflect.TypeOf(time.Time{})
+	timePtrType   = reflect.PtrTo(timeType)
+)
+
+// For returns the recipients of the fields of vod, an addressable struct value, the returned
+// slice is the same for every call so it must be used before calling For again.
+func (r *Recipients) For(vod reflect.Value) []interface{} {
+	for i, column := range r.columns {
+		if column.index == nil {
 			continue
-		case time.Time:
-			fieldRecipients[i] = &nullScanner{
-				fieldPtr: fieldPtrI,
-				logger:   logger,
-			}
+		}
+		var fieldPtrI interface{}
+		if column.hot {
+			fieldPtrI = fieldPointer(vod, column.offset, column.fieldType)
+		} else {
+			fieldPtrI = vod.FieldByIndex(column.index).Addr().Interface()
+		}
+		if column.nullable {
+			r.scanners[i].fieldPtr = fieldPtrI
 			continue
 		}
-		fieldRecipients[i] = vod.FieldByName(fVal.Name).Addr().Interface()
+		r.recipients[i] = fieldPtrI
 	}
-	return fieldRecipients
+	return r.recipients
 }
@@ -0,0 +1,77 @@
+package srm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ShiftLeftSecurity/gaum/v2/db/logging"
+)
+
+type embeddedRecipient struct {
+	Created time.Time
+}
+
+type recipientsRow struct {
+	embeddedRecipient
+	ID          int `gaum:"field_name:id"`
+	Description *string
+}
+
+func TestRecipientsFor(t *testing.T) {
+	fields := []string{"id", "description", "created", "unmapped"}
+	_, fieldMap, err := MapFromTypeOf(reflect.TypeOf(recipientsRow{}), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients := NewRecipients(logging.NewGoTestingLogger(t), fields, fieldMap, reflect.TypeOf(recipientsRow{}))
+
+	rows := make([]recipientsRow, 2)
+	now := time.Now()
+	for i := range rows {
+		bound := recipients.For(reflect.ValueOf(&rows[i]).Elem())
+		*bound[0].(*int) = i
+		if err := bound[1].(*nullScanner).Scan("row"); err != nil {
+			t.Fatal(err)
+		}
+		if err := bound[2].(*nullScanner).Scan(now); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := bound[3].(noopScanner); !ok {
+			t.Errorf("expected the unmapped column to be ignored got %T", bound[3])
+		}
+	}
+	for i, row := range rows {
+		if row.ID != i || row.Description == nil || *row.Description != "row" || !row.Created.Equal(now) {
+			t.Errorf("row %d was not filled through its recipients: %+v", i, row)
+		}
+	}
+
+	row := reflect.ValueOf(&rows[0]).Elem()
+	allocs := testing.AllocsPerRun(100, func() {
+		recipients.For(row)
+	})
+	if allocs != 0 {
+		t.Errorf("expected binding the recipients not to allocate, got %v allocations", allocs)
+	}
+}
+
+func TestRegisterHotType(t *testing.T) {
+	fields := []string{"id", "description", "created"}
+	_, fieldMap, err := MapFromTypeOf(reflect.TypeOf(recipientsRow{}), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	safe := NewRecipients(logging.NewGoTestingLogger(t), fields, fieldMap, reflect.TypeOf(recipientsRow{}))
+	RegisterHotType(&recipientsRow{})
+	hot := NewRecipients(logging.NewGoTestingLogger(t), fields, fieldMap, reflect.TypeOf(recipientsRow{}))
+
+	var row recipientsRow
+	vod := reflect.ValueOf(&row).Elem()
+	expected := safe.For(vod)
+	got := hot.For(vod)
+	if got[0] != expected[0] || got[1].(*nullScanner).fieldPtr != expected[1].(*nullScanner).fieldPtr ||
+		got[2].(*nullScanner).fieldPtr != expected[2].(*nullScanner).fieldPtr {
+		t.Errorf("expected the hot type recipients to point to the same fields")
+	}
+}
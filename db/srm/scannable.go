@@ -0,0 +1,37 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package srm
+
+import "reflect"
+
+// Scannable is implemented by types that bind their own fields to the columns of a row, when the
+// receiver of a query implements it (through a pointer) the reflection based mapping is skipped
+// and GaumScan is called for each row instead, with the names of the columns fetched and a
+// function that scans them, in that order, into the passed recipients.
+type Scannable interface {
+	GaumScan(fields []string, scan func(dest ...interface{}) error) error
+}
+
+var scannableType = reflect.TypeOf((*Scannable)(nil)).Elem()
+
+// IsScannable returns true if a pointer to a value of the passed type implements Scannable.
+func IsScannable(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(scannableType)
+}
+
+// ScanValueOf scans a row into vod, an addressable value of a type for which IsScannable is true.
+func ScanValueOf(vod reflect.Value, fields []string, scan func(dest ...interface{}) error) error {
+	return vod.Addr().Interface().(Scannable).GaumScan(fields, scan)
+}
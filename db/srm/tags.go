@@ -0,0 +1,130 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package srm
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Sub tags, besides SubTagNameFieldName, that describe the column a field maps to, they are
+// separated by ";" as in `gaum:"field_name:id;type:bigserial;pk"`, those without a value are
+// flags.
+const (
+	// SubTagNameType holds the SQL type of the column, overriding the one inferred from the Go type.
+	SubTagNameType = "type"
+	// SubTagNamePrimaryKey marks the column as (part of) the primary key.
+	SubTagNamePrimaryKey = "pk"
+	// SubTagNameUnique marks the column as unique, columns with the same value, which is the
+	// name of the constraint, are unique together.
+	SubTagNameUnique = "unique"
+	// SubTagNameIndex marks the column to be indexed, columns with the same value, which is the
+	// name of the index, are indexed together.
+	SubTagNameIndex = "index"
+	// SubTagNameDefault holds the default value of the column, as an SQL expression.
+	SubTagNameDefault = "default"
+	// SubTagNameNull marks the column as nullable even if the Go type can't hold a NULL.
+	SubTagNameNull = "null"
+	// SubTagNameHasMany marks a slice field as the children of a has many relation, its value
+	// is the column of the children that references the parent, the field maps to no column.
+	SubTagNameHasMany = "has_many"
+	// SubTagNameBelongsTo marks a struct field as the parent of a belongs to relation, its
+	// value is the column of this struct that references the parent, the field maps to no column.
+	SubTagNameBelongsTo = "belongs_to"
+	// SubTagNameAutoCreate marks a time.Time field that is set to the current time when the
+	// struct is inserted, if it holds the zero value.
+	SubTagNameAutoCreate = "autocreate"
+	// SubTagNameAutoUpdate marks a time.Time field that is set to the current time when the
+	// struct is inserted, if it holds the zero value, and whenever its row is updated.
+	SubTagNameAutoUpdate = "autoupdate"
+	// SubTagNameSoftDelete marks the nullable timestamp column that, when set, marks the row as
+	// deleted, rows of such models are not removed by DELETE but have it set instead.
+	SubTagNameSoftDelete = "soft_delete"
+	// SubTagNameVersion marks the integer column used for optimistic locking, updates of the
+	// struct only apply to the row if it still is at the version the struct holds and bump it.
+	SubTagNameVersion = "version"
+	// SubTagNameOmit marks a field that is read but never written when writing the struct, ie
+	// a generated column.
+	SubTagNameOmit = "omit"
+	// SubTagNameReadOnly marks a field that is written when the struct is inserted but not when
+	// it is updated, ie the owner of the row.
+	SubTagNameReadOnly = "readonly"
+)
+
+// ColumnName returns the name of the column field maps to, from the field_name sub tag or the
+// snake cased name of the field.
+func ColumnName(field reflect.StructField) string {
+	return nameFromTagOrName(field)
+}
+
+// TagOptions returns the sub tags of the gaum tag of field by name, flags map to "".
+func TagOptions(field reflect.StructField) map[string]string {
+	options := map[string]string{}
+	tagText, ok := field.Tag.Lookup(TagName)
+	if !ok {
+		return options
+	}
+	for _, segment := range strings.Split(tagText, ";") {
+		segment = strings.TrimSpace(segment)
+		if segment == "" {
+			continue
+		}
+		pair := strings.SplitN(segment, ":", 2)
+		if len(pair) == 1 {
+			options[pair[0]] = ""
+			continue
+		}
+		options[pair[0]] = pair[1]
+	}
+	return options
+}
+
+// OrderedFields returns the exported fields of the struct type tod in the order they are
+// declared, with the fields of embedded structs in place of the embedded one. Fields shadowed,
+// as MapFromTypeOf does, by an outer one with the same column name are left out.
+func OrderedFields(tod reflect.Type) []reflect.StructField {
+	for tod.Kind() == reflect.Ptr || tod.Kind() == reflect.Slice {
+		tod = tod.Elem()
+	}
+	_, fieldMap, err := MapFromTypeOf(tod, []reflect.Kind{reflect.Struct}, nil)
+	if err != nil {
+		return nil
+	}
+	var fields []reflect.StructField
+	seen := map[string]bool{}
+	var walk func(reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Anonymous {
+				if field.Type.Kind() == reflect.Struct {
+					walk(field.Type)
+				}
+				continue
+			}
+			if field.PkgPath != "" {
+				continue
+			}
+			name := nameFromTagOrName(field)
+			if seen[name] || fieldMap[name].Name != field.Name || fieldMap[name].Type != field.Type {
+				continue
+			}
+			seen[name] = true
+			fields = append(fields, field)
+		}
+	}
+	walk(tod)
+	return fields
+}
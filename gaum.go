@@ -18,6 +18,7 @@ import (
 	"context"
 
 	"github.com/ShiftLeftSecurity/gaum/v2/db/connection"
+	"github.com/ShiftLeftSecurity/gaum/v2/db/model"
 	"github.com/ShiftLeftSecurity/gaum/v2/db/postgres"
 	"github.com/pkg/errors"
 )
@@ -34,3 +35,23 @@ func Open(ctx context.Context, driver string, connInfo *connection.Information)
 	}
 	return handler.Open(ctx, connInfo)
 }
+
+// Register maps the struct of m, a struct or a pointer to one, to the table and keys set by
+// options, so chain and q can infer them from the destination of queries, ie:
+//
+//	err := gaum.Register(&User{}, gaum.Table("users"), gaum.PK("id"))
+//
+// See model.Register.
+func Register(m interface{}, options ...model.Option) error {
+	return model.Register(m, options...)
+}
+
+// Table sets the table a registered model is stored in.
+func Table(name string) model.Option {
+	return model.Table(name)
+}
+
+// PK sets the primary key columns of a registered model.
+func PK(columns ...string) model.Option {
+	return model.PK(columns...)
+}
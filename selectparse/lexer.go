@@ -0,0 +1,301 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package selectparse
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TokenKind is the kind of a lexical Token of a SQL statement.
+type TokenKind int
+
+const (
+	// Space is a run of white space.
+	Space TokenKind = iota
+	// Comment is a -- line or a /* */ block comment, block comments can be nested.
+	Comment
+	// Word is an unquoted identifier or a keyword.
+	Word
+	// QuotedIdentifier is a double quoted identifier such as "UserName".
+	QuotedIdentifier
+	// String is a string constant in any of its forms, ie 'a', E'\n' or $$a$$.
+	String
+	// Number is a numeric constant.
+	Number
+	// Placeholder is a ? argument mark.
+	Placeholder
+	// EscapedPlaceholder is \? which stands for a ? operator and not an argument.
+	EscapedPlaceholder
+	// Positional is a $1 style argument.
+	Positional
+	// OpenParens is (.
+	OpenParens
+	// CloseParens is ).
+	CloseParens
+	// Comma is ,.
+	Comma
+	// Dot is the . that qualifies names.
+	Dot
+	// Operator is any operator, including * and ::.
+	Operator
+	// Punctuation is any other character, such as ; [ or ].
+	Punctuation
+)
+
+// Token is a lexical token of a SQL statement, concatenating the Text of all the tokens of a
+// statement yields the statement.
+type Token struct {
+	Kind TokenKind
+	Text string
+	// Pos is the byte offset of the token in the statement.
+	Pos int
+}
+
+// Is returns true if the token is the passed keyword or word, in any case.
+func (t Token) Is(word string) bool {
+	return t.Kind == Word && strings.EqualFold(t.Text, word)
+}
+
+// Tokenize splits statement in its lexical tokens as postgres would, so placeholders, commas or
+// parens inside strings, quoted identifiers and comments are not mistaken for what they look
+// like. Unterminated strings, identifiers or comments run to the end of the statement.
+func Tokenize(statement string) []Token {
+	var tokens []Token
+	EachToken(statement, func(t Token) {
+		tokens = append(tokens, t)
+	})
+	return tokens
+}
+
+// EachToken calls fn with each of the tokens of statement, as Tokenize returns them, without
+// holding them all in memory.
+func EachToken(statement string, fn func(Token)) {
+	l := lexer{src: statement}
+	for l.pos < len(l.src) {
+		start := l.pos
+		kind := l.next()
+		fn(Token{Kind: kind, Text: l.src[start:l.pos], Pos: start})
+	}
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+const operatorChars = "+-*/<>=~!@#%^&|`:"
+
+func (l *lexer) at(offset int) byte {
+	if l.pos+offset < len(l.src) {
+		return l.src[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) startsComment() bool {
+	return (l.at(0) == '-' && l.at(1) == '-') || (l.at(0) == '/' && l.at(1) == '*')
+}
+
+// next consumes one token and returns its kind.
+func (l *lexer) next() TokenKind {
+	c := l.at(0)
+	switch {
+	case isSpace(c):
+		for l.pos < len(l.src) && isSpace(l.at(0)) {
+			l.pos++
+		}
+		return Space
+	case c == '-' && l.at(1) == '-':
+		for l.pos < len(l.src) && l.at(0) != '\n' {
+			l.pos++
+		}
+		return Comment
+	case c == '/' && l.at(1) == '*':
+		l.blockComment()
+		return Comment
+	case c == '\'':
+		l.quoted('\'', false)
+		return String
+	case c == '"':
+		l.quoted('"', false)
+		return QuotedIdentifier
+	case (c == 'e' || c == 'E') && l.at(1) == '\'':
+		l.pos++
+		l.quoted('\'', true)
+		return String
+	case strings.IndexByte("bBxXnN", c) != -1 && c != 0 && l.at(1) == '\'':
+		l.pos++
+		l.quoted('\'', false)
+		return String
+	case c == '$':
+		return l.dollar()
+	case c == '?':
+		l.pos++
+		return Placeholder
+	case c == '\\' && l.at(1) == '?':
+		l.pos += 2
+		return EscapedPlaceholder
+	case c == '(':
+		l.pos++
+		return OpenParens
+	case c == ')':
+		l.pos++
+		return CloseParens
+	case c == ',':
+		l.pos++
+		return Comma
+	case c == '.' && !isDigit(l.at(1)):
+		l.pos++
+		return Dot
+	case isDigit(c) || c == '.':
+		l.number()
+		return Number
+	case isWordStart(l.src[l.pos:]):
+		l.word()
+		return Word
+	case strings.IndexByte(operatorChars, c) != -1:
+		l.pos++
+		for l.pos < len(l.src) && strings.IndexByte(operatorChars, l.at(0)) != -1 && !l.startsComment() {
+			l.pos++
+		}
+		return Operator
+	}
+	l.pos++
+	return Punctuation
+}
+
+func (l *lexer) blockComment() {
+	depth := 0
+	for l.pos < len(l.src) {
+		switch {
+		case l.at(0) == '/' && l.at(1) == '*':
+			depth++
+			l.pos += 2
+		case l.at(0) == '*' && l.at(1) == '/':
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				return
+			}
+		default:
+			l.pos++
+		}
+	}
+}
+
+// quoted consumes a quote delimited token, doubled quotes are escaped quotes and so is a quote
+// preceded by a backslash if backslashes escape.
+func (l *lexer) quoted(quote byte, backslashes bool) {
+	l.pos++
+	for l.pos < len(l.src) {
+		switch c := l.at(0); {
+		case backslashes && c == '\\':
+			l.pos += 2
+		case c == quote && l.at(1) == quote:
+			l.pos += 2
+		case c == quote:
+			l.pos++
+			return
+		default:
+			l.pos++
+		}
+	}
+	if l.pos > len(l.src) {
+		l.pos = len(l.src)
+	}
+}
+
+// dollar consumes either a $1 style argument or a dollar quoted string.
+func (l *lexer) dollar() TokenKind {
+	if isDigit(l.at(1)) {
+		l.pos++
+		for isDigit(l.at(0)) {
+			l.pos++
+		}
+		return Positional
+	}
+	end := 1
+	for l.pos+end < len(l.src) && (l.at(end) == '_' || isLetterOrDigit(l.src[l.pos+end:])) {
+		end++
+	}
+	if l.at(end) != '$' || isDigit(l.at(1)) {
+		l.pos++
+		return Punctuation
+	}
+	tag := l.src[l.pos : l.pos+end+1]
+	closing := strings.Index(l.src[l.pos+len(tag):], tag)
+	if closing == -1 {
+		l.pos = len(l.src)
+		return String
+	}
+	l.pos += len(tag) + closing + len(tag)
+	return String
+}
+
+func (l *lexer) number() {
+	for isDigit(l.at(0)) || l.at(0) == '.' || l.at(0) == '_' {
+		// a .. is not part of a number (ie, array slices)
+		if l.at(0) == '.' && l.at(1) == '.' {
+			return
+		}
+		l.pos++
+	}
+	if (l.at(0) == 'e' || l.at(0) == 'E') &&
+		(isDigit(l.at(1)) || ((l.at(1) == '+' || l.at(1) == '-') && isDigit(l.at(2)))) {
+		l.pos += 2
+		for isDigit(l.at(0)) {
+			l.pos++
+		}
+	}
+}
+
+func (l *lexer) word() {
+	for l.pos < len(l.src) && (l.at(0) == '_' || l.at(0) == '$' || isLetterOrDigit(l.src[l.pos:])) {
+		if l.at(0) >= 0x80 {
+			for _, r := range l.src[l.pos:] {
+				l.pos += len(string(r))
+				break
+			}
+			continue
+		}
+		l.pos++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordStart(s string) bool {
+	if s[0] == '_' {
+		return true
+	}
+	for _, r := range s {
+		return unicode.IsLetter(r)
+	}
+	return false
+}
+
+func isLetterOrDigit(s string) bool {
+	for _, r := range s {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+	return false
+}
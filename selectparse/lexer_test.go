@@ -0,0 +1,48 @@
+package selectparse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		statement string
+		kinds     []TokenKind
+	}{
+		{statement: `a.b`, kinds: []TokenKind{Word, Dot, Word}},
+		{statement: `"a ""b"",".c`, kinds: []TokenKind{QuotedIdentifier, Dot, Word}},
+		{statement: `'it''s ?' ?`, kinds: []TokenKind{String, Space, Placeholder}},
+		{statement: `E'\' ?' \? $1`, kinds: []TokenKind{String, Space, EscapedPlaceholder, Space, Positional}},
+		{statement: `$tag$ ? $$ $tag$||$$?$$`, kinds: []TokenKind{String, Operator, String}},
+		{statement: "-- ?\n? /* ? /* ? */ */?", kinds: []TokenKind{Comment, Space, Placeholder, Space, Comment, Placeholder}},
+		{statement: `x::numeric(10,2)`, kinds: []TokenKind{Word, Operator, Word, OpenParens, Number, Comma, Number, CloseParens}},
+		{statement: `1.5e3 .5 a[1:2]`, kinds: []TokenKind{Number, Space, Number, Space, Word, Punctuation, Number,
+			Operator, Number, Punctuation}},
+		{statement: `a<=-- c`, kinds: []TokenKind{Word, Operator, Comment}},
+		{statement: `'unterminated ?`, kinds: []TokenKind{String}},
+		{statement: `año $`, kinds: []TokenKind{Word, Space, Punctuation}},
+	}
+	for _, tt := range tests {
+		tokens := Tokenize(tt.statement)
+		var text strings.Builder
+		var kinds []TokenKind
+		for _, token := range tokens {
+			text.WriteString(token.Text)
+			kinds = append(kinds, token.Kind)
+		}
+		if text.String() != tt.statement {
+			t.Errorf("expected the tokens of %q to add up to it got %q", tt.statement, text.String())
+		}
+		if len(kinds) != len(tt.kinds) {
+			t.Errorf("expected %v for %q got %v (%q)", tt.kinds, tt.statement, kinds, tokens)
+			continue
+		}
+		for i := range kinds {
+			if kinds[i] != tt.kinds[i] {
+				t.Errorf("expected %v for %q got %v (%q)", tt.kinds, tt.statement, kinds, tokens)
+				break
+			}
+		}
+	}
+}
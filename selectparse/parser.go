@@ -0,0 +1,531 @@
+//    Copyright 2018 Horacio Duran <horacio@shiftleft.io>, ShiftLeft Inc.
+//
+//    Licensed under the Apache License, Version 2.0 (the "License");
+//    you may not use this file except in compliance with the License.
+//    You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+//    Unless required by applicable law or agreed to in writing, software
+//    distributed under the License is distributed on an "AS IS" BASIS,
+//    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//    See the License for the specific language governing permissions and
+//    limitations under the License.
+
+package selectparse
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Select is a parsed SELECT statement, expressions are kept as written, except for comments
+// which are replaced by a space.
+type Select struct {
+	Distinct bool
+	// DistinctOn holds the expressions of DISTINCT ON, without the parens.
+	DistinctOn string
+	Columns    []Column
+	From       []TableReference
+	Where      string
+	GroupBy    []string
+	Having     string
+	Window     string
+	OrderBy    []string
+	Limit      string
+	Offset     string
+	Fetch      string
+	// Locking is the locking clause without the initial FOR, ie "UPDATE SKIP LOCKED".
+	Locking string
+}
+
+// Column is an item of the select list.
+type Column struct {
+	// Expression is the column as written, without the AS alias if any.
+	Expression string
+	// Alias is the name given with AS, as written.
+	Alias string
+	// Name is the name postgres will most likely give to the column, empty if it can't be
+	// guessed or this is a star.
+	Name string
+	// Star is true for * and qualifier.* columns.
+	Star bool
+	// Qualifier is what qualifies a star column, ie u for u.*.
+	Qualifier string
+}
+
+// TableReference is an item of the FROM clause.
+type TableReference struct {
+	// Join is the join type, ie "LEFT JOIN", "," for items separated by a comma and empty for
+	// the first item.
+	Join string
+	// Expression is the table, sub-query or function as written, including LATERAL or ONLY.
+	Expression string
+	// Table is the name of the table, empty for sub-queries and functions.
+	Table string
+	// Alias is the alias, as written.
+	Alias string
+	// On is the join condition.
+	On string
+	// Using is the list of columns of a USING join, without the parens.
+	Using string
+}
+
+// Name returns the name the table reference goes by in the rest of the query, its alias if it
+// has one and its table otherwise.
+func (t TableReference) Name() string {
+	if t.Alias != "" {
+		return t.Alias
+	}
+	return t.Table
+}
+
+// clauses of a SELECT, in the order they must be found.
+var selectClauses = [][]string{
+	{"FROM"}, {"WHERE"}, {"GROUP", "BY"}, {"HAVING"}, {"WINDOW"}, {"ORDER", "BY"}, {"LIMIT"}, {"OFFSET"},
+	{"FETCH"}, {"FOR"},
+}
+
+// ParseSelect parses a single SELECT statement, set operations (UNION and the like) and WITH
+// are not supported.
+func ParseSelect(statement string) (*Select, error) {
+	tokens := trim(Tokenize(statement))
+	if n := len(tokens); n != 0 && tokens[n-1].Text == ";" {
+		tokens = trim(tokens[:n-1])
+	}
+	if len(tokens) == 0 || !tokens[0].Is("SELECT") {
+		return nil, errors.Errorf("expected a SELECT statement got %q", statement)
+	}
+	tokens = tokens[1:]
+
+	// find where each clause starts.
+	starts := make([]int, len(selectClauses))
+	ends := make([]int, len(selectClauses))
+	for i := range starts {
+		starts[i] = -1
+	}
+	listEnd := len(tokens)
+	last := -1
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i].Kind {
+		case OpenParens:
+			depth++
+			continue
+		case CloseParens:
+			depth--
+			continue
+		}
+		if depth != 0 || tokens[i].Kind != Word {
+			continue
+		}
+		for _, setOp := range []string{"UNION", "INTERSECT", "EXCEPT"} {
+			if tokens[i].Is(setOp) {
+				return nil, errors.Errorf("%s is not supported", setOp)
+			}
+		}
+		for c, keywords := range selectClauses {
+			end, ok := keywordsAt(tokens, i, keywords...)
+			if !ok {
+				continue
+			}
+			if c <= last {
+				return nil, errors.Errorf("unexpected %s in %q", strings.Join(keywords, " "), statement)
+			}
+			if last == -1 {
+				listEnd = i
+			} else {
+				ends[last] = i
+			}
+			starts[c] = end
+			last = c
+			i = end - 1
+			break
+		}
+	}
+	if depth != 0 {
+		return nil, errors.Errorf("unbalanced parens in %q", statement)
+	}
+	if last != -1 {
+		ends[last] = len(tokens)
+	}
+	clause := func(c int) []Token {
+		if starts[c] == -1 {
+			return nil
+		}
+		return tokens[starts[c]:ends[c]]
+	}
+
+	s := &Select{}
+	list := trim(tokens[:listEnd])
+	switch {
+	case len(list) != 0 && list[0].Is("ALL"):
+		list = trim(list[1:])
+	case len(list) != 0 && list[0].Is("DISTINCT"):
+		s.Distinct = true
+		list = trim(list[1:])
+		if len(list) != 0 && list[0].Is("ON") {
+			list = trim(list[1:])
+			group, rest := leadingGroup(list)
+			if group == nil {
+				return nil, errors.Errorf("expected the expressions of DISTINCT ON in %q", statement)
+			}
+			s.DistinctOn = render(group[1 : len(group)-1])
+			list = trim(rest)
+		}
+	}
+	if len(list) == 0 {
+		return nil, errors.Errorf("no columns found in %q", statement)
+	}
+	s.Columns = parseColumns(list)
+
+	if from := clause(0); from != nil {
+		var err error
+		s.From, err = parseFrom(from)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing FROM of %q", statement)
+		}
+	}
+	s.Where = render(clause(1))
+	s.GroupBy = renderList(clause(2))
+	s.Having = render(clause(3))
+	s.Window = render(clause(4))
+	s.OrderBy = renderList(clause(5))
+	s.Limit = render(clause(6))
+	s.Offset = render(clause(7))
+	s.Fetch = render(clause(8))
+	s.Locking = render(clause(9))
+	return s, nil
+}
+
+// ParseFrom parses the contents of a FROM clause, ie "users AS u JOIN orders o ON o.uid = u.id".
+func ParseFrom(from string) ([]TableReference, error) {
+	return parseFrom(Tokenize(from))
+}
+
+func parseColumns(list []Token) []Column {
+	var columns []Column
+	for _, item := range splitTopLevel(list, func(t Token) bool { return t.Kind == Comma }) {
+		c := Column{Expression: render(item)}
+		if qualifier, ok := starQualifier(c.Expression); ok {
+			c.Star, c.Qualifier = true, qualifier
+			columns = append(columns, c)
+			continue
+		}
+		c.Name = columnName(c.Expression)
+		words := splitTopLevel(item, isBlank)
+		if n := len(words); n > 2 && len(words[n-2]) == 1 && words[n-2][0].Is("AS") {
+			c.Alias = render(words[n-1])
+			c.Expression = render(item[:words[n-2][0].index(item)])
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// joinWords are the words that can make a join type.
+var joinWords = map[string]bool{"NATURAL": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true,
+	"CROSS": true, "OUTER": true, "JOIN": true}
+
+func parseFrom(tokens []Token) ([]TableReference, error) {
+	var references []TableReference
+	join := ""
+	start := 0
+	depth := 0
+	add := func(end int) error {
+		reference, err := parseTableReference(tokens[start:end])
+		if err != nil {
+			return err
+		}
+		reference.Join = join
+		references = append(references, reference)
+		return nil
+	}
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.Kind == OpenParens:
+			depth++
+		case t.Kind == CloseParens:
+			depth--
+		case depth != 0:
+		case t.Kind == Comma:
+			if err := add(i); err != nil {
+				return nil, err
+			}
+			join, start = ",", i+1
+		case t.Kind == Word && joinWords[strings.ToUpper(t.Text)]:
+			var words []string
+			end := i
+			for end < len(tokens) {
+				if tokens[end].Kind == Space || tokens[end].Kind == Comment {
+					end++
+					continue
+				}
+				if tokens[end].Kind != Word || !joinWords[strings.ToUpper(tokens[end].Text)] {
+					break
+				}
+				words = append(words, strings.ToUpper(tokens[end].Text))
+				end++
+				if words[len(words)-1] == "JOIN" {
+					break
+				}
+			}
+			if words[len(words)-1] != "JOIN" {
+				// something like the left() function.
+				continue
+			}
+			if err := add(i); err != nil {
+				return nil, err
+			}
+			join, start = strings.Join(words, " "), end
+			i = end - 1
+		}
+	}
+	if err := add(len(tokens)); err != nil {
+		return nil, err
+	}
+	return references, nil
+}
+
+func parseTableReference(tokens []Token) (TableReference, error) {
+	reference := TableReference{}
+	source := tokens
+	for i, t := range tokens {
+		if !(t.Is("ON") || t.Is("USING")) || depthAt(tokens, i) != 0 {
+			continue
+		}
+		source = tokens[:i]
+		if t.Is("ON") {
+			reference.On = render(tokens[i+1:])
+		} else {
+			using := trim(tokens[i+1:])
+			if len(using) < 2 || using[0].Kind != OpenParens || using[len(using)-1].Kind != CloseParens {
+				return reference, errors.Errorf("expected the columns of USING got %q", render(using))
+			}
+			reference.Using = render(using[1 : len(using)-1])
+		}
+		break
+	}
+
+	words := splitTopLevel(source, isBlank)
+	if len(words) == 0 || len(words[0]) == 0 {
+		return reference, errors.Errorf("expected a table got %q", render(tokens))
+	}
+	// the source is the first word, after LATERAL or ONLY, with the arguments if it is a function.
+	n := 0
+	if len(words[0]) == 1 && (words[0][0].Is("LATERAL") || words[0][0].Is("ONLY")) {
+		n++
+	}
+	if n >= len(words) {
+		return reference, errors.Errorf("expected a table got %q", render(tokens))
+	}
+	table := words[n]
+	if _, isName := identifierName(table); isName {
+		reference.Table = render(table)
+	}
+	n++
+	reference.Expression = render(joinWordsTokens(words[:n]))
+	if n < len(words) && len(words[n]) == 1 && words[n][0].Is("AS") {
+		n++
+	}
+	if n < len(words) {
+		reference.Alias = render(words[n])
+		// column aliases, ie t(a, b), are kept with the alias.
+		if i := strings.IndexByte(reference.Alias, '('); i != -1 {
+			reference.Alias = strings.TrimSpace(reference.Alias[:i])
+		}
+	}
+	return reference, nil
+}
+
+// keywordsAt returns the position after keywords if they are found at tokens[i], separated only
+// by space or comments.
+func keywordsAt(tokens []Token, i int, keywords ...string) (int, bool) {
+	for k, keyword := range keywords {
+		if k != 0 {
+			for i < len(tokens) && (tokens[i].Kind == Space || tokens[i].Kind == Comment) {
+				i++
+			}
+		}
+		if i >= len(tokens) || !tokens[i].Is(keyword) {
+			return 0, false
+		}
+		i++
+	}
+	return i, true
+}
+
+// splitTopLevel splits tokens on the ones isSep accepts outside of parens, which are dropped
+// along with the space around each part, parts that end up empty are skipped.
+func splitTopLevel(tokens []Token, isSep func(Token) bool) [][]Token {
+	var parts [][]Token
+	depth := 0
+	start := 0
+	for i, t := range tokens {
+		switch t.Kind {
+		case OpenParens:
+			depth++
+		case CloseParens:
+			depth--
+		}
+		if depth == 0 && isSep(t) {
+			if part := trim(tokens[start:i]); len(part) != 0 {
+				parts = append(parts, part)
+			}
+			start = i + 1
+		}
+	}
+	if part := trim(tokens[start:]); len(part) != 0 {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// isBlank is true for the tokens that separate words, note that a function call, name and
+// arguments, is a single word as long as there is no space between them.
+func isBlank(t Token) bool {
+	return t.Kind == Space || t.Kind == Comment
+}
+
+// leadingGroup returns the parenthesized group at the start of tokens and what follows it.
+func leadingGroup(tokens []Token) ([]Token, []Token) {
+	if len(tokens) == 0 || tokens[0].Kind != OpenParens {
+		return nil, tokens
+	}
+	depth := 0
+	for i, t := range tokens {
+		switch t.Kind {
+		case OpenParens:
+			depth++
+		case CloseParens:
+			depth--
+			if depth == 0 {
+				return tokens[:i+1], tokens[i+1:]
+			}
+		}
+	}
+	return nil, tokens
+}
+
+// depthAt returns how many parens are open at tokens[i].
+func depthAt(tokens []Token, i int) int {
+	depth := 0
+	for _, t := range tokens[:i] {
+		switch t.Kind {
+		case OpenParens:
+			depth++
+		case CloseParens:
+			depth--
+		}
+	}
+	return depth
+}
+
+// index returns the position of t in tokens, which it must belong to.
+func (t Token) index(tokens []Token) int {
+	for i := range tokens {
+		if tokens[i].Pos == t.Pos {
+			return i
+		}
+	}
+	return -1
+}
+
+// identifierName returns the last part of tokens if they are an, optionally qualified, name
+// such as t."UserName", unquoted parts are lowercased as postgres does.
+func identifierName(tokens []Token) (string, bool) {
+	name := ""
+	for i, t := range tokens {
+		if i%2 == 1 {
+			if t.Kind != Dot {
+				return "", false
+			}
+			continue
+		}
+		switch t.Kind {
+		case Word:
+			name = strings.ToLower(t.Text)
+		case QuotedIdentifier:
+			unquoted, ok := unquote(t.Text)
+			if !ok {
+				return "", false
+			}
+			name = unquoted
+		default:
+			return "", false
+		}
+	}
+	if len(tokens)%2 == 0 {
+		return "", false
+	}
+	return name, true
+}
+
+// unquote returns the contents of a double quoted identifier, false if it is not terminated.
+func unquote(quoted string) (string, bool) {
+	var b strings.Builder
+	for i := 1; i < len(quoted); i++ {
+		if quoted[i] != '"' {
+			b.WriteByte(quoted[i])
+			continue
+		}
+		if i == len(quoted)-1 {
+			return b.String(), true
+		}
+		if quoted[i+1] != '"' {
+			return "", false
+		}
+		b.WriteByte('"')
+		i++
+	}
+	return "", false
+}
+
+// trim drops the space and comments around tokens.
+func trim(tokens []Token) []Token {
+	for len(tokens) != 0 && isBlank(tokens[0]) {
+		tokens = tokens[1:]
+	}
+	for len(tokens) != 0 && isBlank(tokens[len(tokens)-1]) {
+		tokens = tokens[:len(tokens)-1]
+	}
+	return tokens
+}
+
+// render returns the text of tokens, comments are replaced by a space.
+func render(tokens []Token) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		if t.Kind == Comment {
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteString(t.Text)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func renderList(tokens []Token) []string {
+	if tokens == nil {
+		return nil
+	}
+	var items []string
+	for _, item := range splitTopLevel(tokens, func(t Token) bool { return t.Kind == Comma }) {
+		items = append(items, render(item))
+	}
+	return items
+}
+
+// joinWordsTokens puts back together words split by splitTopLevel, separated by a space.
+func joinWordsTokens(words [][]Token) []Token {
+	var tokens []Token
+	for i, word := range words {
+		if i != 0 {
+			tokens = append(tokens, Token{Kind: Space, Text: " "})
+		}
+		tokens = append(tokens, word...)
+	}
+	return tokens
+}
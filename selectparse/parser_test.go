@@ -0,0 +1,88 @@
+package selectparse
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseSelect(t *testing.T) {
+	statement := `SELECT DISTINCT ON (u.id) u.*, o.total AS "Total", count(*) OVER (PARTITION BY u.id)
+		FROM ONLY users AS u
+		LEFT OUTER JOIN orders o ON o.user_id = u.id AND o.state IN ('from', 'where')
+		JOIN LATERAL (SELECT 1 FROM x WHERE x.id = u.id LIMIT 1) AS l ON true
+		CROSS JOIN generate_series(1, 3) g(n), tags
+		JOIN groups USING (group_id)
+		WHERE u.id > ? /* ORDER BY */ AND left(u.name, 1) = 'a'
+		GROUP BY u.id, o.total HAVING count(*) > 1
+		ORDER BY u.id DESC, 2 LIMIT 10 OFFSET ? FOR UPDATE SKIP LOCKED;`
+	s, err := ParseSelect(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Select{
+		Distinct:   true,
+		DistinctOn: "u.id",
+		Columns: []Column{
+			{Expression: "u.*", Star: true, Qualifier: "u"},
+			{Expression: "o.total", Alias: `"Total"`, Name: "Total"},
+			{Expression: "count(*) OVER (PARTITION BY u.id)", Name: "count"},
+		},
+		From: []TableReference{
+			{Expression: "ONLY users", Table: "users", Alias: "u"},
+			{Join: "LEFT OUTER JOIN", Expression: "orders", Table: "orders", Alias: "o",
+				On: "o.user_id = u.id AND o.state IN ('from', 'where')"},
+			{Join: "JOIN", Expression: "LATERAL (SELECT 1 FROM x WHERE x.id = u.id LIMIT 1)", Alias: "l", On: "true"},
+			{Join: "CROSS JOIN", Expression: "generate_series(1, 3)", Alias: "g"},
+			{Join: ",", Expression: "tags", Table: "tags"},
+			{Join: "JOIN", Expression: "groups", Table: "groups", Using: "group_id"},
+		},
+		Where:   "u.id > ?   AND left(u.name, 1) = 'a'",
+		GroupBy: []string{"u.id", "o.total"},
+		Having:  "count(*) > 1",
+		OrderBy: []string{"u.id DESC", "2"},
+		Limit:   "10",
+		Offset:  "?",
+		Locking: "UPDATE SKIP LOCKED",
+	}
+	if diff := deep.Equal(s, expected); diff != nil {
+		t.Error(diff)
+	}
+	if s.From[1].Name() != "o" || s.From[4].Name() != "tags" {
+		t.Errorf("expected references to go by their alias or table")
+	}
+}
+
+func TestParseSelectErrors(t *testing.T) {
+	for _, statement := range []string{
+		"UPDATE users SET a = 1",
+		"SELECT 1 UNION SELECT 2",
+		"SELECT a FROM t WHERE (a = 1",
+		"SELECT a FROM t ORDER BY a WHERE a = 1",
+		"SELECT FROM t",
+		"SELECT a FROM t JOIN u USING a",
+	} {
+		if _, err := ParseSelect(statement); err == nil {
+			t.Errorf("expected an error parsing %q", statement)
+		}
+	}
+}
+
+func TestFieldsFromSelectMatchesParseSelect(t *testing.T) {
+	list := `id, u.name AS "Name", count(*) OVER (PARTITION BY u.id), created_at::date`
+	s, err := ParseSelect("SELECT " + list + " FROM users u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range s.Columns {
+		names = append(names, c.Name)
+	}
+	fields, err := FieldsFromSelect(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(names, fields); diff != nil {
+		t.Error(diff)
+	}
+}
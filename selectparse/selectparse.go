@@ -21,24 +21,60 @@ import (
 	"github.com/pkg/errors"
 )
 
-const (
-	openParens  = '('
-	closeParens = ')'
-	comma       = ','
-	escapeChar  = '\\'
-	space       = ' '
-)
-
 // FieldsFromSelect returns a list of field names based on the columns of a select statement
 // or error if it's unable to extract them.
 func FieldsFromSelect(statement string) ([]string, error) {
-	s := &SelectParser{Statement: statement}
-	s.splitFields()
-	err := s.extractNames()
-	if err != nil {
-		return nil, errors.Wrapf(err, "extracting column names from %q", statement)
+	return fieldsFromColumns(statement, nil)
+}
+
+// FieldsFromSelectExpanding is FieldsFromSelect but `*` and `qualifier.*` columns are replaced by
+// the columns that expand returns for their qualifier, which is passed as written in the
+// statement and is empty for a bare `*`.
+func FieldsFromSelectExpanding(statement string, expand func(qualifier string) ([]string, error)) ([]string, error) {
+	return fieldsFromColumns(statement, expand)
+}
+
+// fieldsFromColumns returns the names of the columns of the select list statement, as parsed
+// for ParseSelect, expanding stars with expand if it is not nil.
+func fieldsFromColumns(statement string, expand func(qualifier string) ([]string, error)) ([]string, error) {
+	columns := parseColumns(Tokenize(statement))
+	fields := make([]string, 0, len(columns))
+	for _, c := range columns {
+		if c.Star && expand != nil {
+			expanded, err := expand(c.Qualifier)
+			if err != nil {
+				return nil, errors.Wrapf(err, "expanding %q", c.Expression)
+			}
+			fields = append(fields, expanded...)
+			continue
+		}
+		name := c.Name
+		if c.Star {
+			name = columnName(c.Expression)
+		}
+		if name == "" {
+			return nil, errors.Wrapf(
+				errors.Errorf("could not extract potential column name from %q please use AS in your query", c.Expression),
+				"extracting column names from %q", statement)
+		}
+		fields = append(fields, name)
 	}
-	return s.ColumnNames, nil
+	return fields, nil
+}
+
+// starQualifier returns what qualifies column if it is either `*` or `qualifier.*`.
+func starQualifier(column string) (string, bool) {
+	if column == "*" {
+		return "", true
+	}
+	if !strings.HasSuffix(column, ".*") {
+		return "", false
+	}
+	qualifier := strings.TrimSuffix(column, ".*")
+	if extractFromIdentifier(qualifier) == "" {
+		return "", false
+	}
+	return qualifier, true
 }
 
 // SelectParser contains the fields part of a SQL SELECT Statement and
@@ -51,84 +87,94 @@ type SelectParser struct {
 }
 
 func (s *SelectParser) splitFields() {
-	var column []string
-	var depth = 0
-	var nextIgnore = false
-	for _, r := range s.Statement {
-		if nextIgnore {
-			nextIgnore = !nextIgnore
-			column = append(column, string(r))
-			continue
-		}
-		switch r {
-		case openParens:
-			depth++
-		case closeParens:
-			depth--
-		case escapeChar:
-			nextIgnore = !nextIgnore
-		case comma:
-			if depth == 0 {
-				s.Columns = append(s.Columns, strings.Trim(strings.Join(column, ""), " "))
-				column = []string{}
-				continue
-			}
-		}
-		column = append(column, string(r))
+	for _, column := range splitTopLevel(Tokenize(s.Statement), func(t Token) bool { return t.Kind == Comma }) {
+		s.Columns = append(s.Columns, render(column))
 	}
-	s.Columns = append(s.Columns, strings.Trim(strings.Join(column, ""), " "))
 }
 
 func (s *SelectParser) extractNames() error {
 	s.ColumnNames = make([]string, len(s.Columns), len(s.Columns))
 	for i, c := range s.Columns {
-		// are we lucky enough to get column or table.column ?
-		fromSimpleColumn := extractFromSingleWord(c)
-		if fromSimpleColumn != "" {
-			s.ColumnNames[i] = fromSimpleColumn
-			continue
+		name := columnName(c)
+		if name == "" {
+			return errors.Errorf("could not extract potential column name from %q please use AS in your query", c)
 		}
+		s.ColumnNames[i] = name
+	}
+	return nil
+}
 
-		// is this perhaps column as label?
-		fromAs := extractAsIfAny(c)
-		if fromAs != "" {
-			s.ColumnNames[i] = fromAs
-			continue
+// columnName returns the name postgres will most likely give to column or an empty string if
+// it can't be guessed.
+func columnName(column string) string {
+	// are we lucky enough to get column or table.column ?
+	fromSimpleColumn := extractFromSingleWord(column)
+	if fromSimpleColumn != "" {
+		return fromSimpleColumn
+	}
+
+	// is this perhaps column as label?
+	fromAs := extractAsIfAny(column)
+	if fromAs != "" {
+		return fromAs
+	}
+
+	// a cast keeps the name of what is cast, or takes that of the type if that has none.
+	if value, typ, ok := splitCast(column); ok {
+		if name := columnName(value); name != "" && !isConstant(value) {
+			return name
 		}
+		return extractFromKeywordsOrFunc(typ)
+	}
 
-		// well of course it isn't life is complicated
-		fromComplex := extractFromKeywordsOrFunc(c)
-		if fromComplex != "" {
-			s.ColumnNames[i] = fromComplex
-			continue
+	// well of course it isn't life is complicated
+	return extractFromKeywordsOrFunc(column)
+}
+
+// isConstant returns true if expression is a number or string constant.
+func isConstant(expression string) bool {
+	tokens := trim(Tokenize(expression))
+	return len(tokens) == 1 && (tokens[0].Kind == Number || tokens[0].Kind == String)
+}
+
+// splitCast splits a column such as `created_at::date` into the expression that is cast and
+// the type, the last cast is the one split if there are more.
+func splitCast(column string) (string, string, bool) {
+	tokens := Tokenize(column)
+	depth := 0
+	cast := -1
+	for i, t := range tokens {
+		switch {
+		case t.Kind == OpenParens:
+			depth++
+		case t.Kind == CloseParens:
+			depth--
+		case depth == 0 && t.Kind == Operator && t.Text == "::":
+			cast = i
 		}
-		return errors.Errorf("could not extract potential column name from %q please use AS in your query", c)
 	}
-	return nil
+	if cast <= 0 {
+		return "", "", false
+	}
+	return render(tokens[:cast]), render(tokens[cast+1:]), true
 }
 
+// extractAsIfAny returns the alias of column if it ends in `AS alias`, AS inside function calls,
+// window definitions or subqueries is ignored.
 func extractAsIfAny(column string) string {
-	lowerColumn := strings.ToLower(column)
-	potentials := strings.Split(lowerColumn, " as ")
-	if len(potentials) == 1 {
-		return ""
-	}
-	lastSegment := potentials[len(potentials)-1]
-	if len(lastSegment) == 0 {
+	tokens := topLevelTokens(column)
+	if len(tokens) < 3 || !strings.EqualFold(tokens[len(tokens)-2], "as") {
 		return ""
 	}
-	for _, r := range lastSegment {
-		switch r {
-		case openParens, closeParens, comma:
-			return ""
-		}
-	}
-	return lastSegment
+	return extractFromSingleWord(tokens[len(tokens)-1])
 }
 
 var wordRe = regexp.MustCompile("([.0-9a-z_-]+)")
 
 func extractFromSingleWord(column string) string {
+	if strings.ContainsRune(column, '"') {
+		return extractFromIdentifier(column)
+	}
 	lowerColumn := strings.ToLower(column)
 	if wordRe.FindString(lowerColumn) != lowerColumn {
 		return ""
@@ -138,60 +184,104 @@ func extractFromSingleWord(column string) string {
 	return parts[len(parts)-1]
 }
 
+// extractFromIdentifier returns the last part of an identifier that can contain double quoted
+// segments, such as `t."UserName"`, quoted segments are returned as they are, with escaped
+// quotes ("") unescaped, and the rest lowercased, as postgres does.
+// An empty string is returned if column is not such an identifier.
+func extractFromIdentifier(column string) string {
+	name, ok := identifierName(Tokenize(column))
+	if !ok {
+		return ""
+	}
+	return name
+}
+
 func extractFromKeywordsOrFunc(column string) string {
 	// IF this is a function call the column will be called after it, for instance
 	// `DISTINCT some_wicked_pl(arg1, column, blah)` will most likely be called `some_wicked_pl`
-	lowerColumn := strings.ToLower(column)
-	if strings.HasPrefix(lowerColumn, string(openParens)) && strings.HasSuffix(lowerColumn, string(closeParens)) {
+	tokens := topLevelTokens(column)
+	if len(tokens) == 1 && strings.HasPrefix(tokens[0], "(") {
+		inner := strings.TrimPrefix(strings.TrimSuffix(tokens[0], ")"), "(")
+		if isSubquery(inner) {
+			// postgres calls these after the sub-query column, which is too much guessing
+			// so an alias is required.
+			return ""
+		}
 		// Honestly, why would you do that?
-		lowerColumn = strings.TrimPrefix(strings.TrimSuffix(lowerColumn, ")"), "(")
+		return extractFromKeywordsOrFunc(inner)
+	}
+	// `agg(x) FILTER (WHERE ...) OVER (...)` is still called `agg`.
+	for i, token := range tokens {
+		if strings.EqualFold(token, "over") || strings.EqualFold(token, "filter") {
+			tokens = tokens[:i]
+			break
+		}
+	}
+	if len(tokens) == 0 {
+		return ""
+	}
+	last := tokens[len(tokens)-1]
+	if !strings.HasPrefix(last, "(") {
+		return extractFromSingleWord(last)
+	}
+	if len(tokens) < 2 {
+		return ""
+	}
+	return extractFromSingleWord(tokens[len(tokens)-2])
+}
+
+// isSubquery returns true if expression is a query, ie what goes in the parens of a scalar
+// sub-query.
+func isSubquery(expression string) bool {
+	tokens := trim(Tokenize(expression))
+	for len(tokens) != 0 && tokens[0].Kind == OpenParens {
+		tokens = trim(tokens[1:])
+	}
+	return len(tokens) != 0 && (tokens[0].Is("SELECT") || tokens[0].Is("WITH") || tokens[0].Is("VALUES") ||
+		tokens[0].Is("TABLE"))
+}
+
+// topLevelTokens splits column in words and parenthesized groups, nested parens, quoted
+// identifiers and string literals are kept within the token they belong to.
+// An unbalanced column yields no tokens.
+func topLevelTokens(column string) []string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() != 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
 	}
-	var buffer []string
-	var previousToken []string
-	previousWasSpace := false
 	depth := 0
-	for _, r := range lowerColumn {
-		switch r {
-		case openParens:
-			if depth == 0 && len(buffer) != 0 {
-				previousToken = make([]string, len(buffer), len(buffer))
-				copy(previousToken, buffer)
-				buffer = []string{}
+	for _, t := range Tokenize(column) {
+		switch t.Kind {
+		case OpenParens:
+			if depth == 0 {
+				flush()
 			}
 			depth++
-			previousWasSpace = false
-			continue
-		case closeParens:
+		case CloseParens:
 			depth--
-			previousWasSpace = false
-			continue
-		case space:
-			if depth != 0 {
-				continue
+			if depth < 0 {
+				return nil
 			}
-			// At this point this might be a keyword
-			if !previousWasSpace && len(buffer) != 0 {
-				previousToken = make([]string, len(buffer), len(buffer))
-				copy(previousToken, buffer)
-				buffer = []string{}
+			if depth == 0 {
+				word.WriteString(t.Text)
+				flush()
+				continue
 			}
-			previousWasSpace = true
-			continue
-		default:
-			previousWasSpace = false
-			// we dont care for things inside a function argument set
-			if depth != 0 {
+		case Space, Comment:
+			if depth == 0 {
+				flush()
 				continue
 			}
 		}
-		buffer = append(buffer, string(r))
+		word.WriteString(t.Text)
 	}
-	if len(buffer) != 0 && depth == 0 {
-		return extractFromSingleWord(strings.Trim(strings.Join(buffer, ""), " "))
+	if depth != 0 {
+		return nil
 	}
-	if len(previousToken) != 0 && depth == 0 {
-		return extractFromSingleWord(strings.Trim(strings.Join(previousToken, ""), " "))
-	}
-
-	return ""
+	flush()
+	return words
 }
@@ -15,6 +15,8 @@
 package selectparse
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -106,3 +108,164 @@ func Test_extractFromKeywordsOrFunc(t *testing.T) {
 		})
 	}
 }
+
+func TestFieldsFromSelectQuotedIdentifiers(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{statement: `"UserName", t."order", age`, want: []string{"UserName", "order", "age"}},
+		{statement: `"Schema"."Table"."Col", "T".name`, want: []string{"Col", "name"}},
+		{statement: `"a,b", "we""ird"`, want: []string{"a,b", `we"ird`}},
+		{statement: `"f(x", COUNT(*) AS "Total"`, want: []string{"f(x", "Total"}},
+		{statement: `DISTINCT "UserName", name AS Label`, want: []string{"UserName", "label"}},
+		{statement: `COALESCE("Nick", name) AS nick`, want: []string{"nick"}},
+	}
+	for _, tt := range tests {
+		expectFields(t, tt.statement, tt.want)
+	}
+}
+
+func Test_extractFromIdentifier(t *testing.T) {
+	for column, want := range map[string]string{
+		`"UserName"`:    "UserName",
+		`t."order"`:     "order",
+		`"T".Name`:      "name",
+		`"a""b"`:        `a"b`,
+		`"unterminated`: "",
+		`"a"b`:          "",
+		`t.`:            "",
+		`"a" || "b"`:    "",
+		`t."Col".`:      "",
+	} {
+		if got := extractFromIdentifier(column); got != want {
+			t.Errorf("extractFromIdentifier(%q) = %q, want %q", column, got, want)
+		}
+	}
+}
+
+func TestFieldsFromSelectWindowsAndNestedCalls(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{statement: "sum(x) OVER (PARTITION BY y) AS total", want: []string{"total"}},
+		{statement: "sum(x) OVER (PARTITION BY y ORDER BY z)", want: []string{"sum"}},
+		{statement: "count(*) FILTER (WHERE a > 1) OVER w", want: []string{"count"}},
+		{statement: "coalesce(nullif(a,''), b) AS v, id", want: []string{"v", "id"}},
+		{statement: "coalesce(nullif(a, ','), b)", want: []string{"coalesce"}},
+		{statement: "cast(a AS text)", want: []string{"cast"}},
+		{statement: "lower(cast(a AS text))\n\tAS\tlowered", want: []string{"lowered"}},
+		{statement: "'(' AS open, ',' AS sep", want: []string{"open", "sep"}},
+		{statement: "pg_catalog.max(x)", want: []string{"max"}},
+	}
+	for _, tt := range tests {
+		expectFields(t, tt.statement, tt.want)
+	}
+}
+
+func expectFields(t *testing.T, statement string, want []string) {
+	t.Helper()
+	got, err := FieldsFromSelect(statement)
+	if err != nil {
+		t.Logf("failed to extract fields from %q: %v", statement, err)
+		t.FailNow()
+	}
+	if len(got) != len(want) {
+		t.Logf("expected %q got %q", want, got)
+		t.FailNow()
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Logf("expected %q got %q", want, got)
+			t.FailNow()
+		}
+	}
+}
+
+func TestFieldsFromSelectComments(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{statement: "id, -- the id, really\nname", want: []string{"id", "name"}},
+		{statement: "id /* , ( */, name", want: []string{"id", "name"}},
+		{statement: "/* outer /* inner, */ still, */ id, t.name -- trailing", want: []string{"id", "name"}},
+		{statement: "count(*) /* all, of them */ AS total", want: []string{"total"}},
+		{statement: "'--' AS dashes, \"/*\" AS \"*/\"", want: []string{"dashes", "*/"}},
+		{statement: "a - -1 AS b, c / 2 AS d", want: []string{"b", "d"}},
+	}
+	for _, tt := range tests {
+		expectFields(t, tt.statement, tt.want)
+	}
+}
+
+func TestFieldsFromSelectExpanding(t *testing.T) {
+	var qualifiers []string
+	expand := func(qualifier string) ([]string, error) {
+		qualifiers = append(qualifiers, qualifier)
+		return []string{"id", "name"}, nil
+	}
+	got, err := FieldsFromSelectExpanding(`u.*, o.total, "O".*, *, count(*) AS n`, expand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"id", "name", "total", "id", "name", "id", "name", "n"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %q got %q", want, got)
+	}
+	if strings.Join(qualifiers, ",") != `u,"O",` {
+		t.Errorf("expected qualifiers u, \"O\" and none got %q", qualifiers)
+	}
+
+	_, err = FieldsFromSelectExpanding("u.*", func(string) ([]string, error) {
+		return nil, errors.New("unknown")
+	})
+	if err == nil {
+		t.Errorf("expected expansion errors to be returned")
+	}
+}
+
+func TestFieldsFromSelectSubqueries(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{statement: "(SELECT count(*) FROM x WHERE x.id = t.id) AS cnt", want: []string{"cnt"}},
+		{statement: "id, (SELECT max(y.v) FROM y WHERE y.a IN (1, 2)) AS \"MaxV\"", want: []string{"id", "MaxV"}},
+		{statement: "((SELECT 1)) AS one", want: []string{"one"}},
+		{statement: "EXISTS (SELECT 1 FROM x) AS present", want: []string{"present"}},
+		{statement: "EXISTS (SELECT 1 FROM x)", want: []string{"exists"}},
+	}
+	for _, tt := range tests {
+		expectFields(t, tt.statement, tt.want)
+	}
+
+	for _, statement := range []string{
+		"(SELECT count(*) FROM x WHERE x.id = t.id)",
+		"id, ( WITH a AS (SELECT 1) SELECT * FROM a )",
+		"((SELECT 1))",
+	} {
+		if fields, err := FieldsFromSelect(statement); err == nil {
+			t.Errorf("expected sub-queries without alias to fail got %q for %q", fields, statement)
+		}
+	}
+}
+
+func TestFieldsFromSelectCasts(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{statement: "created_at::date", want: []string{"created_at"}},
+		{statement: "t.created_at::date, amount::numeric(10,2) AS amount_fixed", want: []string{"created_at", "amount_fixed"}},
+		{statement: "amount::numeric(10,2)", want: []string{"amount"}},
+		{statement: `t."At"::timestamptz::date`, want: []string{"At"}},
+		{statement: "sum(total)::bigint", want: []string{"sum"}},
+		{statement: "'a'::text, 1::numeric(10, 2)", want: []string{"text", "numeric"}},
+		{statement: "coalesce(a::text, '') AS a_text", want: []string{"a_text"}},
+	}
+	for _, tt := range tests {
+		expectFields(t, tt.statement, tt.want)
+	}
+}